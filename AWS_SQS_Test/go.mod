@@ -3,7 +3,8 @@ module sqs
 go 1.20
 
 require (
-	github.com/aws/aws-sdk-go v1.45.26 // indirect
-	github.com/jmespath/go-jmespath v0.4.0 // indirect
-	golang.org/x/sync v0.4.0 // indirect
+	github.com/aws/aws-sdk-go v1.45.26
+	golang.org/x/sync v0.4.0
 )
+
+require github.com/jmespath/go-jmespath v0.4.0 // indirect
@@ -0,0 +1,117 @@
+// HAM3 API(/api/v1)のリクエストとレスポンスの型
+// サーバー(services)とクライアント(ham3/client)で共有し、OpenAPIのドキュメント(/api/v1/openapi.json)もこの型から生成する
+// CLIからも使うため、標準ライブラリ以外に依存しない
+package api
+
+import "net/http"
+
+// レスポンスのstatus
+const (
+	StatusSuccess  = "success"
+	StatusAccepted = "accepted"
+	StatusError    = "error"
+)
+
+// 成功したレスポンス（messageはエンドポイントごとの型）
+type Response[T any] struct {
+	Status  string `json:"status"`
+	Message T      `json:"message"`
+}
+
+func Success[T any](message T) Response[T] {
+	return Response[T]{Status: StatusSuccess, Message: message}
+}
+
+// ジョブとして受け付けたレスポンス(202)
+// ジョブの進捗と結果はGET /api/v1/jobs/{job_id}で取得する
+type AcceptedResponse struct {
+	Status  string `json:"status"`
+	Message string `json:"message"`
+	JobId   string `json:"job_id"`
+}
+
+// スナップショットの作成を受け付けたレスポンス(202)
+// 進捗はGET /api/v1/logaas/{logaas_id}/snapshots/{snapshot}のstateで確認する
+type SnapshotAcceptedResponse struct {
+	Status   string `json:"status"`
+	Message  string `json:"message"`
+	Snapshot string `json:"snapshot"`
+}
+
+// スナップショットのリストアを受け付けたレスポンス(202)
+type RestoreAcceptedResponse struct {
+	Status  string `json:"status"`
+	Message string `json:"message"`
+	Target  string `json:"target"`
+}
+
+// エラーのレスポンス（4xx/5xx）
+// codeでエラーの種類を判別し、messageは人が読むための説明
+type ErrorResponse struct {
+	Status  string    `json:"status"`
+	Code    ErrorCode `json:"code"`
+	Message string    `json:"message"`
+	// codeがvalidation_failedの場合のフィールドごとのエラー
+	Errors []FieldError `json:"errors,omitempty"`
+}
+
+// バリデーションエラー（リクエストのJSONのキーごとに返す）
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// エラーの種類
+type ErrorCode string
+
+const (
+	// JSONの形式やクエリが不正
+	CodeInvalidRequest ErrorCode = "invalid_request"
+	// パラメータの検証エラー（errorsにフィールドごとのエラー）
+	CodeValidationFailed ErrorCode = "validation_failed"
+	CodeUnauthorized     ErrorCode = "unauthorized"
+	CodeForbidden        ErrorCode = "forbidden"
+	CodeNotFound         ErrorCode = "not_found"
+	// 同名のリソースが存在する、実行中のジョブがある、など
+	CodeConflict ErrorCode = "conflict"
+	// OpenSearchなど、HAM3が呼び出したサービスのエラー
+	CodeUpstreamError ErrorCode = "upstream_error"
+	// ジョブのキューが一杯、サーバーの停止中など（時間をおいて再試行する）
+	CodeUnavailable   ErrorCode = "unavailable"
+	CodeInternalError ErrorCode = "internal_error"
+)
+
+// HTTPのステータスコードに対応するエラーの種類
+func CodeForStatus(status int) ErrorCode {
+	switch {
+	case status == http.StatusUnauthorized:
+		return CodeUnauthorized
+	case status == http.StatusForbidden:
+		return CodeForbidden
+	case status == http.StatusNotFound:
+		return CodeNotFound
+	case status == http.StatusConflict:
+		return CodeConflict
+	case status == http.StatusBadGateway || status == http.StatusGatewayTimeout:
+		return CodeUpstreamError
+	case status == http.StatusServiceUnavailable:
+		return CodeUnavailable
+	case status >= 400 && status < 500:
+		return CodeInvalidRequest
+	default:
+		return CodeInternalError
+	}
+}
+
+// ステータスコードから種類を決めたエラーのレスポンス
+func NewError(status int, message string) ErrorResponse {
+	return ErrorResponse{Status: StatusError, Code: CodeForStatus(status), Message: message}
+}
+
+// 一覧のレスポンス（?page=&page_size=でページを指定する）
+type Page[T any] struct {
+	Items    []T   `json:"items"`
+	Total    int64 `json:"total"`
+	Page     int   `json:"page"`
+	PageSize int   `json:"page_size"`
+}
@@ -0,0 +1,399 @@
+package api
+
+import (
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// /api/v1のエンドポイント
+// OpenAPIのドキュメントはこの一覧とリクエスト/レスポンスの型から生成する（ルートを追加したらここにも追加する）
+type Operation struct {
+	Method string
+	// /api/v1からのパス（パスパラメータは{name}）
+	Path        string
+	OperationId string
+	Summary     string
+	Tag         string
+	Query       []QueryParam
+	// リクエストのJSON（nilの場合はボディなし）
+	Request interface{}
+	// ボディを省略できる（省略した場合はすべてのキーが空）
+	RequestOptional bool
+	// 成功した場合のステータスコードとレスポンス
+	Status   int
+	Response interface{}
+	// text/event-streamでResponseの型のイベントを送信する
+	EventStream bool
+	// 管理者のみ
+	Admin bool
+}
+
+type QueryParam struct {
+	Name        string
+	Type        string
+	Description string
+}
+
+var pageQuery = []QueryParam{
+	{"page", "integer", "Page number (1-)"},
+	{"page_size", "integer", "Items per page"},
+}
+
+func listQuery(params ...QueryParam) []QueryParam {
+	return append(append([]QueryParam{}, pageQuery...), params...)
+}
+
+var (
+	projectQuery = QueryParam{"project", "string", "Project ID (admin only; other users always see their own project)"}
+	statusQuery  = QueryParam{"status", "string", "Resource status"}
+	driftedQuery = QueryParam{"drifted", "boolean", "Whether the reconciler detected drift"}
+)
+
+var Operations = []Operation{
+	// CaaS
+	{Method: http.MethodGet, Path: "/caas/", OperationId: "listCaas", Summary: "List CaaS namespaces", Tag: "CaaS",
+		Query: listQuery(projectQuery, statusQuery, driftedQuery), Status: http.StatusOK, Response: Response[Page[Caas]]{}},
+	{Method: http.MethodPost, Path: "/caas/{caas_id}", OperationId: "createCaas", Summary: "Create a CaaS namespace", Tag: "CaaS",
+		Request: CaasRequestData{}, RequestOptional: true, Status: http.StatusAccepted, Response: AcceptedResponse{}},
+	{Method: http.MethodGet, Path: "/caas/{caas_id}", OperationId: "getCaas", Summary: "Get the Kubernetes objects of a CaaS namespace", Tag: "CaaS",
+		Status: http.StatusOK, Response: Response[CaasDetail]{}},
+	{Method: http.MethodPut, Path: "/caas/{caas_id}", OperationId: "updateCaas", Summary: "Update the quota of a CaaS namespace", Tag: "CaaS",
+		Request: CaasRequestData{}, Status: http.StatusAccepted, Response: AcceptedResponse{}},
+	{Method: http.MethodDelete, Path: "/caas/{caas_id}", OperationId: "deleteCaas", Summary: "Delete a CaaS namespace", Tag: "CaaS",
+		Status: http.StatusAccepted, Response: AcceptedResponse{}},
+
+	// LOGaaS
+	{Method: http.MethodGet, Path: "/logaas/", OperationId: "listLogaas", Summary: "List LOGaaS clusters", Tag: "LOGaaS",
+		Query:  listQuery(projectQuery, statusQuery, QueryParam{"cluster_type", "string", "standard or scalable"}, driftedQuery),
+		Status: http.StatusOK, Response: Response[Page[Logaas]]{}},
+	{Method: http.MethodPost, Path: "/logaas/{logaas_id}", OperationId: "createLogaas", Summary: "Create a LOGaaS cluster", Tag: "LOGaaS",
+		Request: LogaasRequestData{}, Status: http.StatusAccepted, Response: AcceptedResponse{}},
+	{Method: http.MethodGet, Path: "/logaas/{logaas_id}", OperationId: "getLogaas", Summary: "Get the Helm releases and pods of a LOGaaS cluster", Tag: "LOGaaS",
+		Query:  []QueryParam{{"cluster_type", "string", "Cluster type of a LOGaaS not registered in the DB (admin only)"}},
+		Status: http.StatusOK, Response: Response[LogaasDetail]{}},
+	{Method: http.MethodPut, Path: "/logaas/{logaas_id}", OperationId: "updateLogaas", Summary: "Update a LOGaaS cluster", Tag: "LOGaaS",
+		Request: LogaasRequestData{}, Status: http.StatusAccepted, Response: AcceptedResponse{}},
+	{Method: http.MethodDelete, Path: "/logaas/{logaas_id}", OperationId: "deleteLogaas", Summary: "Delete a LOGaaS cluster", Tag: "LOGaaS",
+		Request: LogaasRequestData{}, RequestOptional: true, Status: http.StatusAccepted, Response: AcceptedResponse{}},
+	{Method: http.MethodGet, Path: "/logaas/volumes/leaked", OperationId: "listLeakedLogaasVolumes", Summary: "List Cinder volumes whose LOGaaS no longer exists", Tag: "LOGaaS",
+		Query:  []QueryParam{{"ocp_cluster", "string", "OCP cluster of the volumes (default: the server's OCP_CLUSTER)"}},
+		Status: http.StatusOK, Response: Response[[]LeakedVolume]{}, Admin: true},
+	{Method: http.MethodGet, Path: "/logaas/{logaas_id}/credentials", OperationId: "getLogaasCredentials", Summary: "Get the tenant user of a LOGaaS cluster", Tag: "LOGaaS",
+		Status: http.StatusOK, Response: Response[LogaasCredentials]{}},
+	{Method: http.MethodPost, Path: "/logaas/{logaas_id}/credentials/rotate", OperationId: "rotateLogaasCredentials", Summary: "Rotate the password of the tenant or admin user", Tag: "LOGaaS",
+		Request: LogaasCredentialsRotateRequestData{}, RequestOptional: true, Status: http.StatusOK, Response: Response[LogaasCredentials]{}},
+
+	// LOGaaSのスナップショット
+	{Method: http.MethodPut, Path: "/logaas/{logaas_id}/snapshots/repository", OperationId: "putLogaasSnapshotRepository", Summary: "Register the S3 snapshot repository", Tag: "LOGaaS snapshots",
+		Request: SnapshotRepositoryRequestData{}, Status: http.StatusOK, Response: Response[SnapshotRepositoryRequestData]{}},
+	{Method: http.MethodGet, Path: "/logaas/{logaas_id}/snapshots/repository", OperationId: "getLogaasSnapshotRepository", Summary: "Get the snapshot repository (secret key redacted)", Tag: "LOGaaS snapshots",
+		Status: http.StatusOK, Response: Response[SnapshotRepositoryRequestData]{}},
+	{Method: http.MethodPut, Path: "/logaas/{logaas_id}/snapshots/policy", OperationId: "putLogaasSnapshotPolicy", Summary: "Create or update the scheduled snapshot policy", Tag: "LOGaaS snapshots",
+		Request: SnapshotPolicyRequestData{}, Status: http.StatusOK, Response: Response[SnapshotPolicyRequestData]{}},
+	{Method: http.MethodGet, Path: "/logaas/{logaas_id}/snapshots/policy", OperationId: "getLogaasSnapshotPolicy", Summary: "Get the scheduled snapshot policy and its state", Tag: "LOGaaS snapshots",
+		Status: http.StatusOK, Response: Response[SnapshotPolicyStatus]{}},
+	{Method: http.MethodDelete, Path: "/logaas/{logaas_id}/snapshots/policy", OperationId: "deleteLogaasSnapshotPolicy", Summary: "Delete the scheduled snapshot policy", Tag: "LOGaaS snapshots",
+		Status: http.StatusOK, Response: Response[string]{}},
+	{Method: http.MethodPost, Path: "/logaas/{logaas_id}/snapshots", OperationId: "createLogaasSnapshot", Summary: "Start a snapshot", Tag: "LOGaaS snapshots",
+		Request: SnapshotRequestData{}, RequestOptional: true, Status: http.StatusAccepted, Response: SnapshotAcceptedResponse{}},
+	{Method: http.MethodGet, Path: "/logaas/{logaas_id}/snapshots", OperationId: "listLogaasSnapshots", Summary: "List snapshots", Tag: "LOGaaS snapshots",
+		Status: http.StatusOK, Response: Response[[]Snapshot]{}},
+	{Method: http.MethodGet, Path: "/logaas/{logaas_id}/snapshots/{snapshot}", OperationId: "getLogaasSnapshot", Summary: "Get a snapshot", Tag: "LOGaaS snapshots",
+		Status: http.StatusOK, Response: Response[Snapshot]{}},
+	{Method: http.MethodDelete, Path: "/logaas/{logaas_id}/snapshots/{snapshot}", OperationId: "deleteLogaasSnapshot", Summary: "Delete a snapshot", Tag: "LOGaaS snapshots",
+		Status: http.StatusOK, Response: Response[string]{}},
+	{Method: http.MethodPost, Path: "/logaas/{logaas_id}/snapshots/{snapshot}/restore", OperationId: "restoreLogaasSnapshot", Summary: "Restore a snapshot", Tag: "LOGaaS snapshots",
+		Request: SnapshotRestoreRequestData{}, RequestOptional: true, Status: http.StatusAccepted, Response: RestoreAcceptedResponse{}},
+
+	// LOGaaSのISMポリシー
+	{Method: http.MethodGet, Path: "/logaas/{logaas_id}/ism-policies", OperationId: "listLogaasIsmPolicies", Summary: "List index retention (ISM) policies", Tag: "LOGaaS ISM policies",
+		Status: http.StatusOK, Response: Response[[]IsmPolicy]{}},
+	{Method: http.MethodPut, Path: "/logaas/{logaas_id}/ism-policies/{policy_id}", OperationId: "putLogaasIsmPolicy", Summary: "Create or update an ISM policy", Tag: "LOGaaS ISM policies",
+		Request: IsmPolicyRequestData{}, Status: http.StatusOK, Response: Response[IsmPolicyRequestData]{}},
+	{Method: http.MethodGet, Path: "/logaas/{logaas_id}/ism-policies/{policy_id}", OperationId: "getLogaasIsmPolicy", Summary: "Get an ISM policy", Tag: "LOGaaS ISM policies",
+		Status: http.StatusOK, Response: Response[IsmPolicy]{}},
+	{Method: http.MethodDelete, Path: "/logaas/{logaas_id}/ism-policies/{policy_id}", OperationId: "deleteLogaasIsmPolicy", Summary: "Delete an ISM policy", Tag: "LOGaaS ISM policies",
+		Status: http.StatusOK, Response: Response[string]{}},
+
+	// AAPaaS
+	{Method: http.MethodGet, Path: "/aapaas/", OperationId: "listAapaas", Summary: "List AAPaaS instances", Tag: "AAPaaS",
+		Query: listQuery(projectQuery, statusQuery), Status: http.StatusOK, Response: Response[Page[Aapaas]]{}},
+	{Method: http.MethodPost, Path: "/aapaas/{aapaas_id}", OperationId: "createAapaas", Summary: "Create an AAPaaS instance", Tag: "AAPaaS",
+		Request: AapaasRequestData{}, RequestOptional: true, Status: http.StatusAccepted, Response: AcceptedResponse{}},
+	{Method: http.MethodGet, Path: "/aapaas/{aapaas_id}", OperationId: "getAapaas", Summary: "Get the endpoint, Helm release and pods of an AAPaaS instance", Tag: "AAPaaS",
+		Status: http.StatusOK, Response: Response[AapaasDetail]{}},
+	{Method: http.MethodDelete, Path: "/aapaas/{aapaas_id}", OperationId: "deleteAapaas", Summary: "Delete an AAPaaS instance", Tag: "AAPaaS",
+		Status: http.StatusAccepted, Response: AcceptedResponse{}},
+
+	// プロジェクト
+	{Method: http.MethodGet, Path: "/projects/", OperationId: "listProjects", Summary: "List projects", Tag: "Projects",
+		Query: pageQuery, Status: http.StatusOK, Response: Response[Page[Project]]{}},
+	{Method: http.MethodPost, Path: "/projects/{project_id}", OperationId: "createProject", Summary: "Register a project", Tag: "Projects",
+		Request: ProjectRequestData{}, Status: http.StatusOK, Response: Response[Project]{}, Admin: true},
+	{Method: http.MethodGet, Path: "/projects/{project_id}", OperationId: "getProject", Summary: "Get a project", Tag: "Projects",
+		Status: http.StatusOK, Response: Response[Project]{}},
+	{Method: http.MethodPut, Path: "/projects/{project_id}", OperationId: "updateProject", Summary: "Rename a project", Tag: "Projects",
+		Request: ProjectRequestData{}, Status: http.StatusOK, Response: Response[Project]{}, Admin: true},
+	{Method: http.MethodDelete, Path: "/projects/{project_id}", OperationId: "deleteProject", Summary: "Delete a project without resources", Tag: "Projects",
+		Status: http.StatusOK, Response: Response[string]{}, Admin: true},
+	{Method: http.MethodGet, Path: "/projects/{project_id}/resources", OperationId: "getProjectResources", Summary: "List the resources of a project", Tag: "Projects",
+		Status: http.StatusOK, Response: Response[ProjectResources]{}},
+
+	// その他
+	{Method: http.MethodGet, Path: "/flavors", OperationId: "listFlavors", Summary: "List LOGaaS node flavors", Tag: "Flavors",
+		Status: http.StatusOK, Response: Response[[]Flavor]{}},
+	{Method: http.MethodGet, Path: "/audit", OperationId: "listAuditLogs", Summary: "Query the audit log", Tag: "Audit",
+		Query: listQuery(
+			QueryParam{"from", "string", "RFC3339 time (inclusive)"},
+			QueryParam{"to", "string", "RFC3339 time (exclusive)"},
+			QueryParam{"resource_type", "string", "caas, logaas, aapaas, project or job"},
+			QueryParam{"resource_id", "string", "Resource name"},
+			QueryParam{"project", "string", "Project ID"},
+			QueryParam{"action", "string", "create, update, delete, ..."},
+			QueryParam{"outcome", "string", "success, accepted, denied or failure"},
+			QueryParam{"error_code", "string", "Error code of the failed response (e.g. validation_failed)"},
+			QueryParam{"user", "string", "User name or ID"},
+		),
+		Status: http.StatusOK, Response: Response[Page[AuditLog]]{}, Admin: true},
+	{Method: http.MethodGet, Path: "/jobs/{job_id}", OperationId: "getJob", Summary: "Get the progress of a job", Tag: "Jobs",
+		Query:  []QueryParam{{"wait", "string", "Long-poll: wait up to this duration (e.g. 30s) until the job is updated"}},
+		Status: http.StatusOK, Response: Response[Job]{}},
+	{Method: http.MethodGet, Path: "/jobs/{job_id}/events", OperationId: "watchJob", Summary: "Stream job updates as server-sent events until the job finishes", Tag: "Jobs",
+		Status: http.StatusOK, Response: Job{}, EventStream: true},
+}
+
+var (
+	openAPIOnce sync.Once
+	openAPIDoc  map[string]interface{}
+)
+
+// OpenAPI 3のドキュメント（初回に生成してキャッシュする）
+func OpenAPI() map[string]interface{} {
+	openAPIOnce.Do(func() {
+		openAPIDoc = newOpenAPIGenerator().document(Operations)
+	})
+	return openAPIDoc
+}
+
+var pathParamPattern = regexp.MustCompile(`\{([^}]+)\}`)
+
+type openAPIGenerator struct {
+	schemas map[string]interface{}
+}
+
+func newOpenAPIGenerator() *openAPIGenerator {
+	return &openAPIGenerator{schemas: map[string]interface{}{}}
+}
+
+func (g *openAPIGenerator) document(operations []Operation) map[string]interface{} {
+	paths := map[string]interface{}{}
+	tags := []string{}
+	seenTags := map[string]bool{}
+	for _, op := range operations {
+		item, ok := paths[op.Path].(map[string]interface{})
+		if !ok {
+			item = map[string]interface{}{}
+			paths[op.Path] = item
+		}
+		item[strings.ToLower(op.Method)] = g.operation(op)
+		if !seenTags[op.Tag] {
+			seenTags[op.Tag] = true
+			tags = append(tags, op.Tag)
+		}
+	}
+
+	tagList := make([]interface{}, 0, len(tags))
+	for _, tag := range tags {
+		tagList = append(tagList, map[string]interface{}{"name": tag})
+	}
+
+	g.schema(reflect.TypeOf(ErrorResponse{}))
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":       "HAM3 API",
+			"version":     "v1",
+			"description": "CaaS, LOGaaS and AAPaaS provisioning. Errors are returned as ErrorResponse; asynchronous operations return 202 with a job_id to poll at /jobs/{job_id}.",
+		},
+		"servers":  []interface{}{map[string]interface{}{"url": "/api/v1"}},
+		"tags":     tagList,
+		"security": []interface{}{map[string]interface{}{"keystoneToken": []interface{}{}}},
+		"paths":    paths,
+		"components": map[string]interface{}{
+			"schemas": g.schemas,
+			"securitySchemes": map[string]interface{}{
+				"keystoneToken": map[string]interface{}{
+					"type":        "apiKey",
+					"in":          "header",
+					"name":        "X-Auth-Token",
+					"description": "Keystone token",
+				},
+			},
+			"responses": map[string]interface{}{
+				"Error": map[string]interface{}{
+					"description": "Error (see code)",
+					"content":     jsonContent(ref("ErrorResponse")),
+				},
+			},
+		},
+	}
+}
+
+func (g *openAPIGenerator) operation(op Operation) map[string]interface{} {
+	parameters := []interface{}{}
+	for _, match := range pathParamPattern.FindAllStringSubmatch(op.Path, -1) {
+		parameters = append(parameters, map[string]interface{}{
+			"name":     match[1],
+			"in":       "path",
+			"required": true,
+			"schema":   map[string]interface{}{"type": "string"},
+		})
+	}
+	for _, query := range op.Query {
+		parameters = append(parameters, map[string]interface{}{
+			"name":        query.Name,
+			"in":          "query",
+			"description": query.Description,
+			"schema":      map[string]interface{}{"type": query.Type},
+		})
+	}
+	// 作成するリソースのプロジェクト
+	if op.Method == http.MethodPost {
+		parameters = append(parameters, map[string]interface{}{
+			"name":        "X-Project-Id",
+			"in":          "header",
+			"description": "Project of the new resource (admin only; defaults to the token's project)",
+			"schema":      map[string]interface{}{"type": "string"},
+		})
+	}
+
+	content := jsonContent(g.schema(reflect.TypeOf(op.Response)))
+	if op.EventStream {
+		content = map[string]interface{}{"text/event-stream": map[string]interface{}{"schema": g.schema(reflect.TypeOf(op.Response))}}
+	}
+	result := map[string]interface{}{
+		"operationId": op.OperationId,
+		"summary":     op.Summary,
+		"tags":        []interface{}{op.Tag},
+		"parameters":  parameters,
+		"responses": map[string]interface{}{
+			statusKey(op.Status): map[string]interface{}{
+				"description": http.StatusText(op.Status),
+				"content":     content,
+			},
+			"default": map[string]interface{}{"$ref": "#/components/responses/Error"},
+		},
+	}
+	if op.Admin {
+		result["description"] = "Admin only."
+	}
+	if op.Request != nil {
+		result["requestBody"] = map[string]interface{}{
+			"required": !op.RequestOptional,
+			"content":  jsonContent(g.schema(reflect.TypeOf(op.Request))),
+		}
+	}
+	return result
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// Goの型のJSON Schema
+// apiパッケージの名前付きの構造体はcomponents/schemasに登録して参照する
+func (g *openAPIGenerator) schema(t reflect.Type) map[string]interface{} {
+	if t == timeType {
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	}
+	if t == reflect.TypeOf(ErrorCode("")) {
+		return map[string]interface{}{"type": "string", "enum": []interface{}{
+			CodeInvalidRequest, CodeValidationFailed, CodeUnauthorized, CodeForbidden, CodeNotFound,
+			CodeConflict, CodeUpstreamError, CodeUnavailable, CodeInternalError,
+		}}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		schema := g.schema(t.Elem())
+		if _, isRef := schema["$ref"]; isRef {
+			return map[string]interface{}{"allOf": []interface{}{schema}, "nullable": true}
+		}
+		schema["nullable"] = true
+		return schema
+	case reflect.Interface:
+		return map[string]interface{}{}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return map[string]interface{}{"type": "integer", "format": "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "format": "int64"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": g.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": g.schema(t.Elem())}
+	case reflect.Struct:
+		// ジェネリクスの型（Response[T]、Page[T]）は展開する
+		if t.PkgPath() != reflect.TypeOf(Operation{}).PkgPath() || strings.Contains(t.Name(), "[") {
+			return g.structSchema(t)
+		}
+		if _, ok := g.schemas[t.Name()]; !ok {
+			// 再帰する型のために先に登録する
+			g.schemas[t.Name()] = map[string]interface{}{}
+			g.schemas[t.Name()] = g.structSchema(t)
+		}
+		return ref(t.Name())
+	}
+	return map[string]interface{}{}
+}
+
+func (g *openAPIGenerator) structSchema(t reflect.Type) map[string]interface{} {
+	properties := map[string]interface{}{}
+	required := []string{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		properties[name] = g.schema(field.Type)
+		// リクエストのキーは省略できる（必須かどうかはサーバーの検証で決まる）
+		if !strings.HasSuffix(t.Name(), "RequestData") && !strings.Contains(options, "omitempty") {
+			required = append(required, name)
+		}
+	}
+	sort.Strings(required)
+
+	schema := map[string]interface{}{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+func ref(name string) map[string]interface{} {
+	return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+}
+
+func jsonContent(schema map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{"application/json": map[string]interface{}{"schema": schema}}
+}
+
+func statusKey(status int) string {
+	return strconv.Itoa(status)
+}
@@ -0,0 +1,102 @@
+package api
+
+// CaasRequestData is a struct that represents the request data for CaaS.
+// Planを指定しない場合はデフォルトのPlanを使用し、個別に指定した値でPlanの値を上書きする
+type CaasRequestData struct {
+	Plan           string `json:"plan,omitempty"`
+	RequestsCpu    string `json:"requests-cpu,omitempty"`
+	RequestsMemory string `json:"requests-memory,omitempty"`
+	Pods           string `json:"pods,omitempty"`
+	LimitCpu       string `json:"limit-cpu,omitempty"`
+	LimitMemory    string `json:"limit-memory,omitempty"`
+}
+
+// LogaasRequestData is a struct that represents the request data for LOGaaS.
+// 作成時はデフォルト値、更新時は現在の値をリクエストで指定した値のみ上書きするため、未指定の値は送信しない
+type LogaasRequestData struct {
+	ClusterType                 string `json:"cluster-type,omitempty"`
+	OpenSearchVersion           string `json:"opensearch-version,omitempty"`
+	OpenSearchDashboardsVersion string `json:"opensearch-dashboards-version,omitempty"`
+	ScaleSize                   int    `json:"scale-size,omitempty"`
+	BaseDomain                  string `json:"base-domain,omitempty"`
+	K8sName                     string `json:"k8s-name,omitempty"`
+	MasterFlavor                string `json:"master-flavor,omitempty"`
+	ClientFlavor                string `json:"client-flavor,omitempty"`
+	DataFlavor                  string `json:"data-flavor,omitempty"`
+	GuiFlavor                   string `json:"gui-flavor,omitempty"`
+	DataDiskSize                int    `json:"data-disk-size,omitempty"`
+	DiskType                    string `json:"disk-type-ham3,omitempty"`
+	Site                        string `json:"site,omitempty"`
+	Zone                        string `json:"zone,omitempty"`
+	OcpCluster                  string `json:"ocp-cluster,omitempty"`
+}
+
+// LogaasCredentialsRotateRequestData is a struct that represents the request data for rotating LOGaaS credentials.
+type LogaasCredentialsRotateRequestData struct {
+	// 空の場合はテナントのユーザー、"admin"の場合はHAM3が使うadminユーザー（管理者のみ）
+	User string `json:"user"`
+}
+
+// AapaasRequestData is a struct that represents the request data for AAPaaS.
+// 未指定の値はデフォルト値で作成するため送信しない
+type AapaasRequestData struct {
+	OperatorVersion string `json:"operator-version,omitempty"`
+	AdminUser       string `json:"admin-user,omitempty"`
+	BaseDomain      string `json:"base-domain,omitempty"`
+}
+
+// SnapshotRepositoryRequestData is a struct that represents the request data for a LOGaaS snapshot repository (S3 compatible).
+type SnapshotRepositoryRequestData struct {
+	Bucket   string `json:"bucket"`
+	BasePath string `json:"base-path"`
+	// S3互換ストレージのエンドポイント（空の場合はAWS S3）
+	Endpoint        string `json:"endpoint"`
+	Region          string `json:"region"`
+	PathStyleAccess bool   `json:"path-style-access"`
+	AccessKey       string `json:"access-key"`
+	SecretKey       string `json:"secret-key"`
+}
+
+// SnapshotRequestData is a struct that represents the request data for creating a snapshot.
+type SnapshotRequestData struct {
+	// 空の場合は"<LOGaaS名>-<日時>"
+	Name string `json:"name"`
+	// 空の場合はすべてのインデックス
+	Indices []string `json:"indices"`
+}
+
+// SnapshotRestoreRequestData is a struct that represents the request data for restoring a snapshot.
+type SnapshotRestoreRequestData struct {
+	// リストア先のLOGaaS（空の場合はスナップショットを取得したLOGaaS）
+	TargetLogaas string   `json:"target-logaas"`
+	Indices      []string `json:"indices"`
+	// 既存のインデックスと重複しないようにインデックス名を変更する（例: "(.+)" -> "restored-$1"）
+	RenamePattern     string `json:"rename-pattern"`
+	RenameReplacement string `json:"rename-replacement"`
+}
+
+// SnapshotPolicyRequestData is a struct that represents the request data for the scheduled snapshot policy.
+type SnapshotPolicyRequestData struct {
+	// スナップショットを取得するcron式（例: "0 1 * * *"）
+	Schedule string `json:"schedule"`
+	// 空の場合はUTC
+	Timezone string   `json:"timezone"`
+	Indices  []string `json:"indices"`
+	// 保持期間（例: "30d"）と保持する数の上限/下限
+	MaxAge   string `json:"max-age"`
+	MaxCount int    `json:"max-count"`
+	MinCount int    `json:"min-count"`
+}
+
+// IsmPolicyRequestData is a struct that represents the request data for an index retention (ISM) policy.
+type IsmPolicyRequestData struct {
+	Description   string   `json:"description"`
+	IndexPatterns []string `json:"index-patterns"`
+	// インデックスの作成から削除までの期間（例: "30d"）
+	DeleteAfter string `json:"delete-after"`
+}
+
+// ProjectRequestData is a struct that represents the request data for projects.
+type ProjectRequestData struct {
+	ProjectName string `json:"project-name"`
+}
@@ -0,0 +1,293 @@
+package api
+
+import "time"
+
+// CaaS/LOGaaS/AAPaaSのステータス
+const (
+	StatusCreating = "creating"
+	StatusUpdating = "updating"
+	StatusReady    = "ready"
+	StatusDeleting = "deleting"
+	StatusDeleted  = "deleted"
+	StatusFailed   = "failed"
+)
+
+// 非同期ジョブのステータス
+const (
+	JobPending   = "pending"
+	JobRunning   = "running"
+	JobSucceeded = "succeeded"
+	JobFailed    = "failed"
+)
+
+// DBに登録されたCaaS
+// キーはDBのモデルをそのまま返していたときのもの（互換性のため変更しない）
+type Caas struct {
+	ID        uint      `json:"ID"`
+	CreatedAt time.Time `json:"CreatedAt"`
+	UpdatedAt time.Time `json:"UpdatedAt"`
+	ProjectId string    `json:"ProjectId"`
+	Namespace string    `json:"Namespace"`
+	Status    string    `json:"Status"`
+
+	// ResourceQuota/LimitRangeのサイズ（Planを個別に上書きした値を含む）
+	Plan           string `json:"Plan"`
+	RequestsCpu    string `json:"RequestsCpu"`
+	RequestsMemory string `json:"RequestsMemory"`
+	Pods           string `json:"Pods"`
+	LimitCpu       string `json:"LimitCpu"`
+	LimitMemory    string `json:"LimitMemory"`
+
+	// Reconcilerが検出したDBとクラスタの差分（空の場合は差分なし）
+	Drift          string     `json:"Drift"`
+	DriftCheckedAt *time.Time `json:"DriftCheckedAt"`
+}
+
+// GET /caas/{caas_id}: CaaSのNamespaceとResourceQuota/LimitRange/RoleBinding（Kubernetesのオブジェクト）
+type CaasDetail struct {
+	Namespace     interface{} `json:"Namespace"`
+	ResourceQuota interface{} `json:"ResourceQuota"`
+	LimitRange    interface{} `json:"LimitRange"`
+	RoleBinding   interface{} `json:"RoleBinding"`
+}
+
+// DBに登録されたLOGaaS
+type Logaas struct {
+	ID          uint      `json:"ID"`
+	CreatedAt   time.Time `json:"CreatedAt"`
+	UpdatedAt   time.Time `json:"UpdatedAt"`
+	ProjectId   string    `json:"ProjectId"`
+	ClusterName string    `json:"ClusterName"`
+	ClusterType string    `json:"ClusterType"`
+	GuiEndpoint string    `json:"GuiEndpoint"`
+	ApiEndpoint string    `json:"ApiEndpoint"`
+	Status      string    `json:"Status"`
+	// OpenSearch Dashboardsのステータス
+	GuiStatus string `json:"GuiStatus"`
+	// 作成/更新時のパラメータ(LogaasRequestData)のJSON
+	Spec string `json:"Spec"`
+
+	Drift          string     `json:"Drift"`
+	DriftCheckedAt *time.Time `json:"DriftCheckedAt"`
+}
+
+// GET /logaas/{logaas_id}: OpenSearchとDashboardsのHelmリリースとPodの状態
+// DBに登録されていないLOGaaSの場合、logaasとspecは含まない
+type LogaasDetail struct {
+	Helm       OpenSearchHelmStatus `json:"helm"`
+	Pods       PodStatus            `json:"pods"`
+	Dashboards DashboardsStatus     `json:"dashboards"`
+	Logaas     *Logaas              `json:"logaas,omitempty"`
+	Spec       *LogaasRequestData   `json:"spec,omitempty"`
+}
+
+// LOGaaSを構成するリリース（scalableはノードグループごと）の状態
+// すべてのリリースがdeployedの場合のみdeployed、リリースがない場合はnot-found
+type OpenSearchHelmStatus struct {
+	Status   string                       `json:"status"`
+	Releases map[string]HelmReleaseStatus `json:"releases"`
+}
+
+type DashboardsStatus struct {
+	Helm HelmReleaseStatus `json:"helm"`
+	Pods PodStatus         `json:"pods"`
+}
+
+// Helmリリースの状態（取得に失敗した場合はerrorのみ）
+type HelmReleaseStatus struct {
+	Status       string     `json:"status,omitempty"`
+	Revision     int        `json:"revision,omitempty"`
+	ChartVersion string     `json:"chart_version,omitempty"`
+	AppVersion   string     `json:"app_version,omitempty"`
+	LastDeployed *time.Time `json:"last_deployed,omitempty"`
+	Error        string     `json:"error,omitempty"`
+}
+
+// Podの数とReadyなPodの数（取得に失敗した場合はerrorのみ）
+type PodStatus struct {
+	Ready int    `json:"ready"`
+	Total int    `json:"total"`
+	Items []Pod  `json:"items,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+type Pod struct {
+	Name  string `json:"name"`
+	Phase string `json:"phase"`
+	Ready bool   `json:"ready"`
+}
+
+// LOGaaSのユーザーと接続先（GETはテナントのユーザー、ローテーションは変更したユーザーのみ）
+// adminユーザーのローテーションではパスワードを返さない
+type LogaasCredentials struct {
+	User        string `json:"user"`
+	Password    string `json:"password,omitempty"`
+	ApiEndpoint string `json:"api_endpoint,omitempty"`
+	GuiEndpoint string `json:"gui_endpoint,omitempty"`
+}
+
+// OpenSearchのスナップショット（_snapshot/<リポジトリ>/<スナップショット>）
+type Snapshot struct {
+	Snapshot         string                   `json:"snapshot"`
+	State            string                   `json:"state"`
+	Indices          []string                 `json:"indices"`
+	StartTime        string                   `json:"start_time,omitempty"`
+	EndTime          string                   `json:"end_time,omitempty"`
+	DurationInMillis int64                    `json:"duration_in_millis"`
+	Failures         []map[string]interface{} `json:"failures"`
+	Shards           map[string]interface{}   `json:"shards,omitempty"`
+}
+
+// Snapshot Managementのポリシー（OpenSearchの形式）と実行状態
+type SnapshotPolicyStatus struct {
+	Policy  map[string]interface{} `json:"policy"`
+	Explain map[string]interface{} `json:"explain,omitempty"`
+}
+
+// ISMのポリシー（OpenSearchの_plugins/_ism/policiesの形式）
+type IsmPolicy map[string]interface{}
+
+// LOGaaSのボリュームのうち、LOGaaSが存在しないボリューム
+type LeakedVolume struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	LogaasId  string `json:"logaas_id"`
+	Status    string `json:"status"`
+	Size      int    `json:"size"`
+	CreatedAt string `json:"created_at"`
+}
+
+// DBに登録されたAAPaaS
+type Aapaas struct {
+	ID        uint      `json:"ID"`
+	CreatedAt time.Time `json:"CreatedAt"`
+	UpdatedAt time.Time `json:"UpdatedAt"`
+	ProjectId string    `json:"ProjectId"`
+	Name      string    `json:"Name"`
+	Namespace string    `json:"Namespace"`
+	Endpoint  string    `json:"Endpoint"`
+	AdminUser string    `json:"AdminUser"`
+	// 管理者パスワードを保存するSecretの名前
+	AdminSecret     string `json:"AdminSecret"`
+	OperatorVersion string `json:"OperatorVersion"`
+	Status          string `json:"Status"`
+}
+
+// GET /aapaas/{aapaas_id}: AWXの接続先と管理者パスワードのSecretの参照、HelmリリースとPodの状態
+type AapaasDetail struct {
+	Aapaas           Aapaas            `json:"aapaas"`
+	Endpoint         string            `json:"endpoint"`
+	AdminCredentials AdminCredentials  `json:"admin_credentials"`
+	Helm             HelmReleaseStatus `json:"helm"`
+	Pods             PodStatus         `json:"pods"`
+}
+
+type AdminCredentials struct {
+	User   string          `json:"user"`
+	Secret SecretReference `json:"secret"`
+}
+
+// パスワードを保存したKubernetesのSecretのキー
+type SecretReference struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	Key       string `json:"key"`
+}
+
+// CaaS/LOGaaS/AAPaaSの作成・更新・削除などのジョブ
+type Job struct {
+	ID           string     `json:"id"`
+	ProjectId    string     `json:"project_id"`
+	ResourceType string     `json:"resource_type"`
+	ResourceId   string     `json:"resource_id"`
+	Action       string     `json:"action"`
+	Status       string     `json:"status"`
+	Error        string     `json:"error,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+	StartedAt    *time.Time `json:"started_at,omitempty"`
+	FinishedAt   *time.Time `json:"finished_at,omitempty"`
+	Steps        []JobStep  `json:"steps"`
+}
+
+// ジョブが終了したか
+func (j *Job) Finished() bool {
+	return j.Status == JobSucceeded || j.Status == JobFailed
+}
+
+// ジョブの各ステップの進捗
+type JobStep struct {
+	Name       string     `json:"name"`
+	Status     string     `json:"status"`
+	Error      string     `json:"error,omitempty"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// Keystoneのプロジェクト
+type Project struct {
+	ProjectId   string    `json:"project_id"`
+	ProjectName string    `json:"project_name"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// GET /projects/{project_id}/resources: プロジェクトが所有するリソース（削除済みのリソースは含まない）
+type ProjectResources struct {
+	Project   Project             `json:"project"`
+	Counts    ResourceCounts      `json:"counts"`
+	Resources ProjectResourceList `json:"resources"`
+}
+
+type ResourceCounts struct {
+	Caas   int `json:"caas"`
+	Logaas int `json:"logaas"`
+	Aapaas int `json:"aapaas"`
+}
+
+type ProjectResourceList struct {
+	Caas   []Caas   `json:"caas"`
+	Logaas []Logaas `json:"logaas"`
+	Aapaas []Aapaas `json:"aapaas"`
+}
+
+// LOGaaSのノードのサイズ
+type Flavor struct {
+	Name     string          `json:"name"`
+	Requests FlavorResources `json:"requests"`
+	Limits   FlavorResources `json:"limits"`
+	JvmHeap  string          `json:"jvm_heap"`
+	JvmPerm  string          `json:"jvm_perm"`
+}
+
+type FlavorResources struct {
+	Cpu    string `json:"cpu"`
+	Memory string `json:"memory"`
+}
+
+// /api/v1のPOST/PUT/DELETEの監査ログ
+type AuditLog struct {
+	ID           uint      `json:"id"`
+	CreatedAt    time.Time `json:"created_at"`
+	UserId       string    `json:"user_id"`
+	UserName     string    `json:"user_name"`
+	ProjectId    string    `json:"project_id"`
+	ProjectName  string    `json:"project_name"`
+	IsAdmin      bool      `json:"is_admin"`
+	ClientIp     string    `json:"client_ip"`
+	Method       string    `json:"method"`
+	Route        string    `json:"route"`
+	Path         string    `json:"path"`
+	Action       string    `json:"action"`
+	ResourceType string    `json:"resource_type"`
+	ResourceId   string    `json:"resource_id"`
+	Payload      string    `json:"payload,omitempty"`
+	StatusCode   int       `json:"status_code"`
+	Outcome      string    `json:"outcome"`
+	Error        string    `json:"error,omitempty"`
+	// 失敗したレスポンスのcodeとerrors
+	ErrorCode ErrorCode    `json:"error_code,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+	JobId     string       `json:"job_id,omitempty"`
+	TraceId   string       `json:"trace_id,omitempty"`
+}
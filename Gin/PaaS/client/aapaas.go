@@ -0,0 +1,24 @@
+package client
+
+import (
+	"context"
+	"net/http"
+
+	"ham3/api"
+)
+
+func (c *Client) ListAapaas(ctx context.Context, options ListOptions) (api.Page[api.Aapaas], error) {
+	return call[api.Page[api.Aapaas]](ctx, c, http.MethodGet, "/aapaas/", options.query(), nil)
+}
+
+func (c *Client) CreateAapaas(ctx context.Context, aapaas_id string, requestData api.AapaasRequestData) (*api.AcceptedResponse, error) {
+	return accept(ctx, c, http.MethodPost, path("/aapaas/%s", aapaas_id), requestData)
+}
+
+func (c *Client) GetAapaas(ctx context.Context, aapaas_id string) (api.AapaasDetail, error) {
+	return call[api.AapaasDetail](ctx, c, http.MethodGet, path("/aapaas/%s", aapaas_id), nil, nil)
+}
+
+func (c *Client) DeleteAapaas(ctx context.Context, aapaas_id string) (*api.AcceptedResponse, error) {
+	return accept(ctx, c, http.MethodDelete, path("/aapaas/%s", aapaas_id), nil)
+}
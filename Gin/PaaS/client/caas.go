@@ -0,0 +1,29 @@
+package client
+
+import (
+	"context"
+	"net/http"
+
+	"ham3/api"
+)
+
+func (c *Client) ListCaas(ctx context.Context, options ListOptions) (api.Page[api.Caas], error) {
+	return call[api.Page[api.Caas]](ctx, c, http.MethodGet, "/caas/", options.query(), nil)
+}
+
+// Planのみ、または個別の値で上書きしたPlanでCaaSを作成する（ジョブの結果はWaitJobで待つ）
+func (c *Client) CreateCaas(ctx context.Context, caas_id string, requestData api.CaasRequestData) (*api.AcceptedResponse, error) {
+	return accept(ctx, c, http.MethodPost, path("/caas/%s", caas_id), requestData)
+}
+
+func (c *Client) GetCaas(ctx context.Context, caas_id string) (api.CaasDetail, error) {
+	return call[api.CaasDetail](ctx, c, http.MethodGet, path("/caas/%s", caas_id), nil, nil)
+}
+
+func (c *Client) UpdateCaas(ctx context.Context, caas_id string, requestData api.CaasRequestData) (*api.AcceptedResponse, error) {
+	return accept(ctx, c, http.MethodPut, path("/caas/%s", caas_id), requestData)
+}
+
+func (c *Client) DeleteCaas(ctx context.Context, caas_id string) (*api.AcceptedResponse, error) {
+	return accept(ctx, c, http.MethodDelete, path("/caas/%s", caas_id), nil)
+}
@@ -0,0 +1,189 @@
+// HAM3 API(/api/v1)のクライアント
+// リクエストとレスポンスはham3/apiの型を使う（CLIから使うため、標準ライブラリとham3/api以外に依存しない）
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"ham3/api"
+)
+
+// HAM3 APIのクライアント
+type Client struct {
+	// HAM3のURL（例: http://localhost:8081）
+	BaseURL string
+	// KeystoneのToken（X-Auth-Token）
+	Token string
+	// 作成するリソースのプロジェクト（X-Project-Id、管理者のみ）
+	ProjectId  string
+	HTTPClient *http.Client
+}
+
+func New(baseURL string, token string) *Client {
+	return &Client{
+		BaseURL:    strings.TrimSuffix(baseURL, "/"),
+		Token:      token,
+		HTTPClient: http.DefaultClient,
+	}
+}
+
+// APIがエラー(4xx/5xx)を返した場合のエラー
+type Error struct {
+	StatusCode int
+	api.ErrorResponse
+}
+
+func (e *Error) Error() string {
+	message := fmt.Sprintf("%d %s: %s", e.StatusCode, e.Code, e.Message)
+	for _, fieldError := range e.Errors {
+		message += fmt.Sprintf("\n  %s: %s", fieldError.Field, fieldError.Message)
+	}
+	return message
+}
+
+// エラーの種類がcodeか
+func IsCode(err error, code api.ErrorCode) bool {
+	var apiErr *Error
+	return errors.As(err, &apiErr) && apiErr.Code == code
+}
+
+// 一覧の絞り込みとページ（空の項目は指定しない）
+type ListOptions struct {
+	Page     int
+	PageSize int
+	// プロジェクトID（管理者のみ）
+	Project string
+	Status  string
+	// LOGaaSのみ
+	ClusterType string
+	// Reconcilerが検出した差分の有無（CaaS/LOGaaSのみ）
+	Drifted *bool
+}
+
+func (o ListOptions) query() url.Values {
+	query := url.Values{}
+	if o.Page > 0 {
+		query.Set("page", fmt.Sprint(o.Page))
+	}
+	if o.PageSize > 0 {
+		query.Set("page_size", fmt.Sprint(o.PageSize))
+	}
+	if o.Project != "" {
+		query.Set("project", o.Project)
+	}
+	if o.Status != "" {
+		query.Set("status", o.Status)
+	}
+	if o.ClusterType != "" {
+		query.Set("cluster_type", o.ClusterType)
+	}
+	if o.Drifted != nil {
+		query.Set("drifted", fmt.Sprint(*o.Drifted))
+	}
+	return query
+}
+
+// /api/v1からのパス（パスパラメータはエスケープする）
+func path(format string, params ...string) string {
+	escaped := make([]interface{}, 0, len(params))
+	for _, param := range params {
+		escaped = append(escaped, url.PathEscape(param))
+	}
+	return fmt.Sprintf(format, escaped...)
+}
+
+// リクエストを送信し、成功した場合はレスポンスをoutに読み込む
+// bodyがnilの場合はボディなし
+func (c *Client) do(ctx context.Context, method string, path string, query url.Values, body interface{}, out interface{}) error {
+	endpoint := c.BaseURL + "/api/v1" + path
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.Token != "" {
+		req.Header.Set("X-Auth-Token", c.Token)
+	}
+	if c.ProjectId != "" {
+		req.Header.Set("X-Project-Id", c.ProjectId)
+	}
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("error reading response body: %v", err)
+	}
+
+	if resp.StatusCode >= 400 {
+		apiErr := &Error{StatusCode: resp.StatusCode}
+		// JSONでない場合（プロキシのエラーなど）はボディをそのままmessageにする
+		if json.Unmarshal(data, &apiErr.ErrorResponse) != nil || apiErr.Code == "" {
+			apiErr.ErrorResponse = api.NewError(resp.StatusCode, strings.TrimSpace(string(data)))
+		}
+		return apiErr
+	}
+
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("error parsing response body: %v", err)
+	}
+	return nil
+}
+
+// {"status":"success","message":...}のmessageを返す
+func call[T any](ctx context.Context, c *Client, method string, path string, query url.Values, body interface{}) (T, error) {
+	var response api.Response[T]
+	err := c.do(ctx, method, path, query, body, &response)
+	return response.Message, err
+}
+
+// ジョブとして受け付けられたリクエスト(202)
+func accept(ctx context.Context, c *Client, method string, path string, body interface{}) (*api.AcceptedResponse, error) {
+	var response api.AcceptedResponse
+	if err := c.do(ctx, method, path, nil, body, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// OpenAPIのドキュメント
+func (c *Client) OpenAPI(ctx context.Context) (map[string]interface{}, error) {
+	var document map[string]interface{}
+	err := c.do(ctx, http.MethodGet, "/openapi.json", nil, nil, &document)
+	return document, err
+}
@@ -0,0 +1,180 @@
+package client_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"ham3/api"
+	"ham3/client"
+	"ham3/config"
+	"ham3/jobs"
+	"ham3/middlewares"
+	"ham3/models"
+	"ham3/routers"
+	"ham3/services"
+	"ham3/utilities"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"helm.sh/helm/v3/pkg/chart"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// Cinderの代わりにボリュームを作成したことにするVolumeProvider
+type fakeVolumeProvider struct{}
+
+func (fakeVolumeProvider) CreateVolumes(logaas_id string, requestData api.LogaasRequestData) ([]utilities.OpenSearchVolume, error) {
+	return nil, nil
+}
+
+func (fakeVolumeProvider) WaitVolumesAvailable(volumes []utilities.OpenSearchVolume, timeout int) error {
+	return nil
+}
+
+func (fakeVolumeProvider) DeleteVolumes(logaas_id string, requestData api.LogaasRequestData) error {
+	return nil
+}
+
+func (fakeVolumeProvider) FindLeakedVolumes(ocpCluster string, exists func(logaas_id string) (bool, error)) ([]utilities.LeakedVolume, error) {
+	return nil, nil
+}
+
+// 常にgreenを返すOpenSearchClient
+type fakeOpenSearchClient struct{}
+
+func (fakeOpenSearchClient) WaitHealthy(ctx context.Context, logaas_id string, baseDomain string) (string, error) {
+	return utilities.OpenSearchHealthGreen, nil
+}
+
+func (fakeOpenSearchClient) Do(ctx context.Context, logaas_id string, baseDomain string, method string, path string, body interface{}, out interface{}) error {
+	return nil
+}
+
+// テストサーバーが受け取ったPOST/PUTのボディ
+type requestBodies struct {
+	mu     sync.Mutex
+	bodies []string
+}
+
+func (b *requestBodies) last() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.bodies) == 0 {
+		return ""
+	}
+	return b.bodies[len(b.bodies)-1]
+}
+
+// テストサーバーの設定のデフォルト値
+var testDefaults = config.DefaultsConfig{BaseDomain: "example.com", K8sName: "k8s", Site: "site-a", OcpCluster: "ocp"}
+
+// ルーターのハンドラーを使うHAM3のテストサーバー（KeystoneAuthの代わりにproject-1のトークンとして認証済みにする）
+func newTestServer(t *testing.T, bodies *requestBodies) *client.Client {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{TranslateError: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := models.MigrateUp(db, 0); err != nil {
+		t.Fatal(err)
+	}
+	jm := jobs.NewManager(db, 1, 10)
+	clients := &services.Clients{
+		Kube: fake.NewSimpleClientset(),
+		Helm: utilities.NewMemoryHelmClient(func(helmChart utilities.HelmChart) (*chart.Chart, error) {
+			return &chart.Chart{Metadata: &chart.Metadata{APIVersion: chart.APIVersionV2, Name: helmChart.Name, Version: "0.1.0"}}, nil
+		}),
+		Volumes:    fakeVolumeProvider{},
+		OpenSearch: fakeOpenSearchClient{},
+		Logaas:     config.DefaultLogaasConfig(),
+		Defaults:   testDefaults,
+	}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	v1 := r.Group("/api/v1")
+	v1.Use(func(c *gin.Context) {
+		if c.Request.Method == http.MethodPost || c.Request.Method == http.MethodPut {
+			data, _ := io.ReadAll(c.Request.Body)
+			c.Request.Body = io.NopCloser(bytes.NewReader(data))
+			bodies.mu.Lock()
+			bodies.bodies = append(bodies.bodies, string(data))
+			bodies.mu.Unlock()
+		}
+		c.Set(middlewares.ProjectIdContextKey, "project-1")
+		c.Next()
+	})
+	routers.RegisterRoutes(v1, clients, db, jm)
+	server := httptest.NewServer(r)
+
+	t.Cleanup(func() {
+		server.Close()
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		jm.Shutdown(ctx)
+		if sqlDb, err := db.DB(); err == nil {
+			sqlDb.Close()
+		}
+	})
+	return client.New(server.URL, "token")
+}
+
+func waitJob(t *testing.T, ham3 *client.Client, accepted *api.AcceptedResponse, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if _, err := ham3.WaitJob(ctx, accepted.JobId, nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// CLIと同じくcluster-typeのみ指定して作成し、1つの値のみ指定して更新する
+// 指定しなかった値は送信されず、作成時はデフォルト値、更新時は現在の値のまま
+func TestLogaasCreateWithDefaultsAndPartialUpdate(t *testing.T) {
+	if err := config.LoadFlavors("../" + config.DefaultFlavorFile); err != nil {
+		t.Fatal(err)
+	}
+	var bodies requestBodies
+	ham3 := newTestServer(t, &bodies)
+	ctx := context.Background()
+
+	accepted, err := ham3.CreateLogaas(ctx, "logs", api.LogaasRequestData{ClusterType: "standard"})
+	waitJob(t, ham3, accepted, err)
+	if want := `{"cluster-type":"standard"}`; bodies.last() != want {
+		t.Errorf("create body = %s, want %s", bodies.last(), want)
+	}
+	created, err := ham3.GetLogaas(ctx, "logs", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var defaults api.LogaasRequestData
+	utilities.LogaasGetDefaultValue(&defaults, testDefaults)
+	if created.Spec == nil || *created.Spec != defaults {
+		t.Fatalf("spec after create = %+v, want %+v", created.Spec, defaults)
+	}
+
+	accepted, err = ham3.UpdateLogaas(ctx, "logs", api.LogaasRequestData{MasterFlavor: "m1.medium"})
+	waitJob(t, ham3, accepted, err)
+	if want := `{"master-flavor":"m1.medium"}`; bodies.last() != want {
+		t.Errorf("update body = %s, want %s", bodies.last(), want)
+	}
+	updated, err := ham3.GetLogaas(ctx, "logs", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := defaults
+	want.MasterFlavor = "m1.medium"
+	if updated.Spec == nil || *updated.Spec != want {
+		t.Errorf("spec after update = %+v, want %+v", updated.Spec, want)
+	}
+}
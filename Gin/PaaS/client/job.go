@@ -0,0 +1,52 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"ham3/api"
+)
+
+// long-pollで待機する時間（サーバーの上限を超える場合はサーバーの上限まで）
+const jobWait = 30 * time.Second
+
+// ジョブの進捗を返す（waitを指定した場合はジョブが更新されるかwaitが経過するまで待つ）
+func (c *Client) GetJob(ctx context.Context, job_id string, wait time.Duration) (api.Job, error) {
+	query := url.Values{}
+	if wait > 0 {
+		query.Set("wait", wait.String())
+	}
+	return call[api.Job](ctx, c, http.MethodGet, path("/jobs/%s", job_id), query, nil)
+}
+
+// ジョブが失敗した場合のエラー
+type JobFailedError struct {
+	Job api.Job
+}
+
+func (e *JobFailedError) Error() string {
+	return fmt.Sprintf("job %s failed: %s", e.Job.ID, e.Job.Error)
+}
+
+// ジョブが終了するまでlong-pollで待機する（updatedがnilでない場合はジョブを取得するたびに呼ぶ）
+// ジョブが失敗した場合はJobFailedErrorを返す
+func (c *Client) WaitJob(ctx context.Context, job_id string, updated func(job *api.Job)) (*api.Job, error) {
+	for {
+		job, err := c.GetJob(ctx, job_id, jobWait)
+		if err != nil {
+			return nil, err
+		}
+		if updated != nil {
+			updated(&job)
+		}
+		switch job.Status {
+		case api.JobSucceeded:
+			return &job, nil
+		case api.JobFailed:
+			return &job, &JobFailedError{Job: job}
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	"ham3/api"
+)
+
+func (c *Client) ListLogaas(ctx context.Context, options ListOptions) (api.Page[api.Logaas], error) {
+	return call[api.Page[api.Logaas]](ctx, c, http.MethodGet, "/logaas/", options.query(), nil)
+}
+
+func (c *Client) CreateLogaas(ctx context.Context, logaas_id string, requestData api.LogaasRequestData) (*api.AcceptedResponse, error) {
+	return accept(ctx, c, http.MethodPost, path("/logaas/%s", logaas_id), requestData)
+}
+
+// clusterTypeはDBに登録されていないLOGaaSの場合のみ指定する（管理者のみ）
+func (c *Client) GetLogaas(ctx context.Context, logaas_id string, clusterType string) (api.LogaasDetail, error) {
+	query := url.Values{}
+	if clusterType != "" {
+		query.Set("cluster_type", clusterType)
+	}
+	return call[api.LogaasDetail](ctx, c, http.MethodGet, path("/logaas/%s", logaas_id), query, nil)
+}
+
+func (c *Client) UpdateLogaas(ctx context.Context, logaas_id string, requestData api.LogaasRequestData) (*api.AcceptedResponse, error) {
+	return accept(ctx, c, http.MethodPut, path("/logaas/%s", logaas_id), requestData)
+}
+
+// clusterTypeはDBに登録されていないLOGaaSの場合のみ指定する
+// （リクエストのキーは登録済みのspecを上書きするため、cluster-type以外は送信しない）
+func (c *Client) DeleteLogaas(ctx context.Context, logaas_id string, clusterType string) (*api.AcceptedResponse, error) {
+	var body interface{}
+	if clusterType != "" {
+		body = map[string]string{"cluster-type": clusterType}
+	}
+	return accept(ctx, c, http.MethodDelete, path("/logaas/%s", logaas_id), body)
+}
+
+func (c *Client) GetLogaasCredentials(ctx context.Context, logaas_id string) (api.LogaasCredentials, error) {
+	return call[api.LogaasCredentials](ctx, c, http.MethodGet, path("/logaas/%s/credentials", logaas_id), nil, nil)
+}
+
+// userが空の場合はテナントのユーザー、"admin"の場合はadminユーザー（管理者のみ、パスワードは返さない）
+func (c *Client) RotateLogaasCredentials(ctx context.Context, logaas_id string, user string) (api.LogaasCredentials, error) {
+	body := api.LogaasCredentialsRotateRequestData{User: user}
+	return call[api.LogaasCredentials](ctx, c, http.MethodPost, path("/logaas/%s/credentials/rotate", logaas_id), nil, body)
+}
+
+// ocpClusterが空の場合はサーバーのOCP_CLUSTER（管理者のみ）
+func (c *Client) ListLeakedLogaasVolumes(ctx context.Context, ocpCluster string) ([]api.LeakedVolume, error) {
+	query := url.Values{}
+	if ocpCluster != "" {
+		query.Set("ocp_cluster", ocpCluster)
+	}
+	return call[[]api.LeakedVolume](ctx, c, http.MethodGet, "/logaas/volumes/leaked", query, nil)
+}
@@ -0,0 +1,74 @@
+package client
+
+import (
+	"context"
+	"net/http"
+
+	"ham3/api"
+)
+
+func (c *Client) PutLogaasSnapshotRepository(ctx context.Context, logaas_id string, requestData api.SnapshotRepositoryRequestData) (api.SnapshotRepositoryRequestData, error) {
+	return call[api.SnapshotRepositoryRequestData](ctx, c, http.MethodPut, path("/logaas/%s/snapshots/repository", logaas_id), nil, requestData)
+}
+
+// secret-keyはマスクされる
+func (c *Client) GetLogaasSnapshotRepository(ctx context.Context, logaas_id string) (api.SnapshotRepositoryRequestData, error) {
+	return call[api.SnapshotRepositoryRequestData](ctx, c, http.MethodGet, path("/logaas/%s/snapshots/repository", logaas_id), nil, nil)
+}
+
+func (c *Client) PutLogaasSnapshotPolicy(ctx context.Context, logaas_id string, requestData api.SnapshotPolicyRequestData) (api.SnapshotPolicyRequestData, error) {
+	return call[api.SnapshotPolicyRequestData](ctx, c, http.MethodPut, path("/logaas/%s/snapshots/policy", logaas_id), nil, requestData)
+}
+
+func (c *Client) GetLogaasSnapshotPolicy(ctx context.Context, logaas_id string) (api.SnapshotPolicyStatus, error) {
+	return call[api.SnapshotPolicyStatus](ctx, c, http.MethodGet, path("/logaas/%s/snapshots/policy", logaas_id), nil, nil)
+}
+
+func (c *Client) DeleteLogaasSnapshotPolicy(ctx context.Context, logaas_id string) (string, error) {
+	return call[string](ctx, c, http.MethodDelete, path("/logaas/%s/snapshots/policy", logaas_id), nil, nil)
+}
+
+// スナップショットの作成を開始する（進捗はGetLogaasSnapshotのstateで確認する）
+func (c *Client) CreateLogaasSnapshot(ctx context.Context, logaas_id string, requestData api.SnapshotRequestData) (*api.SnapshotAcceptedResponse, error) {
+	var response api.SnapshotAcceptedResponse
+	if err := c.do(ctx, http.MethodPost, path("/logaas/%s/snapshots", logaas_id), nil, requestData, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+func (c *Client) ListLogaasSnapshots(ctx context.Context, logaas_id string) ([]api.Snapshot, error) {
+	return call[[]api.Snapshot](ctx, c, http.MethodGet, path("/logaas/%s/snapshots", logaas_id), nil, nil)
+}
+
+func (c *Client) GetLogaasSnapshot(ctx context.Context, logaas_id string, snapshot string) (api.Snapshot, error) {
+	return call[api.Snapshot](ctx, c, http.MethodGet, path("/logaas/%s/snapshots/%s", logaas_id, snapshot), nil, nil)
+}
+
+func (c *Client) DeleteLogaasSnapshot(ctx context.Context, logaas_id string, snapshot string) (string, error) {
+	return call[string](ctx, c, http.MethodDelete, path("/logaas/%s/snapshots/%s", logaas_id, snapshot), nil, nil)
+}
+
+func (c *Client) RestoreLogaasSnapshot(ctx context.Context, logaas_id string, snapshot string, requestData api.SnapshotRestoreRequestData) (*api.RestoreAcceptedResponse, error) {
+	var response api.RestoreAcceptedResponse
+	if err := c.do(ctx, http.MethodPost, path("/logaas/%s/snapshots/%s/restore", logaas_id, snapshot), nil, requestData, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+func (c *Client) ListLogaasIsmPolicies(ctx context.Context, logaas_id string) ([]api.IsmPolicy, error) {
+	return call[[]api.IsmPolicy](ctx, c, http.MethodGet, path("/logaas/%s/ism-policies", logaas_id), nil, nil)
+}
+
+func (c *Client) PutLogaasIsmPolicy(ctx context.Context, logaas_id string, policy_id string, requestData api.IsmPolicyRequestData) (api.IsmPolicyRequestData, error) {
+	return call[api.IsmPolicyRequestData](ctx, c, http.MethodPut, path("/logaas/%s/ism-policies/%s", logaas_id, policy_id), nil, requestData)
+}
+
+func (c *Client) GetLogaasIsmPolicy(ctx context.Context, logaas_id string, policy_id string) (api.IsmPolicy, error) {
+	return call[api.IsmPolicy](ctx, c, http.MethodGet, path("/logaas/%s/ism-policies/%s", logaas_id, policy_id), nil, nil)
+}
+
+func (c *Client) DeleteLogaasIsmPolicy(ctx context.Context, logaas_id string, policy_id string) (string, error) {
+	return call[string](ctx, c, http.MethodDelete, path("/logaas/%s/ism-policies/%s", logaas_id, policy_id), nil, nil)
+}
@@ -0,0 +1,80 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"ham3/api"
+)
+
+func (c *Client) ListProjects(ctx context.Context, options ListOptions) (api.Page[api.Project], error) {
+	return call[api.Page[api.Project]](ctx, c, http.MethodGet, "/projects/", options.query(), nil)
+}
+
+// 管理者のみ
+func (c *Client) CreateProject(ctx context.Context, project_id string, projectName string) (api.Project, error) {
+	body := api.ProjectRequestData{ProjectName: projectName}
+	return call[api.Project](ctx, c, http.MethodPost, path("/projects/%s", project_id), nil, body)
+}
+
+func (c *Client) GetProject(ctx context.Context, project_id string) (api.Project, error) {
+	return call[api.Project](ctx, c, http.MethodGet, path("/projects/%s", project_id), nil, nil)
+}
+
+// 管理者のみ
+func (c *Client) UpdateProject(ctx context.Context, project_id string, projectName string) (api.Project, error) {
+	body := api.ProjectRequestData{ProjectName: projectName}
+	return call[api.Project](ctx, c, http.MethodPut, path("/projects/%s", project_id), nil, body)
+}
+
+// 管理者のみ（リソースが残っている場合はconflict）
+func (c *Client) DeleteProject(ctx context.Context, project_id string) (string, error) {
+	return call[string](ctx, c, http.MethodDelete, path("/projects/%s", project_id), nil, nil)
+}
+
+func (c *Client) GetProjectResources(ctx context.Context, project_id string) (api.ProjectResources, error) {
+	return call[api.ProjectResources](ctx, c, http.MethodGet, path("/projects/%s/resources", project_id), nil, nil)
+}
+
+func (c *Client) ListFlavors(ctx context.Context) ([]api.Flavor, error) {
+	return call[[]api.Flavor](ctx, c, http.MethodGet, "/flavors", nil, nil)
+}
+
+// 監査ログの絞り込み（空の項目は指定しない）
+type AuditLogOptions struct {
+	Page         int
+	PageSize     int
+	From         time.Time
+	To           time.Time
+	ResourceType string
+	ResourceId   string
+	Project      string
+	Action       string
+	Outcome      string
+	// ユーザー名またはユーザーID
+	User string
+}
+
+// 管理者のみ
+func (c *Client) ListAuditLogs(ctx context.Context, options AuditLogOptions) (api.Page[api.AuditLog], error) {
+	query := ListOptions{Page: options.Page, PageSize: options.PageSize, Project: options.Project}.query()
+	if !options.From.IsZero() {
+		query.Set("from", options.From.Format(time.RFC3339))
+	}
+	if !options.To.IsZero() {
+		query.Set("to", options.To.Format(time.RFC3339))
+	}
+	for param, value := range map[string]string{
+		"resource_type": options.ResourceType,
+		"resource_id":   options.ResourceId,
+		"action":        options.Action,
+		"outcome":       options.Outcome,
+		"user":          options.User,
+	} {
+		if value != "" {
+			query.Set(param, value)
+		}
+	}
+	return call[api.Page[api.AuditLog]](ctx, c, http.MethodGet, "/audit", query, nil)
+}
@@ -0,0 +1,53 @@
+package config
+
+// CaaSのResourceQuota(Requests*, Pods)とLimitRange(Limit*、Podあたりの上限)のサイズ
+type CaasPlan struct {
+	RequestsCpu    string `json:"requests-cpu"`
+	RequestsMemory string `json:"requests-memory"`
+	Pods           string `json:"pods"`
+	LimitCpu       string `json:"limit-cpu"`
+	LimitMemory    string `json:"limit-memory"`
+}
+
+const CaasDefaultPlan = "medium"
+
+var CaasPlans = map[string]CaasPlan{
+	"small": {
+		RequestsCpu:    "4",
+		RequestsMemory: "4Gi",
+		Pods:           "10",
+		LimitCpu:       "2000m",
+		LimitMemory:    "1024Mi",
+	},
+	"medium": {
+		RequestsCpu:    "10",
+		RequestsMemory: "10Gi",
+		Pods:           "20",
+		LimitCpu:       "4000m",
+		LimitMemory:    "2048Mi",
+	},
+	"large": {
+		RequestsCpu:    "20",
+		RequestsMemory: "32Gi",
+		Pods:           "50",
+		LimitCpu:       "8000m",
+		LimitMemory:    "8Gi",
+	},
+}
+
+// リクエストで個別に指定できる値の下限/上限（管理者が設定）
+var CaasPlanMin = CaasPlan{
+	RequestsCpu:    "1",
+	RequestsMemory: "1Gi",
+	Pods:           "1",
+	LimitCpu:       "250m",
+	LimitMemory:    "256Mi",
+}
+
+var CaasPlanMax = CaasPlan{
+	RequestsCpu:    "40",
+	RequestsMemory: "128Gi",
+	Pods:           "200",
+	LimitCpu:       "16000m",
+	LimitMemory:    "32Gi",
+}
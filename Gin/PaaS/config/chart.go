@@ -0,0 +1,72 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// チャートの取得元の種類
+const (
+	// チャートリポジトリ（デフォルトは公開リポジトリ、社内ミラーのURLも指定可能）
+	ChartSourceRepo = "repo"
+	// OCIレジストリ（oci://<registry>/<path>/<chart>）
+	ChartSourceOci = "oci"
+	// 展開済みのチャートのディレクトリ
+	ChartSourceDir = "dir"
+	// チャートのアーカイブ(.tgz)
+	ChartSourceArchive = "archive"
+)
+
+// パスに含めるとチャートのバージョンに置き換える文字列（例: /opt/charts/opensearch-{version}.tgz）
+const ChartVersionPlaceholder = "{version}"
+
+// チャートの取得元
+type ChartSource struct {
+	Type string
+	// repo: リポジトリのURL（空の場合は公開リポジトリ）、oci: チャートの参照、dir/archive: パス
+	Location string
+}
+
+func ParseChartSource(value string) (ChartSource, error) {
+	value = strings.TrimSpace(value)
+	switch {
+	case strings.HasPrefix(value, "oci://"):
+		return ChartSource{Type: ChartSourceOci, Location: value}, nil
+	case strings.HasPrefix(value, "http://"), strings.HasPrefix(value, "https://"):
+		return ChartSource{Type: ChartSourceRepo, Location: value}, nil
+	}
+
+	path := strings.TrimPrefix(value, "file://")
+	sourceType := ChartSourceDir
+	if strings.HasSuffix(path, ".tgz") || strings.HasSuffix(path, ".tar.gz") {
+		sourceType = ChartSourceArchive
+	}
+	// バージョンごとのパスは取得時に確認する
+	if !strings.Contains(path, ChartVersionPlaceholder) {
+		info, err := os.Stat(path)
+		if err != nil {
+			return ChartSource{}, err
+		}
+		if (sourceType == ChartSourceDir) != info.IsDir() {
+			return ChartSource{}, fmt.Errorf("%s is not a chart %s", path, sourceType)
+		}
+	}
+	return ChartSource{Type: sourceType, Location: path}, nil
+}
+
+// OpenSearchのバージョンに対応するチャートのバージョン（対応がない場合は空）
+func OpenSearchChartVersion(openSearchVersion string) string {
+	return chartVersion("opensearch", "opensearch", openSearchVersion)
+}
+
+// OpenSearch Dashboardsのバージョンに対応するチャートのバージョン（対応がない場合は空）
+func OpenSearchDashboardsChartVersion(dashboardsVersion string) string {
+	return chartVersion("dashboards", "opensearch-dashboards", dashboardsVersion)
+}
+
+// HelmChartVersionsの"<チャート名>-<チャートのバージョン>"からバージョンを取り出す
+func chartVersion(key string, chartName string, appVersion string) string {
+	versions, _ := HelmChartVersions[key].(map[string]string)
+	return strings.TrimPrefix(versions[appVersion], chartName+"-")
+}
@@ -0,0 +1,363 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"sigs.k8s.io/yaml"
+)
+
+// HAM3サーバーの設定
+// デフォルト値 < 設定ファイル < 環境変数 < コマンドラインフラグ の順に上書きする
+type Config struct {
+	Server     ServerConfig
+	Telemetry  TelemetryConfig
+	Kubernetes KubernetesConfig
+	Keystone   KeystoneConfig
+	Database   DatabaseConfig
+	Helm       HelmConfig
+	OpenSearch OpenSearchClientConfig
+	Reconcile  ReconcileConfig
+	Jobs       JobsConfig
+	Logaas     LogaasConfig
+	Defaults   DefaultsConfig
+	// Flavorファイルのパス
+	FlavorFile string
+}
+
+// 設定ファイルのパスを指定する環境変数とフラグ
+const (
+	ConfigFileEnv  = "HAM3_CONFIG"
+	ConfigFileFlag = "config"
+)
+
+// 設定項目（設定ファイルのキー、環境変数、フラグの対応）
+type setting struct {
+	// 設定ファイルのキー（"<セクション>.<項目>"、セクションがない場合は"<項目>"）
+	key string
+	env string
+	// 空の場合はフラグでは指定できない
+	flag  string
+	usage string
+	set   func(cfg *Config, value string) error
+}
+
+var settings = []setting{
+	stringSetting("server.listen", "HAM3_LISTEN", "listen", "APIサーバーのアドレス", func(cfg *Config) *string { return &cfg.Server.Addr }),
+	stringSetting("server.metrics-listen", "HAM3_METRICS_LISTEN", "metrics-listen", "メトリクス(/metrics)のアドレス", func(cfg *Config) *string { return &cfg.Server.MetricsAddr }),
+	durationSetting("server.read-header-timeout", "HAM3_READ_HEADER_TIMEOUT", "", "リクエストヘッダーの読み込みのタイムアウト", func(cfg *Config) *time.Duration { return &cfg.Server.ReadHeaderTimeout }),
+	durationSetting("server.read-timeout", "HAM3_READ_TIMEOUT", "", "リクエストの読み込みのタイムアウト", func(cfg *Config) *time.Duration { return &cfg.Server.ReadTimeout }),
+	durationSetting("server.write-timeout", "HAM3_WRITE_TIMEOUT", "", "レスポンスの書き込みのタイムアウト（0の場合は無効）", func(cfg *Config) *time.Duration { return &cfg.Server.WriteTimeout }),
+	durationSetting("server.idle-timeout", "HAM3_IDLE_TIMEOUT", "", "Keep-Aliveの接続のタイムアウト", func(cfg *Config) *time.Duration { return &cfg.Server.IdleTimeout }),
+	durationSetting("server.shutdown-timeout", "HAM3_SHUTDOWN_TIMEOUT", "", "停止時に処理中のリクエストを待つ時間", func(cfg *Config) *time.Duration { return &cfg.Server.ShutdownTimeout }),
+
+	stringSetting("telemetry.otlp-endpoint", "HAM3_OTLP_ENDPOINT", "otlp-endpoint", "トレースの送信先（OTLP/HTTP、host:port）", func(cfg *Config) *string { return &cfg.Telemetry.OtlpEndpoint }),
+	boolSetting("telemetry.otlp-insecure", "HAM3_OTLP_INSECURE", "", "トレースの送信にTLSを使わない", func(cfg *Config) *bool { return &cfg.Telemetry.OtlpInsecure }),
+	stringSetting("telemetry.service-name", "OTEL_SERVICE_NAME", "", "トレースのサービス名", func(cfg *Config) *string { return &cfg.Telemetry.ServiceName }),
+	ratioSetting("telemetry.sample-ratio", "HAM3_TRACE_SAMPLE_RATIO", "trace-sample-ratio", "親のないトレースを記録する割合（0〜1）", func(cfg *Config) *float64 { return &cfg.Telemetry.SampleRatio }),
+	stringSetting("telemetry.log-format", "HAM3_LOG_FORMAT", "log-format", "ログの形式（text/json）", func(cfg *Config) *string { return &cfg.Telemetry.LogFormat }),
+	stringSetting("telemetry.log-level", "HAM3_LOG_LEVEL", "log-level", "ログのレベル（debug/info/warn/error）", func(cfg *Config) *string { return &cfg.Telemetry.LogLevel }),
+
+	stringSetting("kubernetes.kubeconfig", "HAM3_KUBECONFIG", "kubeconfig", "kubeconfigのパス（空の場合はPod内ならServiceAccount、それ以外は~/.kube/config）", func(cfg *Config) *string { return &cfg.Kubernetes.Kubeconfig }),
+	stringSetting("kubernetes.opensearch-namespace", "OPENSEARCH_NAMESPACE", "", "OpenSearchをデプロイするNamespace", func(cfg *Config) *string { return &cfg.Kubernetes.OpenSearchNamespace }),
+
+	stringSetting("keystone.auth-endpoint", "OPENSTACK_AUTH_ENDPOINT", "", "KeystoneのURL", func(cfg *Config) *string { return &cfg.Keystone.AuthEndpoint }),
+	stringSetting("keystone.username", "OPENSTACK_USERNAME", "", "トークンの検証とCinderの操作に使うユーザー", func(cfg *Config) *string { return &cfg.Keystone.Username }),
+	stringSetting("keystone.password", "OPENSTACK_PASSWORD", "", "ユーザーのパスワード", func(cfg *Config) *string { return &cfg.Keystone.Password }),
+	stringSetting("keystone.project-name", "OPENSTACK_PROJECT_NAME", "", "ユーザーのプロジェクト", func(cfg *Config) *string { return &cfg.Keystone.ProjectName }),
+	stringSetting("keystone.domain-name", "OPENSTACK_DOMAIN_NAME", "", "ユーザーとプロジェクトのドメイン", func(cfg *Config) *string { return &cfg.Keystone.DomainName }),
+	stringSetting("keystone.region", "OPENSTACK_REGION", "", "Keystone/Cinder/Novaのリージョン", func(cfg *Config) *string { return &cfg.Keystone.Region }),
+	stringSetting("keystone.admin-role", "OPENSTACK_ADMIN_ROLE", "", "全プロジェクトを操作できる管理者のロール名", func(cfg *Config) *string { return &cfg.Keystone.AdminRole }),
+
+	stringSetting("database.driver", "DB_DRIVER", "", "sqlite / postgres", func(cfg *Config) *string { return &cfg.Database.Driver }),
+	stringSetting("database.dsn", "DB_DSN", "", "DBの接続先（sqliteのデフォルトはham3.db）", func(cfg *Config) *string { return &cfg.Database.Dsn }),
+	intSetting("database.max-open-conns", "DB_MAX_OPEN_CONNS", "", "最大接続数", func(cfg *Config) *int { return &cfg.Database.MaxOpenConns }),
+	intSetting("database.max-idle-conns", "DB_MAX_IDLE_CONNS", "", "最大アイドル接続数", func(cfg *Config) *int { return &cfg.Database.MaxIdleConns }),
+	durationSetting("database.conn-max-lifetime", "DB_CONN_MAX_LIFETIME", "", "接続の最大利用時間", func(cfg *Config) *time.Duration { return &cfg.Database.ConnMaxLifetime }),
+	boolSetting("database.auto-migrate", "DB_AUTO_MIGRATE", "", "起動時にマイグレーションを適用するか（sqliteのデフォルトはtrue、postgresはfalse）", func(cfg *Config) *bool { return &cfg.Database.AutoMigrate }),
+
+	boolSetting("helm.wait", "HELM_WAIT", "", "Readyになるまで待つか", func(cfg *Config) *bool { return &cfg.Helm.Wait }),
+	durationSetting("helm.timeout", "HELM_TIMEOUT", "", "Readyになるまでの待ち時間", func(cfg *Config) *time.Duration { return &cfg.Helm.Timeout }),
+	boolSetting("helm.atomic", "HELM_ATOMIC", "", "失敗した場合にロールバックするか", func(cfg *Config) *bool { return &cfg.Helm.Atomic }),
+	stringSetting("helm.opensearch-chart-source", "OPENSEARCH_CHART_SOURCE", "", "OpenSearchのチャートの取得元（空の場合は公開リポジトリ）", func(cfg *Config) *string { return &cfg.Helm.OpenSearchChartSource }),
+	stringSetting("helm.opensearch-dashboards-chart-source", "OPENSEARCH_DASHBOARDS_CHART_SOURCE", "", "OpenSearch Dashboardsのチャートの取得元", func(cfg *Config) *string { return &cfg.Helm.OpenSearchDashboardsChartSource }),
+	stringSetting("helm.awx-operator-chart-source", "AWX_OPERATOR_CHART_SOURCE", "", "AWX Operatorのチャートの取得元", func(cfg *Config) *string { return &cfg.Helm.AwxOperatorChartSource }),
+
+	durationSetting("opensearch.health-timeout", "OPENSEARCH_HEALTH_TIMEOUT", "", "green/yellowになるまで待つ時間", func(cfg *Config) *time.Duration { return &cfg.OpenSearch.HealthTimeout }),
+	durationSetting("opensearch.health-interval", "OPENSEARCH_HEALTH_INTERVAL", "", "クラスタの状態の確認の間隔", func(cfg *Config) *time.Duration { return &cfg.OpenSearch.HealthInterval }),
+	stringSetting("opensearch.admin-user", "OPENSEARCH_ADMIN_USER", "", "認証情報のSecretがないLOGaaSのAPIのユーザー", func(cfg *Config) *string { return &cfg.OpenSearch.Username }),
+	stringSetting("opensearch.admin-password", "OPENSEARCH_ADMIN_PASSWORD", "", "認証情報のSecretがないLOGaaSのAPIのパスワード", func(cfg *Config) *string { return &cfg.OpenSearch.Password }),
+	boolSetting("opensearch.insecure-skip-verify", "OPENSEARCH_INSECURE_SKIP_VERIFY", "", "APIの証明書を検証しない", func(cfg *Config) *bool { return &cfg.OpenSearch.InsecureSkipVerify }),
+
+	durationSetting("reconcile.interval", "RECONCILE_INTERVAL", "", "全件チェックの間隔（0で無効）", func(cfg *Config) *time.Duration { return &cfg.Reconcile.Interval }),
+	boolSetting("reconcile.repair", "RECONCILE_REPAIR", "", "CaaSのリソースを再作成するか", func(cfg *Config) *bool { return &cfg.Reconcile.Repair }),
+
+	intSetting("jobs.workers", "JOB_WORKERS", "", "ジョブのワーカー数", func(cfg *Config) *int { return &cfg.Jobs.Workers }),
+	intSetting("jobs.queue-size", "JOB_QUEUE_SIZE", "", "実行待ちのジョブの上限", func(cfg *Config) *int { return &cfg.Jobs.QueueSize }),
+	durationSetting("jobs.drain-timeout", "JOB_DRAIN_TIMEOUT", "", "停止時に実行中のジョブの完了を待つ時間", func(cfg *Config) *time.Duration { return &cfg.Jobs.DrainTimeout }),
+
+	stringSetting("logaas.http-proxy-url", "HTTP_PROXY_URL", "", "OpenSearchが使うHTTPプロキシのホスト", func(cfg *Config) *string { return &cfg.Logaas.HttpProxyUrl }),
+	stringSetting("logaas.http-proxy-port", "HTTP_PROXY_PORT", "", "OpenSearchが使うHTTPプロキシのポート", func(cfg *Config) *string { return &cfg.Logaas.HttpProxyPort }),
+	listSetting("logaas.disk-types", "LOGAAS_DISK_TYPES", "", "指定できるCinderのボリュームタイプ（カンマ区切り）", func(cfg *Config) *[]string { return &cfg.Logaas.DiskTypes }),
+	listSetting("logaas.zones", "LOGAAS_ZONES", "", "指定できるAZ（カンマ区切り）", func(cfg *Config) *[]string { return &cfg.Logaas.Zones }),
+
+	stringSetting("defaults.base-domain", "BASE_DOMAIN", "", "base-domainのデフォルト値", func(cfg *Config) *string { return &cfg.Defaults.BaseDomain }),
+	stringSetting("defaults.k8s-name", "KUBE_NAME", "", "k8s-nameのデフォルト値", func(cfg *Config) *string { return &cfg.Defaults.K8sName }),
+	stringSetting("defaults.site", "SITE", "", "siteのデフォルト値", func(cfg *Config) *string { return &cfg.Defaults.Site }),
+	stringSetting("defaults.ocp-cluster", "OCP_CLUSTER", "", "ocp-clusterのデフォルト値", func(cfg *Config) *string { return &cfg.Defaults.OcpCluster }),
+
+	stringSetting("flavor-file", "FLAVOR_FILE", "flavor-file", "Flavorファイルのパス", func(cfg *Config) *string { return &cfg.FlavorFile }),
+}
+
+// デフォルト値の設定
+func DefaultConfig() *Config {
+	return &Config{
+		Server:     DefaultServerConfig(),
+		Telemetry:  DefaultTelemetryConfig(),
+		Kubernetes: KubernetesConfig{OpenSearchNamespace: DefaultOpenSearchNamespace},
+		Keystone:   KeystoneConfig{DomainName: DefaultKeystoneDomainName, AdminRole: DefaultKeystoneAdminRole},
+		Database:   DatabaseConfig{Driver: DbDriverSqlite},
+		Helm:       HelmConfig{Wait: true, Timeout: DefaultHelmTimeout, Atomic: true},
+		OpenSearch: OpenSearchClientConfig{
+			HealthTimeout:  DefaultOpenSearchHealthTimeout,
+			HealthInterval: DefaultOpenSearchHealthInterval,
+			Username:       "admin",
+			Password:       "admin",
+		},
+		Reconcile:  ReconcileConfig{Interval: DefaultReconcileInterval},
+		Jobs:       JobsConfig{Workers: DefaultJobWorkers, QueueSize: DefaultJobQueueSize, DrainTimeout: DefaultJobDrainTimeout},
+		Logaas:     DefaultLogaasConfig(),
+		FlavorFile: DefaultFlavorFile,
+	}
+}
+
+// 設定ファイル（-configまたはHAM3_CONFIG）、環境変数、argsのフラグから設定を読み込む
+// 値の形式が不正な場合はエラーを返す（必須項目などの確認はValidateで行う）
+// -hが指定された場合はflag.ErrHelpを返す
+func Load(args []string) (*Config, error) {
+	fs := flag.NewFlagSet("ham3", flag.ContinueOnError)
+	configFile := fs.String(ConfigFileFlag, os.Getenv(ConfigFileEnv), fmt.Sprintf("設定ファイル(YAML)のパス (env %s)", ConfigFileEnv))
+	flagValues := map[string]*string{}
+	for _, s := range settings {
+		if s.flag != "" {
+			flagValues[s.flag] = fs.String(s.flag, "", fmt.Sprintf("%s (env %s)", s.usage, s.env))
+		}
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() > 0 {
+		return nil, fmt.Errorf("Unexpected arguments: %s", strings.Join(fs.Args(), " "))
+	}
+
+	cfg := DefaultConfig()
+	// 明示的に指定された項目（デフォルト値がほかの項目によって変わる項目の判定に使う）
+	specified := map[string]bool{}
+
+	if *configFile != "" {
+		values, err := readConfigFile(*configFile)
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range settings {
+			if value, ok := values[s.key]; ok {
+				if err := s.set(cfg, value); err != nil {
+					return nil, fmt.Errorf("Invalid %s in %s: %v", s.key, *configFile, err)
+				}
+				specified[s.key] = true
+				delete(values, s.key)
+			}
+		}
+		if len(values) > 0 {
+			keys := make([]string, 0, len(values))
+			for key := range values {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			return nil, fmt.Errorf("Unknown keys in %s: %s", *configFile, strings.Join(keys, ", "))
+		}
+	}
+
+	for _, s := range settings {
+		if value, ok := os.LookupEnv(s.env); ok && value != "" {
+			if err := s.set(cfg, value); err != nil {
+				return nil, fmt.Errorf("Invalid %s: %v", s.env, err)
+			}
+			specified[s.key] = true
+		}
+	}
+
+	var flagErr error
+	fs.Visit(func(f *flag.Flag) {
+		for _, s := range settings {
+			if s.flag == f.Name && flagErr == nil {
+				if err := s.set(cfg, *flagValues[f.Name]); err != nil {
+					flagErr = fmt.Errorf("Invalid -%s: %v", f.Name, err)
+				}
+				specified[s.key] = true
+			}
+		}
+	})
+	if flagErr != nil {
+		return nil, flagErr
+	}
+
+	// sqliteの場合のみ、DBの接続先とマイグレーションの適用にデフォルト値がある
+	if cfg.Database.Driver == DbDriverSqlite && cfg.Database.Dsn == "" {
+		cfg.Database.Dsn = DefaultSqliteDsn
+	}
+	if !specified["database.auto-migrate"] {
+		// 本番(PostgreSQL)ではham3 migrateで明示的に適用する
+		cfg.Database.AutoMigrate = cfg.Database.Driver == DbDriverSqlite
+	}
+	if cfg.Helm.Atomic {
+		cfg.Helm.Wait = true
+	}
+	return cfg, nil
+}
+
+// すべての設定を確認し、問題をまとめて返す
+func (cfg *Config) Validate() error {
+	errs := []error{
+		cfg.Server.Validate(),
+		cfg.Telemetry.Validate(),
+		cfg.Kubernetes.Validate(),
+		cfg.Keystone.Validate(),
+		cfg.Database.Validate(),
+		cfg.Helm.Validate(),
+		cfg.OpenSearch.Validate(),
+		cfg.Jobs.Validate(),
+		cfg.Logaas.Validate(),
+		cfg.Defaults.Validate(),
+	}
+	if cfg.FlavorFile == "" {
+		errs = append(errs, errors.New("flavor-file is required"))
+	}
+	return errors.Join(errs...)
+}
+
+// 設定ファイルを読み込み、"<セクション>.<項目>"をキーとした値を返す
+func readConfigFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read config file %s: %v", path, err)
+	}
+	var file map[string]interface{}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("Failed to parse config file %s: %v", path, err)
+	}
+
+	values := map[string]string{}
+	for name, value := range file {
+		if section, ok := value.(map[string]interface{}); ok {
+			for item, v := range section {
+				if values[name+"."+item], err = configFileValue(v); err != nil {
+					return nil, fmt.Errorf("Invalid %s.%s in %s: %v", name, item, path, err)
+				}
+			}
+			continue
+		}
+		if values[name], err = configFileValue(value); err != nil {
+			return nil, fmt.Errorf("Invalid %s in %s: %v", name, path, err)
+		}
+	}
+	return values, nil
+}
+
+// 設定ファイルの値を環境変数と同じ文字列の形式にする
+func configFileValue(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case nil:
+		return "", nil
+	case []interface{}:
+		// リストの項目はカンマ区切りにする
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			s, err := configFileValue(item)
+			if err != nil {
+				return "", err
+			}
+			items = append(items, s)
+		}
+		return strings.Join(items, ","), nil
+	default:
+		return "", fmt.Errorf("must be a string, number, boolean or list")
+	}
+}
+
+func stringSetting(key, env, flag, usage string, field func(*Config) *string) setting {
+	return setting{key: key, env: env, flag: flag, usage: usage, set: func(cfg *Config, value string) error {
+		*field(cfg) = value
+		return nil
+	}}
+}
+
+// カンマ区切りのリスト（空の項目は無視する）
+func listSetting(key, env, flag, usage string, field func(*Config) *[]string) setting {
+	return setting{key: key, env: env, flag: flag, usage: usage, set: func(cfg *Config, value string) error {
+		var list []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		*field(cfg) = list
+		return nil
+	}}
+}
+
+func boolSetting(key, env, flag, usage string, field func(*Config) *bool) setting {
+	return setting{key: key, env: env, flag: flag, usage: usage, set: func(cfg *Config, value string) error {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%q is not a boolean", value)
+		}
+		*field(cfg) = b
+		return nil
+	}}
+}
+
+func intSetting(key, env, flag, usage string, field func(*Config) *int) setting {
+	return setting{key: key, env: env, flag: flag, usage: usage, set: func(cfg *Config, value string) error {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return fmt.Errorf("%q must be a non-negative integer", value)
+		}
+		*field(cfg) = n
+		return nil
+	}}
+}
+
+func ratioSetting(key, env, flag, usage string, field func(*Config) *float64) setting {
+	return setting{key: key, env: env, flag: flag, usage: usage, set: func(cfg *Config, value string) error {
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil || f < 0 || f > 1 {
+			return fmt.Errorf("%q must be a number between 0 and 1", value)
+		}
+		*field(cfg) = f
+		return nil
+	}}
+}
+
+func durationSetting(key, env, flag, usage string, field func(*Config) *time.Duration) setting {
+	return setting{key: key, env: env, flag: flag, usage: usage, set: func(cfg *Config, value string) error {
+		d, err := time.ParseDuration(value)
+		if err != nil || d < 0 {
+			return fmt.Errorf("%q must be a non-negative duration (e.g. 30s)", value)
+		}
+		*field(cfg) = d
+		return nil
+	}}
+}
@@ -0,0 +1,45 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// 対応しているDB
+const (
+	DbDriverSqlite   = "sqlite"
+	DbDriverPostgres = "postgres"
+)
+
+// SQLiteのデフォルトのファイル（ローカルでの開発用）
+const DefaultSqliteDsn = "ham3.db"
+
+// DB接続の設定
+type DatabaseConfig struct {
+	// sqlite または postgres
+	Driver string
+	// SQLiteの場合はファイルのパス、PostgreSQLの場合は "host=... user=... dbname=..." 形式またはURL
+	Dsn string
+
+	// コネクションプール（0の場合はdatabase/sqlのデフォルト）
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+
+	// 起動時に未適用のマイグレーションを適用するか（falseの場合は未適用があると起動しない）
+	AutoMigrate bool
+}
+
+// DB接続の設定を確認する
+func (cfg DatabaseConfig) Validate() error {
+	switch cfg.Driver {
+	case DbDriverSqlite:
+	case DbDriverPostgres:
+		if cfg.Dsn == "" {
+			return fmt.Errorf("database.dsn is required for %s", cfg.Driver)
+		}
+	default:
+		return fmt.Errorf("Unsupported database.driver %q (supported: %s, %s)", cfg.Driver, DbDriverSqlite, DbDriverPostgres)
+	}
+	return nil
+}
@@ -0,0 +1,25 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// LOGaaS/AAPaaSのリクエストで省略した場合のデフォルト値（空の場合はリクエストで指定する必要がある）
+type DefaultsConfig struct {
+	// Ingress/Routeのホストのドメイン
+	BaseDomain string
+	K8sName    string
+	Site       string
+	// Cinderのボリュームを作成するOpenShiftのクラスタ（削除漏れのボリュームの検索にも使う）
+	OcpCluster string
+}
+
+var baseDomainPattern = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*$`)
+
+func (cfg DefaultsConfig) Validate() error {
+	if cfg.BaseDomain != "" && !baseDomainPattern.MatchString(cfg.BaseDomain) {
+		return fmt.Errorf("Invalid defaults.base-domain %q: must be a lowercase DNS name", cfg.BaseDomain)
+	}
+	return nil
+}
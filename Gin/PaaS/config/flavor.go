@@ -0,0 +1,113 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	"sigs.k8s.io/yaml"
+)
+
+// Flavorファイルのデフォルトのパス（flavor-file/FLAVOR_FILEで変更可能、JSONでも可）
+const DefaultFlavorFile = "config/flavors.yaml"
+
+// FlavorのCPUとメモリ
+type FlavorResources struct {
+	Cpu    string `json:"cpu"`
+	Memory string `json:"memory"`
+}
+
+// LOGaaSのノード(OpenSearch/OpenSearch Dashboards)のサイズ
+type Flavor struct {
+	Name     string          `json:"name"`
+	Requests FlavorResources `json:"requests"`
+	Limits   FlavorResources `json:"limits"`
+	JvmHeap  string          `json:"jvm_heap"`
+	JvmPerm  string          `json:"jvm_perm"`
+}
+
+// 起動時にLoadFlavorsで読み込んだFlavor（読み込み後は変更しない）
+var Flavors = map[string]Flavor{}
+
+// JVMのヒープサイズ(-Xms/-Xmx、-XX:MaxMetaspaceSizeに指定する値)
+var jvmSizePattern = regexp.MustCompile(`^[0-9]+[kKmMgG]$`)
+
+// Flavorファイルを読み込み、検証してからFlavorsに設定する
+func LoadFlavors(path string) error {
+	if path == "" {
+		path = DefaultFlavorFile
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("Failed to read flavor file %s: %v", path, err)
+	}
+
+	flavors := map[string]Flavor{}
+	if err := yaml.UnmarshalStrict(data, &flavors); err != nil {
+		return fmt.Errorf("Failed to parse flavor file %s: %v", path, err)
+	}
+	if len(flavors) == 0 {
+		return fmt.Errorf("No flavors defined in %s", path)
+	}
+
+	for name, flavor := range flavors {
+		if err := validateFlavor(flavor); err != nil {
+			return fmt.Errorf("Invalid flavor %s in %s: %v", name, path, err)
+		}
+		flavor.Name = name
+		flavors[name] = flavor
+	}
+
+	Flavors = flavors
+	return nil
+}
+
+// requestsがlimitsを超えていないこと、JVMのサイズが指定できる形式であることを確認
+func validateFlavor(flavor Flavor) error {
+	quantities := map[string]string{
+		"requests.cpu":    flavor.Requests.Cpu,
+		"requests.memory": flavor.Requests.Memory,
+		"limits.cpu":      flavor.Limits.Cpu,
+		"limits.memory":   flavor.Limits.Memory,
+	}
+	parsed := map[string]resource.Quantity{}
+	for key, value := range quantities {
+		quantity, err := resource.ParseQuantity(value)
+		if err != nil {
+			return fmt.Errorf("%s %q is not a valid quantity", key, value)
+		}
+		parsed[key] = quantity
+	}
+	for _, kind := range []string{"cpu", "memory"} {
+		requests, limits := parsed["requests."+kind], parsed["limits."+kind]
+		if requests.Cmp(limits) > 0 {
+			return fmt.Errorf("requests.%s exceeds limits.%s", kind, kind)
+		}
+	}
+	if !jvmSizePattern.MatchString(flavor.JvmHeap) {
+		return fmt.Errorf("jvm_heap %q must be a size like 512M or 4G", flavor.JvmHeap)
+	}
+	if !jvmSizePattern.MatchString(flavor.JvmPerm) {
+		return fmt.Errorf("jvm_perm %q must be a size like 128M or 256M", flavor.JvmPerm)
+	}
+	return nil
+}
+
+func GetFlavor(name string) (Flavor, bool) {
+	flavor, ok := Flavors[name]
+	return flavor, ok
+}
+
+// 名前順のFlavor一覧
+func FlavorList() []Flavor {
+	flavors := make([]Flavor, 0, len(Flavors))
+	for _, flavor := range Flavors {
+		flavors = append(flavors, flavor)
+	}
+	sort.Slice(flavors, func(i, j int) bool {
+		return flavors[i].Name < flavors[j].Name
+	})
+	return flavors
+}
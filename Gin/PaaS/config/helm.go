@@ -0,0 +1,95 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Helmのインストール/アップグレードのデフォルトのタイムアウト
+const DefaultHelmTimeout = 10 * time.Minute
+
+// Helmのインストール/アップグレードの設定
+type HelmConfig struct {
+	// リソースがetcdに登録されるだけでなく、Pod/PVCなどがReadyになるまで待つか
+	Wait bool
+	// Waitの待ち時間
+	Timeout time.Duration
+	// 失敗した場合にインストールしたリリースを削除（アップグレードは前のリビジョンにロールバック）するか
+	// trueの場合はWaitもtrueになる
+	Atomic bool
+	// チャートの取得元（空の場合は公開リポジトリ）
+	//
+	//	https://mirror.example.com/charts   チャートリポジトリ（社内ミラー）
+	//	oci://registry.example.com/charts/opensearch   OCIレジストリ
+	//	/opt/charts/opensearch   展開済みのチャートのディレクトリ
+	//	/opt/charts/opensearch-{version}.tgz   チャートのアーカイブ
+	OpenSearchChartSource           string
+	OpenSearchDashboardsChartSource string
+	AwxOperatorChartSource          string
+}
+
+func (cfg HelmConfig) Validate() error {
+	var errs []error
+	if cfg.Timeout <= 0 {
+		errs = append(errs, errors.New("helm.timeout must be positive"))
+	}
+	if _, err := cfg.ChartSources(); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// チャート名ごとの取得元（指定がないチャートは含まない）
+func (cfg HelmConfig) ChartSources() (map[string]ChartSource, error) {
+	sources := map[string]ChartSource{}
+	var errs []error
+	for _, s := range []struct{ key, chartName, value string }{
+		{"helm.opensearch-chart-source", "opensearch", cfg.OpenSearchChartSource},
+		{"helm.opensearch-dashboards-chart-source", "opensearch-dashboards", cfg.OpenSearchDashboardsChartSource},
+		{"helm.awx-operator-chart-source", "awx-operator", cfg.AwxOperatorChartSource},
+	} {
+		if s.value == "" {
+			continue
+		}
+		source, err := ParseChartSource(s.value)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("Invalid %s: %v", s.key, err))
+			continue
+		}
+		sources[s.chartName] = source
+	}
+	return sources, errors.Join(errs...)
+}
+
+// OpenSearchのクラスタの状態(_cluster/health)を確認する際のデフォルト値
+const (
+	DefaultOpenSearchHealthTimeout  = 15 * time.Minute
+	DefaultOpenSearchHealthInterval = 10 * time.Second
+)
+
+// OpenSearchのREST API（クラスタの状態の確認、スナップショットの操作）の設定
+type OpenSearchClientConfig struct {
+	// LOGaaSをreadyにする前にクラスタの状態がgreen/yellowになるまで待つ時間と確認の間隔
+	HealthTimeout  time.Duration
+	HealthInterval time.Duration
+	// 認証情報のSecretがない（認証情報を発行する前に作成した）LOGaaSのAPIのBasic認証のユーザー/パスワード
+	Username string
+	Password string
+	// Ingress/Routeの証明書を検証しない（自己署名証明書の環境向け）
+	InsecureSkipVerify bool
+}
+
+func (cfg OpenSearchClientConfig) Validate() error {
+	var errs []error
+	if cfg.HealthTimeout <= 0 {
+		errs = append(errs, errors.New("opensearch.health-timeout must be positive"))
+	}
+	if cfg.HealthInterval <= 0 {
+		errs = append(errs, errors.New("opensearch.health-interval must be positive"))
+	}
+	if cfg.Username == "" || cfg.Password == "" {
+		errs = append(errs, errors.New("opensearch.admin-user and opensearch.admin-password are required"))
+	}
+	return errors.Join(errs...)
+}
@@ -1,166 +1,47 @@
 package config
 
 import (
-	"os"
+	"errors"
+	"strconv"
 )
 
-var (
-	HttpProxyUrl  = os.Getenv("HTTP_PROXY_URL")
-	HttpProxyPort = os.Getenv("HTTP_PROXY_PORT")
+// LOGaaSの作成の設定
+type LogaasConfig struct {
+	// OpenSearchのJVMが外部（スナップショットのS3など）への接続に使うHTTPプロキシのホストとポート
+	HttpProxyUrl  string
+	HttpProxyPort string
+	// LOGaaSで指定できるCinderのボリュームタイプとAZ
+	DiskTypes []string
+	Zones     []string
+}
+
+// LOGaaSのscale-sizeとdata-disk-size(GiB)の上限
+const (
+	LogaasMaxScaleSize    = 20
+	LogaasMaxDataDiskSize = 1000
 )
 
-// RequestData is a struct that represents the request data for LOGaaS.
-type LogaasRequestData struct {
-	ClusterType                 string `json:"cluster-type"`
-	OpenSearchVersion           string `json:"opensearch-version"`
-	OpenSearchDashboardsVersion string `json:"opensearch-dashboards-version"`
-	ScaleSize                   int    `json:"scale-size"`
-	BaseDomain                  string `json:"base-domain"`
-	K8sName                     string `json:"k8s-name"`
-	MasterFlavor                string `json:"master-flavor"`
-	ClientFlavor                string `json:"client-flavor"`
-	DataFlavor                  string `json:"data-flavor"`
-	GuiFlavor                   string `json:"gui-flavor"`
-	DataDiskSize                int    `json:"data-disk-size"`
-	DiskType                    string `json:"disk-type-ham3"`
-	Site                        string `json:"site"`
-	Zone                        string `json:"zone"`
-	OcpCluster                  string `json:"ocp-cluster"`
+func DefaultLogaasConfig() LogaasConfig {
+	return LogaasConfig{DiskTypes: []string{"economy-medium"}, Zones: []string{"az-a"}}
 }
 
-var Flavors = map[string]interface{}{
-	"m1.tiny": map[string]interface{}{
-		"requests": map[string]string{
-			"cpu":    "125m",
-			"memory": "640Mi",
-		},
-		"limits": map[string]string{
-			"cpu":    "500m",
-			"memory": "1Gi",
-		},
-		"jvm_heap": "512M",
-		"jvm_perm": "128M",
-	},
-	"m1.small": map[string]interface{}{
-		"requests": map[string]string{
-			"cpu":    "250m",
-			"memory": "1280Mi",
-		},
-		"limits": map[string]string{
-			"cpu":    "1000m",
-			"memory": "2Gi",
-		},
-		"jvm_heap": "1G",
-		"jvm_perm": "256M",
-	},
-	"m1.medium": map[string]interface{}{
-		"requests": map[string]string{
-			"cpu":    "500m",
-			"memory": "4352Mi",
-		},
-		"limits": map[string]string{
-			"cpu":    "2000m",
-			"memory": "8Gi",
-		},
-		"jvm_heap": "4G",
-		"jvm_perm": "256M",
-	},
-	"m1.large": map[string]interface{}{
-		"requests": map[string]string{
-			"cpu":    "1000m",
-			"memory": "8448Mi",
-		},
-		"limits": map[string]string{
-			"cpu":    "4000m",
-			"memory": "16Gi",
-		},
-		"jvm_heap": "8G",
-		"jvm_perm": "256M",
-	},
-	"m1.xlarge": map[string]interface{}{
-		"requests": map[string]string{
-			"cpu":    "2000m",
-			"memory": "16640Mi",
-		},
-		"limits": map[string]string{
-			"cpu":    "8000m",
-			"memory": "32Gi",
-		},
-		"jvm_heap": "16G",
-		"jvm_perm": "256M",
-	},
-	"d1.tiny": map[string]interface{}{
-		"requests": map[string]string{
-			"cpu":    "125m",
-			"memory": "1Gi",
-		},
-		"limits": map[string]string{
-			"cpu":    "500m",
-			"memory": "1Gi",
-		},
-		"jvm_heap": "512M",
-		"jvm_perm": "128M",
-	},
-	"d1.small": map[string]interface{}{
-		"requests": map[string]string{
-			"cpu":    "250m",
-			"memory": "2Gi",
-		},
-		"limits": map[string]string{
-			"cpu":    "1000m",
-			"memory": "2Gi",
-		},
-		"jvm_heap": "1G",
-		"jvm_perm": "256M",
-	},
-	"d1.medium": map[string]interface{}{
-		"requests": map[string]string{
-			"cpu":    "500m",
-			"memory": "8Gi",
-		},
-		"limits": map[string]string{
-			"cpu":    "2000m",
-			"memory": "8Gi",
-		},
-		"jvm_heap": "4G",
-		"jvm_perm": "256M",
-	},
-	"d1.large": map[string]interface{}{
-		"requests": map[string]string{
-			"cpu":    "1000m",
-			"memory": "16Gi",
-		},
-		"limits": map[string]string{
-			"cpu":    "4000m",
-			"memory": "16Gi",
-		},
-		"jvm_heap": "8G",
-		"jvm_perm": "256M",
-	},
-	"d1.mlarge": map[string]interface{}{
-		"requests": map[string]string{
-			"cpu":    "1000m",
-			"memory": "32Gi",
-		},
-		"limits": map[string]string{
-			"cpu":    "4000m",
-			"memory": "32Gi",
-		},
-		"jvm_heap": "16G",
-		"jvm_perm": "256M",
-	},
-	"d1.xlarge": map[string]interface{}{
-		"requests": map[string]string{
-			"cpu":    "2000m",
-			"memory": "32Gi",
-		},
-		"limits": map[string]string{
-			"cpu":    "8000m",
-			"memory": "32Gi",
-		},
-		"jvm_heap": "16G",
-		"jvm_perm": "256M",
-	},
+func (cfg LogaasConfig) Validate() error {
+	var errs []error
+	if (cfg.HttpProxyUrl == "") != (cfg.HttpProxyPort == "") {
+		errs = append(errs, errors.New("logaas.http-proxy-url and logaas.http-proxy-port must be specified together"))
+	}
+	if cfg.HttpProxyPort != "" {
+		if port, err := strconv.Atoi(cfg.HttpProxyPort); err != nil || port < 1 || port > 65535 {
+			errs = append(errs, errors.New("logaas.http-proxy-port must be a port number"))
+		}
+	}
+	if len(cfg.DiskTypes) == 0 {
+		errs = append(errs, errors.New("logaas.disk-types is required"))
+	}
+	if len(cfg.Zones) == 0 {
+		errs = append(errs, errors.New("logaas.zones is required"))
+	}
+	return errors.Join(errs...)
 }
 
 var Exporter = map[string]interface{}{
@@ -193,57 +74,197 @@ var HelmChartVersions = map[string]interface{}{
 	},
 }
 
+// opensearch.ymlのテンプレート（YAMLのためインデントはスペースのみ）
 var OpensearchYamlTmpl = `
-cluster.name: opensearch-cluster
-netwrok.host: 0.0.0.0
+cluster.name: {{ .ClusterName }}
+network.host: 0.0.0.0
 node:
-	processors: {{ .Nproc }}
-{{- if Contains .ExporterAivenVer .OpenSearchVer}}
+  processors: {{ .Nproc }}
+{{- if contains .ExporterAivenVer .OpenSearchVer }}
 prometheus.metric_name.prefix: "es_"
-{{- end}}
-{{- if eq .ClusterType "standard"}}
-plugins.security.ssl.http.enabled: false // standardのみ存在
-{{- end}} 
+{{- end }}
 plugins:
-	security:
-		ssl:
-			transport:
-				pemcert_filepath: esnode.pem
-				pemkey_filepath: esnode-key.pem
-				pemtrustedcas_filepath: root-ca.pem
-				enforce_hostname_verification: false
-			http:
-				enabled: false
-		allow_unsafe_democertificates: true
-		allow_default_init_securityindex: true
-		authcz:
-			admin_dn:
-				- CN=kirk,OU=client,O=client,L=test,C=de
-		audit.type: internal_opensearch
-		enable_snapshot_restore_privilege: true
-		check_snapshot_restore_write_privileges: true
-		restapi:
-			roles_enabled: ["all_access", "security_rest_api_access"]
-		system_indices:
-			enabled: true
-			indices:
-				[
-					".opendistro-alerting-config",
-					".opendistro-alerting-alert*",
-					".opendistro-anomaly-results*",
-					".opendistro-anomaly-detector*",
-					".opendistro-anomaly-checkpoints",
-					".opendistro-anomaly-detection-state",
-					".opendistro-reports-*",
-					".opendistro-notifications-*",
-					".opendistro-nootbooks",
-					".opendistro-asynchronous-search-response*",
-				]
+  security:
+    ssl:
+      transport:
+        pemcert_filepath: esnode.pem
+        pemkey_filepath: esnode-key.pem
+        pemtrustedcas_filepath: root-ca.pem
+        enforce_hostname_verification: false
+      http:
+        enabled: false
+    allow_unsafe_democertificates: true
+    allow_default_init_securityindex: true
+    authcz:
+      admin_dn:
+        - CN=kirk,OU=client,O=client,L=test,C=de
+    audit.type: internal_opensearch
+    enable_snapshot_restore_privilege: true
+    check_snapshot_restore_write_privileges: true
+    restapi:
+      roles_enabled: ["all_access", "security_rest_api_access"]
+    system_indices:
+      enabled: true
+      indices:
+        [
+          ".opendistro-alerting-config",
+          ".opendistro-alerting-alert*",
+          ".opendistro-anomaly-results*",
+          ".opendistro-anomaly-detector*",
+          ".opendistro-anomaly-checkpoints",
+          ".opendistro-anomaly-detection-state",
+          ".opendistro-reports-*",
+          ".opendistro-notifications-*",
+          ".opendistro-notebooks",
+          ".opendistro-asynchronous-search-response*",
+        ]
 `
 
+// securityConfigに設定するopensearch-securityの設定ファイル
 var ActionGroupsYaml = `
 ---
 _meta:
   type: "actiongroups"
-	config_version: 2
+  config_version: 2
+`
+
+var AuditYaml = `
+---
+_meta:
+  type: "audit"
+  config_version: 2
+config:
+  enabled: false
+`
+
+var ConfigYaml = `
+---
+_meta:
+  type: "config"
+  config_version: 2
+config:
+  dynamic:
+    http:
+      anonymous_auth_enabled: false
+    authc:
+      basic_internal_auth_domain:
+        description: "Authenticate via HTTP Basic against internal users database"
+        http_enabled: true
+        transport_enabled: true
+        order: 0
+        http_authenticator:
+          type: basic
+          challenge: true
+        authentication_backend:
+          type: intern
+`
+
+// 認証情報を発行する前に作成したLOGaaSのadminユーザーのパスワードのハッシュ（OpenSearchのデモ設定と同じ"admin"）
+const DemoAdminPasswordHash = "$2a$12$VcCDgh2NDk07JGN0rjGbM.Ad41qVR/YFJcgHp0UGns5JDymv..TOG"
+
+// 認証情報を発行する前に作成したLOGaaSのkibanaserverユーザーのパスワードのハッシュ（OpenSearchのデモ設定と同じ"kibanaserver"）
+const DemoDashboardsPasswordHash = "$2a$12$4AcgAt3xwOWadA5s5blL6ev39OXDNhmOesEoo33eZtrq2N0YrU3H."
+
+// OpenSearch Dashboardsのユーザーに付与するロール（.kibanaインデックスなどDashboardsのサーバーが使う権限）
+const DashboardsSecurityRole = "kibana_server"
+
+// テナントのユーザーに付与するバックエンドロール（ham3_tenantロールにマッピングする）
+const TenantBackendRole = "ham3_tenant"
+
+// LOGaaSごとに生成したadminユーザー（HAM3が使う）、テナントのユーザーとDashboardsのユーザーのパスワードのハッシュを埋め込む
+// adminユーザーはパスワードをREST APIで変更するため、reservedにしない
+// Dashboardsのユーザーにはロールマッピングを使わずkibana_serverロールを直接付与する
+var InternalUsersYamlTmpl = `
+---
+_meta:
+  type: "internalusers"
+  config_version: 2
+admin:
+  hash: "{{ .AdminPasswordHash }}"
+  reserved: false
+  backend_roles:
+    - "admin"
+  description: "Admin user"
+{{- if .User }}
+{{ .User }}:
+  hash: "{{ .PasswordHash }}"
+  reserved: false
+  backend_roles:
+    - "ham3_tenant"
+  description: "Tenant user"
+{{- end }}
+{{- if .DashboardsPasswordHash }}
+kibanaserver:
+  hash: "{{ .DashboardsPasswordHash }}"
+  reserved: false
+  opendistro_security_roles:
+    - "kibana_server"
+  description: "OpenSearch Dashboards user"
+{{- end }}
+`
+
+var NodesDnYaml = `
+---
+_meta:
+  type: "nodesdn"
+  config_version: 2
+`
+
+// テナントのユーザーはインデックスとDashboardsのglobalテナントを操作できる（セキュリティの設定は変更できない）
+var RolesYaml = `
+---
+_meta:
+  type: "roles"
+  config_version: 2
+ham3_tenant:
+  reserved: true
+  cluster_permissions:
+    - "cluster_composite_ops"
+    - "cluster_monitor"
+    - "cluster_manage_index_templates"
+  index_permissions:
+    - index_patterns:
+        - "*"
+      allowed_actions:
+        - "indices_all"
+  tenant_permissions:
+    - tenant_patterns:
+        - "global_tenant"
+      allowed_actions:
+        - "kibana_all_write"
+`
+
+var RolesMappingYaml = `
+---
+_meta:
+  type: "rolesmapping"
+  config_version: 2
+all_access:
+  reserved: false
+  backend_roles:
+    - "admin"
+ham3_tenant:
+  reserved: true
+  backend_roles:
+    - "ham3_tenant"
+own_index:
+  reserved: false
+  users:
+    - "*"
+`
+
+var TenantsYaml = `
+---
+_meta:
+  type: "tenants"
+  config_version: 2
+`
+
+var WhitelistYaml = `
+---
+_meta:
+  type: "whitelist"
+  config_version: 2
+config:
+  enabled: false
 `
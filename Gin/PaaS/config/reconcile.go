@@ -0,0 +1,14 @@
+package config
+
+import "time"
+
+// 定期的な差分チェックのデフォルトの間隔
+const DefaultReconcileInterval = 5 * time.Minute
+
+// DBとクラスタの差分チェックの設定
+type ReconcileConfig struct {
+	// 全件チェックの間隔（0の場合はReconcilerを起動しない）
+	Interval time.Duration
+	// CaaSのResourceQuota/LimitRange/RoleBindingがない場合に再作成するか
+	Repair bool
+}
@@ -0,0 +1,192 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"time"
+)
+
+// APIサーバーとメトリクスのサーバーの設定
+type ServerConfig struct {
+	// APIサーバーのアドレス（例: :8081）
+	Addr string
+	// Prometheusのメトリクス(/metrics)のアドレス（例: :9090）
+	MetricsAddr string
+
+	ReadHeaderTimeout time.Duration
+	ReadTimeout       time.Duration
+	// ジョブのイベント(Server-Sent Events)は長時間レスポンスを書き込むため、デフォルトは0（無効）
+	WriteTimeout time.Duration
+	IdleTimeout  time.Duration
+	// SIGTERMを受け取ってから処理中のリクエストの完了を待つ時間（経過したら接続を切断する）
+	ShutdownTimeout time.Duration
+}
+
+func DefaultServerConfig() ServerConfig {
+	return ServerConfig{
+		Addr:              ":8081",
+		MetricsAddr:       ":9090",
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       30 * time.Second,
+		IdleTimeout:       2 * time.Minute,
+		ShutdownTimeout:   30 * time.Second,
+	}
+}
+
+func (cfg ServerConfig) Validate() error {
+	var errs []error
+	for name, addr := range map[string]string{"server.listen": cfg.Addr, "server.metrics-listen": cfg.MetricsAddr} {
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			errs = append(errs, fmt.Errorf("Invalid %s %q: %v", name, addr, err))
+		}
+	}
+	if cfg.Addr == cfg.MetricsAddr {
+		errs = append(errs, fmt.Errorf("server.listen and server.metrics-listen must be different (%s)", cfg.Addr))
+	}
+	if cfg.ReadHeaderTimeout <= 0 {
+		errs = append(errs, errors.New("server.read-header-timeout must be positive"))
+	}
+	if cfg.ShutdownTimeout <= 0 {
+		errs = append(errs, errors.New("server.shutdown-timeout must be positive"))
+	}
+	return errors.Join(errs...)
+}
+
+// トレース・メトリクス・ログの設定
+type TelemetryConfig struct {
+	// OTLP/HTTPの送信先（host:port、空の場合は送信しない）
+	OtlpEndpoint string
+	OtlpInsecure bool
+	ServiceName  string
+	// 親のないリクエストのトレースを記録する割合（親がある場合は親の判定に従う）
+	SampleRatio float64
+	// ログの形式(text/json)とレベル(debug/info/warn/error)
+	LogFormat string
+	LogLevel  string
+}
+
+func DefaultTelemetryConfig() TelemetryConfig {
+	return TelemetryConfig{
+		OtlpEndpoint: "localhost:4318",
+		OtlpInsecure: true,
+		ServiceName:  "HAM3",
+		SampleRatio:  1,
+		LogFormat:    "text",
+		LogLevel:     "info",
+	}
+}
+
+func (cfg TelemetryConfig) Validate() error {
+	if cfg.OtlpEndpoint != "" {
+		if _, _, err := net.SplitHostPort(cfg.OtlpEndpoint); err != nil {
+			return fmt.Errorf("Invalid telemetry.otlp-endpoint %q: must be host:port", cfg.OtlpEndpoint)
+		}
+	}
+	if cfg.ServiceName == "" {
+		return errors.New("telemetry.service-name is required")
+	}
+	if cfg.SampleRatio < 0 || cfg.SampleRatio > 1 {
+		return fmt.Errorf("telemetry.sample-ratio must be between 0 and 1 (%v)", cfg.SampleRatio)
+	}
+	switch cfg.LogFormat {
+	case "text", "json":
+	default:
+		return fmt.Errorf("Invalid telemetry.log-format %q: must be text or json", cfg.LogFormat)
+	}
+	switch cfg.LogLevel {
+	case "debug", "info", "warn", "error":
+	default:
+		return fmt.Errorf("Invalid telemetry.log-level %q: must be debug, info, warn or error", cfg.LogLevel)
+	}
+	return nil
+}
+
+// OpenSearchをデプロイするデフォルトのNamespace
+const DefaultOpenSearchNamespace = "opensearch"
+
+// Kubernetesの接続先とLOGaaSのNamespace
+type KubernetesConfig struct {
+	// 空の場合はPod内ならServiceAccount、それ以外は~/.kube/config
+	Kubeconfig          string
+	OpenSearchNamespace string
+}
+
+func (cfg KubernetesConfig) Validate() error {
+	if cfg.OpenSearchNamespace == "" {
+		return errors.New("kubernetes.opensearch-namespace is required")
+	}
+	return nil
+}
+
+// Keystoneのユーザーとプロジェクトのデフォルトのドメインと管理者ロール
+const (
+	DefaultKeystoneDomainName = "Default"
+	DefaultKeystoneAdminRole  = "admin"
+)
+
+// トークンの検証とCinder/Novaの操作に使うKeystoneの設定
+type KeystoneConfig struct {
+	AuthEndpoint string
+	Username     string
+	Password     string
+	ProjectName  string
+	DomainName   string
+	Region       string
+	// このロール（名前の完全一致）を持つトークンは全プロジェクトを操作できる
+	AdminRole string
+}
+
+func (cfg KeystoneConfig) Validate() error {
+	var errs []error
+	required := []struct{ name, value string }{
+		{"keystone.auth-endpoint", cfg.AuthEndpoint},
+		{"keystone.username", cfg.Username},
+		{"keystone.password", cfg.Password},
+		{"keystone.project-name", cfg.ProjectName},
+		{"keystone.admin-role", cfg.AdminRole},
+	}
+	for _, r := range required {
+		if r.value == "" {
+			errs = append(errs, fmt.Errorf("%s is required", r.name))
+		}
+	}
+	if cfg.AuthEndpoint != "" {
+		if u, err := url.Parse(cfg.AuthEndpoint); err != nil || u.Scheme == "" || u.Host == "" {
+			errs = append(errs, fmt.Errorf("Invalid keystone.auth-endpoint %q: must be a URL", cfg.AuthEndpoint))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ジョブのワーカーのデフォルト値
+const (
+	DefaultJobWorkers   = 4
+	DefaultJobQueueSize = 100
+	// Helmのデフォルトのタイムアウト(10m)のインストールが完了するまで待つ
+	DefaultJobDrainTimeout = 11 * time.Minute
+)
+
+// ジョブのワーカーの設定
+type JobsConfig struct {
+	Workers   int
+	QueueSize int
+	// SIGTERMを受け取ってから実行中・実行待ちのジョブの完了を待つ時間（経過したらジョブをキャンセルする）
+	// PodのterminationGracePeriodSecondsはserver.shutdown-timeoutとこの時間の合計より長くする
+	DrainTimeout time.Duration
+}
+
+func (cfg JobsConfig) Validate() error {
+	var errs []error
+	if cfg.Workers <= 0 {
+		errs = append(errs, errors.New("jobs.workers must be positive"))
+	}
+	if cfg.QueueSize <= 0 {
+		errs = append(errs, errors.New("jobs.queue-size must be positive"))
+	}
+	if cfg.DrainTimeout <= 0 {
+		errs = append(errs, errors.New("jobs.drain-timeout must be positive"))
+	}
+	return errors.Join(errs...)
+}
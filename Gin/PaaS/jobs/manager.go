@@ -0,0 +1,287 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"ham3/models"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+// Shutdownの期限までに終わらなかったジョブをキャンセルしてから、ジョブの終了（失敗の記録）を待つ時間
+const cancelGracePeriod = 30 * time.Second
+
+var (
+	ErrQueueFull = errors.New("job queue is full")
+	ErrShutdown  = errors.New("job manager is shutting down")
+)
+
+// ジョブとして実行する処理（進捗はRecorderに記録する）
+type Task func(ctx context.Context, rec *Recorder) error
+
+type queuedJob struct {
+	ctx  context.Context
+	id   string
+	task Task
+	// メトリクスのラベル
+	resourceType string
+	action       string
+}
+
+// ジョブをDBに記録し、バックグラウンドのワーカーで実行する
+type Manager struct {
+	db    *gorm.DB
+	queue chan queuedJob
+	wg    sync.WaitGroup
+
+	// すべてのジョブのctxの親（Shutdownの期限が過ぎたらキャンセルする）
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu       sync.Mutex
+	closed   bool
+	watchers map[string][]chan struct{}
+}
+
+func NewManager(db *gorm.DB, workers, queueSize int) *Manager {
+	m := &Manager{
+		db:       db,
+		queue:    make(chan queuedJob, queueSize),
+		watchers: map[string][]chan struct{}{},
+	}
+	m.ctx, m.cancel = context.WithCancel(context.Background())
+
+	// 前回のプロセス終了時に未完了だったジョブと、そのジョブが処理中だったリソースは失敗扱いにする
+	if err := failInterruptedJobs(db); err != nil {
+		fmt.Printf("Error failing interrupted jobs: %v\n", err)
+	}
+
+	for i := 0; i < workers; i++ {
+		m.wg.Add(1)
+		go m.worker()
+	}
+	return m
+}
+
+// 処理中のステータスのままのリソースのテーブルとリソース名のカラム
+var interruptedResources = map[string]struct {
+	model  interface{}
+	column string
+}{
+	models.ResourceCaaS:   {&models.CaaS{}, "namespace"},
+	models.ResourceLOGaaS: {&models.LOGaaS{}, "cluster_name"},
+	models.ResourceAAPaaS: {&models.AAPaaS{}, "name"},
+}
+
+// 未完了のジョブを失敗にし、対象のリソースがcreating/updating/deletingのままの場合はfailedにする
+// （failedにしないと、再作成や更新がステータスのチェックで拒否され続ける）
+func failInterruptedJobs(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var interrupted []models.Job
+		if err := tx.Where("status IN ?", []string{models.JobPending, models.JobRunning}).Find(&interrupted).Error; err != nil {
+			return err
+		}
+		if len(interrupted) == 0 {
+			return nil
+		}
+
+		now := time.Now()
+		err := tx.Model(&models.Job{}).
+			Where("status IN ?", []string{models.JobPending, models.JobRunning}).
+			Updates(map[string]interface{}{"status": models.JobFailed, "error": "interrupted by server restart", "finished_at": now}).Error
+		if err != nil {
+			return err
+		}
+
+		inProgress := []string{models.StatusCreating, models.StatusUpdating, models.StatusDeleting}
+		for _, job := range interrupted {
+			resource, ok := interruptedResources[job.ResourceType]
+			if !ok {
+				continue
+			}
+			err := tx.Model(resource.model).
+				Where(resource.column+" = ? AND status IN ?", job.ResourceId, inProgress).
+				Update("status", models.StatusFailed).Error
+			if err != nil {
+				return err
+			}
+			// LOGaaSはOpenSearch Dashboardsのステータスも同じジョブで更新している
+			if job.ResourceType == models.ResourceLOGaaS {
+				err := tx.Model(&models.LOGaaS{}).
+					Where("cluster_name = ? AND gui_status IN ?", job.ResourceId, inProgress).
+					Update("gui_status", models.StatusFailed).Error
+				if err != nil {
+					return err
+				}
+			}
+			fmt.Printf("Failed interrupted job %s (%s %s %s)\n", job.ID, job.Action, job.ResourceType, job.ResourceId)
+		}
+		return nil
+	})
+}
+
+// ジョブをpendingでDBに登録してキューに追加する
+// ジョブのIDとステータスはこの関数内で設定する
+func (m *Manager) Submit(ctx context.Context, job *models.Job, task Task) error {
+	job.ID = uuid.NewString()
+	job.Status = models.JobPending
+	if err := m.db.Create(job).Error; err != nil {
+		return err
+	}
+
+	// リクエストのトレースは引き継ぐが、リクエストのキャンセルは引き継がない
+	jobCtx := trace.ContextWithSpanContext(m.ctx, trace.SpanContextFromContext(ctx))
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		m.finish(job.ID, ErrShutdown)
+		return ErrShutdown
+	}
+	select {
+	case m.queue <- queuedJob{ctx: jobCtx, id: job.ID, task: task, resourceType: job.ResourceType, action: job.Action}:
+		return nil
+	default:
+		m.finish(job.ID, ErrQueueFull)
+		return ErrQueueFull
+	}
+}
+
+// 新しいジョブの受付を停止し、実行中・キュー内のジョブの完了を待つ
+// ctxの期限までに終わらない場合はジョブをキャンセルし、失敗を記録するまで待ってからctxのエラーを返す
+func (m *Manager) Shutdown(ctx context.Context) error {
+	m.mu.Lock()
+	if !m.closed {
+		m.closed = true
+		close(m.queue)
+	}
+	m.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		m.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+	}
+
+	m.cancel()
+	select {
+	case <-done:
+	case <-time.After(cancelGracePeriod):
+	}
+	return ctx.Err()
+}
+
+// ジョブをステップ込みで取得する
+func (m *Manager) Get(id string) (*models.Job, error) {
+	var job models.Job
+	err := m.db.Preload("Steps", func(db *gorm.DB) *gorm.DB {
+		return db.Order("id")
+	}).Where("id = ?", id).First(&job).Error
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// 指定したリソースに未完了のジョブが存在するか
+func (m *Manager) Active(resourceType, resourceId string) (bool, error) {
+	var count int64
+	err := m.db.Model(&models.Job{}).
+		Where("resource_type = ? AND resource_id = ? AND status IN ?", resourceType, resourceId, []string{models.JobPending, models.JobRunning}).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// ジョブが更新されるたびに通知を受け取る
+// 戻り値の関数で通知の受け取りを終了する
+func (m *Manager) Watch(id string) (<-chan struct{}, func()) {
+	ch := make(chan struct{}, 1)
+
+	m.mu.Lock()
+	m.watchers[id] = append(m.watchers[id], ch)
+	m.mu.Unlock()
+
+	return ch, func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		watchers := m.watchers[id]
+		for i, w := range watchers {
+			if w == ch {
+				m.watchers[id] = append(watchers[:i], watchers[i+1:]...)
+				break
+			}
+		}
+		if len(m.watchers[id]) == 0 {
+			delete(m.watchers, id)
+		}
+	}
+}
+
+func (m *Manager) notify(id string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, ch := range m.watchers[id] {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (m *Manager) worker() {
+	defer m.wg.Done()
+	for q := range m.queue {
+		m.run(q)
+	}
+}
+
+func (m *Manager) run(q queuedJob) {
+	// Shutdownでキャンセルされた後に取り出したジョブは実行しない
+	if q.ctx.Err() != nil {
+		m.finish(q.id, ErrShutdown)
+		return
+	}
+
+	now := time.Now()
+	if err := m.db.Model(&models.Job{ID: q.id}).Updates(map[string]interface{}{"status": models.JobRunning, "started_at": now}).Error; err != nil {
+		fmt.Printf("Error updating job[%s]: %v\n", q.id, err)
+	}
+	m.notify(q.id)
+
+	rec := &Recorder{m: m, jobId: q.id, resourceType: q.resourceType, steps: map[string]recordedStep{}}
+	err := func() (err error) {
+		// タスク内でpanicしてもワーカーは停止させない
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("panic: %v", r)
+			}
+		}()
+		return q.task(q.ctx, rec)
+	}()
+	observeJob(q.resourceType, q.action, now, err)
+	m.finish(q.id, err)
+}
+
+func (m *Manager) finish(id string, err error) {
+	updates := map[string]interface{}{"status": models.JobSucceeded, "finished_at": time.Now()}
+	if err != nil {
+		updates["status"] = models.JobFailed
+		updates["error"] = err.Error()
+	}
+	if err := m.db.Model(&models.Job{ID: id}).Updates(updates).Error; err != nil {
+		fmt.Printf("Error updating job[%s]: %v\n", id, err)
+	}
+	m.notify(id)
+}
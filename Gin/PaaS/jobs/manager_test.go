@@ -0,0 +1,79 @@
+package jobs
+
+import (
+	"context"
+	"testing"
+
+	"ham3/models"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func newTestDb(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := models.MigrateUp(db, 0); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestNewManagerFailsInterruptedResources(t *testing.T) {
+	db := newTestDb(t)
+	rows := []interface{}{
+		&models.CaaS{ProjectId: "p", Namespace: "creating-caas", Status: models.StatusCreating},
+		&models.CaaS{ProjectId: "p", Namespace: "ready-caas", Status: models.StatusReady},
+		&models.LOGaaS{ProjectId: "p", ClusterName: "updating-logaas", ClusterType: "standard", Status: models.StatusUpdating, GuiStatus: models.StatusUpdating},
+		&models.AAPaaS{ProjectId: "p", Name: "deleting-aapaas", Namespace: "ns", Status: models.StatusDeleting},
+		&models.Job{ID: "1", ProjectId: "p", ResourceType: models.ResourceCaaS, ResourceId: "creating-caas", Action: "create", Status: models.JobRunning},
+		&models.Job{ID: "2", ProjectId: "p", ResourceType: models.ResourceLOGaaS, ResourceId: "updating-logaas", Action: "update", Status: models.JobPending},
+		&models.Job{ID: "3", ProjectId: "p", ResourceType: models.ResourceAAPaaS, ResourceId: "deleting-aapaas", Action: "delete", Status: models.JobRunning},
+		// 終了済みのジョブのリソースは変更しない
+		&models.Job{ID: "4", ProjectId: "p", ResourceType: models.ResourceCaaS, ResourceId: "ready-caas", Action: "create", Status: models.JobSucceeded},
+	}
+	for _, row := range rows {
+		if err := db.Create(row).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	m := NewManager(db, 1, 1)
+	defer m.Shutdown(context.Background())
+
+	var jobs []models.Job
+	db.Order("id").Find(&jobs)
+	for _, job := range jobs {
+		want := models.JobFailed
+		if job.ID == "4" {
+			want = models.JobSucceeded
+		}
+		if job.Status != want {
+			t.Errorf("job %s status = %s, want %s", job.ID, job.Status, want)
+		}
+	}
+
+	var caas models.CaaS
+	db.Where("namespace = ?", "creating-caas").First(&caas)
+	if caas.Status != models.StatusFailed {
+		t.Errorf("caas status = %s, want failed", caas.Status)
+	}
+	var readyCaas models.CaaS
+	db.Where("namespace = ?", "ready-caas").First(&readyCaas)
+	if readyCaas.Status != models.StatusReady {
+		t.Errorf("ready caas status = %s, want ready", readyCaas.Status)
+	}
+	var logaas models.LOGaaS
+	db.Where("cluster_name = ?", "updating-logaas").First(&logaas)
+	if logaas.Status != models.StatusFailed || logaas.GuiStatus != models.StatusFailed {
+		t.Errorf("logaas status = %s/%s, want failed/failed", logaas.Status, logaas.GuiStatus)
+	}
+	var aapaas models.AAPaaS
+	db.Where("name = ?", "deleting-aapaas").First(&aapaas)
+	if aapaas.Status != models.StatusFailed {
+		t.Errorf("aapaas status = %s, want failed", aapaas.Status)
+	}
+}
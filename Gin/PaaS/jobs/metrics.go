@@ -0,0 +1,54 @@
+package jobs
+
+import (
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// ジョブとステップの所要時間（Helmのインストールやボリュームの作成は数分かかる）
+var durationBuckets = []float64{0.1, 0.5, 1, 5, 10, 30, 60, 120, 300, 600, 1200}
+
+var (
+	jobDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "provisioning_job_duration_seconds",
+		Help:    "Duration of provisioning jobs from start to finish",
+		Buckets: durationBuckets,
+	},
+		[]string{"resource_type", "action", "outcome"},
+	)
+
+	stepDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "provisioning_step_duration_seconds",
+		Help:    "Duration of each step of provisioning jobs",
+		Buckets: durationBuckets,
+	},
+		[]string{"resource_type", "step", "outcome"},
+	)
+)
+
+func observeJob(resourceType, action string, startedAt time.Time, err error) {
+	jobDuration.WithLabelValues(resourceType, action, outcome(err)).Observe(time.Since(startedAt).Seconds())
+}
+
+func observeStep(resourceType, name string, startedAt time.Time, err error) {
+	stepDuration.WithLabelValues(resourceType, stepLabel(name), outcome(err)).Observe(time.Since(startedAt).Seconds())
+}
+
+// ステップ名の括弧内（ノードグループやリリース名）はラベルに含めない
+// 例: "Uninstall OpenSearch (logs-master)" -> "Uninstall OpenSearch"
+func stepLabel(name string) string {
+	if i := strings.Index(name, " ("); i > 0 {
+		return name[:i]
+	}
+	return name
+}
+
+func outcome(err error) string {
+	if err != nil {
+		return "failed"
+	}
+	return "succeeded"
+}
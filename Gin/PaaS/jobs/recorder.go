@@ -0,0 +1,75 @@
+package jobs
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"ham3/models"
+)
+
+// ジョブの各ステップの進捗をDBに記録する
+// utilities.Pipelineのオブザーバーとしても利用できる
+type Recorder struct {
+	m     *Manager
+	jobId string
+	// メトリクスのラベル
+	resourceType string
+
+	mu    sync.Mutex
+	steps map[string]recordedStep
+}
+
+type recordedStep struct {
+	// DBへの記録に失敗した場合は0
+	id        uint
+	startedAt time.Time
+}
+
+func (r *Recorder) StepStarted(name string) {
+	step := models.JobStep{
+		JobId:     r.jobId,
+		Name:      name,
+		Status:    models.StepRunning,
+		StartedAt: time.Now(),
+	}
+	if err := r.m.db.Create(&step).Error; err != nil {
+		fmt.Printf("Error recording step %q of job[%s]: %v\n", name, r.jobId, err)
+	}
+
+	r.mu.Lock()
+	r.steps[name] = recordedStep{id: step.ID, startedAt: step.StartedAt}
+	r.mu.Unlock()
+	r.m.notify(r.jobId)
+}
+
+func (r *Recorder) StepFinished(name string, err error) {
+	r.mu.Lock()
+	step, ok := r.steps[name]
+	r.mu.Unlock()
+	if !ok {
+		return
+	}
+	observeStep(r.resourceType, name, step.startedAt, err)
+	if step.id == 0 {
+		return
+	}
+
+	updates := map[string]interface{}{"status": models.StepSucceeded, "finished_at": time.Now()}
+	if err != nil {
+		updates["status"] = models.StepFailed
+		updates["error"] = err.Error()
+	}
+	if err := r.m.db.Model(&models.JobStep{ID: step.id}).Updates(updates).Error; err != nil {
+		fmt.Printf("Error recording step %q of job[%s]: %v\n", name, r.jobId, err)
+	}
+	r.m.notify(r.jobId)
+}
+
+// fnを1ステップとして実行し、結果を記録する
+func (r *Recorder) Step(name string, fn func() error) error {
+	r.StepStarted(name)
+	err := fn()
+	r.StepFinished(name, err)
+	return err
+}
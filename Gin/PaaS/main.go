@@ -1,15 +1,52 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
+	"ham3/config"
+	"ham3/middlewares"
 	"ham3/routers"
+	"ham3/telemetry"
+	"log"
+	"os"
 
 	"github.com/gin-gonic/gin"
 )
 
 func main() {
+	// 管理コマンド（サーバーは起動しない）
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		os.Exit(runMigrate(os.Args[2:]))
+	}
+
+	// 設定の読み込み（設定ファイル、環境変数、フラグ）と検証（不正な設定の場合は起動しない）
+	cfg, err := config.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	} else if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		log.Fatalf("Invalid config:\n%v", err)
+	}
+
+	// トレース・メトリクス・ログの設定（以降のログはtelemetry.log-format/log-levelに従う）
+	tel, err := telemetry.Setup(context.Background(), cfg.Telemetry)
+	if err != nil {
+		log.Fatalf("Failed to setup telemetry: %v", err)
+	}
+
 	router := gin.Default()
 
 	// middlewareの設定
+	// すべてのルートのリクエスト数、エラー、所要時間のメトリクス
+	router.Use(middlewares.Metrics())
+
+	// Flavorファイルの読み込み（不正な定義の場合は起動しない）
+	if err := config.LoadFlavors(cfg.FlavorFile); err != nil {
+		log.Fatalf("Failed to load flavors: %v", err)
+	}
 
 	// 静的ファイルの設定
 	router.Static("/static", "./static")
@@ -18,8 +55,10 @@ func main() {
 	router.LoadHTMLGlob("templates/*")
 
 	// ルーティングの設定
-	routers.SetupRouter(router)
+	res := routers.SetupRouter(router, cfg, tel)
 
-	// サーバーの起動
-	router.Run(":8081")
+	// サーバーの起動（SIGINT/SIGTERMで停止する）
+	if err := runServer(cfg, router, res); err != nil {
+		log.Fatalf("%v", err)
+	}
 }
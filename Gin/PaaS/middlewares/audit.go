@@ -0,0 +1,241 @@
+package middlewares
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"ham3/api"
+	"ham3/models"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+// 監査ログに記録するリクエストのボディの上限（超えた場合はサイズのみ記録する）
+const maxAuditPayloadSize = 64 * 1024
+
+// 失敗のメッセージとジョブIDを取得するために保持するレスポンスの上限
+const maxAuditResponseSize = 16 * 1024
+
+// 値を伏せ字にするキー（小文字にしたキーに含まれる場合）
+var auditSecretKeys = []string{"password", "secret", "token", "access-key", "access_key", "accesskey", "private", "credential"}
+
+const auditRedacted = "[REDACTED]"
+
+// リソースの操作を表すメソッドごとの動詞
+var auditVerbs = map[string]string{
+	http.MethodPost:   "create",
+	http.MethodPut:    "update",
+	http.MethodPatch:  "update",
+	http.MethodDelete: "delete",
+}
+
+// ハンドラーが書き込んだレスポンスの先頭を保持する
+type auditResponseWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *auditResponseWriter) Write(data []byte) (int, error) {
+	if room := maxAuditResponseSize - w.body.Len(); room > 0 {
+		w.body.Write(data[:min(len(data), room)])
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *auditResponseWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// POST/PUT/PATCH/DELETEのリクエストを監査ログ(audit_logs)に記録する
+// 認証に失敗したリクエストも記録するため、KeystoneAuthより前に使う
+func Audit(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := auditVerbs[c.Request.Method]; !ok {
+			c.Next()
+			return
+		}
+
+		payload := readAuditPayload(c)
+		writer := &auditResponseWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+
+		c.Next()
+
+		resourceType, resourceId := auditResource(c)
+		entry := models.AuditLog{
+			UserId:       GetUserId(c),
+			UserName:     GetUserName(c),
+			ProjectId:    GetProjectId(c),
+			ProjectName:  GetProjectName(c),
+			IsAdmin:      IsAdmin(c),
+			ClientIp:     c.ClientIP(),
+			Method:       c.Request.Method,
+			Route:        c.FullPath(),
+			Path:         c.Request.URL.Path,
+			Action:       auditAction(c),
+			ResourceType: resourceType,
+			ResourceId:   resourceId,
+			Payload:      payload,
+			StatusCode:   writer.Status(),
+			TraceId:      auditTraceId(c),
+		}
+
+		// 失敗した場合はエラーのレスポンス(api.ErrorResponse)のmessage、code、errorsを記録する
+		var response struct {
+			api.ErrorResponse
+			JobId string `json:"job_id"`
+		}
+		json.Unmarshal(writer.body.Bytes(), &response)
+		switch status := writer.Status(); {
+		case status == http.StatusAccepted:
+			entry.Outcome = models.AuditAccepted
+			entry.JobId = response.JobId
+		case status < 400:
+			entry.Outcome = models.AuditSucceeded
+		default:
+			entry.Outcome = models.AuditFailed
+			if status == http.StatusUnauthorized || status == http.StatusForbidden {
+				entry.Outcome = models.AuditDenied
+			}
+			entry.Error = response.Message
+			if entry.Error == "" {
+				entry.Error = http.StatusText(status)
+			}
+			entry.ErrorCode = string(response.Code)
+			if len(response.Errors) > 0 {
+				data, _ := json.Marshal(response.Errors)
+				entry.ErrorFields = string(data)
+			}
+		}
+
+		// 記録に失敗してもレスポンスは返しているため、ログに出力するのみ
+		if err := db.Create(&entry).Error; err != nil {
+			fmt.Printf("Error writing audit log (%s %s by %s/%s): %v\n", entry.Method, entry.Path, entry.ProjectId, entry.UserName, err)
+		}
+	}
+}
+
+// リクエストのボディを読み込み（ハンドラーでも読めるように戻す）、秘密の値を伏せ字にしたJSONを返す
+func readAuditPayload(c *gin.Context) string {
+	if c.Request.Body == nil {
+		return ""
+	}
+	data, err := io.ReadAll(io.LimitReader(c.Request.Body, maxAuditPayloadSize+1))
+	c.Request.Body = io.NopCloser(io.MultiReader(bytes.NewReader(data), c.Request.Body))
+	if err != nil {
+		return fmt.Sprintf("<failed to read body: %v>", err)
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return ""
+	}
+	if len(data) > maxAuditPayloadSize {
+		return fmt.Sprintf("<body larger than %d bytes, not recorded>", maxAuditPayloadSize)
+	}
+
+	var body interface{}
+	if err := json.Unmarshal(data, &body); err != nil {
+		// JSON以外は秘密の値を判別できないため記録しない
+		return fmt.Sprintf("<non-JSON body, %d bytes, not recorded>", len(data))
+	}
+	redacted, err := json.Marshal(redactAuditValue(body))
+	if err != nil {
+		return fmt.Sprintf("<failed to encode body: %v>", err)
+	}
+	return string(redacted)
+}
+
+// オブジェクトのキーが秘密の値を表す場合は伏せ字にする（ネストしたオブジェクト/配列も対象）
+func redactAuditValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			if isAuditSecretKey(key) {
+				v[key] = auditRedacted
+			} else {
+				v[key] = redactAuditValue(item)
+			}
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = redactAuditValue(item)
+		}
+	}
+	return value
+}
+
+func isAuditSecretKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, secret := range auditSecretKeys {
+		if strings.Contains(key, secret) {
+			return true
+		}
+	}
+	return false
+}
+
+// /api/v1/<リソースの種類>/<名前>/... の種類と名前
+func auditResource(c *gin.Context) (string, string) {
+	segments := auditRouteSegments(c)
+	if len(segments) == 0 {
+		return "", ""
+	}
+	resourceId := ""
+	if len(c.Params) > 0 {
+		resourceId = c.Params[0].Value
+	}
+	return segments[0], resourceId
+}
+
+// メソッドの動詞と、リソースの下位のパス（パラメータを除く）
+// 例: POST /api/v1/caas/:caas_id -> create、POST /api/v1/logaas/:logaas_id/snapshots/:snapshot/restore -> create snapshots/restore
+func auditAction(c *gin.Context) string {
+	verb := auditVerbs[c.Request.Method]
+	segments := auditRouteSegments(c)
+	if len(segments) <= 1 {
+		return verb
+	}
+	var sub []string
+	for _, segment := range segments[1:] {
+		if segment != "" && !strings.HasPrefix(segment, ":") && !strings.HasPrefix(segment, "*") {
+			sub = append(sub, segment)
+		}
+	}
+	if len(sub) == 0 {
+		return verb
+	}
+	return verb + " " + strings.Join(sub, "/")
+}
+
+// /api/v1以下のルートのパス（ルートがない場合はリクエストのパス）
+func auditRouteSegments(c *gin.Context) []string {
+	route := c.FullPath()
+	if route == "" {
+		route = c.Request.URL.Path
+	}
+	route = strings.Trim(strings.TrimPrefix(route, "/api/v1"), "/")
+	if route == "" {
+		return nil
+	}
+	return strings.Split(route, "/")
+}
+
+// リクエストのトレースID（スパンがない場合はtraceparentヘッダー）
+func auditTraceId(c *gin.Context) string {
+	spanContext := trace.SpanContextFromContext(c.Request.Context())
+	if !spanContext.IsValid() {
+		ctx := otel.GetTextMapPropagator().Extract(c.Request.Context(), propagation.HeaderCarrier(c.Request.Header))
+		spanContext = trace.SpanContextFromContext(ctx)
+	}
+	if !spanContext.IsValid() {
+		return ""
+	}
+	return spanContext.TraceID().String()
+}
@@ -0,0 +1,57 @@
+package middlewares
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"ham3/api"
+	"ham3/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestAuditRecordsErrorResponse(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := models.MigrateUp(db, 0); err != nil {
+		t.Fatal(err)
+	}
+	sqlDb, _ := db.DB()
+	defer sqlDb.Close()
+
+	fieldErrors := []api.FieldError{{Field: "scale-size", Message: "must be between 1 and 10"}, {Field: "zone", Message: "must be one of az-a"}}
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(Audit(db))
+	r.POST("/api/v1/logaas/:logaas_id", func(c *gin.Context) {
+		c.JSON(http.StatusBadRequest, api.ErrorResponse{Status: api.StatusError, Code: api.CodeValidationFailed, Message: "Invalid parameters", Errors: fieldErrors})
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/logaas/logs", strings.NewReader(`{"scale-size": 0, "password": "secret"}`))
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	var entry models.AuditLog
+	if err := db.First(&entry).Error; err != nil {
+		t.Fatal(err)
+	}
+	if entry.Outcome != models.AuditFailed || entry.Error != "Invalid parameters" || entry.ErrorCode != string(api.CodeValidationFailed) {
+		t.Errorf("outcome, error, error_code = %s, %q, %s", entry.Outcome, entry.Error, entry.ErrorCode)
+	}
+	var recorded []api.FieldError
+	if err := json.Unmarshal([]byte(entry.ErrorFields), &recorded); err != nil {
+		t.Fatalf("error_fields %q: %v", entry.ErrorFields, err)
+	}
+	if len(recorded) != len(fieldErrors) || recorded[0] != fieldErrors[0] || recorded[1] != fieldErrors[1] {
+		t.Errorf("error_fields = %v, want %v", recorded, fieldErrors)
+	}
+	if strings.Contains(entry.Payload, "secret") {
+		t.Errorf("payload %s contains the password", entry.Payload)
+	}
+}
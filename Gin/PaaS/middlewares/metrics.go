@@ -0,0 +1,67 @@
+package middlewares
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	httpRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "The total number of HTTP requests",
+	},
+		[]string{"method", "route", "status"},
+	)
+
+	httpRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "Duration of HTTP requests",
+		Buckets: prometheus.DefBuckets,
+	},
+		[]string{"method", "route", "status"},
+	)
+
+	httpRequestsInFlight = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "http_requests_in_flight",
+		Help: "The number of HTTP requests being served",
+	})
+)
+
+// ラベルに使うメソッド（それ以外はother）
+var metricsMethods = map[string]bool{
+	http.MethodGet: true, http.MethodHead: true, http.MethodPost: true, http.MethodPut: true,
+	http.MethodPatch: true, http.MethodDelete: true, http.MethodOptions: true,
+}
+
+// ルートにマッチしなかったリクエストのrouteラベル
+const unmatchedRoute = "unmatched"
+
+// リクエスト数、エラー(status)、所要時間を記録する
+// routeラベルはパスではなくルートの定義（例: /api/v1/caas/:caas_id）を使い、値の種類が増えないようにする
+func Metrics() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		httpRequestsInFlight.Inc()
+		defer httpRequestsInFlight.Dec()
+
+		c.Next()
+
+		method := c.Request.Method
+		if !metricsMethods[method] {
+			method = "other"
+		}
+		route := c.FullPath()
+		if route == "" {
+			route = unmatchedRoute
+		}
+		status := strconv.Itoa(c.Writer.Status())
+
+		httpRequestsTotal.WithLabelValues(method, route, status).Inc()
+		httpRequestDuration.WithLabelValues(method, route, status).Observe(time.Since(start).Seconds())
+	}
+}
@@ -0,0 +1,59 @@
+package middlewares
+
+import (
+	"fmt"
+	"sync"
+
+	"ham3/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DBに登録済みのプロジェクト（プロジェクトID→プロジェクト名）
+// 登録済みで名前が変わっていないプロジェクトはリクエストごとにDBを更新しない
+var registeredProjects = struct {
+	sync.Mutex
+	names map[string]string
+}{names: map[string]string{}}
+
+// トークンのプロジェクトをprojectsテーブルに登録する（すでに存在する場合はプロジェクト名を更新）
+// KeystoneAuthの後に使う
+func RegisterProject(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		projectId, projectName := GetProjectId(c), GetProjectName(c)
+		if projectId == "" {
+			c.Next()
+			return
+		}
+
+		registeredProjects.Lock()
+		name, ok := registeredProjects.names[projectId]
+		registeredProjects.Unlock()
+		if !ok || name != projectName {
+			project := models.Projects{ProjectId: projectId, ProjectName: projectName}
+			err := db.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "project_id"}},
+				DoUpdates: clause.AssignmentColumns([]string{"project_name"}),
+			}).Create(&project).Error
+			if err != nil {
+				// 登録に失敗してもリクエストは処理する（次のリクエストで再度登録する）
+				fmt.Printf("Error registering project[%s]: %v\n", projectId, err)
+			} else {
+				registeredProjects.Lock()
+				registeredProjects.names[projectId] = projectName
+				registeredProjects.Unlock()
+			}
+		}
+
+		c.Next()
+	}
+}
+
+// 削除したプロジェクトを登録済みから外す（同じプロジェクトのリクエストで再登録される）
+func ForgetProject(projectId string) {
+	registeredProjects.Lock()
+	delete(registeredProjects.names, projectId)
+	registeredProjects.Unlock()
+}
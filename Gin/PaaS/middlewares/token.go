@@ -1,24 +1,156 @@
 package middlewares
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
 	"net/http"
+	"sync"
+	"time"
+
+	"ham3/api"
+	"ham3/utilities"
 
 	"github.com/gin-gonic/gin"
 )
 
-const TokenKey = "X-Auth-Token"
+const (
+	TokenKey     = "X-Auth-Token"
+	ProjectIdKey = "X-Project-Id"
+)
+
+// gin.Contextに格納するキー
+const (
+	ProjectIdContextKey   = "project_id"
+	ProjectNameContextKey = "project_name"
+	UserIdContextKey      = "user_id"
+	UserNameContextKey    = "user_name"
+	IsAdminContextKey     = "is_admin"
+)
+
+// トークンの検証結果をキャッシュする期間
+var TokenCacheTTL = 5 * time.Minute
+
+// Keystoneでトークンを検証する関数
+var tokenAuth = utilities.TokenAuth
+
+type tokenCacheEntry struct {
+	info      utilities.TokenInfo
+	expiresAt time.Time
+}
+
+// トークン(のハッシュ値)ごとの検証結果
+var tokenCache = struct {
+	sync.Mutex
+	entries map[string]tokenCacheEntry
+}{entries: map[string]tokenCacheEntry{}}
+
+// トークンを検証し、トークンのプロジェクトとユーザーを返す
+// 検証に成功した結果のみTokenCacheTTLの間（トークンの有効期限の方が早い場合は有効期限まで）キャッシュする
+func validateToken(token string) (utilities.TokenInfo, error) {
+	sum := sha256.Sum256([]byte(token))
+	key := hex.EncodeToString(sum[:])
+	now := time.Now()
+
+	tokenCache.Lock()
+	entry, ok := tokenCache.entries[key]
+	tokenCache.Unlock()
+	if ok && now.Before(entry.expiresAt) {
+		return entry.info, nil
+	}
+
+	info, err := tokenAuth(token)
+	if err != nil {
+		return info, err
+	}
+
+	tokenCache.Lock()
+	defer tokenCache.Unlock()
+	// 期限切れのエントリを削除
+	for k, e := range tokenCache.entries {
+		if now.After(e.expiresAt) {
+			delete(tokenCache.entries, k)
+		}
+	}
+	expiresAt := now.Add(TokenCacheTTL)
+	if !info.ExpiresAt.IsZero() && info.ExpiresAt.Before(expiresAt) {
+		expiresAt = info.ExpiresAt
+	}
+	tokenCache.entries[key] = tokenCacheEntry{
+		info:      info,
+		expiresAt: expiresAt,
+	}
+
+	return info, nil
+}
 
-func CheckTokenExists() gin.HandlerFunc {
+// HeaderのTokenをKeystoneで検証し、プロジェクト、ユーザーとAdminなのかどうかをgin.Contextに格納する
+func KeystoneAuth() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		tokenValue := c.GetHeader(TokenKey)
 		if tokenValue == "" {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
-				"status":  "error",
-				"message": "Unauthorized",
-			})
+			c.AbortWithStatusJSON(http.StatusUnauthorized, api.NewError(http.StatusUnauthorized, "Unauthorized"))
 			return
 		}
-		// Tokenが存在する場合は次のミドルウェアを呼び出す
+
+		info, err := validateToken(tokenValue)
+		if err != nil {
+			fmt.Printf("Token validation failed: %v\n", err)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, api.NewError(http.StatusUnauthorized, "Unauthorized"))
+			return
+		}
+
+		c.Set(ProjectIdContextKey, info.ProjectId)
+		c.Set(ProjectNameContextKey, info.ProjectName)
+		c.Set(UserIdContextKey, info.UserId)
+		c.Set(UserNameContextKey, info.UserName)
+		c.Set(IsAdminContextKey, info.IsAdmin)
+
+		// Tokenが有効な場合は次のミドルウェアを呼び出す
 		c.Next()
 	}
 }
+
+// トークンのプロジェクトID
+func GetProjectId(c *gin.Context) string {
+	return c.GetString(ProjectIdContextKey)
+}
+
+// トークンのプロジェクト名
+func GetProjectName(c *gin.Context) string {
+	return c.GetString(ProjectNameContextKey)
+}
+
+// トークンのユーザーID
+func GetUserId(c *gin.Context) string {
+	return c.GetString(UserIdContextKey)
+}
+
+// トークンのユーザー名
+func GetUserName(c *gin.Context) string {
+	return c.GetString(UserNameContextKey)
+}
+
+// トークンにadminロールが含まれているか
+func IsAdmin(c *gin.Context) bool {
+	return c.GetBool(IsAdminContextKey)
+}
+
+// 管理者以外は自身のプロジェクトのリソースのみ操作可能
+// 操作できない場合は403を返してfalseを返す
+func AuthorizeProject(c *gin.Context, projectId string) bool {
+	if IsAdmin(c) || (projectId != "" && GetProjectId(c) == projectId) {
+		return true
+	}
+	c.AbortWithStatusJSON(http.StatusForbidden, api.NewError(http.StatusForbidden, "Forbidden"))
+	return false
+}
+
+// 操作対象のプロジェクトID
+// 管理者はHeaderで他のプロジェクトを指定可能、それ以外はトークンのプロジェクト
+func TargetProjectId(c *gin.Context) string {
+	if projectId := c.GetHeader(ProjectIdKey); projectId != "" && IsAdmin(c) {
+		return projectId
+	}
+	return GetProjectId(c)
+}
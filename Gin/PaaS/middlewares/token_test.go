@@ -0,0 +1,45 @@
+package middlewares
+
+import (
+	"testing"
+	"time"
+
+	"ham3/utilities"
+)
+
+// 有効期限がTokenCacheTTLより早いトークンは有効期限を過ぎるとKeystoneで再検証する
+func TestValidateTokenCacheExpiresWithToken(t *testing.T) {
+	calls := map[string]int{}
+	expiresAt := map[string]time.Time{
+		"expiring": time.Now().Add(50 * time.Millisecond),
+		"long":     time.Now().Add(time.Hour),
+	}
+	original := tokenAuth
+	tokenAuth = func(token string) (utilities.TokenInfo, error) {
+		calls[token]++
+		return utilities.TokenInfo{ProjectId: "project-1", ExpiresAt: expiresAt[token]}, nil
+	}
+	t.Cleanup(func() { tokenAuth = original })
+
+	for _, token := range []string{"expiring", "long"} {
+		if _, err := validateToken(token); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := validateToken(token); err != nil {
+			t.Fatal(err)
+		}
+	}
+	time.Sleep(100 * time.Millisecond)
+	for _, token := range []string{"expiring", "long"} {
+		if _, err := validateToken(token); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if calls["expiring"] != 2 {
+		t.Errorf("expiring token validated %d times, want 2 (cached until its expiry)", calls["expiring"])
+	}
+	if calls["long"] != 1 {
+		t.Errorf("long-lived token validated %d times, want 1 (cached for TokenCacheTTL)", calls["long"])
+	}
+}
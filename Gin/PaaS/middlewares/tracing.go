@@ -0,0 +1,53 @@
+package middlewares
+
+import (
+	"log/slog"
+	"net/http"
+
+	"ham3/telemetry"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// リクエストをサーバーのスパン（"<メソッド> <ルート>"）にする
+// traceparentヘッダーがある場合は呼び出し元のトレースを引き継ぎ、c.Request.Context()をスパンのctxにする
+// ハンドラーがc.Request.Context()をクライアントに渡すと、Kubernetes/Helmの呼び出しがこのスパンの子になる
+func TracerSetting(service string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+
+		ctx := otel.GetTextMapPropagator().Extract(c.Request.Context(), propagation.HeaderCarrier(c.Request.Header))
+		ctx, span := otel.Tracer(telemetry.TracerName).Start(ctx, c.Request.Method+" "+route,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("service.name", service),
+				attribute.String("http.method", c.Request.Method),
+				attribute.String("http.route", route),
+				attribute.String("tenant", GetProjectId(c)),
+			),
+		)
+		defer span.End()
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+
+		status := c.Writer.Status()
+		span.SetAttributes(attribute.Int("http.status_code", status))
+		for _, err := range c.Errors {
+			span.RecordError(err.Err)
+		}
+		// 4xxはクライアントのエラーのため、スパンのステータスはErrorにしない
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+			slog.ErrorContext(ctx, "request failed", "service", service, "method", c.Request.Method, "route", route, "status", status)
+		}
+	}
+}
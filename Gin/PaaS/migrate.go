@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"ham3/config"
+	"ham3/models"
+)
+
+const migrateUsage = `Usage: ham3 migrate <command>
+
+Commands:
+  status          マイグレーションの適用状況を表示
+  up [version]    未適用のマイグレーションを適用（versionを指定した場合はそのバージョンまで）
+  down [steps]    適用済みのマイグレーションを新しいものからsteps個戻す（デフォルト: 1）
+
+接続先はDB_DRIVER/DB_DSN、またはHAM3_CONFIGの設定ファイルのdatabaseで指定する
+`
+
+// ham3 migrate（終了コードを返す）
+func runMigrate(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, migrateUsage)
+		return 2
+	}
+
+	// サーバーと同じ設定（設定ファイルはHAM3_CONFIGで指定する）のDB接続先を使う
+	cfg, err := config.Load(nil)
+	if err == nil {
+		err = cfg.Database.Validate()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid database config: %v\n", err)
+		return 1
+	}
+	db, err := models.ConnectDb(cfg.Database)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+
+	switch args[0] {
+	case "status":
+		states, err := models.MigrationStatus(db)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			return 1
+		}
+		for _, state := range states {
+			appliedAt := "pending"
+			if state.Applied {
+				appliedAt = state.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%4d  %-20s  %s\n", state.Version, appliedAt, state.Name)
+		}
+		return 0
+
+	case "up":
+		target, ok := migrateArg(args, 0)
+		if !ok {
+			return 2
+		}
+		applied, err := models.MigrateUp(db, target)
+		for _, m := range applied {
+			fmt.Printf("Applied migration %d: %s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			return 1
+		}
+		if len(applied) == 0 {
+			fmt.Println("No pending migrations")
+		}
+		return 0
+
+	case "down":
+		steps, ok := migrateArg(args, 1)
+		if !ok {
+			return 2
+		}
+		reverted, err := models.MigrateDown(db, steps)
+		for _, m := range reverted {
+			fmt.Printf("Rolled back migration %d: %s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			return 1
+		}
+		if len(reverted) == 0 {
+			fmt.Println("No applied migrations")
+		}
+		return 0
+	}
+
+	fmt.Fprint(os.Stderr, migrateUsage)
+	return 2
+}
+
+// up/downの引数（省略時はdefaultValue）
+func migrateArg(args []string, defaultValue int) (int, bool) {
+	if len(args) < 2 {
+		return defaultValue, true
+	}
+	n, err := strconv.Atoi(args[1])
+	if err != nil || n < 0 {
+		fmt.Fprintf(os.Stderr, "Invalid argument %q\n\n%s", args[1], migrateUsage)
+		return 0, false
+	}
+	return n, true
+}
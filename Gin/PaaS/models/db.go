@@ -0,0 +1,71 @@
+package models
+
+import (
+	"fmt"
+
+	"ham3/config"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// DB接続（ドライバーとコネクションプールは設定から決める）
+// テーブルの作成/更新はMigrateUpで行う
+func ConnectDb(cfg config.DatabaseConfig) (*gorm.DB, error) {
+	var dialector gorm.Dialector
+	switch cfg.Driver {
+	case config.DbDriverSqlite:
+		dialector = sqlite.Open(cfg.Dsn)
+	case config.DbDriverPostgres:
+		dialector = postgres.Open(cfg.Dsn)
+	default:
+		return nil, fmt.Errorf("Unsupported database driver %q", cfg.Driver)
+	}
+
+	// 一意制約の違反をgorm.ErrDuplicatedKeyに変換する（ドライバーに依存せず409を返せるようにする）
+	db, err := gorm.Open(dialector, &gorm.Config{TranslateError: true})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to connect database (%s): %v", cfg.Driver, err)
+	}
+
+	sqlDb, err := db.DB()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get database connection pool: %v", err)
+	}
+	if cfg.MaxOpenConns > 0 {
+		sqlDb.SetMaxOpenConns(cfg.MaxOpenConns)
+	}
+	if cfg.MaxIdleConns > 0 {
+		sqlDb.SetMaxIdleConns(cfg.MaxIdleConns)
+	}
+	if cfg.ConnMaxLifetime > 0 {
+		sqlDb.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	}
+	if err := sqlDb.Ping(); err != nil {
+		return nil, fmt.Errorf("Failed to connect database (%s): %v", cfg.Driver, err)
+	}
+
+	return db, nil
+}
+
+// 起動時のスキーマ確認
+// autoMigrateがtrueの場合は未適用のマイグレーションを適用し、falseの場合は未適用があればエラーを返す
+func EnsureSchema(db *gorm.DB, autoMigrate bool) error {
+	if autoMigrate {
+		applied, err := MigrateUp(db, 0)
+		for _, m := range applied {
+			fmt.Printf("Applied migration %d: %s\n", m.Version, m.Name)
+		}
+		return err
+	}
+
+	pending, err := PendingMigrations(db)
+	if err != nil {
+		return err
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("%d pending migrations (latest: %d), run `ham3 migrate up`", len(pending), pending[len(pending)-1].Version)
+	}
+	return nil
+}
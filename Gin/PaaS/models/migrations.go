@@ -0,0 +1,459 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// バージョン管理されたスキーマ変更
+// 追加したマイグレーションは変更せず、スキーマを変える場合は新しいバージョンを追加する
+// Up/Downはトランザクション内で実行される
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(tx *gorm.DB) error
+	Down    func(tx *gorm.DB) error
+}
+
+// 適用済みのマイグレーション
+type SchemaMigration struct {
+	Version   int       `gorm:"primaryKey;autoIncrement:false;column:version"`
+	Name      string    `gorm:"not null;column:name"`
+	AppliedAt time.Time `gorm:"not null;column:applied_at"`
+}
+
+func (SchemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// マイグレーションの適用状況（ham3 migrate statusで表示）
+type MigrationState struct {
+	Migration
+	Applied   bool
+	AppliedAt *time.Time
+}
+
+// バージョン順のマイグレーション
+var migrations = []Migration{
+	{
+		Version: 1,
+		Name:    "initial schema",
+		// AutoMigrateで作成済みのDBもそのまま取り込めるよう、当時のモデルでAutoMigrateする
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&m1Projects{}, &m1LOGaaS{}, &m1CaaS{}, &m1AAPaaS{}, &m1Job{}, &m1JobStep{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&m1JobStep{}, &m1Job{}, &m1AAPaaS{}, &m1CaaS{}, &m1LOGaaS{}, &m1Projects{})
+		},
+	},
+	{
+		Version: 2,
+		Name:    "add drift columns to caas and logaas",
+		Up: func(tx *gorm.DB) error {
+			return addColumns(tx, []interface{}{&m2CaaSDrift{}, &m2LOGaaSDrift{}}, "Drift", "DriftCheckedAt")
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropColumns(tx, []interface{}{&m2CaaSDrift{}, &m2LOGaaSDrift{}}, "Drift", "DriftCheckedAt")
+		},
+	},
+	{
+		Version: 3,
+		Name:    "add audit_logs",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&m3AuditLog{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&m3AuditLog{})
+		},
+	},
+	{
+		Version: 4,
+		Name:    "unique namespace of live caas",
+		// 同名のCaaSを同時に作成するリクエストのうち1つだけがINSERTできるようにする（削除済みの行は対象外）
+		Up: func(tx *gorm.DB) error {
+			return createLiveUniqueIndex(tx, "caas", "namespace", "idx_caas_namespace_live")
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Exec("DROP INDEX IF EXISTS idx_caas_namespace_live").Error
+		},
+	},
+	{
+		Version: 5,
+		Name:    "unique cluster_name of live logaas",
+		// LOGaaSは論理削除するため、cluster_nameのUNIQUE制約を削除済みの行を除くユニークインデックスに置き換える
+		// （置き換えないと、削除したLOGaaSと同名のLOGaaSを作成できない）
+		Up: func(tx *gorm.DB) error {
+			// 作成したgormのバージョンによって制約の名前が異なる
+			for _, name := range []string{"uni_logaas_cluster_name", "logaas_cluster_name_key", "idx_logaas_cluster_name"} {
+				if tx.Migrator().HasConstraint(&m1LOGaaS{}, name) {
+					if err := tx.Migrator().DropConstraint(&m1LOGaaS{}, name); err != nil {
+						return err
+					}
+				}
+				if tx.Migrator().HasIndex(&m1LOGaaS{}, name) {
+					if err := tx.Migrator().DropIndex(&m1LOGaaS{}, name); err != nil {
+						return err
+					}
+				}
+			}
+			return createLiveUniqueIndex(tx, "logaas", "cluster_name", "idx_logaas_cluster_name_live")
+		},
+		// 削除済みの行と同名のLOGaaSがある場合は失敗する
+		Down: func(tx *gorm.DB) error {
+			if err := tx.Exec("DROP INDEX IF EXISTS idx_logaas_cluster_name_live").Error; err != nil {
+				return err
+			}
+			return tx.Exec("CREATE UNIQUE INDEX uni_logaas_cluster_name ON logaas (cluster_name)").Error
+		},
+	},
+	{
+		Version: 6,
+		Name:    "add error code and field errors to audit_logs",
+		Up: func(tx *gorm.DB) error {
+			return addColumns(tx, []interface{}{&m6AuditLogError{}}, "ErrorCode", "ErrorFields")
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropColumns(tx, []interface{}{&m6AuditLogError{}}, "ErrorCode", "ErrorFields")
+		},
+	},
+	{
+		Version: 7,
+		Name:    "unique name of live aapaas",
+		// 同名のAAPaaSを同時に作成するリクエストのうち1つだけがINSERTできるようにする（削除済みの行は対象外）
+		Up: func(tx *gorm.DB) error {
+			return createLiveUniqueIndex(tx, "aapaas", "name", "idx_aapaas_name_live")
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Exec("DROP INDEX IF EXISTS idx_aapaas_name_live").Error
+		},
+	},
+}
+
+// deleted_atがNULLの行のみを対象にしたユニークインデックスを作成する
+// 重複した行がすでにある場合は、どの値が重複しているかを返す（手動で整理してから再実行する）
+func createLiveUniqueIndex(tx *gorm.DB, table string, column string, name string) error {
+	var duplicates []string
+	err := tx.Table(table).
+		Where("deleted_at IS NULL").
+		Group(column).
+		Having("COUNT(*) > 1").
+		Pluck(column, &duplicates).Error
+	if err != nil {
+		return err
+	}
+	if len(duplicates) > 0 {
+		return fmt.Errorf("%s has duplicate %s values: %v", table, column, duplicates)
+	}
+	return tx.Exec(fmt.Sprintf("CREATE UNIQUE INDEX IF NOT EXISTS %s ON %s (%s) WHERE deleted_at IS NULL", name, table, column)).Error
+}
+
+// テーブルごとに存在しないカラムを追加する
+func addColumns(tx *gorm.DB, tables []interface{}, fields ...string) error {
+	for _, table := range tables {
+		for _, field := range fields {
+			if tx.Migrator().HasColumn(table, field) {
+				continue
+			}
+			if err := tx.Migrator().AddColumn(table, field); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// テーブルごとに存在するカラムを削除する
+func dropColumns(tx *gorm.DB, tables []interface{}, fields ...string) error {
+	for _, table := range tables {
+		for _, field := range fields {
+			if !tx.Migrator().HasColumn(table, field) {
+				continue
+			}
+			if err := tx.Migrator().DropColumn(table, field); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// 定義済みのマイグレーション
+func Migrations() []Migration {
+	return append([]Migration(nil), migrations...)
+}
+
+func appliedMigrations(db *gorm.DB) (map[int]SchemaMigration, error) {
+	if err := db.AutoMigrate(&SchemaMigration{}); err != nil {
+		return nil, fmt.Errorf("Failed to create schema_migrations: %v", err)
+	}
+	var rows []SchemaMigration
+	if err := db.Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("Failed to get applied migrations: %v", err)
+	}
+	applied := map[int]SchemaMigration{}
+	for _, row := range rows {
+		applied[row.Version] = row
+	}
+	return applied, nil
+}
+
+// 全マイグレーションの適用状況
+func MigrationStatus(db *gorm.DB) ([]MigrationState, error) {
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return nil, err
+	}
+	states := make([]MigrationState, 0, len(migrations))
+	for _, m := range migrations {
+		state := MigrationState{Migration: m}
+		if row, ok := applied[m.Version]; ok {
+			state.Applied = true
+			appliedAt := row.AppliedAt
+			state.AppliedAt = &appliedAt
+		}
+		states = append(states, state)
+	}
+	return states, nil
+}
+
+// 未適用のマイグレーション
+func PendingMigrations(db *gorm.DB) ([]Migration, error) {
+	states, err := MigrationStatus(db)
+	if err != nil {
+		return nil, err
+	}
+	var pending []Migration
+	for _, state := range states {
+		if !state.Applied {
+			pending = append(pending, state.Migration)
+		}
+	}
+	return pending, nil
+}
+
+// targetまでの未適用のマイグレーションを適用する（targetが0の場合は最新まで）
+// 適用したマイグレーションを返す（途中で失敗した場合はそれまでに適用したもの）
+func MigrateUp(db *gorm.DB, target int) ([]Migration, error) {
+	if target < 0 {
+		return nil, fmt.Errorf("Invalid target version %d", target)
+	}
+	if target > 0 && !knownVersion(target) {
+		return nil, fmt.Errorf("Unknown migration version %d", target)
+	}
+
+	pending, err := PendingMigrations(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for _, m := range pending {
+		if target > 0 && m.Version > target {
+			break
+		}
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := m.Up(tx); err != nil {
+				return err
+			}
+			return tx.Create(&SchemaMigration{Version: m.Version, Name: m.Name, AppliedAt: time.Now()}).Error
+		})
+		if err != nil {
+			return done, fmt.Errorf("Migration %d (%s) failed: %v", m.Version, m.Name, err)
+		}
+		done = append(done, m)
+	}
+	return done, nil
+}
+
+// 適用済みのマイグレーションを新しいものからsteps個戻す
+// 戻したマイグレーションを返す（途中で失敗した場合はそれまでに戻したもの）
+func MigrateDown(db *gorm.DB, steps int) ([]Migration, error) {
+	if steps < 1 {
+		return nil, fmt.Errorf("Invalid number of steps %d", steps)
+	}
+
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return nil, err
+	}
+	versions := make([]int, 0, len(applied))
+	for version := range applied {
+		versions = append(versions, version)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(versions)))
+
+	var done []Migration
+	for _, version := range versions {
+		if len(done) == steps {
+			break
+		}
+		m, ok := findMigration(version)
+		if !ok {
+			return done, fmt.Errorf("Applied migration %d is not defined in this binary", version)
+		}
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := m.Down(tx); err != nil {
+				return err
+			}
+			return tx.Delete(&SchemaMigration{}, "version = ?", m.Version).Error
+		})
+		if err != nil {
+			return done, fmt.Errorf("Rollback of migration %d (%s) failed: %v", m.Version, m.Name, err)
+		}
+		done = append(done, m)
+	}
+	return done, nil
+}
+
+func findMigration(version int) (Migration, bool) {
+	for _, m := range migrations {
+		if m.Version == version {
+			return m, true
+		}
+	}
+	return Migration{}, false
+}
+
+func knownVersion(version int) bool {
+	_, ok := findMigration(version)
+	return ok
+}
+
+// 以下はマイグレーション1の時点のモデル
+// 現在のモデルが変わってもマイグレーションの内容が変わらないように、当時の定義を残しておく
+
+type m1Projects struct {
+	ProjectId   string `gorm:"primaryKey;column:project_id"`
+	ProjectName string `gorm:"not null;column:project_name"`
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+func (m1Projects) TableName() string { return "projects" }
+
+type m1LOGaaS struct {
+	gorm.Model
+	ProjectId   string `gorm:"not null;index;column:project_id"`
+	ClusterName string `gorm:"not null;column:cluster_name;unique"`
+	ClusterType string `gorm:"not null;column:cluster_type"`
+	GuiEndpoint string `gorm:"not null;column:gui_endpoint"`
+	ApiEndpoint string `gorm:"not null;column:api_endpoint"`
+	Status      string `gorm:"not null;column:status"`
+	GuiStatus   string `gorm:"column:gui_status"`
+	Spec        string `gorm:"type:text;column:spec"`
+}
+
+func (m1LOGaaS) TableName() string { return "logaas" }
+
+type m1CaaS struct {
+	gorm.Model
+	ProjectId      string `gorm:"not null;index;column:project_id"`
+	Namespace      string `gorm:"not null;index;column:namespace"`
+	Status         string `gorm:"not null;column:status"`
+	Plan           string `gorm:"column:plan"`
+	RequestsCpu    string `gorm:"column:requests_cpu"`
+	RequestsMemory string `gorm:"column:requests_memory"`
+	Pods           string `gorm:"column:pods"`
+	LimitCpu       string `gorm:"column:limit_cpu"`
+	LimitMemory    string `gorm:"column:limit_memory"`
+}
+
+func (m1CaaS) TableName() string { return "caas" }
+
+type m1AAPaaS struct {
+	gorm.Model
+	ProjectId       string `gorm:"not null;index;column:project_id"`
+	Name            string `gorm:"not null;index;column:name"`
+	Namespace       string `gorm:"not null;column:namespace"`
+	Endpoint        string `gorm:"column:endpoint"`
+	AdminUser       string `gorm:"column:admin_user"`
+	AdminSecret     string `gorm:"column:admin_secret"`
+	OperatorVersion string `gorm:"column:operator_version"`
+	Status          string `gorm:"not null;column:status"`
+}
+
+func (m1AAPaaS) TableName() string { return "aapaas" }
+
+type m1Job struct {
+	ID           string `gorm:"primaryKey;column:id"`
+	ProjectId    string `gorm:"not null;index;column:project_id"`
+	ResourceType string `gorm:"not null;column:resource_type"`
+	ResourceId   string `gorm:"not null;index;column:resource_id"`
+	Action       string `gorm:"not null;column:action"`
+	Status       string `gorm:"not null;index;column:status"`
+	Error        string `gorm:"column:error"`
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	StartedAt    *time.Time  `gorm:"column:started_at"`
+	FinishedAt   *time.Time  `gorm:"column:finished_at"`
+	Steps        []m1JobStep `gorm:"foreignKey:JobId"`
+}
+
+func (m1Job) TableName() string { return "jobs" }
+
+type m1JobStep struct {
+	ID         uint       `gorm:"primaryKey"`
+	JobId      string     `gorm:"not null;index;column:job_id"`
+	Name       string     `gorm:"not null;column:name"`
+	Status     string     `gorm:"not null;column:status"`
+	Error      string     `gorm:"column:error"`
+	StartedAt  time.Time  `gorm:"column:started_at"`
+	FinishedAt *time.Time `gorm:"column:finished_at"`
+}
+
+func (m1JobStep) TableName() string { return "job_steps" }
+
+// 以下はマイグレーション2で追加したカラム
+
+type m2CaaSDrift struct {
+	Drift          string     `gorm:"type:text;column:drift"`
+	DriftCheckedAt *time.Time `gorm:"column:drift_checked_at"`
+}
+
+func (m2CaaSDrift) TableName() string { return "caas" }
+
+type m2LOGaaSDrift struct {
+	Drift          string     `gorm:"type:text;column:drift"`
+	DriftCheckedAt *time.Time `gorm:"column:drift_checked_at"`
+}
+
+func (m2LOGaaSDrift) TableName() string { return "logaas" }
+
+// 以下はマイグレーション3で追加したテーブル
+
+type m3AuditLog struct {
+	ID           uint      `gorm:"primaryKey"`
+	CreatedAt    time.Time `gorm:"index"`
+	UserId       string    `gorm:"column:user_id"`
+	UserName     string    `gorm:"index;column:user_name"`
+	ProjectId    string    `gorm:"index;column:project_id"`
+	ProjectName  string    `gorm:"column:project_name"`
+	IsAdmin      bool      `gorm:"column:is_admin"`
+	ClientIp     string    `gorm:"column:client_ip"`
+	Method       string    `gorm:"not null;column:method"`
+	Route        string    `gorm:"not null;column:route"`
+	Path         string    `gorm:"not null;column:path"`
+	Action       string    `gorm:"not null;index;column:action"`
+	ResourceType string    `gorm:"index;column:resource_type"`
+	ResourceId   string    `gorm:"index;column:resource_id"`
+	Payload      string    `gorm:"type:text;column:payload"`
+	StatusCode   int       `gorm:"not null;column:status_code"`
+	Outcome      string    `gorm:"not null;index;column:outcome"`
+	Error        string    `gorm:"type:text;column:error"`
+	JobId        string    `gorm:"column:job_id"`
+	TraceId      string    `gorm:"column:trace_id"`
+}
+
+func (m3AuditLog) TableName() string { return "audit_logs" }
+
+// 以下はマイグレーション6で追加したカラム
+
+type m6AuditLogError struct {
+	ErrorCode   string `gorm:"index;column:error_code"`
+	ErrorFields string `gorm:"type:text;column:error_fields"`
+}
+
+func (m6AuditLogError) TableName() string { return "audit_logs" }
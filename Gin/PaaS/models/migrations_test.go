@@ -0,0 +1,61 @@
+package models
+
+import (
+	"errors"
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func newTestDb(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{TranslateError: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDb, _ := db.DB()
+	t.Cleanup(func() { sqlDb.Close() })
+	return db
+}
+
+func TestLogaasNameReusableAfterDelete(t *testing.T) {
+	db := newTestDb(t)
+
+	// マイグレーション5より前に削除したLOGaaSが残っているDB
+	if _, err := MigrateUp(db, 4); err != nil {
+		t.Fatal(err)
+	}
+	deleted := LOGaaS{ProjectId: "p", ClusterName: "logs", ClusterType: "standard", Status: StatusDeleted}
+	if err := db.Create(&deleted).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Delete(&deleted).Error; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := MigrateUp(db, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	recreated := LOGaaS{ProjectId: "p", ClusterName: "logs", ClusterType: "standard", Status: StatusCreating}
+	if err := db.Create(&recreated).Error; err != nil {
+		t.Fatalf("re-create after delete: %v", err)
+	}
+	duplicate := LOGaaS{ProjectId: "p", ClusterName: "logs", ClusterType: "standard", Status: StatusCreating}
+	if err := db.Create(&duplicate).Error; !errors.Is(err, gorm.ErrDuplicatedKey) {
+		t.Fatalf("duplicate live logaas: err = %v, want ErrDuplicatedKey", err)
+	}
+}
+
+func TestMigrateDownAndUp(t *testing.T) {
+	db := newTestDb(t)
+	if _, err := MigrateUp(db, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := MigrateDown(db, len(migrations)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := MigrateUp(db, 0); err != nil {
+		t.Fatal(err)
+	}
+}
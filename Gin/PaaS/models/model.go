@@ -1,52 +1,178 @@
 package models
 
 import (
-	"gorm.io/driver/sqlite"
+	"time"
+
 	"gorm.io/gorm"
 )
 
+// CaaS/LOGaaS/AAPaaSのステータス
+const (
+	StatusCreating = "creating"
+	StatusUpdating = "updating"
+	StatusReady    = "ready"
+	StatusDeleting = "deleting"
+	StatusDeleted  = "deleted"
+	StatusFailed   = "failed"
+)
+
+// ジョブ・監査ログなどで扱うリソースの種類
+const (
+	ResourceCaaS   = "caas"
+	ResourceLOGaaS = "logaas"
+	ResourceAAPaaS = "aapaas"
+)
+
+// 非同期ジョブのステータス
+const (
+	JobPending   = "pending"
+	JobRunning   = "running"
+	JobSucceeded = "succeeded"
+	JobFailed    = "failed"
+)
+
+// ジョブのステップのステータス
+const (
+	StepRunning   = "running"
+	StepSucceeded = "succeeded"
+	StepFailed    = "failed"
+)
+
+// Keystoneのプロジェクト（リクエストしたトークンのプロジェクトを自動で登録する）
 type Projects struct {
-	ProjectId   string `gorm:"primaryKey;column:project_id"`
-	ProjectName string `gorm:"not null;column:project_name"`
+	ProjectId   string    `gorm:"primaryKey;column:project_id" json:"project_id"`
+	ProjectName string    `gorm:"not null;column:project_name" json:"project_name"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
 }
 
 type LOGaaS struct {
 	gorm.Model
-	ProjectId   string `gorm:"not null;index;foreignKey:ProjectId;references:Projects.ProjectId;constraint:OnDelete:RESTRICT;column:project_id"`
-	ClusterName string `gorm:"not null;column:cluster_name;unique"`
+	ProjectId string `gorm:"not null;index;foreignKey:ProjectId;references:Projects.ProjectId;constraint:OnDelete:RESTRICT;column:project_id"`
+	// 削除済みでないLOGaaSの名前は一意（マイグレーション5）
+	ClusterName string `gorm:"not null;column:cluster_name;uniqueIndex:idx_logaas_cluster_name_live,where:deleted_at IS NULL"`
 	ClusterType string `gorm:"not null;column:cluster_type"`
 	GuiEndpoint string `gorm:"not null;column:gui_endpoint"`
 	ApiEndpoint string `gorm:"not null;column:api_endpoint"`
 	Status      string `gorm:"not null;column:status"`
+	// OpenSearch Dashboardsのステータス（OpenSearchとは別に管理する）
+	GuiStatus string `gorm:"column:gui_status"`
+
+	// 作成/更新時のパラメータ(api.LogaasRequestData)をJSONで保存
+	Spec string `gorm:"type:text;column:spec"`
+
+	// Reconcilerが検出したDBとクラスタの差分（空の場合は差分なし）
+	Drift          string     `gorm:"type:text;column:drift"`
+	DriftCheckedAt *time.Time `gorm:"column:drift_checked_at"`
 }
 
 type CaaS struct {
 	gorm.Model
 	ProjectId string `gorm:"not null;index;foreignKey:ProjectId;references:Projects.ProjectId;constraint:OnDelete:RESTRICT;column:project_id"`
-	Namespace string `grom:"not null;column:namespace"`
+	// 削除済みでないCaaSのNamespaceは一意（マイグレーション4）
+	Namespace string `gorm:"not null;index;uniqueIndex:idx_caas_namespace_live,where:deleted_at IS NULL;column:namespace"`
 	Status    string `gorm:"not null;column:status"`
+
+	// ResourceQuota/LimitRangeのサイズ（Planを個別に上書きした値を含む）
+	Plan           string `gorm:"column:plan"`
+	RequestsCpu    string `gorm:"column:requests_cpu"`
+	RequestsMemory string `gorm:"column:requests_memory"`
+	Pods           string `gorm:"column:pods"`
+	LimitCpu       string `gorm:"column:limit_cpu"`
+	LimitMemory    string `gorm:"column:limit_memory"`
+
+	// Reconcilerが検出したDBとクラスタの差分（空の場合は差分なし）
+	Drift          string     `gorm:"type:text;column:drift"`
+	DriftCheckedAt *time.Time `gorm:"column:drift_checked_at"`
 }
 
 type AAPaaS struct {
 	gorm.Model
 	ProjectId string `gorm:"not null;index;foreignKey:ProjectId;references:Projects.ProjectId;constraint:OnDelete:RESTRICT;column:project_id"`
-	Status    string `gorm:"not null;column:status"`
+	Name      string `gorm:"not null;index;uniqueIndex:idx_aapaas_name_live,where:deleted_at IS NULL;column:name"`
+	Namespace string `gorm:"not null;column:namespace"`
+	Endpoint  string `gorm:"column:endpoint"`
+	AdminUser string `gorm:"column:admin_user"`
+	// 管理者パスワードはDBに保存せず、AWX Operatorが作成するSecretの名前のみ保存する
+	AdminSecret     string `gorm:"column:admin_secret"`
+	OperatorVersion string `gorm:"column:operator_version"`
+	Status          string `gorm:"not null;column:status"`
 }
 
-func ConnectDb() *gorm.DB {
-	// DB接続
-	db, err := gorm.Open(sqlite.Open("ham3.db"), &gorm.Config{})
-	if err != nil {
-		panic("failed to connect database")
-	}
+// CaaS/LOGaaSの作成・更新・削除などの時間がかかる処理
+type Job struct {
+	ID           string     `gorm:"primaryKey;column:id" json:"id"`
+	ProjectId    string     `gorm:"not null;index;column:project_id" json:"project_id"`
+	ResourceType string     `gorm:"not null;column:resource_type" json:"resource_type"`
+	ResourceId   string     `gorm:"not null;index;column:resource_id" json:"resource_id"`
+	Action       string     `gorm:"not null;column:action" json:"action"`
+	Status       string     `gorm:"not null;index;column:status" json:"status"`
+	Error        string     `gorm:"column:error" json:"error,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+	StartedAt    *time.Time `gorm:"column:started_at" json:"started_at,omitempty"`
+	FinishedAt   *time.Time `gorm:"column:finished_at" json:"finished_at,omitempty"`
+	Steps        []JobStep  `gorm:"foreignKey:JobId" json:"steps"`
+}
+
+// ジョブの各ステップの進捗
+type JobStep struct {
+	ID         uint       `gorm:"primaryKey" json:"-"`
+	JobId      string     `gorm:"not null;index;column:job_id" json:"-"`
+	Name       string     `gorm:"not null;column:name" json:"name"`
+	Status     string     `gorm:"not null;column:status" json:"status"`
+	Error      string     `gorm:"column:error" json:"error,omitempty"`
+	StartedAt  time.Time  `gorm:"column:started_at" json:"started_at"`
+	FinishedAt *time.Time `gorm:"column:finished_at" json:"finished_at,omitempty"`
+}
+
+// 監査ログの結果
+const (
+	AuditSucceeded = "succeeded"
+	// ジョブとして受け付けた（ジョブの結果はjobsテーブルに記録する）
+	AuditAccepted = "accepted"
+	// 認証または認可に失敗した
+	AuditDenied = "denied"
+	AuditFailed = "failed"
+)
+
+// /api/v1のPOST/PUT/DELETEの監査ログ
+type AuditLog struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
 
-	// テーブル作成/更新
-	db.AutoMigrate(&Projects{})
-	db.AutoMigrate(&LOGaaS{})
-	db.AutoMigrate(&CaaS{})
-	db.AutoMigrate(&AAPaaS{})
+	// トークンのユーザーとプロジェクト（認証に失敗した場合は空）
+	UserId      string `gorm:"column:user_id" json:"user_id"`
+	UserName    string `gorm:"index;column:user_name" json:"user_name"`
+	ProjectId   string `gorm:"index;column:project_id" json:"project_id"`
+	ProjectName string `gorm:"column:project_name" json:"project_name"`
+	IsAdmin     bool   `gorm:"column:is_admin" json:"is_admin"`
+	ClientIp    string `gorm:"column:client_ip" json:"client_ip"`
 
-	return db
+	Method string `gorm:"not null;column:method" json:"method"`
+	// ルートのパス（例: /api/v1/logaas/:logaas_id、存在しないルートの場合はリクエストのパス）
+	Route  string `gorm:"not null;column:route" json:"route"`
+	Path   string `gorm:"not null;column:path" json:"path"`
+	Action string `gorm:"not null;index;column:action" json:"action"`
+	// 操作対象のリソースの種類(caas/logaas/aapaas/projects...)と名前
+	ResourceType string `gorm:"index;column:resource_type" json:"resource_type"`
+	ResourceId   string `gorm:"index;column:resource_id" json:"resource_id"`
+	// リクエストのボディ（パスワードなどの値は伏せ字にしたJSON）
+	Payload string `gorm:"type:text;column:payload" json:"payload,omitempty"`
+
+	StatusCode int    `gorm:"not null;column:status_code" json:"status_code"`
+	Outcome    string `gorm:"not null;index;column:outcome" json:"outcome"`
+	// 失敗した場合のレスポンスのメッセージ、エラーの種類(api.ErrorCode)とフィールドごとのエラー(api.FieldErrorのJSON)
+	Error       string `gorm:"type:text;column:error" json:"error,omitempty"`
+	ErrorCode   string `gorm:"index;column:error_code" json:"error_code,omitempty"`
+	ErrorFields string `gorm:"type:text;column:error_fields" json:"error_fields,omitempty"`
+	JobId       string `gorm:"column:job_id" json:"job_id,omitempty"`
+	TraceId     string `gorm:"column:trace_id" json:"trace_id,omitempty"`
+}
+
+// ジョブが終了しているか
+func (j *Job) Finished() bool {
+	return j.Status == JobSucceeded || j.Status == JobFailed
 }
 
 func (Projects) TableName() string {
@@ -64,3 +190,15 @@ func (CaaS) TableName() string {
 func (AAPaaS) TableName() string {
 	return "aapaas"
 }
+
+func (Job) TableName() string {
+	return "jobs"
+}
+
+func (JobStep) TableName() string {
+	return "job_steps"
+}
+
+func (AuditLog) TableName() string {
+	return "audit_logs"
+}
@@ -2,94 +2,201 @@ package routers
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
-	"net/http"
 
+	"ham3/config"
+	"ham3/jobs"
 	"ham3/middlewares"
 	"ham3/models"
 	"ham3/services"
+	"ham3/telemetry"
 	"ham3/utilities"
 
 	"github.com/gin-gonic/gin"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
-	"go.opentelemetry.io/otel"
-	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
-	"go.opentelemetry.io/otel/propagation"
-	"go.opentelemetry.io/otel/sdk/resource"
-	"go.opentelemetry.io/otel/sdk/trace"
-	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"github.com/prometheus/client_golang/prometheus"
+	"gorm.io/gorm"
 	"k8s.io/client-go/kubernetes"
 )
 
-func SetupRouter(r *gin.Engine) {
-	// OTLPエクスポーターの設定
-	exporter, err := otlptracehttp.New(context.Background(),
-		otlptracehttp.WithEndpoint("localhost:4318"),
-		otlptracehttp.WithInsecure(), // TLSを無効にする場合に指定
-	)
-	if err != nil {
-		log.Fatalf("Failed to create exporter: %v", err)
+// サーバーの停止時に終了させるリソース
+type Resources struct {
+	DB        *gorm.DB
+	Jobs      *jobs.Manager
+	Telemetry *telemetry.Provider
+	// Reconcilerを停止する
+	stopReconciler context.CancelFunc
+}
+
+// Reconcilerを停止し、トレースを送信してからDB接続を閉じる
+// ジョブはShutdownの前にJobs.Shutdownで完了を待つ
+func (res *Resources) Shutdown(ctx context.Context) error {
+	var errs []error
+	if res.stopReconciler != nil {
+		res.stopReconciler()
+	}
+	if err := res.Telemetry.Shutdown(ctx); err != nil {
+		errs = append(errs, err)
+	}
+	if sqlDB, err := res.DB.DB(); err == nil {
+		if err := sqlDB.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("Failed to close database: %v", err))
+		}
 	}
+	return errors.Join(errs...)
+}
 
-	// NewTracerProviderを複数設定しても、最後の設定が有効になる
-	tp := trace.NewTracerProvider(
-		trace.WithBatcher(exporter),
-		trace.WithResource(resource.NewWithAttributes(
-			semconv.SchemaURL,                     // SchemaURL is the schema URL used to generate the trace ID. Must be set to an absolute URL.
-			semconv.ServiceNameKey.String("HAM3"), // ServiceNameKey is the key used to identify the service name in a Resource.
-		)),
-	)
+// telはmainで設定したトレース・メトリクスのプロバイダー（サーバーの停止時に未送信のトレースを送信する）
+func SetupRouter(r *gin.Engine, cfg *config.Config, tel *telemetry.Provider) *Resources {
+	// DB接続（未適用のマイグレーションがある場合は設定に応じて適用、または起動しない）
+	db, err := models.ConnectDb(cfg.Database)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	if err := models.EnsureSchema(db, cfg.Database.AutoMigrate); err != nil {
+		log.Fatalf("Database schema is not up to date: %v", err)
+	}
 
-	otel.SetTracerProvider(tp)
-	otel.SetTextMapPropagator(propagation.TraceContext{})
+	// KeystoneとLOGaaSのNamespaceの設定
+	utilities.SetKeystoneConfig(cfg.Keystone)
+	utilities.OpenSearchNamespace = cfg.Kubernetes.OpenSearchNamespace
 
-	config, err := utilities.GetKubeconfig()
+	kubeconfig, err := utilities.GetKubeconfig(cfg.Kubernetes.Kubeconfig)
 	if err != nil {
 		log.Fatalf("Failed to get kubeconfig: %v", err)
 	}
 
-	// Kubernetesクライアントの作成
-	clientset, err := kubernetes.NewForConfig(config)
+	// Kubernetesクライアントの作成（リクエストのスパンの中のAPI呼び出しを子のスパンにする）
+	clientset, err := kubernetes.NewForConfig(telemetry.InstrumentKubeConfig(kubeconfig))
 	if err != nil {
 		log.Fatalf("Error creating Kubernetes client: %v", err)
 	}
 
-	// DB接続
-	db := models.ConnectDb()
+	// Helmクライアントの作成（チャートの取得元が存在しないパスの場合は起動しない）
+	helmClient, err := utilities.NewHelmClient(cfg.Helm)
+	if err != nil {
+		log.Fatalf("Failed to load chart sources: %v", err)
+	}
 
-	v1 := r.Group("/api/v1")
+	// サービスが使うKubernetes/Helm/OpenStack/OpenSearchのクライアントとLOGaaS/AAPaaSの設定
+	clients := &services.Clients{
+		Kube:       clientset,
+		Helm:       utilities.TracedHelmInstaller{Next: helmClient},
+		Volumes:    utilities.CinderVolumeProvider{},
+		OpenSearch: utilities.NewHttpOpenSearchClient(cfg.OpenSearch, clientset),
+		Logaas:     cfg.Logaas,
+		Defaults:   cfg.Defaults,
+	}
 
-	// HeaderにTokenが存在するかチェック
-	v1.Use(middlewares.CheckTokenExists())
+	// DBのCaaS/LOGaaS/AAPaaSのステータスごとの数のメトリクス
+	prometheus.MustRegister(services.NewResourceCollector(db))
 
-	{
-		// CaaS関連ルート
-		caas := v1.Group("/caas")
-		{
-			caas.Use(middlewares.TracerSetting("CaaS"))
-			caas.POST("/:caas_id", func(c *gin.Context) { services.CreateCaas(c.Request.Context(), c, clientset, db) })
-			caas.GET("/:caas_id", func(c *gin.Context) { services.GetCaas(c.Request.Context(), c, clientset, db) })
-			caas.DELETE("/:caas_id", func(c *gin.Context) { services.DeleteCaas(c.Request.Context(), c, clientset, db) })
-			caas.GET("/", func(c *gin.Context) { services.GetCaases(c.Request.Context(), c, clientset, db) })
-		}
+	// 時間がかかる処理を実行するジョブのワーカー
+	jm := jobs.NewManager(db, cfg.Jobs.Workers, cfg.Jobs.QueueSize)
 
-		// LOGaaS関連ルート
-		logaas := v1.Group("/logaas")
-		{
-			logaas.Use(middlewares.TracerSetting("LOGaaS"))
-			logaas.POST("/:logaas_id", func(c *gin.Context) { services.CreateLogaas(c.Request.Context(), c, clientset, db) })
-			logaas.GET("/:logaas_id", func(c *gin.Context) { services.GetLogaas(c.Request.Context(), c, clientset, db) })
-			logaas.PUT("/:logaas_id", func(c *gin.Context) { services.UpdateLogaas(c.Request.Context(), c, clientset, db) })
-			logaas.DELETE("/:logaas_id", func(c *gin.Context) { services.DeleteLogaas(c.Request.Context(), c, clientset, db) })
-			logaas.GET("/", func(c *gin.Context) { services.GetLogaases(c.Request.Context(), c, clientset, db) })
-		}
+	// DBとクラスタの差分を検出するReconciler
+	reconcileCtx, stopReconciler := context.WithCancel(context.Background())
+	if cfg.Reconcile.Interval > 0 {
+		go services.NewReconciler(clients, db, cfg.Reconcile).Run(reconcileCtx)
 	}
 
-	go func() {
-		http.Handle("/metrics", promhttp.Handler())
-		http.ListenAndServe(":9090", nil)
-	}()
+	// OpenAPIのドキュメント（KeystoneAuthの前に登録し、トークンなしで取得できるようにする）
+	r.GET("/api/v1/openapi.json", services.GetOpenAPI)
+
+	v1 := r.Group("/api/v1")
+
+	// POST/PUT/DELETEを監査ログに記録（認証に失敗したリクエストも記録するためKeystoneAuthより前）
+	v1.Use(middlewares.Audit(db))
+	// HeaderのTokenをKeystoneで検証
+	v1.Use(middlewares.KeystoneAuth())
+	// トークンのプロジェクトをDBに登録
+	v1.Use(middlewares.RegisterProject(db))
+
+	RegisterRoutes(v1, clients, db, jm)
 
 	// indexページ
 	r.GET("/", services.Index)
+
+	return &Resources{DB: db, Jobs: jm, Telemetry: tel, stopReconciler: stopReconciler}
+}
+
+// /api/v1のルートを登録する（認証・監査ログのミドルウェアはv1に設定済みであること）
+func RegisterRoutes(v1 *gin.RouterGroup, clients *services.Clients, db *gorm.DB, jm *jobs.Manager) {
+	// CaaS関連ルート
+	caas := v1.Group("/caas")
+	{
+		caas.Use(middlewares.TracerSetting("CaaS"))
+		caas.POST("/:caas_id", func(c *gin.Context) { services.CreateCaas(c.Request.Context(), c, clients, db, jm) })
+		caas.GET("/:caas_id", func(c *gin.Context) { services.GetCaas(c.Request.Context(), c, clients, db) })
+		caas.PUT("/:caas_id", func(c *gin.Context) { services.UpdateCaas(c.Request.Context(), c, clients, db, jm) })
+		caas.DELETE("/:caas_id", func(c *gin.Context) { services.DeleteCaas(c.Request.Context(), c, clients, db, jm) })
+		caas.GET("/", func(c *gin.Context) { services.GetCaases(c.Request.Context(), c, clients, db) })
+	}
+
+	// LOGaaS関連ルート
+	logaas := v1.Group("/logaas")
+	{
+		logaas.Use(middlewares.TracerSetting("LOGaaS"))
+		logaas.POST("/:logaas_id", func(c *gin.Context) { services.CreateLogaas(c.Request.Context(), c, clients, db, jm) })
+		logaas.GET("/:logaas_id", func(c *gin.Context) { services.GetLogaas(c.Request.Context(), c, clients, db) })
+		logaas.PUT("/:logaas_id", func(c *gin.Context) { services.UpdateLogaas(c.Request.Context(), c, clients, db, jm) })
+		logaas.DELETE("/:logaas_id", func(c *gin.Context) { services.DeleteLogaas(c.Request.Context(), c, clients, db, jm) })
+		logaas.GET("/", func(c *gin.Context) { services.GetLogaases(c.Request.Context(), c, clients, db) })
+		logaas.GET("/volumes/leaked", func(c *gin.Context) { services.GetLeakedLogaasVolumes(c.Request.Context(), c, clients, db) })
+
+		// テナントのユーザーの認証情報（LOGaaSのプロジェクトのみ）
+		logaas.GET("/:logaas_id/credentials", func(c *gin.Context) { services.GetLogaasCredentials(c.Request.Context(), c, clients, db) })
+		logaas.POST("/:logaas_id/credentials/rotate", func(c *gin.Context) { services.RotateLogaasCredentials(c.Request.Context(), c, clients, db) })
+
+		// スナップショット（S3リポジトリの登録、取得/リストア、定期取得のポリシー）とインデックスの保持期間
+		logaas.PUT("/:logaas_id/snapshots/repository", func(c *gin.Context) { services.PutLogaasSnapshotRepository(c.Request.Context(), c, clients, db) })
+		logaas.GET("/:logaas_id/snapshots/repository", func(c *gin.Context) { services.GetLogaasSnapshotRepository(c.Request.Context(), c, clients, db) })
+		logaas.PUT("/:logaas_id/snapshots/policy", func(c *gin.Context) { services.PutLogaasSnapshotPolicy(c.Request.Context(), c, clients, db) })
+		logaas.GET("/:logaas_id/snapshots/policy", func(c *gin.Context) { services.GetLogaasSnapshotPolicy(c.Request.Context(), c, clients, db) })
+		logaas.DELETE("/:logaas_id/snapshots/policy", func(c *gin.Context) { services.DeleteLogaasSnapshotPolicy(c.Request.Context(), c, clients, db) })
+		logaas.POST("/:logaas_id/snapshots", func(c *gin.Context) { services.CreateLogaasSnapshot(c.Request.Context(), c, clients, db) })
+		logaas.GET("/:logaas_id/snapshots", func(c *gin.Context) { services.GetLogaasSnapshots(c.Request.Context(), c, clients, db) })
+		logaas.GET("/:logaas_id/snapshots/:snapshot", func(c *gin.Context) { services.GetLogaasSnapshot(c.Request.Context(), c, clients, db) })
+		logaas.DELETE("/:logaas_id/snapshots/:snapshot", func(c *gin.Context) { services.DeleteLogaasSnapshot(c.Request.Context(), c, clients, db) })
+		logaas.POST("/:logaas_id/snapshots/:snapshot/restore", func(c *gin.Context) { services.RestoreLogaasSnapshot(c.Request.Context(), c, clients, db) })
+		logaas.GET("/:logaas_id/ism-policies", func(c *gin.Context) { services.GetLogaasIsmPolicies(c.Request.Context(), c, clients, db) })
+		logaas.PUT("/:logaas_id/ism-policies/:policy_id", func(c *gin.Context) { services.PutLogaasIsmPolicy(c.Request.Context(), c, clients, db) })
+		logaas.GET("/:logaas_id/ism-policies/:policy_id", func(c *gin.Context) { services.GetLogaasIsmPolicy(c.Request.Context(), c, clients, db) })
+		logaas.DELETE("/:logaas_id/ism-policies/:policy_id", func(c *gin.Context) { services.DeleteLogaasIsmPolicy(c.Request.Context(), c, clients, db) })
+	}
+
+	// AAPaaS関連ルート
+	aapaas := v1.Group("/aapaas")
+	{
+		aapaas.Use(middlewares.TracerSetting("AAPaaS"))
+		aapaas.POST("/:aapaas_id", func(c *gin.Context) { services.CreateAapaas(c.Request.Context(), c, clients, db, jm) })
+		aapaas.GET("/:aapaas_id", func(c *gin.Context) { services.GetAapaas(c.Request.Context(), c, clients, db) })
+		aapaas.DELETE("/:aapaas_id", func(c *gin.Context) { services.DeleteAapaas(c.Request.Context(), c, clients, db, jm) })
+		aapaas.GET("/", func(c *gin.Context) { services.GetAapaases(c.Request.Context(), c, clients, db) })
+	}
+
+	// プロジェクト関連ルート
+	project := v1.Group("/projects")
+	{
+		project.POST("/:project_id", func(c *gin.Context) { services.CreateProject(c.Request.Context(), c, db) })
+		project.GET("/:project_id", func(c *gin.Context) { services.GetProject(c.Request.Context(), c, db) })
+		project.PUT("/:project_id", func(c *gin.Context) { services.UpdateProject(c.Request.Context(), c, db) })
+		project.DELETE("/:project_id", func(c *gin.Context) { services.DeleteProject(c.Request.Context(), c, db) })
+		project.GET("/:project_id/resources", func(c *gin.Context) { services.GetProjectResources(c.Request.Context(), c, db) })
+		project.GET("/", func(c *gin.Context) { services.GetProjects(c.Request.Context(), c, db) })
+	}
+
+	// Flavor関連ルート（参照のみ）
+	v1.GET("/flavors", services.GetFlavors)
+
+	// 監査ログ（管理者のみ）
+	v1.GET("/audit", func(c *gin.Context) { services.GetAuditLogs(c.Request.Context(), c, db) })
+
+	// ジョブ関連ルート
+	job := v1.Group("/jobs")
+	{
+		job.GET("/:job_id", func(c *gin.Context) { services.GetJob(c.Request.Context(), c, jm) })
+		job.GET("/:job_id/events", func(c *gin.Context) { services.GetJobEvents(c.Request.Context(), c, jm) })
+	}
 }
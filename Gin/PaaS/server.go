@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"ham3/config"
+	"ham3/routers"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// メトリクスのレスポンスの書き込みのタイムアウト（APIと異なり長時間のレスポンスはない）
+const metricsWriteTimeout = 30 * time.Second
+
+// APIとメトリクスのサーバーを起動し、SIGINT/SIGTERMを受け取るかサーバーが停止するまで待つ
+// 停止時は新しいリクエストの受付を止め、処理中のリクエストとジョブの完了を待ってからリソースを閉じる
+func runServer(cfg *config.Config, handler http.Handler, res *routers.Resources) error {
+	apiServer := &http.Server{
+		Addr:              cfg.Server.Addr,
+		Handler:           handler,
+		ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout,
+		ReadTimeout:       cfg.Server.ReadTimeout,
+		WriteTimeout:      cfg.Server.WriteTimeout,
+		IdleTimeout:       cfg.Server.IdleTimeout,
+	}
+
+	metricsMux := http.NewServeMux()
+	metricsMux.Handle("/metrics", promhttp.Handler())
+	metricsServer := &http.Server{
+		Addr:              cfg.Server.MetricsAddr,
+		Handler:           metricsMux,
+		ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout,
+		ReadTimeout:       cfg.Server.ReadTimeout,
+		WriteTimeout:      metricsWriteTimeout,
+		IdleTimeout:       cfg.Server.IdleTimeout,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// どちらかのサーバーが起動できない（ポートが使用中など）場合は停止する
+	serveErr := make(chan error, 2)
+	for name, server := range map[string]*http.Server{"API": apiServer, "metrics": metricsServer} {
+		go func(name string, server *http.Server) {
+			fmt.Printf("Listening %s server on %s\n", name, server.Addr)
+			if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+				serveErr <- fmt.Errorf("%s server failed: %v", name, err)
+			}
+		}(name, server)
+	}
+
+	var errs []error
+	select {
+	case <-ctx.Done():
+		fmt.Printf("Received signal, shutting down\n")
+	case err := <-serveErr:
+		errs = append(errs, err)
+	}
+	// 停止中に再度シグナルを受け取った場合はすぐに終了する
+	stop()
+
+	// 新しいリクエストの受付を止め、処理中のリクエスト（ジョブの登録を含む）の完了を待つ
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+	if err := apiServer.Shutdown(shutdownCtx); err != nil {
+		fmt.Printf("Error shutting down API server, closing connections: %v\n", err)
+		apiServer.Close()
+	}
+
+	// 実行中・実行待ちのジョブの完了を待つ（期限を過ぎたらキャンセルする）
+	fmt.Printf("Waiting for jobs to finish (timeout: %s)\n", cfg.Jobs.DrainTimeout)
+	drainCtx, cancelDrain := context.WithTimeout(context.Background(), cfg.Jobs.DrainTimeout)
+	defer cancelDrain()
+	if err := res.Jobs.Shutdown(drainCtx); err != nil {
+		errs = append(errs, fmt.Errorf("Jobs did not finish before shutdown: %v", err))
+	}
+
+	// ジョブの完了後にメトリクスのサーバーを止め、トレースを送信してDB接続を閉じる
+	closeCtx, cancelClose := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancelClose()
+	if err := metricsServer.Shutdown(closeCtx); err != nil {
+		metricsServer.Close()
+	}
+	if err := res.Shutdown(closeCtx); err != nil {
+		errs = append(errs, err)
+	}
+
+	fmt.Printf("Server stopped\n")
+	return errors.Join(errs...)
+}
@@ -0,0 +1,407 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"ham3/api"
+	"ham3/jobs"
+	"ham3/middlewares"
+	"ham3/models"
+	"ham3/utilities"
+
+	"github.com/gin-gonic/gin"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"gorm.io/gorm"
+	"helm.sh/helm/v3/pkg/storage/driver"
+)
+
+// AAPaaS名はラベルにしない
+var (
+	AapaasCreateCounter = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "aapaas_create_total",
+		Help: "The total number of AAPaaS created",
+	})
+
+	AapaasDeleteCounter = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "aapaas_delete_total",
+		Help: "The total number of AAPaaS deleted",
+	})
+)
+
+func IncreaseAAPaaSCreateCounter() {
+	AapaasCreateCounter.Inc()
+}
+
+func IncreaseAAPaaSDeleteCounter() {
+	AapaasDeleteCounter.Inc()
+}
+
+// AAPaaSのHelmリリース名（AWX Operatorと、Operatorが作成するAWXのインスタンスを含む）
+const aapaasReleaseName = "awx-operator"
+
+// AAPaaSをデプロイするNamespace（CaaSのNamespaceと名前が重ならないようにprefixを付ける）
+func aapaasNamespaceName(aapaas_id string) string {
+	return fmt.Sprintf("aapaas-%s", aapaas_id)
+}
+
+func updateAapaasStatus(db *gorm.DB, aapaas *models.AAPaaS, status string) {
+	if aapaas.ID == 0 {
+		return
+	}
+	if err := db.Model(aapaas).Update("status", status).Error; err != nil {
+		fmt.Printf("Error updating status of aapaas[%s] to %s: %v\n", aapaas.Name, status, err)
+	}
+}
+
+// DBからAAPaaSを取得し、操作権限を確認する（レスポンスはこの関数内で返す）
+// AAPaaSはDBに登録されていないものは存在しないものとして404を返す
+func getAuthorizedAapaas(c *gin.Context, db *gorm.DB, aapaas_id string) (*models.AAPaaS, bool) {
+	var aapaas models.AAPaaS
+	err := db.Where("name = ?", aapaas_id).First(&aapaas).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		respondError(c, http.StatusNotFound, fmt.Sprintf("%s aapaas not found", aapaas_id))
+		return nil, false
+	} else if err != nil {
+		fmt.Printf("Error getting aapaas from db: %v\n", err)
+		respondError(c, http.StatusInternalServerError, fmt.Sprintf("Error getting aapaas for %s\n Error messages: %s", aapaas_id, err))
+		return nil, false
+	}
+	if !middlewares.AuthorizeProject(c, aapaas.ProjectId) {
+		return nil, false
+	}
+	return &aapaas, true
+}
+
+// AAPaaSのNamespaceを作成するマニフェストの定義
+func aapaasNamespace(aapaas_id string, projectId string) *v1.Namespace {
+	return &v1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: aapaasNamespaceName(aapaas_id),
+			Labels: map[string]string{
+				"target-namespace": "metrics",
+				"app":              "aapaas",
+				"ham3/project":     projectId,
+			},
+		},
+	}
+}
+
+// AAPaaSの作成のステップ（Namespace作成、AWX Operatorのインストール）
+// 途中で失敗した場合は作成済みのリソースを削除する
+func aapaasCreateSteps(clients *Clients, aapaas_id string, projectId string, requestData api.AapaasRequestData) []utilities.Step {
+	namespace := aapaasNamespaceName(aapaas_id)
+	return []utilities.Step{
+		{
+			Name: "Create Namespace",
+			Run: func(ctx context.Context) error {
+				_, err := clients.Kube.CoreV1().Namespaces().Create(ctx, aapaasNamespace(aapaas_id, projectId), metav1.CreateOptions{})
+				return utilities.IgnoreAlreadyExists(err)
+			},
+			Rollback: func(ctx context.Context) error {
+				return utilities.IgnoreNotFound(clients.Kube.CoreV1().Namespaces().Delete(ctx, namespace, metav1.DeleteOptions{}))
+			},
+		},
+		{
+			Name: "Install AWX Operator",
+			Run: func(ctx context.Context) error {
+				// 作成に失敗したAAPaaSの再作成の場合、インストール済みのリリースはそのまま使う
+				if _, err := clients.Helm.Status(ctx, namespace, aapaasReleaseName); err == nil {
+					return nil
+				}
+				release, err := clients.Helm.Install(ctx, namespace, aapaasReleaseName, utilities.AwxOperatorChart.WithVersion(requestData.OperatorVersion), utilities.AapaasGetHelmValue(aapaas_id, requestData))
+				if err != nil {
+					return fmt.Errorf("Failed to install chart: %v", err)
+				}
+				fmt.Printf("Successfully installed chart with release name: %s (namespace: %s)\n", release.Name, namespace)
+				return nil
+			},
+			Rollback: func(ctx context.Context) error {
+				if err := clients.Helm.Uninstall(ctx, namespace, aapaasReleaseName); err != nil && !errors.Is(err, driver.ErrReleaseNotFound) {
+					return err
+				}
+				return nil
+			},
+		},
+	}
+}
+
+// AAPaaSの削除のステップ（すでに削除済みのリソースはスキップする）
+func aapaasDeleteSteps(clients *Clients, aapaas_id string) []utilities.Step {
+	namespace := aapaasNamespaceName(aapaas_id)
+	return []utilities.Step{
+		{
+			Name: "Uninstall AWX Operator",
+			Run: func(ctx context.Context) error {
+				if err := clients.Helm.Uninstall(ctx, namespace, aapaasReleaseName); err != nil && !errors.Is(err, driver.ErrReleaseNotFound) {
+					return fmt.Errorf("Failed to uninstall chart: %v", err)
+				}
+				return nil
+			},
+		},
+		{
+			Name: "Delete Namespace",
+			Run: func(ctx context.Context) error {
+				return utilities.IgnoreNotFound(clients.Kube.CoreV1().Namespaces().Delete(ctx, namespace, metav1.DeleteOptions{}))
+			},
+		},
+	}
+}
+
+// AAPaaSをcreatingでDBに登録する（同名のAAPaaSを同時に作成するリクエストのうち1つだけがtrueを返す）
+// 新規の場合はnameのユニークインデックス、作成に失敗したAAPaaSの再作成の場合はfailedを条件にした更新で競合を検出する
+func registerAapaas(db *gorm.DB, aapaas *models.AAPaaS) (bool, error) {
+	if aapaas.ID == 0 {
+		err := db.Create(aapaas).Error
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return false, nil
+		}
+		return err == nil, err
+	}
+	result := db.Model(aapaas).
+		Where("status = ?", models.StatusFailed).
+		Select("ProjectId", "Namespace", "Endpoint", "AdminUser", "AdminSecret", "OperatorVersion", "Status").
+		Updates(aapaas)
+	return result.RowsAffected == 1, result.Error
+}
+
+func CreateAapaas(ctx context.Context, c *gin.Context, clients *Clients, db *gorm.DB, jm *jobs.Manager) {
+	aapaas_id := c.Param("aapaas_id")
+
+	projectId := middlewares.TargetProjectId(c)
+
+	// リクエストボディは省略可能（省略した場合はデフォルト値で作成）
+	var requestData api.AapaasRequestData
+	utilities.AapaasGetDefaultValue(&requestData, clients.Defaults)
+	if err := c.ShouldBindJSON(&requestData); err != nil && !errors.Is(err, io.EOF) {
+		respondBindError(c, err)
+		return
+	}
+	if fieldErrors := utilities.CheckAapaasCreateParameters(aapaas_id, requestData); len(fieldErrors) > 0 {
+		respondFieldErrors(c, fieldErrors)
+		return
+	}
+
+	// DBに同名のAAPaaSが存在するか確認（作成に失敗したAAPaaSは再作成可能）
+	var aapaas models.AAPaaS
+	err := db.Where("name = ?", aapaas_id).First(&aapaas).Error
+	if err == nil && aapaas.Status != models.StatusFailed {
+		respondError(c, http.StatusBadRequest, fmt.Sprintf("%s aapaas already exists (status: %s)", aapaas_id, aapaas.Status))
+		return
+	} else if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		fmt.Printf("Error getting aapaas from db: %v\n", err)
+		respondError(c, http.StatusInternalServerError, fmt.Sprintf("Error getting aapaas for %s\n Error messages: %s", aapaas_id, err))
+		return
+	}
+	// 他のプロジェクトで作成に失敗したAAPaaSは再作成できない
+	if aapaas.ID != 0 && !middlewares.AuthorizeProject(c, aapaas.ProjectId) {
+		return
+	}
+	if !checkNoActiveJob(c, jm, models.ResourceAAPaaS, aapaas_id) {
+		return
+	}
+
+	// Namespaceが存在するか確認（作成に失敗したAAPaaSの再作成の場合は、残っているNamespaceをそのまま利用する）
+	namespace := aapaasNamespaceName(aapaas_id)
+	ns, err := clients.Kube.CoreV1().Namespaces().Get(ctx, namespace, metav1.GetOptions{})
+	if err == nil && !(aapaas.ID != 0 && ns.Labels["app"] == "aapaas") {
+		respondError(c, http.StatusBadRequest, fmt.Sprintf("%s namespace already exists", namespace))
+		return
+	} else if err != nil && !apierrors.IsNotFound(err) {
+		fmt.Printf("Error getting namespace: %v\n", err)
+		respondError(c, http.StatusInternalServerError, fmt.Sprintf("Error getting namespace for %s\n Error messages: %s", aapaas_id, err))
+		return
+	}
+
+	// AAPaaSをcreatingステータスでDBに登録
+	aapaas.ProjectId = projectId
+	aapaas.Name = aapaas_id
+	aapaas.Namespace = namespace
+	aapaas.Endpoint = fmt.Sprintf("https://%s", utilities.AapaasHost(aapaas_id, requestData.BaseDomain))
+	aapaas.AdminUser = requestData.AdminUser
+	aapaas.AdminSecret = utilities.AapaasAdminSecretName(aapaas_id)
+	aapaas.OperatorVersion = requestData.OperatorVersion
+	aapaas.Status = models.StatusCreating
+	registered, err := registerAapaas(db, &aapaas)
+	if err != nil {
+		fmt.Printf("Error saving aapaas to db: %v\n", err)
+		respondError(c, http.StatusInternalServerError, fmt.Sprintf("Error saving aapaas for %s\n Error messages: %s", aapaas_id, err))
+		return
+	}
+	if !registered {
+		respondError(c, http.StatusConflict, fmt.Sprintf("%s aapaas is being created by another request", aapaas_id))
+		return
+	}
+
+	job := &models.Job{ProjectId: projectId, ResourceType: models.ResourceAAPaaS, ResourceId: aapaas_id, Action: "create"}
+	submitted := submitJob(ctx, c, jm, job, func(ctx context.Context, rec *jobs.Recorder) error {
+		pipeline := utilities.Pipeline{
+			Tracer:     otel.Tracer("Create AAPaaS"),
+			Attributes: []attribute.KeyValue{attribute.String("service.name", "AAPaaS"), attribute.String("aapaas", aapaas_id)},
+			Steps:      aapaasCreateSteps(clients, aapaas_id, projectId, requestData),
+			Observer:   rec,
+		}
+		if err := pipeline.Run(ctx); err != nil {
+			fmt.Printf("Error creating aapaas[%s]: %v (completed steps: %v)\n", aapaas_id, err, pipeline.Completed)
+			updateAapaasStatus(db, &aapaas, models.StatusFailed)
+			return err
+		}
+		fmt.Printf("AAPaaS[%s] created successfully\n", aapaas_id)
+
+		updateAapaasStatus(db, &aapaas, models.StatusReady)
+		IncreaseAAPaaSCreateCounter()
+		return nil
+	})
+	if !submitted {
+		updateAapaasStatus(db, &aapaas, models.StatusFailed)
+	}
+}
+
+// DBの情報にHelmリリースとAWXのPodの状態、管理者パスワードのSecretの参照を合わせて返す
+func GetAapaas(ctx context.Context, c *gin.Context, clients *Clients, db *gorm.DB) {
+	aapaas_id := c.Param("aapaas_id")
+
+	aapaas, ok := getAuthorizedAapaas(c, db, aapaas_id)
+	if !ok {
+		return
+	}
+
+	release, err := clients.Helm.Status(ctx, aapaas.Namespace, aapaasReleaseName)
+	helmStatus := helmStatusResponse(release, err)
+
+	// AWX OperatorがAWXのPodに付与するラベル
+	var podStatus api.PodStatus
+	podList, err := clients.Kube.CoreV1().Pods(aapaas.Namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("app.kubernetes.io/part-of=%s", aapaas_id),
+	})
+	if err != nil {
+		podStatus = api.PodStatus{Error: err.Error()}
+	} else {
+		readyCount := 0
+		for _, pod := range podList.Items {
+			for _, condition := range pod.Status.Conditions {
+				if condition.Type == v1.PodReady && condition.Status == v1.ConditionTrue {
+					readyCount++
+				}
+			}
+		}
+		podStatus = api.PodStatus{Ready: readyCount, Total: len(podList.Items)}
+	}
+
+	c.JSON(http.StatusOK, api.Success(api.AapaasDetail{
+		Aapaas:   aapaasResponse(aapaas),
+		Endpoint: aapaas.Endpoint,
+		AdminCredentials: api.AdminCredentials{
+			User: aapaas.AdminUser,
+			Secret: api.SecretReference{
+				Namespace: aapaas.Namespace,
+				Name:      aapaas.AdminSecret,
+				Key:       "password",
+			},
+		},
+		Helm: helmStatus,
+		Pods: podStatus,
+	}))
+}
+
+func DeleteAapaas(ctx context.Context, c *gin.Context, clients *Clients, db *gorm.DB, jm *jobs.Manager) {
+	aapaas_id := c.Param("aapaas_id")
+
+	aapaas, ok := getAuthorizedAapaas(c, db, aapaas_id)
+	if !ok {
+		return
+	}
+	if !checkNoActiveJob(c, jm, models.ResourceAAPaaS, aapaas_id) {
+		return
+	}
+
+	// 削除中・作成中のAAPaaSは削除できない
+	previousStatus := aapaas.Status
+	if !claimStatus(c, db, aapaas, models.ResourceAAPaaS, aapaas_id, models.StatusDeleting, models.StatusReady, models.StatusFailed) {
+		return
+	}
+
+	job := &models.Job{ProjectId: aapaas.ProjectId, ResourceType: models.ResourceAAPaaS, ResourceId: aapaas_id, Action: "delete"}
+	submitted := submitJob(ctx, c, jm, job, func(ctx context.Context, rec *jobs.Recorder) error {
+		pipeline := utilities.Pipeline{
+			Tracer:     otel.Tracer("Delete AAPaaS"),
+			Attributes: []attribute.KeyValue{attribute.String("service.name", "AAPaaS"), attribute.String("aapaas", aapaas_id)},
+			Steps:      aapaasDeleteSteps(clients, aapaas_id),
+			Observer:   rec,
+		}
+		if err := pipeline.Run(ctx); err != nil {
+			fmt.Printf("Error deleting aapaas[%s]: %v\n", aapaas_id, err)
+			updateAapaasStatus(db, aapaas, models.StatusFailed)
+			return err
+		}
+
+		// ステータスをdeletedに更新してからレコードを論理削除
+		updateAapaasStatus(db, aapaas, models.StatusDeleted)
+		if err := db.Delete(aapaas).Error; err != nil {
+			fmt.Printf("Error deleting aapaas from db: %v\n", err)
+			return err
+		}
+		fmt.Printf("AAPaaS[%s] deleted successfully\n", aapaas_id)
+		IncreaseAAPaaSDeleteCounter()
+		return nil
+	})
+	if !submitted {
+		updateAapaasStatus(db, aapaas, previousStatus)
+	}
+}
+
+func GetAapaases(ctx context.Context, c *gin.Context, clients *Clients, db *gorm.DB) {
+	page, pageSize, err := utilities.GetPagination(c)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// project、statusで絞り込み（管理者以外は自身のプロジェクトのみ）
+	query := db.Model(&models.AAPaaS{})
+	project := c.Query("project")
+	if !middlewares.IsAdmin(c) {
+		if project != "" && !middlewares.AuthorizeProject(c, project) {
+			return
+		}
+		project = middlewares.GetProjectId(c)
+	}
+	if project != "" {
+		query = query.Where("project_id = ?", project)
+	}
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		fmt.Printf("Error counting aapaases: %v\n", err)
+		respondError(c, http.StatusInternalServerError, fmt.Sprintf("Error getting aapaases\n Error messages: %s", err))
+		return
+	}
+
+	var aapaases []models.AAPaaS
+	if err := query.Order("id").Offset((page - 1) * pageSize).Limit(pageSize).Find(&aapaases).Error; err != nil {
+		fmt.Printf("Error getting aapaases: %v\n", err)
+		respondError(c, http.StatusInternalServerError, fmt.Sprintf("Error getting aapaases\n Error messages: %s", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, api.Success(api.Page[api.Aapaas]{
+		Items:    convertAll(aapaases, aapaasResponse),
+		Total:    total,
+		Page:     page,
+		PageSize: pageSize,
+	}))
+}
@@ -0,0 +1,183 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"ham3/api"
+	"ham3/config"
+	"ham3/models"
+	"ham3/utilities"
+
+	"github.com/gin-gonic/gin"
+	"helm.sh/helm/v3/pkg/storage/driver"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestRegisterAapaasOnlyOnce(t *testing.T) {
+	db := newTestDb(t)
+
+	first := models.AAPaaS{ProjectId: "p", Name: "awx", Namespace: "aapaas-awx", Status: models.StatusCreating}
+	if ok, err := registerAapaas(db, &first); !ok || err != nil {
+		t.Fatalf("first register = %v, %v", ok, err)
+	}
+	second := models.AAPaaS{ProjectId: "p", Name: "awx", Namespace: "aapaas-awx", Status: models.StatusCreating}
+	if ok, err := registerAapaas(db, &second); ok || err != nil {
+		t.Fatalf("second register = %v, %v, want false, nil", ok, err)
+	}
+
+	// 作成に失敗したAAPaaSの再作成も1つのリクエストだけが成功する
+	db.Model(&first).Update("status", models.StatusFailed)
+	retryA, retryB := first, first
+	retryA.Status, retryB.Status = models.StatusCreating, models.StatusCreating
+	if ok, err := registerAapaas(db, &retryA); !ok || err != nil {
+		t.Fatalf("retry register = %v, %v", ok, err)
+	}
+	if ok, err := registerAapaas(db, &retryB); ok || err != nil {
+		t.Fatalf("concurrent retry register = %v, %v, want false, nil", ok, err)
+	}
+}
+
+func TestAapaasCreateGetDelete(t *testing.T) {
+	db := newTestDb(t)
+	jm := newTestJobManager(t, db)
+	clients := newTestClients()
+	clients.Defaults = config.DefaultsConfig{BaseDomain: "apps.example.com"}
+
+	r := newTestEngine()
+	r.POST("/aapaas/:aapaas_id", func(c *gin.Context) { CreateAapaas(c.Request.Context(), c, clients, db, jm) })
+	r.GET("/aapaas/:aapaas_id", func(c *gin.Context) { GetAapaas(c.Request.Context(), c, clients, db) })
+	r.DELETE("/aapaas/:aapaas_id", func(c *gin.Context) { DeleteAapaas(c.Request.Context(), c, clients, db, jm) })
+	ctx := context.Background()
+
+	// 作成（ボディを省略した場合は設定のbase-domainとデフォルトの管理者ユーザー）
+	job := waitAcceptedJob(t, jm, serve(r, http.MethodPost, "/aapaas/awx", ""))
+	if job.Status != models.JobSucceeded {
+		t.Fatalf("create job = %s (%s), steps: %v", job.Status, job.Error, jobStepResults(job))
+	}
+	ns, err := clients.Kube.CoreV1().Namespaces().Get(ctx, "aapaas-awx", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ns.Labels["app"] != "aapaas" {
+		t.Errorf("namespace labels = %v, want app=aapaas", ns.Labels)
+	}
+	release, err := clients.Helm.Status(ctx, "aapaas-awx", aapaasReleaseName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	awx := release.Config["AWX"].(map[string]interface{})
+	spec := awx["spec"].(map[string]interface{})
+	if awx["name"] != "awx" || spec["route_host"] != "awx.aap.apps.example.com" || spec["admin_user"] != "admin" {
+		t.Errorf("AWX values = %v", awx)
+	}
+
+	// 接続先と管理者パスワードのSecretの参照
+	w := serve(r, http.MethodGet, "/aapaas/awx", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("get status = %d: %s", w.Code, w.Body)
+	}
+	var detail api.Response[api.AapaasDetail]
+	if err := json.Unmarshal(w.Body.Bytes(), &detail); err != nil {
+		t.Fatal(err)
+	}
+	wantSecret := api.SecretReference{Namespace: "aapaas-awx", Name: "awx-admin-password", Key: "password"}
+	if detail.Message.Endpoint != "https://awx.aap.apps.example.com" || detail.Message.AdminCredentials.Secret != wantSecret ||
+		detail.Message.Aapaas.Status != models.StatusReady || detail.Message.Helm.Status != "deployed" {
+		t.Errorf("detail = %+v", detail.Message)
+	}
+
+	// 作成済みのAAPaaSは作成できない
+	if w := serve(r, http.MethodPost, "/aapaas/awx", ""); w.Code != http.StatusBadRequest {
+		t.Errorf("second create status = %d, want 400: %s", w.Code, w.Body)
+	}
+
+	// 削除（リリースとNamespaceを削除してからレコードを論理削除する）
+	job = waitAcceptedJob(t, jm, serve(r, http.MethodDelete, "/aapaas/awx", ""))
+	if job.Status != models.JobSucceeded {
+		t.Fatalf("delete job = %s (%s), steps: %v", job.Status, job.Error, jobStepResults(job))
+	}
+	if _, err := clients.Helm.Status(ctx, "aapaas-awx", aapaasReleaseName); !errors.Is(err, driver.ErrReleaseNotFound) {
+		t.Errorf("release after delete: %v", err)
+	}
+	if _, err := clients.Kube.CoreV1().Namespaces().Get(ctx, "aapaas-awx", metav1.GetOptions{}); !apierrors.IsNotFound(err) {
+		t.Errorf("namespace after delete: %v", err)
+	}
+	if w := serve(r, http.MethodGet, "/aapaas/awx", ""); w.Code != http.StatusNotFound {
+		t.Errorf("get after delete status = %d, want 404", w.Code)
+	}
+}
+
+// AWX Operatorのインストールに失敗した場合はNamespaceを削除し、同じ名前で再作成できる
+func TestCreateAapaasRollsBackNamespace(t *testing.T) {
+	db := newTestDb(t)
+	jm := newTestJobManager(t, db)
+	clients := newTestClients()
+	helm := clients.Helm
+	clients.Helm = failingHelm{HelmInstaller: helm, failRelease: aapaasReleaseName}
+
+	r := newTestEngine()
+	r.POST("/aapaas/:aapaas_id", func(c *gin.Context) { CreateAapaas(c.Request.Context(), c, clients, db, jm) })
+	ctx := context.Background()
+
+	job := waitAcceptedJob(t, jm, serve(r, http.MethodPost, "/aapaas/awx", `{"base-domain": "apps.example.com"}`))
+	if job.Status != models.JobFailed {
+		t.Fatalf("create job = %s, want failed", job.Status)
+	}
+	steps := jobStepResults(job)
+	for _, want := range []string{"Install AWX Operator=failed", "Rollback Create Namespace=succeeded"} {
+		if !utilities.Contains(steps, want) {
+			t.Errorf("steps %v do not contain %q", steps, want)
+		}
+	}
+	if _, err := clients.Kube.CoreV1().Namespaces().Get(ctx, "aapaas-awx", metav1.GetOptions{}); !apierrors.IsNotFound(err) {
+		t.Errorf("namespace after rollback: %v", err)
+	}
+	var aapaas models.AAPaaS
+	if err := db.Where("name = ?", "awx").First(&aapaas).Error; err != nil {
+		t.Fatal(err)
+	}
+	if aapaas.Status != models.StatusFailed {
+		t.Errorf("status = %s, want failed", aapaas.Status)
+	}
+
+	clients.Helm = helm
+	if job := waitAcceptedJob(t, jm, serve(r, http.MethodPost, "/aapaas/awx", `{"base-domain": "apps.example.com"}`)); job.Status != models.JobSucceeded {
+		t.Fatalf("re-create job = %s (%s)", job.Status, job.Error)
+	}
+}
+
+func TestCreateAapaasValidation(t *testing.T) {
+	db := newTestDb(t)
+	jm := newTestJobManager(t, db)
+	clients := newTestClients()
+
+	r := newTestEngine()
+	r.POST("/aapaas/:aapaas_id", func(c *gin.Context) { CreateAapaas(c.Request.Context(), c, clients, db, jm) })
+
+	// base-domainの設定がない場合はリクエストで指定する必要がある
+	w := serve(r, http.MethodPost, "/aapaas/AWX_1", `{"admin-user": ""}`)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400: %s", w.Code, w.Body)
+	}
+	var response api.ErrorResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	var fields []string
+	for _, fieldError := range response.Errors {
+		fields = append(fields, fieldError.Field)
+	}
+	for _, want := range []string{"aapaas_id", "admin-user", "base-domain"} {
+		if !utilities.Contains(fields, want) {
+			t.Errorf("field errors %v do not contain %s", fields, want)
+		}
+	}
+	if _, err := clients.Kube.CoreV1().Namespaces().Get(context.Background(), "aapaas-AWX_1", metav1.GetOptions{}); !apierrors.IsNotFound(err) {
+		t.Errorf("namespace created for invalid request: %v", err)
+	}
+}
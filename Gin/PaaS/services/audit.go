@@ -0,0 +1,94 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"ham3/api"
+	"ham3/models"
+	"ham3/utilities"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 監査ログの一覧（管理者のみ、新しい順）
+// from/to(RFC3339)の期間、resource_type、resource_id、project、user、action、outcomeで絞り込み
+func GetAuditLogs(ctx context.Context, c *gin.Context, db *gorm.DB) {
+	if !requireAdmin(c) {
+		return
+	}
+
+	page, pageSize, err := utilities.GetPagination(c)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	query := db.Model(&models.AuditLog{})
+
+	var from, to time.Time
+	for _, param := range []struct {
+		name  string
+		value *time.Time
+	}{{"from", &from}, {"to", &to}} {
+		value := c.Query(param.name)
+		if value == "" {
+			continue
+		}
+		if *param.value, err = time.Parse(time.RFC3339, value); err != nil {
+			respondError(c, http.StatusBadRequest, fmt.Sprintf("%s must be RFC3339 (e.g. 2024-01-02T15:04:05Z)", param.name))
+			return
+		}
+	}
+	if !from.IsZero() && !to.IsZero() && to.Before(from) {
+		respondError(c, http.StatusBadRequest, "to must not be before from")
+		return
+	}
+	if !from.IsZero() {
+		query = query.Where("created_at >= ?", from)
+	}
+	if !to.IsZero() {
+		query = query.Where("created_at < ?", to)
+	}
+
+	for param, column := range map[string]string{
+		"resource_type": "resource_type",
+		"resource_id":   "resource_id",
+		"project":       "project_id",
+		"action":        "action",
+		"outcome":       "outcome",
+		"error_code":    "error_code",
+	} {
+		if value := c.Query(param); value != "" {
+			query = query.Where(column+" = ?", value)
+		}
+	}
+	// ユーザー名またはユーザーID
+	if user := c.Query("user"); user != "" {
+		query = query.Where("user_name = ? OR user_id = ?", user, user)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		fmt.Printf("Error counting audit logs: %v\n", err)
+		respondError(c, http.StatusInternalServerError, fmt.Sprintf("Error getting audit logs\n Error messages: %s", err))
+		return
+	}
+
+	var logs []models.AuditLog
+	if err := query.Order("id DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&logs).Error; err != nil {
+		fmt.Printf("Error getting audit logs: %v\n", err)
+		respondError(c, http.StatusInternalServerError, fmt.Sprintf("Error getting audit logs\n Error messages: %s", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, api.Success(api.Page[api.AuditLog]{
+		Items:    convertAll(logs, auditLogResponse),
+		Total:    total,
+		Page:     page,
+		PageSize: pageSize,
+	}))
+}
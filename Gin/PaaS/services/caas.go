@@ -2,58 +2,120 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 
+	"ham3/api"
+	"ham3/config"
+	"ham3/jobs"
+	"ham3/middlewares"
+	"ham3/models"
+	"ham3/telemetry"
+	"ham3/utilities"
+
 	"github.com/gin-gonic/gin"
 	v1 "k8s.io/api/core/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
-	"go.opentelemetry.io/otel/trace"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 	"gorm.io/gorm"
 )
 
+// CaaS名やプロジェクトはラベルにしない（プロジェクトごとの数はpaas_resourcesやDBで確認する）
 var (
-	CaasCreateCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+	CaasCreateCounter = promauto.NewCounter(prometheus.CounterOpts{
 		Name: "caas_create_total",
 		Help: "The total number of CaaS created",
-	},
-		[]string{"tenant"},
-	)
+	})
 
-	CaasDeleteCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+	CaasDeleteCounter = promauto.NewCounter(prometheus.CounterOpts{
 		Name: "caas_delete_total",
 		Help: "The total number of CaaS deleted",
+	})
+
+	CaasDriftGauge = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "caas_drifted",
+		Help: "The number of CaaS whose cluster state differs from the DB",
+	})
+
+	CaasRepairCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "caas_repair_total",
+		Help: "The total number of CaaS objects re-created by the reconciler",
 	},
-		[]string{"tenant"},
+		[]string{"kind"},
 	)
 )
 
-func IncreaseCaaSCreateCounter(tenant string) {
-	CaasCreateCounter.WithLabelValues(tenant).Inc()
+func IncreaseCaaSCreateCounter() {
+	CaasCreateCounter.Inc()
 }
 
-func IncreaseCaaSDeleteCounter(tenant string) {
-	CaasDeleteCounter.WithLabelValues(tenant).Inc()
+func IncreaseCaaSDeleteCounter() {
+	CaasDeleteCounter.Inc()
 }
 
-func CreateCaas(ctx context.Context, c *gin.Context, clientset *kubernetes.Clientset, db *gorm.DB) {
-	caas_id := c.Param("caas_id")
+// CaaSのステータスを更新する（DBに登録されていないCaaSの場合は何もしない）
+func updateCaasStatus(db *gorm.DB, caas *models.CaaS, status string) {
+	if caas.ID == 0 {
+		return
+	}
+	if err := db.Model(caas).Update("status", status).Error; err != nil {
+		fmt.Printf("Error updating status of caas[%s] to %s: %v\n", caas.Namespace, status, err)
+	}
+}
 
-	// Tracerの設定
-	tr := otel.Tracer("Create CaaS Cluster")
-	_, span := tr.Start(ctx, "Create Namespace", trace.WithAttributes(attribute.String("service.name", "CaaS"), attribute.String("tenant", caas_id)))
+// DBからCaaSを取得し、操作権限を確認する（レスポンスはこの関数内で返す）
+// DB登録以前に作成されたCaaSはレコードが存在しない（IDが0）ため、管理者のみ操作可能
+func getAuthorizedCaas(c *gin.Context, db *gorm.DB, caas_id string) (*models.CaaS, bool) {
+	var caas models.CaaS
+	if err := db.Where("namespace = ?", caas_id).First(&caas).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		fmt.Printf("Error getting caas from db: %v\n", err)
+		respondError(c, http.StatusInternalServerError, fmt.Sprintf("Error getting caas for %s\n Error messages: %s", caas_id, err))
+		return nil, false
+	}
+	if !middlewares.AuthorizeProject(c, caas.ProjectId) {
+		return nil, false
+	}
+	return &caas, true
+}
 
-	// Namespaceを作成するマニフェストの定義
-	namespace := &v1.Namespace{
+// DBに登録されているCaaSのサイズ
+// サイズ指定に対応する以前に作成されたCaaSはデフォルトのPlanで作成されている
+func caasPlanFromModel(caas *models.CaaS) config.CaasPlan {
+	if caas.RequestsCpu == "" {
+		return config.CaasPlans[config.CaasDefaultPlan]
+	}
+	return config.CaasPlan{
+		RequestsCpu:    caas.RequestsCpu,
+		RequestsMemory: caas.RequestsMemory,
+		Pods:           caas.Pods,
+		LimitCpu:       caas.LimitCpu,
+		LimitMemory:    caas.LimitMemory,
+	}
+}
+
+func setCaasPlan(caas *models.CaaS, planName string, plan config.CaasPlan) {
+	caas.Plan = planName
+	caas.RequestsCpu = plan.RequestsCpu
+	caas.RequestsMemory = plan.RequestsMemory
+	caas.Pods = plan.Pods
+	caas.LimitCpu = plan.LimitCpu
+	caas.LimitMemory = plan.LimitMemory
+}
+
+// CaaSのNamespaceを作成するマニフェストの定義
+func caasNamespace(caas_id string) *v1.Namespace {
+	return &v1.Namespace{
 		ObjectMeta: metav1.ObjectMeta{
 			Name: caas_id,
 			Labels: map[string]string{
@@ -62,31 +124,11 @@ func CreateCaas(ctx context.Context, c *gin.Context, clientset *kubernetes.Clien
 			},
 		},
 	}
+}
 
-	// Namespaceが存在するか確認、Namespace作成 (指定したnamespaceがすでに存在する場合はerrはnilになる)
-	// Namespaceが存在する場合は以降の処理をスキップ
-	ns, err := clientset.CoreV1().Namespaces().Get(context.TODO(), namespace.Name, metav1.GetOptions{})
-	if err != nil {
-		_, err := clientset.CoreV1().Namespaces().Create(context.TODO(), namespace, metav1.CreateOptions{})
-		if err != nil {
-			fmt.Printf("Error creating namespace: %v\n", err)
-		}
-		fmt.Printf("Namespace[%v] created successfully\n", namespace.Name)
-		span.End()
-	} else {
-		fmt.Printf("Namespace already exists: %v\n", ns)
-		c.JSON(http.StatusBadRequest, gin.H{
-			"status":  "error",
-			"message": fmt.Sprintf("%s namespace already exists", caas_id),
-		})
-		span.End()
-		return
-	}
-
-	_, span2 := tr.Start(ctx, "Create ResourceQuota", trace.WithAttributes(attribute.String("service.name", "CaaS"), attribute.String("tenant", caas_id)))
-
-	// ResourceQuotaを作成するマニフェストの定義
-	resourceQuota := &v1.ResourceQuota{
+// CaaSのResourceQuotaを作成するマニフェストの定義
+func caasResourceQuota(caas_id string, plan config.CaasPlan) *v1.ResourceQuota {
+	return &v1.ResourceQuota{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      fmt.Sprintf("quota-%s", caas_id),
 			Namespace: caas_id,
@@ -96,31 +138,17 @@ func CreateCaas(ctx context.Context, c *gin.Context, clientset *kubernetes.Clien
 		},
 		Spec: v1.ResourceQuotaSpec{
 			Hard: v1.ResourceList{
-				v1.ResourceRequestsCPU:    resource.MustParse("10"),
-				v1.ResourceRequestsMemory: resource.MustParse("10Gi"),
-				v1.ResourcePods:           resource.MustParse("20"),
+				v1.ResourceRequestsCPU:    resource.MustParse(plan.RequestsCpu),
+				v1.ResourceRequestsMemory: resource.MustParse(plan.RequestsMemory),
+				v1.ResourcePods:           resource.MustParse(plan.Pods),
 			},
 		},
 	}
+}
 
-	// ResourceQuotaを作成（ResourceQuotas()内のパラメータはnamespaceを指しており、必須）
-	_, err = clientset.CoreV1().ResourceQuotas(caas_id).Create(context.TODO(), resourceQuota, metav1.CreateOptions{})
-	if err != nil {
-		fmt.Printf("Error creating resourcequota: %v\n", err)
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"status":  "error",
-			"message": fmt.Sprintf("Error creating resourcequota for %s\n Error messages: %s", caas_id, err),
-		})
-		span2.End()
-		return
-	} else {
-		span2.End()
-	}
-
-	_, span3 := tr.Start(ctx, "Create LimitRange", trace.WithAttributes(attribute.String("service.name", "CaaS"), attribute.String("tenant", caas_id)))
-
-	// LimitRangeを作成するマニフェストの定義
-	limitRange := &v1.LimitRange{
+// CaaSのLimitRangeを作成するマニフェストの定義
+func caasLimitRange(caas_id string, plan config.CaasPlan) *v1.LimitRange {
+	return &v1.LimitRange{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      fmt.Sprintf("limit-%s", caas_id),
 			Namespace: caas_id,
@@ -133,8 +161,8 @@ func CreateCaas(ctx context.Context, c *gin.Context, clientset *kubernetes.Clien
 				{
 					Type: v1.LimitTypePod,
 					Max: v1.ResourceList{
-						v1.ResourceCPU:    resource.MustParse("4000m"),
-						v1.ResourceMemory: resource.MustParse("2048Mi"),
+						v1.ResourceCPU:    resource.MustParse(plan.LimitCpu),
+						v1.ResourceMemory: resource.MustParse(plan.LimitMemory),
 					},
 					MaxLimitRequestRatio: v1.ResourceList{
 						v1.ResourceCPU:    resource.MustParse("2.1"),
@@ -144,25 +172,11 @@ func CreateCaas(ctx context.Context, c *gin.Context, clientset *kubernetes.Clien
 			},
 		},
 	}
+}
 
-	// LimitRangeを作成
-	_, err = clientset.CoreV1().LimitRanges(caas_id).Create(context.TODO(), limitRange, metav1.CreateOptions{})
-	if err != nil {
-		fmt.Printf("Error creating limitrange: %v\n", err)
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"status":  "error",
-			"message": fmt.Sprintf("Error creating limitrange for %s\n Error messages: %s", caas_id, err),
-		})
-		span3.End()
-		return
-	} else {
-		span3.End()
-	}
-
-	_, span4 := tr.Start(ctx, "Create RoleBinding", trace.WithAttributes(attribute.String("service.name", "CaaS"), attribute.String("tenant", caas_id)))
-
-	// RoleBindingを作成するマニフェストの定義
-	roleBinding := &rbacv1.RoleBinding{
+// CaaSのRoleBindingを作成するマニフェストの定義
+func caasRoleBinding(caas_id string) *rbacv1.RoleBinding {
+	return &rbacv1.RoleBinding{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      fmt.Sprintf("cass-user-role-%s", caas_id),
 			Namespace: caas_id,
@@ -183,187 +197,484 @@ func CreateCaas(ctx context.Context, c *gin.Context, clientset *kubernetes.Clien
 			APIGroup: "rbac.authorization.k8s.io",
 		},
 	}
+}
 
-	// RoleBindingを作成
-	_, err = clientset.RbacV1().RoleBindings(caas_id).Create(context.TODO(), roleBinding, metav1.CreateOptions{})
+// CaaS作成のステップ（失敗した場合は作成済みのリソースを逆順に削除する）
+// 再作成時に残っているリソースはそのまま利用する
+func caasCreateSteps(clientset kubernetes.Interface, caas_id string, plan config.CaasPlan) []utilities.Step {
+	return []utilities.Step{
+		{
+			Name: "Create Namespace",
+			Run: func(ctx context.Context) error {
+				_, err := clientset.CoreV1().Namespaces().Create(ctx, caasNamespace(caas_id), metav1.CreateOptions{})
+				return utilities.IgnoreAlreadyExists(err)
+			},
+			Rollback: func(ctx context.Context) error {
+				return utilities.IgnoreNotFound(clientset.CoreV1().Namespaces().Delete(ctx, caas_id, metav1.DeleteOptions{}))
+			},
+		},
+		{
+			// ResourceQuotas()内のパラメータはnamespaceを指しており、必須
+			Name: "Create ResourceQuota",
+			Run: func(ctx context.Context) error {
+				_, err := clientset.CoreV1().ResourceQuotas(caas_id).Create(ctx, caasResourceQuota(caas_id, plan), metav1.CreateOptions{})
+				return utilities.IgnoreAlreadyExists(err)
+			},
+			Rollback: func(ctx context.Context) error {
+				return utilities.IgnoreNotFound(clientset.CoreV1().ResourceQuotas(caas_id).Delete(ctx, fmt.Sprintf("quota-%s", caas_id), metav1.DeleteOptions{}))
+			},
+		},
+		{
+			Name: "Create LimitRange",
+			Run: func(ctx context.Context) error {
+				_, err := clientset.CoreV1().LimitRanges(caas_id).Create(ctx, caasLimitRange(caas_id, plan), metav1.CreateOptions{})
+				return utilities.IgnoreAlreadyExists(err)
+			},
+			Rollback: func(ctx context.Context) error {
+				return utilities.IgnoreNotFound(clientset.CoreV1().LimitRanges(caas_id).Delete(ctx, fmt.Sprintf("limit-%s", caas_id), metav1.DeleteOptions{}))
+			},
+		},
+		{
+			Name: "Create RoleBinding",
+			Run: func(ctx context.Context) error {
+				_, err := clientset.RbacV1().RoleBindings(caas_id).Create(ctx, caasRoleBinding(caas_id), metav1.CreateOptions{})
+				return utilities.IgnoreAlreadyExists(err)
+			},
+			Rollback: func(ctx context.Context) error {
+				return utilities.IgnoreNotFound(clientset.RbacV1().RoleBindings(caas_id).Delete(ctx, fmt.Sprintf("cass-user-role-%s", caas_id), metav1.DeleteOptions{}))
+			},
+		},
+	}
+}
+
+// CaaSのサイズ変更のステップ（ResourceQuotaとLimitRangeをその場で更新する）
+// 失敗した場合は更新前のspecに戻す
+func caasResizeSteps(clientset kubernetes.Interface, caas_id string, plan config.CaasPlan) []utilities.Step {
+	var oldQuota v1.ResourceQuotaSpec
+	var oldLimit v1.LimitRangeSpec
+
+	return []utilities.Step{
+		{
+			Name: "Update ResourceQuota",
+			Run: func(ctx context.Context) error {
+				quota, err := clientset.CoreV1().ResourceQuotas(caas_id).Get(ctx, fmt.Sprintf("quota-%s", caas_id), metav1.GetOptions{})
+				if err != nil {
+					return err
+				}
+				oldQuota = quota.Spec
+				quota.Spec = caasResourceQuota(caas_id, plan).Spec
+				_, err = clientset.CoreV1().ResourceQuotas(caas_id).Update(ctx, quota, metav1.UpdateOptions{})
+				return err
+			},
+			Rollback: func(ctx context.Context) error {
+				quota, err := clientset.CoreV1().ResourceQuotas(caas_id).Get(ctx, fmt.Sprintf("quota-%s", caas_id), metav1.GetOptions{})
+				if err != nil {
+					return err
+				}
+				quota.Spec = oldQuota
+				_, err = clientset.CoreV1().ResourceQuotas(caas_id).Update(ctx, quota, metav1.UpdateOptions{})
+				return err
+			},
+		},
+		{
+			Name: "Update LimitRange",
+			Run: func(ctx context.Context) error {
+				limit, err := clientset.CoreV1().LimitRanges(caas_id).Get(ctx, fmt.Sprintf("limit-%s", caas_id), metav1.GetOptions{})
+				if err != nil {
+					return err
+				}
+				oldLimit = limit.Spec
+				limit.Spec = caasLimitRange(caas_id, plan).Spec
+				_, err = clientset.CoreV1().LimitRanges(caas_id).Update(ctx, limit, metav1.UpdateOptions{})
+				return err
+			},
+			Rollback: func(ctx context.Context) error {
+				limit, err := clientset.CoreV1().LimitRanges(caas_id).Get(ctx, fmt.Sprintf("limit-%s", caas_id), metav1.GetOptions{})
+				if err != nil {
+					return err
+				}
+				limit.Spec = oldLimit
+				_, err = clientset.CoreV1().LimitRanges(caas_id).Update(ctx, limit, metav1.UpdateOptions{})
+				return err
+			},
+		},
+	}
+}
+
+// CaaS削除のステップ（すでに存在しないリソースはスキップする）
+func caasDeleteSteps(clientset kubernetes.Interface, caas_id string) []utilities.Step {
+	return []utilities.Step{
+		{
+			Name: "Delete ResourceQuota",
+			Run: func(ctx context.Context) error {
+				return utilities.IgnoreNotFound(clientset.CoreV1().ResourceQuotas(caas_id).Delete(ctx, fmt.Sprintf("quota-%s", caas_id), metav1.DeleteOptions{}))
+			},
+		},
+		{
+			Name: "Delete LimitRange",
+			Run: func(ctx context.Context) error {
+				return utilities.IgnoreNotFound(clientset.CoreV1().LimitRanges(caas_id).Delete(ctx, fmt.Sprintf("limit-%s", caas_id), metav1.DeleteOptions{}))
+			},
+		},
+		{
+			Name: "Delete RoleBinding",
+			Run: func(ctx context.Context) error {
+				return utilities.IgnoreNotFound(clientset.RbacV1().RoleBindings(caas_id).Delete(ctx, fmt.Sprintf("cass-user-role-%s", caas_id), metav1.DeleteOptions{}))
+			},
+		},
+		{
+			Name: "Delete Namespace",
+			Run: func(ctx context.Context) error {
+				return utilities.IgnoreNotFound(clientset.CoreV1().Namespaces().Delete(ctx, caas_id, metav1.DeleteOptions{}))
+			},
+		},
+	}
+}
+
+// CaaSをcreatingでDBに登録する（同名のCaaSを同時に作成するリクエストのうち1つだけがtrueを返す）
+// 新規の場合はNamespaceのユニークインデックス、作成に失敗したCaaSの再作成の場合はfailedを条件にした更新で競合を検出する
+func registerCaas(db *gorm.DB, caas *models.CaaS) (bool, error) {
+	if caas.ID == 0 {
+		err := db.Create(caas).Error
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return false, nil
+		}
+		return err == nil, err
+	}
+	result := db.Model(caas).
+		Where("status = ?", models.StatusFailed).
+		Select("ProjectId", "Status", "Plan", "RequestsCpu", "RequestsMemory", "Pods", "LimitCpu", "LimitMemory").
+		Updates(caas)
+	return result.RowsAffected == 1, result.Error
+}
+
+func CreateCaas(ctx context.Context, c *gin.Context, clients *Clients, db *gorm.DB, jm *jobs.Manager) {
+	caas_id := c.Param("caas_id")
+
+	projectId := middlewares.TargetProjectId(c)
+
+	// リクエストボディは省略可能（省略した場合はデフォルトのPlanで作成）
+	var requestData api.CaasRequestData
+	if err := c.ShouldBindJSON(&requestData); err != nil && !errors.Is(err, io.EOF) {
+		respondBindError(c, err)
+		return
+	}
+	plan, err := utilities.ResolveCaasPlan(requestData, nil)
 	if err != nil {
-		fmt.Printf("Error creating rolebinding: %v\n", err)
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"status":  "error",
-			"message": fmt.Sprintf("Error creating rolebinding for %s\n Error messages: %s", caas_id, err),
-		})
-		span4.End()
+		respondError(c, http.StatusBadRequest, err.Error())
 		return
-	} else {
-		span4.End()
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"status":  "success",
-		"message": fmt.Sprintf("Created CaaS for %s successfully", caas_id),
+	// DBに同名のCaaSが存在するか確認（作成に失敗したCaaSは再作成可能）
+	var caas models.CaaS
+	err = db.Where("namespace = ?", caas_id).First(&caas).Error
+	if err == nil && caas.Status != models.StatusFailed {
+		respondError(c, http.StatusConflict, fmt.Sprintf("%s caas already exists (status: %s)", caas_id, caas.Status))
+		return
+	} else if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		fmt.Printf("Error getting caas from db: %v\n", err)
+		respondError(c, http.StatusInternalServerError, fmt.Sprintf("Error getting caas for %s\n Error messages: %s", caas_id, err))
+		return
+	}
+	// 他のプロジェクトで作成に失敗したCaaSは再作成できない
+	if caas.ID != 0 && !middlewares.AuthorizeProject(c, caas.ProjectId) {
+		return
+	}
+
+	// Namespaceが存在するか確認 (指定したnamespaceがすでに存在する場合はerrはnilになる)
+	// 作成に失敗したCaaSの再作成の場合は、残っているCaaSのNamespaceをそのまま利用する
+	ns, err := clients.Kube.CoreV1().Namespaces().Get(ctx, caas_id, metav1.GetOptions{})
+	if err == nil && !(caas.ID != 0 && ns.Labels["app"] == "caas") {
+		fmt.Printf("Namespace already exists: %v\n", ns)
+		respondError(c, http.StatusBadRequest, fmt.Sprintf("%s namespace already exists", caas_id))
+		return
+	} else if err != nil && !apierrors.IsNotFound(err) {
+		fmt.Printf("Error getting namespace: %v\n", err)
+		respondError(c, http.StatusInternalServerError, fmt.Sprintf("Error getting namespace for %s\n Error messages: %s", caas_id, err))
+		return
+	}
+
+	// CaaSをcreatingステータスでDBに登録
+	caas.ProjectId = projectId
+	caas.Namespace = caas_id
+	caas.Status = models.StatusCreating
+	setCaasPlan(&caas, caasPlanName(requestData, config.CaasDefaultPlan), plan)
+	registered, err := registerCaas(db, &caas)
+	if err != nil {
+		fmt.Printf("Error saving caas to db: %v\n", err)
+		respondError(c, http.StatusInternalServerError, fmt.Sprintf("Error saving caas for %s\n Error messages: %s", caas_id, err))
+		return
+	}
+	if !registered {
+		respondError(c, http.StatusConflict, fmt.Sprintf("%s caas is being created by another request", caas_id))
+		return
+	}
+
+	// Namespace、ResourceQuota、LimitRange、RoleBindingをジョブで順番に作成
+	// 途中で失敗した場合は作成済みのリソースを削除する
+	job := &models.Job{ProjectId: projectId, ResourceType: models.ResourceCaaS, ResourceId: caas_id, Action: "create"}
+	submitted := submitJob(ctx, c, jm, job, func(ctx context.Context, rec *jobs.Recorder) error {
+		pipeline := utilities.Pipeline{
+			Tracer:     otel.Tracer("Create CaaS Cluster"),
+			Attributes: []attribute.KeyValue{attribute.String("service.name", "CaaS"), attribute.String("tenant", caas_id)},
+			Steps:      caasCreateSteps(clients.Kube, caas_id, plan),
+			Observer:   rec,
+		}
+		if err := pipeline.Run(ctx); err != nil {
+			fmt.Printf("Error creating caas[%s]: %v (completed steps: %v)\n", caas_id, err, pipeline.Completed)
+			updateCaasStatus(db, &caas, models.StatusFailed)
+			return err
+		}
+		fmt.Printf("CaaS[%s] created successfully\n", caas_id)
+
+		updateCaasStatus(db, &caas, models.StatusReady)
+		IncreaseCaaSCreateCounter()
+		return nil
+	})
+	if !submitted {
+		updateCaasStatus(db, &caas, models.StatusFailed)
+	}
+}
+
+// DBに登録するPlan名
+// Planを指定せずに個別の値のみ指定した場合はcustom、何も指定しない場合はbaseのまま
+func caasPlanName(requestData api.CaasRequestData, base string) string {
+	if requestData.Plan != "" {
+		return requestData.Plan
+	}
+	if requestData == (api.CaasRequestData{}) {
+		return base
+	}
+	return "custom"
+}
+
+// ResourceQuotaとLimitRangeのサイズを変更する
+func UpdateCaas(ctx context.Context, c *gin.Context, clients *Clients, db *gorm.DB, jm *jobs.Manager) {
+	caas_id := c.Param("caas_id")
+
+	var requestData api.CaasRequestData
+	if err := c.ShouldBindJSON(&requestData); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	caas, ok := getAuthorizedCaas(c, db, caas_id)
+	if !ok {
+		return
+	}
+	if caas.ID == 0 {
+		respondError(c, http.StatusNotFound, fmt.Sprintf("%s caas not found", caas_id))
+		return
+	}
+	if !checkNoActiveJob(c, jm, models.ResourceCaaS, caas_id) {
+		return
+	}
+	if caas.Status != models.StatusReady {
+		respondError(c, http.StatusConflict, fmt.Sprintf("%s caas cannot be resized (status: %s)", caas_id, caas.Status))
+		return
+	}
+
+	// Planを指定しない場合は現在のサイズを個別に指定された値で上書きする
+	current := caasPlanFromModel(caas)
+	plan, err := utilities.ResolveCaasPlan(requestData, &current)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if !claimStatus(c, db, caas, models.ResourceCaaS, caas_id, models.StatusUpdating, models.StatusReady) {
+		return
+	}
+
+	job := &models.Job{ProjectId: caas.ProjectId, ResourceType: models.ResourceCaaS, ResourceId: caas_id, Action: "update"}
+	submitted := submitJob(ctx, c, jm, job, func(ctx context.Context, rec *jobs.Recorder) error {
+		pipeline := utilities.Pipeline{
+			Tracer:     otel.Tracer("Update CaaS Cluster"),
+			Attributes: []attribute.KeyValue{attribute.String("service.name", "CaaS"), attribute.String("tenant", caas_id)},
+			Steps:      caasResizeSteps(clients.Kube, caas_id, plan),
+			Observer:   rec,
+		}
+		if err := pipeline.Run(ctx); err != nil {
+			fmt.Printf("Error resizing caas[%s]: %v (completed steps: %v)\n", caas_id, err, pipeline.Completed)
+			// ロールバックに成功した場合は変更前のサイズのまま利用可能
+			var stepErr *utilities.StepError
+			if errors.As(err, &stepErr) && len(stepErr.RollbackErrs) == 0 {
+				updateCaasStatus(db, caas, models.StatusReady)
+			} else {
+				updateCaasStatus(db, caas, models.StatusFailed)
+			}
+			return err
+		}
+
+		setCaasPlan(caas, caasPlanName(requestData, caas.Plan), plan)
+		caas.Status = models.StatusReady
+		if err := db.Save(caas).Error; err != nil {
+			fmt.Printf("Error saving caas to db: %v\n", err)
+			return err
+		}
+		return nil
 	})
-	IncreaseCaaSCreateCounter(caas_id)
+	if !submitted {
+		updateCaasStatus(db, caas, models.StatusReady)
+	}
 }
 
-func GetCaas(ctx context.Context, c *gin.Context, clientset *kubernetes.Clientset, db *gorm.DB) {
+func GetCaas(ctx context.Context, c *gin.Context, clients *Clients, db *gorm.DB) {
 	caas_id := c.Param("caas_id")
 
-	// Traceの設定
-	tr := otel.Tracer("Get CaaS Cluster")
-	_, span := tr.Start(ctx, "Get Namespace", trace.WithAttributes(attribute.String("service.name", "CaaS"), attribute.String("tenant", caas_id)))
+	if _, ok := getAuthorizedCaas(c, db, caas_id); !ok {
+		return
+	}
+
+	attrs := []attribute.KeyValue{attribute.String("service.name", "CaaS"), attribute.String("tenant", caas_id)}
 
 	// Namespaceを取得
-	ns, err := clientset.CoreV1().Namespaces().Get(context.TODO(), caas_id, metav1.GetOptions{})
+	spanCtx, span := telemetry.Start(ctx, "Get Namespace", attrs...)
+	ns, err := clients.Kube.CoreV1().Namespaces().Get(spanCtx, caas_id, metav1.GetOptions{})
+	telemetry.End(span, err)
 	if err != nil {
 		fmt.Printf("Error getting namespace: %v\n", err)
-		c.JSON(http.StatusBadRequest, gin.H{
-			"status":  "error",
-			"message": fmt.Sprintf("Error getting namespace for %s\n Error messages: %s", caas_id, err),
-		})
-		span.End()
+		respondError(c, http.StatusBadRequest, fmt.Sprintf("Error getting namespace for %s\n Error messages: %s", caas_id, err))
 		return
-	} else {
-		span.End()
 	}
 
-	_, span2 := tr.Start(ctx, "Get ResourceQuota", trace.WithAttributes(attribute.String("service.name", "CaaS"), attribute.String("tenant", caas_id)))
-
 	// ResourceQuotaを取得
-	resourcequota, err := clientset.CoreV1().ResourceQuotas(caas_id).Get(context.TODO(), fmt.Sprintf("quota-%s", caas_id), metav1.GetOptions{})
+	spanCtx, span = telemetry.Start(ctx, "Get ResourceQuota", attrs...)
+	resourcequota, err := clients.Kube.CoreV1().ResourceQuotas(caas_id).Get(spanCtx, fmt.Sprintf("quota-%s", caas_id), metav1.GetOptions{})
+	telemetry.End(span, err)
 	if err != nil {
 		fmt.Printf("Error getting resourcequota: %v\n", err)
-		c.JSON(http.StatusBadRequest, gin.H{
-			"status":  "error",
-			"message": fmt.Sprintf("Error getting resourcequota for %s\n Error messages: %s", caas_id, err),
-		})
-		span2.End()
+		respondError(c, http.StatusBadRequest, fmt.Sprintf("Error getting resourcequota for %s\n Error messages: %s", caas_id, err))
 		return
-	} else {
-		span2.End()
 	}
 
-	_, span3 := tr.Start(ctx, "Get LimitRange", trace.WithAttributes(attribute.String("service.name", "CaaS"), attribute.String("tenant", caas_id)))
-
 	// LimitRangeを取得
-	limitrange, err := clientset.CoreV1().LimitRanges(caas_id).Get(context.TODO(), fmt.Sprintf("limit-%s", caas_id), metav1.GetOptions{})
+	spanCtx, span = telemetry.Start(ctx, "Get LimitRange", attrs...)
+	limitrange, err := clients.Kube.CoreV1().LimitRanges(caas_id).Get(spanCtx, fmt.Sprintf("limit-%s", caas_id), metav1.GetOptions{})
+	telemetry.End(span, err)
 	if err != nil {
 		fmt.Printf("Error getting limitrange: %v\n", err)
-		c.JSON(http.StatusBadRequest, gin.H{
-			"status":  "error",
-			"message": fmt.Sprintf("Error getting limitrange for %s\n Error messages: %s", caas_id, err),
-		})
-		span3.End()
+		respondError(c, http.StatusBadRequest, fmt.Sprintf("Error getting limitrange for %s\n Error messages: %s", caas_id, err))
 		return
-	} else {
-		span3.End()
 	}
 
-	_, span4 := tr.Start(ctx, "Get RoleBinding", trace.WithAttributes(attribute.String("service.name", "CaaS"), attribute.String("tenant", caas_id)))
-
 	// RoleBindingを取得
-	rolebinding, err := clientset.RbacV1().RoleBindings(caas_id).Get(context.TODO(), fmt.Sprintf("cass-user-role-%s", caas_id), metav1.GetOptions{})
+	spanCtx, span = telemetry.Start(ctx, "Get RoleBinding", attrs...)
+	rolebinding, err := clients.Kube.RbacV1().RoleBindings(caas_id).Get(spanCtx, fmt.Sprintf("cass-user-role-%s", caas_id), metav1.GetOptions{})
+	telemetry.End(span, err)
 	if err != nil {
 		fmt.Printf("Error getting rolebinding: %v\n", err)
-		c.JSON(http.StatusBadRequest, gin.H{
-			"status":  "error",
-			"message": fmt.Sprintf("Error getting rolebinding for %s\n Error messages: %s", caas_id, err),
-		})
-		span4.End()
+		respondError(c, http.StatusBadRequest, fmt.Sprintf("Error getting rolebinding for %s\n Error messages: %s", caas_id, err))
 		return
-	} else {
-		span4.End()
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"status": "success",
-		"message": gin.H{
-			"Namespace":     ns,
-			"ResourceQuota": resourcequota,
-			"LimitRange":    limitrange,
-			"RoleBinding":   rolebinding,
-		},
-	})
+	c.JSON(http.StatusOK, api.Success(api.CaasDetail{
+		Namespace:     ns,
+		ResourceQuota: resourcequota,
+		LimitRange:    limitrange,
+		RoleBinding:   rolebinding,
+	}))
 }
 
-func DeleteCaas(ctx context.Context, c *gin.Context, clientset *kubernetes.Clientset, db *gorm.DB) {
+func DeleteCaas(ctx context.Context, c *gin.Context, clients *Clients, db *gorm.DB, jm *jobs.Manager) {
 	caas_id := c.Param("caas_id")
 
-	// Traceの設定
-	tr := otel.Tracer("Delete CaaS Cluster")
-
-	_, span := tr.Start(ctx, "Delete ResourceQuota", trace.WithAttributes(attribute.String("service.name", "CaaS"), attribute.String("tenant", caas_id)))
-
-	// ResourceQuotaを削除
-	err := clientset.CoreV1().ResourceQuotas(caas_id).Delete(context.TODO(), fmt.Sprintf("quota-%s", caas_id), metav1.DeleteOptions{})
-	if err != nil {
-		fmt.Printf("Error deleting resourcequota: %v\n", err)
-		c.JSON(http.StatusBadRequest, gin.H{
-			"status":  "error",
-			"message": fmt.Sprintf("Error deleting resourcequota for %s\n Error messages: %s", caas_id, err),
-		})
-		span.End()
+	// DBからCaaSを取得（DB登録以前に作成されたCaaSの場合はレコードが存在しない）
+	caas, ok := getAuthorizedCaas(c, db, caas_id)
+	if !ok {
 		return
-	} else {
-		span.End()
 	}
-
-	_, span2 := tr.Start(ctx, "Delete LimitRange", trace.WithAttributes(attribute.String("service.name", "CaaS"), attribute.String("tenant", caas_id)))
-
-	// LimitRangeを削除
-	err = clientset.CoreV1().LimitRanges(caas_id).Delete(context.TODO(), fmt.Sprintf("limit-%s", caas_id), metav1.DeleteOptions{})
-	if err != nil {
-		fmt.Printf("Error deleting limitrange: %v\n", err)
-		c.JSON(http.StatusBadRequest, gin.H{
-			"status":  "error",
-			"message": fmt.Sprintf("Error deleting limitrange for %s\n Error messages: %s", caas_id, err),
-		})
-		span2.End()
+	if !checkNoActiveJob(c, jm, models.ResourceCaaS, caas_id) {
+		return
+	}
+	// 削除中・更新中のCaaSは削除できない（DB登録以前に作成されたCaaSはジョブの有無のみ確認する）
+	previousStatus := caas.Status
+	if caas.ID != 0 && !claimStatus(c, db, caas, models.ResourceCaaS, caas_id, models.StatusDeleting, models.StatusReady, models.StatusFailed) {
 		return
-	} else {
-		span2.End()
 	}
 
-	_, span3 := tr.Start(ctx, "Delete RoleBinding", trace.WithAttributes(attribute.String("service.name", "CaaS"), attribute.String("tenant", caas_id)))
+	// ResourceQuota、LimitRange、RoleBinding、Namespaceをジョブで順番に削除
+	// すでに削除済みのリソースはスキップするため、削除に失敗した場合も再実行できる
+	projectId := caas.ProjectId
+	if caas.ID == 0 {
+		projectId = middlewares.TargetProjectId(c)
+	}
+	job := &models.Job{ProjectId: projectId, ResourceType: models.ResourceCaaS, ResourceId: caas_id, Action: "delete"}
+	submitted := submitJob(ctx, c, jm, job, func(ctx context.Context, rec *jobs.Recorder) error {
+		pipeline := utilities.Pipeline{
+			Tracer:     otel.Tracer("Delete CaaS Cluster"),
+			Attributes: []attribute.KeyValue{attribute.String("service.name", "CaaS"), attribute.String("tenant", caas_id)},
+			Steps:      caasDeleteSteps(clients.Kube, caas_id),
+			Observer:   rec,
+		}
+		if err := pipeline.Run(ctx); err != nil {
+			fmt.Printf("Error deleting caas[%s]: %v (completed steps: %v)\n", caas_id, err, pipeline.Completed)
+			updateCaasStatus(db, caas, models.StatusFailed)
+			return err
+		}
+		fmt.Printf("CaaS[%s] deleted successfully\n", caas_id)
+
+		// ステータスをdeletedに更新してからレコードを論理削除
+		updateCaasStatus(db, caas, models.StatusDeleted)
+		if caas.ID != 0 {
+			if err := db.Delete(caas).Error; err != nil {
+				fmt.Printf("Error deleting caas from db: %v\n", err)
+				return err
+			}
+		}
+		IncreaseCaaSDeleteCounter()
+		return nil
+	})
+	if !submitted {
+		updateCaasStatus(db, caas, previousStatus)
+	}
+}
 
-	// RoleBindingを削除
-	err = clientset.RbacV1().RoleBindings(caas_id).Delete(context.TODO(), fmt.Sprintf("cass-user-role-%s", caas_id), metav1.DeleteOptions{})
+func GetCaases(ctx context.Context, c *gin.Context, clients *Clients, db *gorm.DB) {
+	page, pageSize, err := utilities.GetPagination(c)
 	if err != nil {
-		fmt.Printf("Error deleting rolebinding: %v\n", err)
-		c.JSON(http.StatusBadRequest, gin.H{
-			"status":  "error",
-			"message": fmt.Sprintf("Error deleting rolebinding for %s\n Error messages: %s", caas_id, err),
-		})
-		span3.End()
+		respondError(c, http.StatusBadRequest, err.Error())
 		return
-	} else {
-		span3.End()
 	}
 
-	_, span4 := tr.Start(ctx, "Delete Namespace", trace.WithAttributes(attribute.String("service.name", "CaaS"), attribute.String("tenant", "caas_id")))
+	// project、status、driftedで絞り込み（管理者以外は自身のプロジェクトのみ）
+	query := db.Model(&models.CaaS{})
+	project := c.Query("project")
+	if !middlewares.IsAdmin(c) {
+		if project != "" && !middlewares.AuthorizeProject(c, project) {
+			return
+		}
+		project = middlewares.GetProjectId(c)
+	}
+	if project != "" {
+		query = query.Where("project_id = ?", project)
+	}
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	query, ok := filterDrifted(c, query)
+	if !ok {
+		return
+	}
 
-	// Namespaceを削除
-	err = clientset.CoreV1().Namespaces().Delete(context.TODO(), caas_id, metav1.DeleteOptions{})
-	if err != nil {
-		fmt.Printf("Error deleting namespace: %v\n", err)
-		c.JSON(http.StatusBadRequest, gin.H{
-			"status":  "error",
-			"message": fmt.Sprintf("Error deleting namespace for %s\n Error messages: %s", caas_id, err),
-		})
-		span4.End()
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		fmt.Printf("Error counting caases: %v\n", err)
+		respondError(c, http.StatusInternalServerError, fmt.Sprintf("Error getting caases\n Error messages: %s", err))
 		return
-	} else {
-		span4.End()
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"status":  "success",
-		"message": fmt.Sprintf("Deleted CaaS for %s successfully", caas_id),
-	})
-	IncreaseCaaSDeleteCounter(caas_id)
-}
+	var caases []models.CaaS
+	if err := query.Order("id").Offset((page - 1) * pageSize).Limit(pageSize).Find(&caases).Error; err != nil {
+		fmt.Printf("Error getting caases: %v\n", err)
+		respondError(c, http.StatusInternalServerError, fmt.Sprintf("Error getting caases\n Error messages: %s", err))
+		return
+	}
 
-func GetCaases(ctx context.Context, c *gin.Context, clientset *kubernetes.Clientset, db *gorm.DB) {
-	c.JSON(http.StatusOK, gin.H{
-		"message": "Get caases",
-	})
+	c.JSON(http.StatusOK, api.Success(api.Page[api.Caas]{
+		Items:    convertAll(caases, caasResponse),
+		Total:    total,
+		Page:     page,
+		PageSize: pageSize,
+	}))
 }
@@ -0,0 +1,136 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"ham3/config"
+	"ham3/models"
+
+	"github.com/gin-gonic/gin"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestRegisterCaasOnlyOnce(t *testing.T) {
+	db := newTestDb(t)
+
+	first := models.CaaS{ProjectId: "p", Namespace: "tenant", Status: models.StatusCreating}
+	if ok, err := registerCaas(db, &first); !ok || err != nil {
+		t.Fatalf("first register = %v, %v", ok, err)
+	}
+	// 同じNamespaceを同時に作成した2つ目のリクエスト
+	second := models.CaaS{ProjectId: "p", Namespace: "tenant", Status: models.StatusCreating}
+	if ok, err := registerCaas(db, &second); ok || err != nil {
+		t.Fatalf("second register = %v, %v, want false, nil", ok, err)
+	}
+
+	// 作成に失敗したCaaSの再作成も1つのリクエストだけが成功する
+	db.Model(&first).Update("status", models.StatusFailed)
+	retryA, retryB := first, first
+	retryA.Status, retryB.Status = models.StatusCreating, models.StatusCreating
+	if ok, err := registerCaas(db, &retryA); !ok || err != nil {
+		t.Fatalf("retry register = %v, %v", ok, err)
+	}
+	if ok, err := registerCaas(db, &retryB); ok || err != nil {
+		t.Fatalf("concurrent retry register = %v, %v, want false, nil", ok, err)
+	}
+
+	// 削除済みのCaaSと同名のCaaSは作成できる
+	if err := db.Delete(&first).Error; err != nil {
+		t.Fatal(err)
+	}
+	again := models.CaaS{ProjectId: "p", Namespace: "tenant", Status: models.StatusCreating}
+	if ok, err := registerCaas(db, &again); !ok || err != nil {
+		t.Fatalf("register after delete = %v, %v", ok, err)
+	}
+
+	var count int64
+	db.Model(&models.CaaS{}).Where("namespace = ?", "tenant").Count(&count)
+	if count != 1 {
+		t.Errorf("live caas rows = %d, want 1", count)
+	}
+}
+
+func TestCaasCreateResizeDelete(t *testing.T) {
+	db := newTestDb(t)
+	jm := newTestJobManager(t, db)
+	clients := newTestClients()
+
+	r := newTestEngine()
+	r.POST("/caas/:caas_id", func(c *gin.Context) { CreateCaas(c.Request.Context(), c, clients, db, jm) })
+	r.PUT("/caas/:caas_id", func(c *gin.Context) { UpdateCaas(c.Request.Context(), c, clients, db, jm) })
+	r.DELETE("/caas/:caas_id", func(c *gin.Context) { DeleteCaas(c.Request.Context(), c, clients, db, jm) })
+	ctx := context.Background()
+
+	// 作成（ボディを省略した場合はデフォルトのPlan）
+	job := waitAcceptedJob(t, jm, serve(r, http.MethodPost, "/caas/tenant", ""))
+	if job.Status != models.JobSucceeded {
+		t.Fatalf("create job = %s (%s), steps: %v", job.Status, job.Error, jobStepResults(job))
+	}
+	quota, err := clients.Kube.CoreV1().ResourceQuotas("tenant").Get(ctx, "quota-tenant", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pods := quota.Spec.Hard[corev1.ResourcePods]; pods.String() != config.CaasPlans[config.CaasDefaultPlan].Pods {
+		t.Errorf("pods = %s, want %s", pods.String(), config.CaasPlans[config.CaasDefaultPlan].Pods)
+	}
+	for _, err := range []error{
+		getError(clients.Kube.CoreV1().LimitRanges("tenant").Get(ctx, "limit-tenant", metav1.GetOptions{})),
+		getError(clients.Kube.RbacV1().RoleBindings("tenant").Get(ctx, "cass-user-role-tenant", metav1.GetOptions{})),
+	} {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+	var caas models.CaaS
+	if err := db.Where("namespace = ?", "tenant").First(&caas).Error; err != nil {
+		t.Fatal(err)
+	}
+	if caas.Status != models.StatusReady || caas.ProjectId != testProjectId {
+		t.Errorf("caas = %s/%s, want %s/%s", caas.Status, caas.ProjectId, models.StatusReady, testProjectId)
+	}
+
+	// 同名のCaaSは作成できない
+	if w := serve(r, http.MethodPost, "/caas/tenant", ""); w.Code != http.StatusConflict {
+		t.Errorf("create again = %d, want 409", w.Code)
+	}
+
+	// Podの数のみ変更（他の値は現在のサイズのまま）
+	job = waitAcceptedJob(t, jm, serve(r, http.MethodPut, "/caas/tenant", `{"pods": "30"}`))
+	if job.Status != models.JobSucceeded {
+		t.Fatalf("update job = %s (%s), steps: %v", job.Status, job.Error, jobStepResults(job))
+	}
+	quota, err = clients.Kube.CoreV1().ResourceQuotas("tenant").Get(ctx, "quota-tenant", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pods, cpu := quota.Spec.Hard[corev1.ResourcePods], quota.Spec.Hard[corev1.ResourceRequestsCPU]; pods.String() != "30" || cpu.String() != config.CaasPlans[config.CaasDefaultPlan].RequestsCpu {
+		t.Errorf("pods, requests.cpu = %s, %s, want 30, %s", pods.String(), cpu.String(), config.CaasPlans[config.CaasDefaultPlan].RequestsCpu)
+	}
+	var resized models.CaaS
+	db.Where("namespace = ?", "tenant").First(&resized)
+	if resized.Status != models.StatusReady || resized.Pods != "30" || resized.Plan != "custom" {
+		t.Errorf("caas = %s/%s/%s, want %s/30/custom", resized.Status, resized.Pods, resized.Plan, models.StatusReady)
+	}
+
+	// 削除
+	job = waitAcceptedJob(t, jm, serve(r, http.MethodDelete, "/caas/tenant", ""))
+	if job.Status != models.JobSucceeded {
+		t.Fatalf("delete job = %s (%s), steps: %v", job.Status, job.Error, jobStepResults(job))
+	}
+	if _, err := clients.Kube.CoreV1().Namespaces().Get(ctx, "tenant", metav1.GetOptions{}); !apierrors.IsNotFound(err) {
+		t.Errorf("namespace after delete: %v, want not found", err)
+	}
+	var count int64
+	db.Model(&models.CaaS{}).Where("namespace = ?", "tenant").Count(&count)
+	if count != 0 {
+		t.Errorf("live caas rows = %d, want 0", count)
+	}
+}
+
+func getError[T any](_ T, err error) error {
+	return err
+}
@@ -0,0 +1,22 @@
+package services
+
+import (
+	"ham3/config"
+	"ham3/utilities"
+
+	"k8s.io/client-go/kubernetes"
+)
+
+// ハンドラーが使う外部システムのクライアントと設定（ルーターの設定時に作成して渡す）
+// テストではclient-goのfakeクライアント、utilities.NewMemoryHelmClientなどに差し替えられる
+type Clients struct {
+	Kube    kubernetes.Interface
+	Helm    utilities.HelmInstaller
+	Volumes utilities.VolumeProvider
+	// LOGaaSのクラスタの状態の確認とスナップショットの操作
+	OpenSearch utilities.OpenSearchClient
+	// LOGaaSのプロキシ、指定できるボリュームタイプ/AZ
+	Logaas config.LogaasConfig
+	// リクエストで省略した値のデフォルト値
+	Defaults config.DefaultsConfig
+}
@@ -0,0 +1,76 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"ham3/config"
+	"ham3/utilities"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// CaaSの作成/サイズ変更/削除のステップをfakeのクライアントで実行する
+func TestCaasStepsWithFakeClientset(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	ctx := context.Background()
+
+	create := utilities.Pipeline{Steps: caasCreateSteps(clientset, "tenant", config.CaasPlans["small"])}
+	if err := create.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := clientset.CoreV1().Namespaces().Get(ctx, "tenant", metav1.GetOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := clientset.RbacV1().RoleBindings("tenant").Get(ctx, "cass-user-role-tenant", metav1.GetOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	resize := utilities.Pipeline{Steps: caasResizeSteps(clientset, "tenant", config.CaasPlans["large"])}
+	if err := resize.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+	quota, err := clientset.CoreV1().ResourceQuotas("tenant").Get(ctx, "quota-tenant", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pods := quota.Spec.Hard[corev1.ResourcePods]; pods.String() != config.CaasPlans["large"].Pods {
+		t.Errorf("pods = %s, want %s", pods.String(), config.CaasPlans["large"].Pods)
+	}
+
+	remove := utilities.Pipeline{Steps: caasDeleteSteps(clientset, "tenant")}
+	if err := remove.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := clientset.CoreV1().Namespaces().Get(ctx, "tenant", metav1.GetOptions{}); !apierrors.IsNotFound(err) {
+		t.Errorf("namespace after delete: %v", err)
+	}
+}
+
+// RoleBindingの作成に失敗した場合は作成済みのリソースを削除する
+func TestCaasCreateStepsRollbackWithFakeClientset(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	clientset.PrependReactor("create", "rolebindings", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.New("forced failure")
+	})
+	ctx := context.Background()
+
+	create := utilities.Pipeline{Steps: caasCreateSteps(clientset, "tenant", config.CaasPlans[config.CaasDefaultPlan])}
+	err := create.Run(ctx)
+	var stepErr *utilities.StepError
+	if !errors.As(err, &stepErr) || stepErr.Step != "Create RoleBinding" {
+		t.Fatalf("err = %v, want failure of Create RoleBinding", err)
+	}
+	if len(stepErr.RolledBack) != 3 || len(stepErr.RollbackErrs) != 0 {
+		t.Errorf("rolled back %v (errors: %v), want 3 steps", stepErr.RolledBack, stepErr.RollbackErrs)
+	}
+	if _, err := clientset.CoreV1().Namespaces().Get(ctx, "tenant", metav1.GetOptions{}); !apierrors.IsNotFound(err) {
+		t.Errorf("namespace after rollback: %v", err)
+	}
+}
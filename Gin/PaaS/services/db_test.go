@@ -0,0 +1,25 @@
+package services
+
+import (
+	"testing"
+
+	"ham3/models"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// テストごとのインメモリDB（本番と同じくマイグレーションでスキーマを作成する）
+func newTestDb(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{TranslateError: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := models.MigrateUp(db, 0); err != nil {
+		t.Fatal(err)
+	}
+	sqlDb, _ := db.DB()
+	t.Cleanup(func() { sqlDb.Close() })
+	return db
+}
@@ -0,0 +1,14 @@
+package services
+
+import (
+	"ham3/api"
+	"ham3/config"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 利用可能なFlavorの一覧（起動時に読み込んだFlavorファイルの内容）
+func GetFlavors(c *gin.Context) {
+	c.JSON(http.StatusOK, api.Success(convertAll(config.FlavorList(), flavorResponse)))
+}
@@ -0,0 +1,104 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"ham3/api"
+	"ham3/config"
+	"ham3/jobs"
+	"ham3/middlewares"
+	"ham3/models"
+	"ham3/utilities"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"helm.sh/helm/v3/pkg/chart"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+const testProjectId = "project-1"
+
+// Kubernetesはfake、Helmはリリース情報をメモリに保存するクライアント
+func newTestClients() *Clients {
+	return &Clients{
+		Kube: fake.NewSimpleClientset(),
+		Helm: utilities.NewMemoryHelmClient(func(helmChart utilities.HelmChart) (*chart.Chart, error) {
+			return &chart.Chart{Metadata: &chart.Metadata{APIVersion: chart.APIVersionV2, Name: helmChart.Name, Version: "0.1.0"}}, nil
+		}),
+		Logaas: config.DefaultLogaasConfig(),
+	}
+}
+
+// テストのDBに記録するジョブのワーカー
+func newTestJobManager(t *testing.T, db *gorm.DB) *jobs.Manager {
+	t.Helper()
+	jm := jobs.NewManager(db, 1, 10)
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		jm.Shutdown(ctx)
+	})
+	return jm
+}
+
+// KeystoneAuthの代わりにtestProjectIdのトークンとして認証済みにする
+func newTestEngine() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set(middlewares.ProjectIdContextKey, testProjectId)
+		c.Set(middlewares.UserIdContextKey, "user-1")
+		c.Next()
+	})
+	return r
+}
+
+func serve(r *gin.Engine, method string, path string, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+// 202のレスポンスのジョブが終了するまで待つ
+func waitAcceptedJob(t *testing.T, jm *jobs.Manager, w *httptest.ResponseRecorder) *models.Job {
+	t.Helper()
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("status = %d, want 202: %s", w.Code, w.Body)
+	}
+	var accepted api.AcceptedResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &accepted); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		job, err := jm.Get(accepted.JobId)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if job.Finished() {
+			return job
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job %s did not finish (status: %s)", job.ID, job.Status)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// ジョブのステップ名と結果（例: "Create Namespace=succeeded"）
+func jobStepResults(job *models.Job) []string {
+	var results []string
+	for _, step := range job.Steps {
+		results = append(results, step.Name+"="+step.Status)
+	}
+	return results
+}
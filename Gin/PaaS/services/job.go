@@ -0,0 +1,169 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"ham3/api"
+	"ham3/jobs"
+	"ham3/middlewares"
+	"ham3/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// long-pollで待機できる最大時間
+const maxJobWait = 60 * time.Second
+
+// ジョブを取得し、操作権限を確認する（レスポンスはこの関数内で返す）
+func getAuthorizedJob(c *gin.Context, jm *jobs.Manager, job_id string) (*models.Job, bool) {
+	job, err := jm.Get(job_id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			respondError(c, http.StatusNotFound, fmt.Sprintf("job %s not found", job_id))
+			return nil, false
+		}
+		fmt.Printf("Error getting job from db: %v\n", err)
+		respondError(c, http.StatusInternalServerError, fmt.Sprintf("Error getting job %s\n Error messages: %s", job_id, err))
+		return nil, false
+	}
+	if !middlewares.AuthorizeProject(c, job.ProjectId) {
+		return nil, false
+	}
+	return job, true
+}
+
+// 同じリソースに未完了のジョブが存在する場合は409を返してfalseを返す
+func checkNoActiveJob(c *gin.Context, jm *jobs.Manager, resourceType, resourceId string) bool {
+	active, err := jm.Active(resourceType, resourceId)
+	if err != nil {
+		fmt.Printf("Error getting jobs from db: %v\n", err)
+		respondError(c, http.StatusInternalServerError, fmt.Sprintf("Error getting jobs for %s\n Error messages: %s", resourceId, err))
+		return false
+	}
+	if active {
+		respondError(c, http.StatusConflict, fmt.Sprintf("another job for %s %s is in progress", resourceType, resourceId))
+		return false
+	}
+	return true
+}
+
+// リソースのステータスをfromのいずれかからtoに変更し、操作を受け付けたリクエストとして確定する
+// 条件付きのUPDATEのため、同じリソースに同時に受け付けたリクエストのうち1つだけが成功する（失敗した場合は409を返してfalseを返す）
+// modelはDBに登録済み（IDが0でない）であること
+func claimStatus(c *gin.Context, db *gorm.DB, model interface{}, resourceType string, resourceId string, to string, from ...string) bool {
+	result := db.Model(model).Where("status IN ?", from).Update("status", to)
+	if result.Error != nil {
+		fmt.Printf("Error updating status of %s[%s] to %s: %v\n", resourceType, resourceId, to, result.Error)
+		respondError(c, http.StatusInternalServerError, fmt.Sprintf("Error updating status of %s\n Error messages: %s", resourceId, result.Error))
+		return false
+	}
+	if result.RowsAffected == 0 {
+		respondError(c, http.StatusConflict, fmt.Sprintf("%s %s was changed by another request", resourceType, resourceId))
+		return false
+	}
+	return true
+}
+
+// ジョブを登録し、202とジョブIDを返す
+// 登録に失敗した場合はエラーを返してfalseを返す
+func submitJob(ctx context.Context, c *gin.Context, jm *jobs.Manager, job *models.Job, task jobs.Task) bool {
+	if err := jm.Submit(ctx, job, task); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, jobs.ErrQueueFull) || errors.Is(err, jobs.ErrShutdown) {
+			status = http.StatusServiceUnavailable
+		}
+		fmt.Printf("Error submitting job: %v\n", err)
+		respondError(c, status, fmt.Sprintf("Error submitting %s job for %s\n Error messages: %s", job.Action, job.ResourceId, err))
+		return false
+	}
+
+	c.JSON(http.StatusAccepted, api.AcceptedResponse{
+		Status:  api.StatusAccepted,
+		Message: fmt.Sprintf("Accepted %s %s for %s", job.Action, job.ResourceType, job.ResourceId),
+		JobId:   job.ID,
+	})
+	return true
+}
+
+// ジョブの状態を返す
+// waitクエリ(e.g. ?wait=30s)を指定した場合は、ジョブが更新されるか指定時間が経過するまで待機する(long-poll)
+func GetJob(ctx context.Context, c *gin.Context, jm *jobs.Manager) {
+	job_id := c.Param("job_id")
+
+	var wait time.Duration
+	if w := c.Query("wait"); w != "" {
+		d, err := time.ParseDuration(w)
+		if err != nil || d < 0 {
+			respondError(c, http.StatusBadRequest, "wait must be a duration (e.g. 30s)")
+			return
+		}
+		wait = min(d, maxJobWait)
+	}
+
+	// 取得前に通知の受け取りを開始し、取得後の更新を取りこぼさないようにする
+	updated, stop := jm.Watch(job_id)
+	defer stop()
+
+	job, ok := getAuthorizedJob(c, jm, job_id)
+	if !ok {
+		return
+	}
+
+	if wait > 0 && !job.Finished() {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case <-updated:
+			if job, ok = getAuthorizedJob(c, jm, job_id); !ok {
+				return
+			}
+		case <-timer.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, api.Success(jobResponse(job)))
+}
+
+// ジョブが更新されるたびにServer-Sent Eventsでジョブの状態を送信する（ジョブが終了したら切断）
+func GetJobEvents(ctx context.Context, c *gin.Context, jm *jobs.Manager) {
+	job_id := c.Param("job_id")
+
+	updated, stop := jm.Watch(job_id)
+	defer stop()
+
+	job, ok := getAuthorizedJob(c, jm, job_id)
+	if !ok {
+		return
+	}
+
+	c.Stream(func(w io.Writer) bool {
+		c.SSEvent("job", jobResponse(job))
+		if job.Finished() {
+			return false
+		}
+
+		// 更新がなくても定期的に送信して接続を維持する
+		select {
+		case <-updated:
+		case <-time.After(15 * time.Second):
+		case <-ctx.Done():
+			return false
+		}
+
+		latest, err := jm.Get(job_id)
+		if err != nil {
+			fmt.Printf("Error getting job from db: %v\n", err)
+			return false
+		}
+		job = latest
+		return true
+	})
+}
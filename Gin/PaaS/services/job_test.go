@@ -0,0 +1,45 @@
+package services
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"ham3/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 同じリソースへの同時の更新・削除はステータスを変更できた1つだけを受け付ける
+func TestClaimStatusOnlyOnce(t *testing.T) {
+	db := newTestDb(t)
+	caas := models.CaaS{ProjectId: "p", Namespace: "tenant", Status: models.StatusReady}
+	if err := db.Create(&caas).Error; err != nil {
+		t.Fatal(err)
+	}
+	// 2つのリクエストがそれぞれreadyのCaaSを取得した
+	first, second := caas, caas
+
+	claim := func(caas *models.CaaS, to string, from ...string) int {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		if !claimStatus(c, db, caas, models.ResourceCaaS, "tenant", to, from...) {
+			return w.Code
+		}
+		return http.StatusOK
+	}
+	if code := claim(&first, models.StatusUpdating, models.StatusReady); code != http.StatusOK {
+		t.Fatalf("first claim = %d", code)
+	}
+	if code := claim(&second, models.StatusDeleting, models.StatusReady, models.StatusFailed); code != http.StatusConflict {
+		t.Fatalf("second claim = %d, want 409", code)
+	}
+
+	var current models.CaaS
+	if err := db.First(&current, caas.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if current.Status != models.StatusUpdating || first.Status != models.StatusUpdating {
+		t.Errorf("status = %s (claimed %s), want %s", current.Status, first.Status, models.StatusUpdating)
+	}
+}
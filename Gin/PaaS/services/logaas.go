@@ -2,188 +2,852 @@ package services
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"ham3/api"
 	"ham3/config"
+	"ham3/jobs"
+	"ham3/middlewares"
+	"ham3/models"
 	"ham3/utilities"
+	"io"
 	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
 	"gorm.io/gorm"
+	"helm.sh/helm/v3/pkg/storage/driver"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 )
 
+// LOGaaS名はラベルにしない（cluster_typeのみ）
 var (
 	LOGaasCreateCounter = promauto.NewCounterVec(prometheus.CounterOpts{
 		Name: "logaas_create_total",
 		Help: "The total number of LOGaaS created",
 	},
-		[]string{
-			"cluster_name",
-			"cluster_type",
-		},
+		[]string{"cluster_type"},
 	)
 
 	LOGaasDeleteCounter = promauto.NewCounterVec(prometheus.CounterOpts{
 		Name: "logaas_delete_total",
 		Help: "The total number of LOGaaS deleted",
 	},
-		[]string{
-			"cluster_name",
-			"cluster_type",
-		},
+		[]string{"cluster_type"},
 	)
+
+	LOGaasDriftGauge = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "logaas_drifted",
+		Help: "The number of LOGaaS whose cluster state differs from the DB",
+	})
 )
 
-func IncreaseLOGaaSCreateCounter(clusterName, clusterType string) {
-	LOGaasCreateCounter.WithLabelValues(clusterName, clusterType).Inc()
+func IncreaseLOGaaSCreateCounter(clusterType string) {
+	LOGaasCreateCounter.WithLabelValues(clusterType).Inc()
+}
+
+func IncreaseLOGaaSDeleteCounter(clusterType string) {
+	LOGaasDeleteCounter.WithLabelValues(clusterType).Inc()
+}
+
+// DBからLOGaaSを取得し、操作権限を確認する（レスポンスはこの関数内で返す）
+// DBに登録されていないLOGaaSはレコードが存在しない（IDが0）ため、管理者のみ操作可能
+func getAuthorizedLogaas(c *gin.Context, db *gorm.DB, logaas_id string) (*models.LOGaaS, bool) {
+	var logaas models.LOGaaS
+	if err := db.Where("cluster_name = ?", logaas_id).First(&logaas).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		fmt.Printf("Error getting logaas from db: %v\n", err)
+		respondError(c, http.StatusInternalServerError, fmt.Sprintf("Error getting logaas for %s\n Error messages: %s", logaas_id, err))
+		return nil, false
+	}
+	if !middlewares.AuthorizeProject(c, logaas.ProjectId) {
+		return nil, false
+	}
+	return &logaas, true
+}
+
+// LOGaaSのステータスを更新する（DBに登録されていないLOGaaSの場合は何もしない）
+func updateLogaasStatus(db *gorm.DB, logaas *models.LOGaaS, status string) {
+	if logaas.ID == 0 {
+		return
+	}
+	if err := db.Model(logaas).Update("status", status).Error; err != nil {
+		fmt.Printf("Error updating status of logaas[%s] to %s: %v\n", logaas.ClusterName, status, err)
+	}
+}
+
+// OpenSearch Dashboardsのステータスを更新する（DBに登録されていないLOGaaSの場合は何もしない）
+func updateLogaasGuiStatus(db *gorm.DB, logaas *models.LOGaaS, status string) {
+	if logaas.ID == 0 {
+		return
+	}
+	if err := db.Model(logaas).Update("gui_status", status).Error; err != nil {
+		fmt.Printf("Error updating gui status of logaas[%s] to %s: %v\n", logaas.ClusterName, status, err)
+	}
+}
+
+// リリースがすでに存在するか（作成に失敗したLOGaaSを再作成する場合はインストール済みのリリースをスキップする）
+func helmReleaseExists(ctx context.Context, helm utilities.HelmInstaller, releaseName string) (bool, error) {
+	_, err := helm.Status(ctx, utilities.OpenSearchNamespace, releaseName)
+	if errors.Is(err, driver.ErrReleaseNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Helmリリースの状態
+func helmReleaseStatus(ctx context.Context, helm utilities.HelmInstaller, releaseName string) (api.HelmReleaseStatus, error) {
+	release, err := helm.Status(ctx, utilities.OpenSearchNamespace, releaseName)
+	return helmStatusResponse(release, err), err
+}
+
+// LOGaaSを構成するリリース（scalableはノードグループごとのリリース）の状態をまとめる
+// すべてのリリースがdeployedの場合のみdeployedとし、それ以外は最初に見つかったdeployed以外の状態を返す
+func openSearchHelmStatus(ctx context.Context, helm utilities.HelmInstaller, releaseNames []string) (api.OpenSearchHelmStatus, bool) {
+	status := "deployed"
+	found := false
+	releases := map[string]api.HelmReleaseStatus{}
+	for _, releaseName := range releaseNames {
+		releaseStatus, err := helmReleaseStatus(ctx, helm, releaseName)
+		releases[releaseName] = releaseStatus
+		if err == nil {
+			found = true
+		}
+		if status != "deployed" {
+			continue
+		}
+		if err != nil {
+			status = "unknown"
+			if errors.Is(err, driver.ErrReleaseNotFound) {
+				status = "not-found"
+			}
+		} else if releaseStatus.Status != "deployed" {
+			status = releaseStatus.Status
+		}
+	}
+	return api.OpenSearchHelmStatus{Status: status, Releases: releases}, found
+}
+
+// Podの状態
+func logaasPodStatus(ctx context.Context, clientset kubernetes.Interface, releaseNames ...string) api.PodStatus {
+	pods, readyCount, err := getLogaasPods(ctx, clientset, releaseNames...)
+	if err != nil {
+		return api.PodStatus{Error: err.Error()}
+	}
+	return api.PodStatus{Ready: readyCount, Total: len(pods), Items: pods}
+}
+
+// DBに登録されているLOGaaSのパラメータ（登録されていない項目はデフォルト値）
+func logaasSpecFromModel(logaas *models.LOGaaS, defaults config.DefaultsConfig) (api.LogaasRequestData, error) {
+	var requestData api.LogaasRequestData
+	utilities.LogaasGetDefaultValue(&requestData, defaults)
+	if logaas.Spec == "" {
+		requestData.ClusterType = logaas.ClusterType
+		return requestData, nil
+	}
+	err := json.Unmarshal([]byte(logaas.Spec), &requestData)
+	return requestData, err
+}
+
+// Helmリリースに属するPodとReadyなPodの数を取得する
+func getLogaasPods(ctx context.Context, clientset kubernetes.Interface, releaseNames ...string) ([]api.Pod, int, error) {
+	podList, err := clientset.CoreV1().Pods(utilities.OpenSearchNamespace).List(ctx, metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("app.kubernetes.io/instance in (%s)", strings.Join(releaseNames, ",")),
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	pods := []api.Pod{}
+	readyCount := 0
+	for _, pod := range podList.Items {
+		ready := false
+		for _, condition := range pod.Status.Conditions {
+			if condition.Type == "Ready" && condition.Status == "True" {
+				ready = true
+			}
+		}
+		if ready {
+			readyCount++
+		}
+		pods = append(pods, api.Pod{Name: pod.Name, Phase: string(pod.Status.Phase), Ready: ready})
+	}
+	return pods, readyCount, nil
+}
+
+// OpenSearchのクラスタの状態を確認するステップ名
+const waitOpenSearchHealthyStep = "Wait for OpenSearch cluster health"
+
+// OpenSearchのクラスタの状態(_cluster/health)がgreen/yellowになるまで待つ（Step.Runとして使う）
+func waitOpenSearchHealthy(clients *Clients, logaas_id string, baseDomain string) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		status, err := clients.OpenSearch.WaitHealthy(ctx, logaas_id, baseDomain)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("OpenSearch cluster %s is %s\n", logaas_id, status)
+		return nil
+	}
+}
+
+// LOGaaSのHelmリリースをインストールするステップ
+// 作成に失敗したLOGaaSの再作成の場合、インストール済みのリリースはそのまま使う
+// ロールバックではリリースのみ削除する（ボリューム、PV、PVCは再作成時にそのまま使い、LOGaaSの削除時に削除する）
+func logaasCreateSteps(clients *Clients, logaas_id string, requestData api.LogaasRequestData, nodeGroups []utilities.OpenSearchNodeGroup, dashboardsValues map[string]interface{}) []utilities.Step {
+	var steps []utilities.Step
+	for _, nodeGroup := range nodeGroups {
+		steps = append(steps, utilities.Step{
+			Name: fmt.Sprintf("Install OpenSearch (%s)", nodeGroup.Name),
+			Run: func(ctx context.Context) error {
+				if exists, err := helmReleaseExists(ctx, clients.Helm, nodeGroup.ReleaseName); err != nil {
+					return err
+				} else if exists {
+					fmt.Printf("Release %s already exists, skipping install\n", nodeGroup.ReleaseName)
+					return nil
+				}
+
+				release, err := clients.Helm.Install(ctx, utilities.OpenSearchNamespace, nodeGroup.ReleaseName, utilities.OpenSearchChart.WithVersion(config.OpenSearchChartVersion(requestData.OpenSearchVersion)), nodeGroup.Values)
+				if err != nil {
+					return fmt.Errorf("Failed to install chart: %v", err)
+				}
+				fmt.Printf("Successfully installed chart with release name: %s\n", release.Name)
+				return nil
+			},
+			Rollback: func(ctx context.Context) error {
+				if err := clients.Helm.Uninstall(ctx, utilities.OpenSearchNamespace, nodeGroup.ReleaseName); err != nil && !errors.Is(err, driver.ErrReleaseNotFound) {
+					return err
+				}
+				return nil
+			},
+		})
+	}
+
+	// Podが起動しただけではシャードが割り当てられていないため、クラスタの状態を確認してからreadyにする
+	steps = append(steps, utilities.Step{
+		Name: waitOpenSearchHealthyStep,
+		Run:  waitOpenSearchHealthy(clients, logaas_id, requestData.BaseDomain),
+	})
+
+	// OpenSearch Dashboardsのデプロイ（scalableとstandardの違いはreplicas数のみ）
+	dashboardsRelease := utilities.OpenSearchDashboardsReleaseName(logaas_id)
+	steps = append(steps, utilities.Step{
+		Name: "Install OpenSearch Dashboards",
+		Run: func(ctx context.Context) error {
+			if exists, err := helmReleaseExists(ctx, clients.Helm, dashboardsRelease); err != nil {
+				return err
+			} else if exists {
+				fmt.Printf("Release %s already exists, skipping install\n", dashboardsRelease)
+				return nil
+			}
+
+			release, err := clients.Helm.Install(ctx, utilities.OpenSearchNamespace, dashboardsRelease, utilities.OpenSearchDashboardsChart.WithVersion(config.OpenSearchDashboardsChartVersion(requestData.OpenSearchDashboardsVersion)), dashboardsValues)
+			if err != nil {
+				return fmt.Errorf("Failed to install dashboards chart: %v", err)
+			}
+			fmt.Printf("Successfully installed chart with release name: %s\n", release.Name)
+			return nil
+		},
+	})
+	return steps
+}
+
+// LOGaaSのノードグループのHelmリリースをアップグレードするステップ
+// ロールバックではアップグレード前のリビジョンに戻す
+func logaasUpgradeSteps(clients *Clients, logaas_id string, requestData api.LogaasRequestData, nodeGroups []utilities.OpenSearchNodeGroup) []utilities.Step {
+	var steps []utilities.Step
+	for _, nodeGroup := range nodeGroups {
+		var previousRevision int
+		steps = append(steps, utilities.Step{
+			Name: fmt.Sprintf("Upgrade OpenSearch (%s)", nodeGroup.Name),
+			Run: func(ctx context.Context) error {
+				current, err := clients.Helm.Status(ctx, utilities.OpenSearchNamespace, nodeGroup.ReleaseName)
+				if err != nil {
+					return fmt.Errorf("Failed to get release %s: %v", nodeGroup.ReleaseName, err)
+				}
+				previousRevision = current.Version
+
+				release, err := clients.Helm.Upgrade(ctx, utilities.OpenSearchNamespace, nodeGroup.ReleaseName, utilities.OpenSearchChart.WithVersion(config.OpenSearchChartVersion(requestData.OpenSearchVersion)), nodeGroup.Values)
+				if err != nil {
+					return fmt.Errorf("Failed to upgrade chart: %v", err)
+				}
+				fmt.Printf("Successfully upgraded chart with release name: %s (revision %d)\n", release.Name, release.Version)
+				return nil
+			},
+			Rollback: func(ctx context.Context) error {
+				if err := clients.Helm.Rollback(ctx, utilities.OpenSearchNamespace, nodeGroup.ReleaseName, previousRevision); err != nil {
+					return fmt.Errorf("Failed to rollback %s to revision %d: %v", nodeGroup.ReleaseName, previousRevision, err)
+				}
+				fmt.Printf("Rolled back release %s to revision %d\n", nodeGroup.ReleaseName, previousRevision)
+				return nil
+			},
+		})
+	}
+
+	steps = append(steps, utilities.Step{
+		Name: waitOpenSearchHealthyStep,
+		Run:  waitOpenSearchHealthy(clients, logaas_id, requestData.BaseDomain),
+	})
+	return steps
 }
 
-func IncreaseLOGaaSDeleteCounter(clusterName, clusterType string) {
-	LOGaasDeleteCounter.WithLabelValues(clusterName, clusterType).Inc()
+// LOGaaSをcreatingでDBに登録する（同名のLOGaaSを同時に作成するリクエストのうち1つだけがtrueを返す）
+// 新規の場合はcluster_nameのユニークインデックス、作成に失敗したLOGaaSの再作成の場合はfailedを条件にした更新で競合を検出する
+func registerLogaas(db *gorm.DB, logaas *models.LOGaaS) (bool, error) {
+	if logaas.ID == 0 {
+		err := db.Create(logaas).Error
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return false, nil
+		}
+		return err == nil, err
+	}
+	result := db.Model(logaas).
+		Where("status = ?", models.StatusFailed).
+		Select("ProjectId", "ClusterType", "ApiEndpoint", "GuiEndpoint", "Status", "GuiStatus", "Spec").
+		Updates(logaas)
+	return result.RowsAffected == 1, result.Error
 }
 
-func CreateLogaas(ctx context.Context, c *gin.Context, clientset *kubernetes.Clientset, db *gorm.DB) {
-	var requestData config.LogaasRequestData
+func CreateLogaas(ctx context.Context, c *gin.Context, clients *Clients, db *gorm.DB, jm *jobs.Manager) {
+	var requestData api.LogaasRequestData
 
 	// OpenSearchのメタデータ(e.g. cluster type)のデフォルト値を取得
-	utilities.LogaasGetDefaultValue(&requestData)
+	utilities.LogaasGetDefaultValue(&requestData, clients.Defaults)
 
 	// OpenSearchのメタデータを実際のリクエスト値に上書き（リクエストに連携されてないパラメータはデフォルト値で設定される）
 	if err := c.ShouldBindJSON(&requestData); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondBindError(c, err)
 		return
 	}
 	logaas_id := c.Param("logaas_id")
 
-	// パラメータのバリデーションチェック
-	if errExist, errMessage := utilities.CheckLogaasCreateParameters(requestData); errExist {
-		c.JSON(http.StatusBadRequest, gin.H{"error": errMessage})
-		return
-	}
-
-	fmt.Printf("ClusterName: %s, Cluster Metadata: %s\n", logaas_id, requestData)
-
-	meta := config.Flavors
-	flavor := config.Flavors[requestData.MasterFlavor].(map[string]interface{})
-	requests := flavor["requests"].(map[string]interface{})
-	limits := flavor["limits"].(map[string]interface{})
-	requests_cpu := requests["cpu"]
-	requests_memory := requests["memory"]
-	limits_cpu := limits["cpu"]
-	limits_memory := limits["memory"]
-	jvm_heap := flavor["jvm_heap"]
-	jvm_perm := flavor["jvm_perm"]
-	fmt.Println("meta:", meta)
-	fmt.Println("flavor:", flavor)
-	fmt.Println("requests:", requests)
-	fmt.Println("requests_cpu:", requests_cpu)
-	fmt.Println("requests_memory:", requests_memory)
-	fmt.Println("limits_cpu:", limits_cpu)
-	fmt.Println("limits_memory:", limits_memory)
-	fmt.Println("jvm_heap:", jvm_heap)
-	fmt.Println("jvm_perm:", jvm_perm)
+	// DBに同名のLOGaaSが存在するか確認（作成に失敗したLOGaaSは再作成可能）
+	// 他のプロジェクトに同名のLOGaaSが存在する場合は作成できない
+	var logaas models.LOGaaS
+	err := db.Where("cluster_name = ?", logaas_id).First(&logaas).Error
+	if err == nil && !middlewares.AuthorizeProject(c, logaas.ProjectId) {
+		return
+	} else if err == nil && logaas.Status != models.StatusFailed {
+		respondError(c, http.StatusConflict, fmt.Sprintf("%s logaas already exists (status: %s)", logaas_id, logaas.Status))
+		return
+	} else if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		fmt.Printf("Error getting logaas from db: %v\n", err)
+		respondError(c, http.StatusInternalServerError, fmt.Sprintf("Error getting logaas for %s\n Error messages: %s", logaas_id, err))
+		return
+	}
+	if !checkNoActiveJob(c, jm, models.ResourceLOGaaS, logaas_id) {
+		return
+	}
 
-	// Helmのvalues.yamlの設定
-	values, err := utilities.OpensearchGetHelmValue(logaas_id, requestData)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"status":  "error",
-			"message": fmt.Sprintf("Failed to get helm value: %v", err),
-		})
+	// パラメータのバリデーションチェック（エラーはすべてまとめて返す）
+	if fieldErrors := utilities.CheckLogaasCreateParameters(logaas_id, requestData, clients.Logaas); len(fieldErrors) > 0 {
+		respondFieldErrors(c, fieldErrors)
 		return
 	}
 
-	// Helmの設定
-	install, _, chart := utilities.OpenSearchHelmSetting(logaas_id, "install")
+	// パラメータには認証情報が含まれないが、Helm valuesはログに出力しないこと
+	fmt.Printf("ClusterName: %s, ClusterType: %s, OpenSearchVersion: %s\n", logaas_id, requestData.ClusterType, requestData.OpenSearchVersion)
 
-	// タイムアウトを10分に設定
-	// ctxtimeout, cancel := context.WithTimeout(ctx, 600*time.Second)
-	// defer cancel()
+	// OpenSearchのadminユーザーとテナントのユーザーの認証情報
+	// 作成に失敗したLOGaaSの再作成の場合は発行済みの認証情報を使う
+	projectId := middlewares.TargetProjectId(c)
+	credentials, err := utilities.GetLogaasCredentials(ctx, clients.Kube, logaas_id)
+	if apierrors.IsNotFound(err) {
+		credentials, err = utilities.GenerateLogaasCredentials(utilities.LogaasTenantUser(projectName(db, projectId)))
+	} else if err == nil && credentials.DashboardsPassword == "" {
+		// Dashboardsのユーザーを発行する前に作成に失敗したLOGaaS
+		credentials.DashboardsPassword, credentials.DashboardsPasswordHash, err = utilities.GeneratePassword()
+	}
+	if err != nil {
+		fmt.Printf("Error preparing credentials of logaas[%s]: %v\n", logaas_id, err)
+		respondError(c, http.StatusInternalServerError, fmt.Sprintf("Error preparing credentials for %s\n Error messages: %s", logaas_id, err))
+		return
+	}
 
-	// release, err := install.RunWithContext(ctxtimeout, chart, values)
-	release, err := install.Run(chart, values)
+	// Helmのvalues.yamlの設定
+	nodeGroups, err := utilities.OpensearchGetHelmValue(logaas_id, requestData, credentials, clients.Logaas)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"status":  "error",
-			"message": fmt.Sprintf("Failed to install chart: %v", err),
-		})
+		respondError(c, http.StatusBadRequest, fmt.Sprintf("Failed to get helm value: %v", err))
+		return
+	}
+	dashboardsValues, err := utilities.OpensearchDashboardsGetHelmValue(logaas_id, requestData)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, fmt.Sprintf("Failed to get dashboards helm value: %v", err))
 		return
 	}
-	fmt.Printf("Successfully installed chart with release name: %s\n", release.Name)
 
-	// OpenSearch Dashboardのデプロイも追加（scalableとstandardの違いはrelicas数のみ）
+	// LOGaaSをcreatingステータスでDBに登録
+	spec, err := json.Marshal(requestData)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, fmt.Sprintf("Failed to marshal request: %v", err))
+		return
+	}
+	logaas.ProjectId = projectId
+	logaas.ClusterName = logaas_id
+	logaas.ClusterType = requestData.ClusterType
+	logaas.ApiEndpoint = utilities.OpenSearchApiHost(logaas_id, requestData.BaseDomain)
+	logaas.GuiEndpoint = utilities.OpenSearchGuiHost(logaas_id, requestData.BaseDomain)
+	logaas.Status = models.StatusCreating
+	logaas.GuiStatus = models.StatusCreating
+	logaas.Spec = string(spec)
+	registered, err := registerLogaas(db, &logaas)
+	if err != nil {
+		fmt.Printf("Error saving logaas to db: %v\n", err)
+		respondError(c, http.StatusInternalServerError, fmt.Sprintf("Error saving logaas for %s\n Error messages: %s", logaas_id, err))
+		return
+	}
+	if !registered {
+		respondError(c, http.StatusConflict, fmt.Sprintf("%s logaas is being created by another request", logaas_id))
+		return
+	}
+
+	// Helmのインストールは時間がかかるためジョブで実行する
+	job := &models.Job{ProjectId: logaas.ProjectId, ResourceType: models.ResourceLOGaaS, ResourceId: logaas_id, Action: "create"}
+	submitted := submitJob(ctx, c, jm, job, func(ctx context.Context, rec *jobs.Recorder) error {
+		// internal_users.ymlに埋め込んだ認証情報をインストール前に保存する
+		err := rec.Step("Save credentials", func() error {
+			return utilities.SaveLogaasCredentials(ctx, clients.Kube, logaas_id, credentials)
+		})
+		if err != nil {
+			updateLogaasStatus(db, &logaas, models.StatusFailed)
+			updateLogaasGuiStatus(db, &logaas, models.StatusFailed)
+			return err
+		}
 
-	c.JSON(http.StatusOK, gin.H{
-		"status":  "success",
-		"message": fmt.Sprintf("Created LOGaaS for %s successfully", logaas_id),
+		// Cinderボリュームと、それをPodにバインドするPV/PVCを準備する
+		if err := provisionLogaasVolumes(ctx, clients, logaas_id, requestData, rec); err != nil {
+			updateLogaasStatus(db, &logaas, models.StatusFailed)
+			updateLogaasGuiStatus(db, &logaas, models.StatusFailed)
+			return err
+		}
+
+		// masterノードから順にノードグループごとにインストールし、クラスタの状態を確認してからDashboardsをインストールする
+		// 途中で失敗した場合はインストール済みのリリースを削除する
+		pipeline := utilities.Pipeline{
+			Tracer:     otel.Tracer("Create LOGaaS Cluster"),
+			Attributes: []attribute.KeyValue{attribute.String("service.name", "LOGaaS"), attribute.String("logaas", logaas_id)},
+			Steps:      logaasCreateSteps(clients, logaas_id, requestData, nodeGroups, dashboardsValues),
+			Observer:   rec,
+		}
+		if err := pipeline.Run(ctx); err != nil {
+			fmt.Printf("Error creating logaas[%s]: %v (completed steps: %v)\n", logaas_id, err, pipeline.Completed)
+			updateLogaasStatus(db, &logaas, models.StatusFailed)
+			updateLogaasGuiStatus(db, &logaas, models.StatusFailed)
+			return err
+		}
+
+		updateLogaasStatus(db, &logaas, models.StatusReady)
+		updateLogaasGuiStatus(db, &logaas, models.StatusReady)
+		IncreaseLOGaaSCreateCounter(requestData.ClusterType)
+		return nil
 	})
-	IncreaseLOGaaSCreateCounter(logaas_id, requestData.ClusterType)
+	if !submitted {
+		updateLogaasStatus(db, &logaas, models.StatusFailed)
+		updateLogaasGuiStatus(db, &logaas, models.StatusFailed)
+	}
 }
 
-func GetLogaas(ctx context.Context, c *gin.Context, clientset *kubernetes.Clientset, db *gorm.DB) {
+// Dashboardsがチャートのsecretで参照するユーザーを準備する
+// 認証情報を発行する前に作成したLOGaaSはデモ設定のkibanaserverユーザーを使い、
+// Dashboardsのユーザーを発行する前に作成したLOGaaSはadminユーザーでOpenSearchにユーザーを作成してから保存する
+func ensureDashboardsUser(ctx context.Context, clients *Clients, logaas_id string, baseDomain string, credentials utilities.LogaasCredentials, legacy bool) error {
+	if legacy || credentials.DashboardsPassword != "" {
+		return utilities.SaveLogaasDashboardsSecret(ctx, clients.Kube, logaas_id, credentials.DashboardsPassword)
+	}
+
+	password, passwordHash, err := utilities.GeneratePassword()
+	if err != nil {
+		return err
+	}
+	// 作成後に保存できなかった場合は次回の更新で作り直す
+	path := fmt.Sprintf("_plugins/_security/api/internalusers/%s", utilities.LogaasDashboardsUser)
+	body := gin.H{"password": password, "opendistro_security_roles": []string{config.DashboardsSecurityRole}, "description": "OpenSearch Dashboards user"}
+	if err := clients.OpenSearch.Do(ctx, logaas_id, baseDomain, http.MethodPut, path, body, nil); err != nil {
+		return err
+	}
+	credentials.DashboardsPassword, credentials.DashboardsPasswordHash = password, passwordHash
+	return utilities.SaveLogaasCredentials(ctx, clients.Kube, logaas_id, credentials)
+}
+
+// DBの情報にHelmリリースとPodの状態を合わせて返す
+func GetLogaas(ctx context.Context, c *gin.Context, clients *Clients, db *gorm.DB) {
 	logaas_id := c.Param("logaas_id")
 
-	var requestData config.LogaasRequestData
-	if err := c.ShouldBindJSON(&requestData); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	logaas, ok := getAuthorizedLogaas(c, db, logaas_id)
+	if !ok {
 		return
 	}
-	fmt.Printf("ClusterName: %s, ClusterType: %s\n", logaas_id, requestData.ClusterType)
 
-	c.JSON(http.StatusOK, gin.H{
-		"status":  "success",
-		"message": fmt.Sprintf("Get LOGaaS info about %s", logaas_id),
-	})
+	// DBに登録されていないLOGaaSの場合はクエリでcluster_typeを指定する（デフォルトはstandard）
+	clusterType := logaas.ClusterType
+	if logaas.ID == 0 {
+		clusterType = c.DefaultQuery("cluster_type", "standard")
+	}
+	releaseNames := utilities.OpenSearchReleaseNames(logaas_id, clusterType)
+
+	// Helmリリースの状態
+	helmStatus, found := openSearchHelmStatus(ctx, clients.Helm, releaseNames)
+	if logaas.ID == 0 && !found {
+		respondError(c, http.StatusNotFound, fmt.Sprintf("%s logaas not found", logaas_id))
+		return
+	}
+
+	// OpenSearch DashboardsはOpenSearchとは別にステータスを返す
+	dashboardsRelease := utilities.OpenSearchDashboardsReleaseName(logaas_id)
+	dashboardsHelmStatus, _ := helmReleaseStatus(ctx, clients.Helm, dashboardsRelease)
+
+	result := api.LogaasDetail{
+		Helm: helmStatus,
+		Pods: logaasPodStatus(ctx, clients.Kube, releaseNames...),
+		Dashboards: api.DashboardsStatus{
+			Helm: dashboardsHelmStatus,
+			Pods: logaasPodStatus(ctx, clients.Kube, dashboardsRelease),
+		},
+	}
+	if logaas.ID != 0 {
+		spec, err := logaasSpecFromModel(logaas, clients.Defaults)
+		if err != nil {
+			fmt.Printf("Error parsing spec of logaas[%s]: %v\n", logaas_id, err)
+		}
+		response := logaasResponse(logaas)
+		result.Logaas = &response
+		result.Spec = &spec
+	}
+
+	c.JSON(http.StatusOK, api.Success(result))
 }
 
-func UpdateLogaas(ctx context.Context, c *gin.Context, clientset *kubernetes.Clientset, db *gorm.DB) {
+// 変更したパラメータ(flavor、scale-size、versionなど)でHelmリリースをアップグレードする
+func UpdateLogaas(ctx context.Context, c *gin.Context, clients *Clients, db *gorm.DB, jm *jobs.Manager) {
 	logaas_id := c.Param("logaas_id")
 
-	var requestData config.LogaasRequestData
+	logaas, ok := getAuthorizedLogaas(c, db, logaas_id)
+	if !ok {
+		return
+	}
+	if logaas.ID == 0 {
+		respondError(c, http.StatusNotFound, fmt.Sprintf("%s logaas not found", logaas_id))
+		return
+	}
+	if !checkNoActiveJob(c, jm, models.ResourceLOGaaS, logaas_id) {
+		return
+	}
+	if logaas.Status != models.StatusReady && logaas.Status != models.StatusFailed {
+		respondError(c, http.StatusConflict, fmt.Sprintf("%s logaas cannot be updated (status: %s)", logaas_id, logaas.Status))
+		return
+	}
+
+	// 現在のパラメータをリクエスト値で上書き（リクエストに連携されてないパラメータは現在の値のまま）
+	requestData, err := logaasSpecFromModel(logaas, clients.Defaults)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, fmt.Sprintf("Failed to parse current spec: %v", err))
+		return
+	}
+	currentData := requestData
 	if err := c.ShouldBindJSON(&requestData); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondBindError(c, err)
 		return
 	}
-	fmt.Printf("ClusterName: %s, ClusterType: %s\n", logaas_id, requestData.ClusterType)
+	// パラメータのバリデーションチェック（エラーはすべてまとめて返す）
+	fieldErrors := utilities.CheckLogaasCreateParameters(logaas_id, requestData, clients.Logaas)
+	if requestData.ClusterType != logaas.ClusterType {
+		fieldErrors = append(fieldErrors, api.FieldError{Field: "cluster-type", Message: "cannot be changed"})
+	}
+	// 作成済みのCinderボリュームのサイズ・タイプは変更できない
+	if requestData.DataDiskSize != currentData.DataDiskSize {
+		fieldErrors = append(fieldErrors, api.FieldError{Field: "data-disk-size", Message: "cannot be changed"})
+	}
+	if requestData.DiskType != currentData.DiskType {
+		fieldErrors = append(fieldErrors, api.FieldError{Field: "disk-type-ham3", Message: "cannot be changed"})
+	}
+	if len(fieldErrors) > 0 {
+		respondFieldErrors(c, fieldErrors)
+		return
+	}
+
+	// 認証情報を発行する前に作成したLOGaaSはデモ設定のadminユーザーのまま
+	credentials, err := utilities.GetLogaasCredentials(ctx, clients.Kube, logaas_id)
+	legacyCredentials := apierrors.IsNotFound(err)
+	if legacyCredentials {
+		credentials, err = utilities.LegacyLogaasCredentials(), nil
+	}
+	if err != nil {
+		fmt.Printf("Error getting credentials of logaas[%s]: %v\n", logaas_id, err)
+		respondError(c, http.StatusInternalServerError, fmt.Sprintf("Error getting credentials for %s\n Error messages: %s", logaas_id, err))
+		return
+	}
+
+	// Helmのvalues.yamlの設定
+	nodeGroups, err := utilities.OpensearchGetHelmValue(logaas_id, requestData, credentials, clients.Logaas)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, fmt.Sprintf("Failed to get helm value: %v", err))
+		return
+	}
+	dashboardsValues, err := utilities.OpensearchDashboardsGetHelmValue(logaas_id, requestData)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, fmt.Sprintf("Failed to get dashboards helm value: %v", err))
+		return
+	}
+	spec, err := json.Marshal(requestData)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, fmt.Sprintf("Failed to marshal request: %v", err))
+		return
+	}
+
+	if !claimStatus(c, db, logaas, models.ResourceLOGaaS, logaas_id, models.StatusUpdating, models.StatusReady, models.StatusFailed) {
+		return
+	}
+	updateLogaasGuiStatus(db, logaas, models.StatusUpdating)
 
-	c.JSON(http.StatusOK, gin.H{
-		"message": fmt.Sprintf("Updated LOGaaS for %s successfully", logaas_id),
+	job := &models.Job{ProjectId: logaas.ProjectId, ResourceType: models.ResourceLOGaaS, ResourceId: logaas_id, Action: "update"}
+	submitted := submitJob(ctx, c, jm, job, func(ctx context.Context, rec *jobs.Recorder) error {
+		// scale-sizeを増やした場合に追加されるノードのボリュームを準備する
+		if err := provisionLogaasVolumes(ctx, clients, logaas_id, requestData, rec); err != nil {
+			updateLogaasStatus(db, logaas, models.StatusFailed)
+			updateLogaasGuiStatus(db, logaas, models.StatusFailed)
+			return err
+		}
+
+		// masterノードから順にノードグループごとにアップグレードし、クラスタの状態を確認する
+		// 失敗した場合はアップグレード済みのノードグループを前のリビジョンに戻す（DBのspecは更新しない）
+		pipeline := utilities.Pipeline{
+			Tracer:     otel.Tracer("Update LOGaaS Cluster"),
+			Attributes: []attribute.KeyValue{attribute.String("service.name", "LOGaaS"), attribute.String("logaas", logaas_id)},
+			Steps:      logaasUpgradeSteps(clients, logaas_id, requestData, nodeGroups),
+			Observer:   rec,
+		}
+		if err := pipeline.Run(ctx); err != nil {
+			fmt.Printf("Error updating logaas[%s]: %v (completed steps: %v)\n", logaas_id, err, pipeline.Completed)
+			updateLogaasStatus(db, logaas, models.StatusFailed)
+			updateLogaasGuiStatus(db, logaas, models.StatusFailed)
+			return err
+		}
+
+		// OpenSearch Dashboardsのリリースが存在しない場合（Dashboards導入前に作成したLOGaaS）はインストールする
+		dashboardsRelease := utilities.OpenSearchDashboardsReleaseName(logaas_id)
+		err := rec.Step("Prepare OpenSearch Dashboards user", func() error {
+			return ensureDashboardsUser(ctx, clients, logaas_id, requestData.BaseDomain, credentials, legacyCredentials)
+		})
+		if err != nil {
+			updateLogaasStatus(db, logaas, models.StatusReady)
+			updateLogaasGuiStatus(db, logaas, models.StatusFailed)
+			return err
+		}
+		err = rec.Step("Upgrade OpenSearch Dashboards", func() error {
+			exists, err := helmReleaseExists(ctx, clients.Helm, dashboardsRelease)
+			if err != nil {
+				return err
+			}
+			if !exists {
+				if _, err := clients.Helm.Install(ctx, utilities.OpenSearchNamespace, dashboardsRelease, utilities.OpenSearchDashboardsChart.WithVersion(config.OpenSearchDashboardsChartVersion(requestData.OpenSearchDashboardsVersion)), dashboardsValues); err != nil {
+					return fmt.Errorf("Failed to install dashboards chart: %v", err)
+				}
+				fmt.Printf("Successfully installed chart with release name: %s\n", dashboardsRelease)
+				return nil
+			}
+			release, err := clients.Helm.Upgrade(ctx, utilities.OpenSearchNamespace, dashboardsRelease, utilities.OpenSearchDashboardsChart.WithVersion(config.OpenSearchDashboardsChartVersion(requestData.OpenSearchDashboardsVersion)), dashboardsValues)
+			if err != nil {
+				return fmt.Errorf("Failed to upgrade dashboards chart: %v", err)
+			}
+			fmt.Printf("Successfully upgraded chart with release name: %s (revision %d)\n", release.Name, release.Version)
+			return nil
+		})
+		if err != nil {
+			updateLogaasStatus(db, logaas, models.StatusReady)
+			updateLogaasGuiStatus(db, logaas, models.StatusFailed)
+			return err
+		}
+
+		logaas.Spec = string(spec)
+		logaas.ApiEndpoint = utilities.OpenSearchApiHost(logaas_id, requestData.BaseDomain)
+		logaas.GuiEndpoint = utilities.OpenSearchGuiHost(logaas_id, requestData.BaseDomain)
+		logaas.Status = models.StatusReady
+		logaas.GuiStatus = models.StatusReady
+		if err := db.Save(logaas).Error; err != nil {
+			fmt.Printf("Error saving logaas to db: %v\n", err)
+			return err
+		}
+		return nil
 	})
+	if !submitted {
+		updateLogaasStatus(db, logaas, models.StatusFailed)
+		updateLogaasGuiStatus(db, logaas, models.StatusFailed)
+	}
 }
 
-func DeleteLogaas(ctx context.Context, c *gin.Context, clientset *kubernetes.Clientset, db *gorm.DB) {
-	var requestData config.LogaasRequestData
-	if err := c.ShouldBindJSON(&requestData); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+func DeleteLogaas(ctx context.Context, c *gin.Context, clients *Clients, db *gorm.DB, jm *jobs.Manager) {
+	logaas_id := c.Param("logaas_id")
+
+	logaas, ok := getAuthorizedLogaas(c, db, logaas_id)
+	if !ok {
+		return
+	}
+	if !checkNoActiveJob(c, jm, models.ResourceLOGaaS, logaas_id) {
 		return
 	}
-	logaas_id := c.Param("logaas_id")
 
-	fmt.Printf("ClusterName: %s, ClusterType: %s\n", logaas_id, requestData.ClusterType)
+	// DBに登録されていないLOGaaSの場合はリクエストでcluster-typeを指定する
+	requestData, err := logaasSpecFromModel(logaas, clients.Defaults)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, fmt.Sprintf("Failed to parse current spec: %v", err))
+		return
+	}
+	if err := c.ShouldBindJSON(&requestData); err != nil && !errors.Is(err, io.EOF) {
+		respondBindError(c, err)
+		return
+	}
 
-	// Helmの設定
-	_, uninstall, _ := utilities.OpenSearchHelmSetting(logaas_id, "uninstall")
+	fmt.Printf("ClusterName: %s, ClusterType: %s\n", logaas_id, requestData.ClusterType)
 
-	_, err := uninstall.Run(logaas_id)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"status":  "error",
-			"message": fmt.Sprintf("Failed to uninstall chart: %v", err),
-		})
+	// 削除中・更新中のLOGaaSは削除できない（DBに登録されていないLOGaaSはジョブの有無のみ確認する）
+	previousStatus, previousGuiStatus := logaas.Status, logaas.GuiStatus
+	if logaas.ID != 0 && !claimStatus(c, db, logaas, models.ResourceLOGaaS, logaas_id, models.StatusDeleting, models.StatusReady, models.StatusFailed) {
 		return
 	}
-	fmt.Printf("Successfully uninstalled chart with release name: %s\n", logaas_id)
+	updateLogaasGuiStatus(db, logaas, models.StatusDeleting)
+
+	projectId := logaas.ProjectId
+	if logaas.ID == 0 {
+		projectId = middlewares.TargetProjectId(c)
+	}
+	job := &models.Job{ProjectId: projectId, ResourceType: models.ResourceLOGaaS, ResourceId: logaas_id, Action: "delete"}
+	submitted := submitJob(ctx, c, jm, job, func(ctx context.Context, rec *jobs.Recorder) error {
+		// すでにアンインストール済みの場合はスキップするため、削除に失敗した場合も再実行できる
+		// OpenSearch Dashboardsを先に削除する
+		dashboardsRelease := utilities.OpenSearchDashboardsReleaseName(logaas_id)
+		err := rec.Step("Uninstall OpenSearch Dashboards", func() error {
+			if err := clients.Helm.Uninstall(ctx, utilities.OpenSearchNamespace, dashboardsRelease); err != nil && !errors.Is(err, driver.ErrReleaseNotFound) {
+				return fmt.Errorf("Failed to uninstall dashboards chart: %v", err)
+			}
+			fmt.Printf("Successfully uninstalled chart with release name: %s\n", dashboardsRelease)
+			return nil
+		})
+		if err != nil {
+			updateLogaasStatus(db, logaas, models.StatusFailed)
+			updateLogaasGuiStatus(db, logaas, models.StatusFailed)
+			return err
+		}
+		updateLogaasGuiStatus(db, logaas, models.StatusDeleted)
+
+		// インストールとは逆順（client、data、master）にノードグループごとにアンインストールする
+		releaseNames := utilities.OpenSearchReleaseNames(logaas_id, requestData.ClusterType)
+		for i := len(releaseNames) - 1; i >= 0; i-- {
+			releaseName := releaseNames[i]
+			err := rec.Step(fmt.Sprintf("Uninstall OpenSearch (%s)", releaseName), func() error {
+				if err := clients.Helm.Uninstall(ctx, utilities.OpenSearchNamespace, releaseName); err != nil && !errors.Is(err, driver.ErrReleaseNotFound) {
+					return fmt.Errorf("Failed to uninstall chart: %v", err)
+				}
+				fmt.Printf("Successfully uninstalled chart with release name: %s\n", releaseName)
+				return nil
+			})
+			if err != nil {
+				updateLogaasStatus(db, logaas, models.StatusFailed)
+				return err
+			}
+		}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message": fmt.Sprintf("Delete LOGaaS for %s successfully", logaas_id),
+		// Podの削除後にPV/PVCとCinderボリュームを削除する
+		err = rec.Step("Delete PersistentVolumes", func() error {
+			return deleteLogaasVolumes(ctx, clients.Kube, logaas_id)
+		})
+		if err != nil {
+			updateLogaasStatus(db, logaas, models.StatusFailed)
+			return err
+		}
+		err = rec.Step("Delete Cinder volumes", func() error {
+			return clients.Volumes.DeleteVolumes(logaas_id, requestData)
+		})
+		if err != nil {
+			updateLogaasStatus(db, logaas, models.StatusFailed)
+			return err
+		}
+		// S3のスナップショットはLOGaaSの削除後もリストアできるように残す
+		err = rec.Step("Delete snapshot repository settings", func() error {
+			return deleteSnapshotRepository(ctx, clients.Kube, logaas_id)
+		})
+		if err != nil {
+			updateLogaasStatus(db, logaas, models.StatusFailed)
+			return err
+		}
+		err = rec.Step("Delete credentials", func() error {
+			return utilities.DeleteLogaasCredentials(ctx, clients.Kube, logaas_id)
+		})
+		if err != nil {
+			updateLogaasStatus(db, logaas, models.StatusFailed)
+			return err
+		}
+
+		// ステータスをdeletedに更新してからレコードを論理削除
+		updateLogaasStatus(db, logaas, models.StatusDeleted)
+		if logaas.ID != 0 {
+			if err := db.Delete(logaas).Error; err != nil {
+				fmt.Printf("Error deleting logaas from db: %v\n", err)
+				return err
+			}
+		}
+
+		IncreaseLOGaaSDeleteCounter(requestData.ClusterType)
+		return nil
 	})
-	IncreaseLOGaaSDeleteCounter(logaas_id, requestData.ClusterType)
+	if !submitted {
+		updateLogaasStatus(db, logaas, previousStatus)
+		updateLogaasGuiStatus(db, logaas, previousGuiStatus)
+	}
 }
 
-func GetLogaases(ctx context.Context, c *gin.Context, clientset *kubernetes.Clientset, db *gorm.DB) {
-	c.JSON(http.StatusOK, gin.H{
-		"message": "Get logaases",
-	})
+func GetLogaases(ctx context.Context, c *gin.Context, clients *Clients, db *gorm.DB) {
+	page, pageSize, err := utilities.GetPagination(c)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// project、status、cluster_type、driftedで絞り込み（管理者以外は自身のプロジェクトのみ）
+	query := db.Model(&models.LOGaaS{})
+	project := c.Query("project")
+	if !middlewares.IsAdmin(c) {
+		if project != "" && !middlewares.AuthorizeProject(c, project) {
+			return
+		}
+		project = middlewares.GetProjectId(c)
+	}
+	if project != "" {
+		query = query.Where("project_id = ?", project)
+	}
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	query, ok := filterDrifted(c, query)
+	if !ok {
+		return
+	}
+	if clusterType := c.Query("cluster_type"); clusterType != "" {
+		query = query.Where("cluster_type = ?", clusterType)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		fmt.Printf("Error counting logaases: %v\n", err)
+		respondError(c, http.StatusInternalServerError, fmt.Sprintf("Error getting logaases\n Error messages: %s", err))
+		return
+	}
+
+	var logaases []models.LOGaaS
+	if err := query.Order("id").Offset((page - 1) * pageSize).Limit(pageSize).Find(&logaases).Error; err != nil {
+		fmt.Printf("Error getting logaases: %v\n", err)
+		respondError(c, http.StatusInternalServerError, fmt.Sprintf("Error getting logaases\n Error messages: %s", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, api.Success(api.Page[api.Logaas]{
+		Items:    convertAll(logaases, logaasResponse),
+		Total:    total,
+		Page:     page,
+		PageSize: pageSize,
+	}))
 }
@@ -0,0 +1,259 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"ham3/api"
+	"ham3/config"
+	"ham3/models"
+	"ham3/utilities"
+
+	"github.com/gin-gonic/gin"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage/driver"
+)
+
+// Cinderの代わりにボリュームを作成したことにするVolumeProvider
+type fakeVolumeProvider struct{}
+
+func (fakeVolumeProvider) CreateVolumes(logaas_id string, requestData api.LogaasRequestData) ([]utilities.OpenSearchVolume, error) {
+	return []utilities.OpenSearchVolume{{Name: logaas_id + "-master-0", NodeGroup: "master", Size: requestData.DataDiskSize, VolumeType: requestData.DiskType, Zone: requestData.Zone, ID: "volume-0"}}, nil
+}
+
+func (fakeVolumeProvider) WaitVolumesAvailable(volumes []utilities.OpenSearchVolume, timeout int) error {
+	return nil
+}
+
+func (fakeVolumeProvider) DeleteVolumes(logaas_id string, requestData api.LogaasRequestData) error {
+	return nil
+}
+
+func (fakeVolumeProvider) FindLeakedVolumes(ocpCluster string, exists func(logaas_id string) (bool, error)) ([]utilities.LeakedVolume, error) {
+	return nil, nil
+}
+
+// WaitHealthyはhealthErrを返す（nilの場合はgreen）
+type fakeOpenSearchClient struct {
+	healthErr error
+}
+
+func (f fakeOpenSearchClient) WaitHealthy(ctx context.Context, logaas_id string, baseDomain string) (string, error) {
+	if f.healthErr != nil {
+		return "", f.healthErr
+	}
+	return "green", nil
+}
+
+func (fakeOpenSearchClient) Do(ctx context.Context, logaas_id string, baseDomain string, method string, path string, body interface{}, out interface{}) error {
+	return nil
+}
+
+// failReleaseのインストールとアップグレードのみ失敗させるHelmInstaller
+type failingHelm struct {
+	utilities.HelmInstaller
+	failRelease string
+}
+
+func (h failingHelm) Install(ctx context.Context, namespace string, releaseName string, chart utilities.HelmChart, values map[string]interface{}) (*release.Release, error) {
+	if releaseName == h.failRelease {
+		return nil, fmt.Errorf("install %s: forced failure", releaseName)
+	}
+	return h.HelmInstaller.Install(ctx, namespace, releaseName, chart, values)
+}
+
+func (h failingHelm) Upgrade(ctx context.Context, namespace string, releaseName string, chart utilities.HelmChart, values map[string]interface{}) (*release.Release, error) {
+	if releaseName == h.failRelease {
+		return nil, fmt.Errorf("upgrade %s: forced failure", releaseName)
+	}
+	return h.HelmInstaller.Upgrade(ctx, namespace, releaseName, chart, values)
+}
+
+func TestRegisterLogaasOnlyOnce(t *testing.T) {
+	db := newTestDb(t)
+
+	first := models.LOGaaS{ProjectId: "p", ClusterName: "logs", ClusterType: "standard", Status: models.StatusCreating}
+	if ok, err := registerLogaas(db, &first); !ok || err != nil {
+		t.Fatalf("first register = %v, %v", ok, err)
+	}
+	second := models.LOGaaS{ProjectId: "p", ClusterName: "logs", ClusterType: "standard", Status: models.StatusCreating}
+	if ok, err := registerLogaas(db, &second); ok || err != nil {
+		t.Fatalf("second register = %v, %v, want false, nil", ok, err)
+	}
+
+	// 作成に失敗したLOGaaSの再作成も1つのリクエストだけが成功する
+	db.Model(&first).Update("status", models.StatusFailed)
+	retryA, retryB := first, first
+	retryA.Status, retryB.Status = models.StatusCreating, models.StatusCreating
+	if ok, err := registerLogaas(db, &retryA); !ok || err != nil {
+		t.Fatalf("retry register = %v, %v", ok, err)
+	}
+	if ok, err := registerLogaas(db, &retryB); ok || err != nil {
+		t.Fatalf("concurrent retry register = %v, %v, want false, nil", ok, err)
+	}
+}
+
+const testLogaasRequest = `{"cluster-type": "scalable", "base-domain": "example.com", "k8s-name": "k8s", "site": "site-a", "ocp-cluster": "ocp"}`
+
+func TestCreateLogaas(t *testing.T) {
+	if err := config.LoadFlavors("../" + config.DefaultFlavorFile); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name        string
+		failRelease string
+		healthErr   error
+		// 作成後に残っているリリース
+		wantReleases []string
+		wantStatus   string
+		wantSteps    []string
+	}{
+		{
+			name:         "succeeded",
+			wantReleases: []string{"logs-master", "logs-data", "logs-client", "logs-dashboards"},
+			wantStatus:   models.StatusReady,
+		},
+		{
+			// 失敗したノードグループより前にインストールしたリリースを削除する
+			name:        "install of data node group fails",
+			failRelease: "logs-data",
+			wantStatus:  models.StatusFailed,
+			wantSteps: []string{
+				"Install OpenSearch (master)=succeeded",
+				"Install OpenSearch (data)=failed",
+				"Rollback Install OpenSearch (master)=succeeded",
+			},
+		},
+		{
+			name:       "cluster does not become healthy",
+			healthErr:  errors.New("timed out waiting for cluster health"),
+			wantStatus: models.StatusFailed,
+			wantSteps: []string{
+				"Wait for OpenSearch cluster health=failed",
+				"Rollback Install OpenSearch (client)=succeeded",
+				"Rollback Install OpenSearch (data)=succeeded",
+				"Rollback Install OpenSearch (master)=succeeded",
+			},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			db := newTestDb(t)
+			jm := newTestJobManager(t, db)
+			clients := newTestClients()
+			helm := clients.Helm
+			clients.Helm = failingHelm{HelmInstaller: helm, failRelease: tc.failRelease}
+			clients.Volumes = fakeVolumeProvider{}
+			clients.OpenSearch = fakeOpenSearchClient{healthErr: tc.healthErr}
+
+			r := newTestEngine()
+			r.POST("/logaas/:logaas_id", func(c *gin.Context) { CreateLogaas(c.Request.Context(), c, clients, db, jm) })
+
+			job := waitAcceptedJob(t, jm, serve(r, http.MethodPost, "/logaas/logs", testLogaasRequest))
+			steps := jobStepResults(job)
+			if (job.Status == models.JobSucceeded) != (tc.wantStatus == models.StatusReady) {
+				t.Fatalf("job = %s (%s), steps: %v", job.Status, job.Error, steps)
+			}
+			for _, want := range tc.wantSteps {
+				if !utilities.Contains(steps, want) {
+					t.Errorf("steps %v do not contain %q", steps, want)
+				}
+			}
+
+			// 失敗した場合はロールバックでいずれのリリースも残らない
+			for _, name := range []string{"logs-master", "logs-data", "logs-client", "logs-dashboards"} {
+				_, err := helm.Status(context.Background(), utilities.OpenSearchNamespace, name)
+				if exists, want := err == nil, utilities.Contains(tc.wantReleases, name); exists != want {
+					t.Errorf("release %s exists = %v, want %v", name, exists, want)
+				} else if !exists && !errors.Is(err, driver.ErrReleaseNotFound) {
+					t.Errorf("status of %s: %v", name, err)
+				}
+			}
+
+			var logaas models.LOGaaS
+			if err := db.Where("cluster_name = ?", "logs").First(&logaas).Error; err != nil {
+				t.Fatal(err)
+			}
+			if logaas.Status != tc.wantStatus || logaas.GuiStatus != tc.wantStatus {
+				t.Errorf("status, gui_status = %s, %s, want %s", logaas.Status, logaas.GuiStatus, tc.wantStatus)
+			}
+		})
+	}
+}
+
+// 後のノードグループのアップグレードに失敗した場合、アップグレード済みのノードグループを前のリビジョンに戻す
+func TestUpdateLogaasRollsBackUpgradedNodeGroups(t *testing.T) {
+	if err := config.LoadFlavors("../" + config.DefaultFlavorFile); err != nil {
+		t.Fatal(err)
+	}
+	db := newTestDb(t)
+	jm := newTestJobManager(t, db)
+	clients := newTestClients()
+	helm := clients.Helm
+	clients.Volumes = fakeVolumeProvider{}
+	clients.OpenSearch = fakeOpenSearchClient{}
+
+	r := newTestEngine()
+	r.POST("/logaas/:logaas_id", func(c *gin.Context) { CreateLogaas(c.Request.Context(), c, clients, db, jm) })
+	r.PUT("/logaas/:logaas_id", func(c *gin.Context) { UpdateLogaas(c.Request.Context(), c, clients, db, jm) })
+	if job := waitAcceptedJob(t, jm, serve(r, http.MethodPost, "/logaas/logs", testLogaasRequest)); job.Status != models.JobSucceeded {
+		t.Fatalf("create job = %s (%s)", job.Status, job.Error)
+	}
+	var created models.LOGaaS
+	if err := db.Where("cluster_name = ?", "logs").First(&created).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	clients.Helm = failingHelm{HelmInstaller: helm, failRelease: "logs-client"}
+	job := waitAcceptedJob(t, jm, serve(r, http.MethodPut, "/logaas/logs", `{"master-flavor": "m1.medium"}`))
+	if job.Status != models.JobFailed {
+		t.Fatalf("update job = %s, want failed", job.Status)
+	}
+	steps := jobStepResults(job)
+	for _, want := range []string{
+		"Upgrade OpenSearch (master)=succeeded",
+		"Upgrade OpenSearch (data)=succeeded",
+		"Upgrade OpenSearch (client)=failed",
+		"Rollback Upgrade OpenSearch (data)=succeeded",
+		"Rollback Upgrade OpenSearch (master)=succeeded",
+	} {
+		if !utilities.Contains(steps, want) {
+			t.Errorf("steps %v do not contain %q", steps, want)
+		}
+	}
+
+	// インストール(1)、アップグレード(2)、ロールバック(3)の順にリビジョンが作成され、valuesはインストール時に戻る
+	for _, name := range []string{"logs-master", "logs-data"} {
+		rel, err := helm.Status(context.Background(), utilities.OpenSearchNamespace, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		actionConfig, err := helm.(*utilities.HelmClient).ActionConfig(utilities.OpenSearchNamespace)
+		if err != nil {
+			t.Fatal(err)
+		}
+		get := action.NewGet(actionConfig)
+		get.Version = 1
+		installed, err := get.Run(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rel.Version != 3 || fmt.Sprint(rel.Config) != fmt.Sprint(installed.Config) {
+			t.Errorf("release %s revision %d with values %v, want revision 3 with the installed values", name, rel.Version, rel.Config)
+		}
+	}
+
+	// DBのspecは更新前のまま
+	var logaas models.LOGaaS
+	if err := db.Where("cluster_name = ?", "logs").First(&logaas).Error; err != nil {
+		t.Fatal(err)
+	}
+	if logaas.Spec != created.Spec || logaas.Status != models.StatusFailed {
+		t.Errorf("status %s, spec %s, want failed with spec %s", logaas.Status, logaas.Spec, created.Spec)
+	}
+}
@@ -0,0 +1,147 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"ham3/api"
+	"ham3/config"
+	"ham3/middlewares"
+	"ham3/utilities"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// LOGaaSのテナントのユーザーの認証情報を返す（LOGaaSのプロジェクトのトークンのみ、管理者も取得できない）
+// パスワードはログに出力しない
+func GetLogaasCredentials(ctx context.Context, c *gin.Context, clients *Clients, db *gorm.DB) {
+	logaas_id := c.Param("logaas_id")
+	logaas, ok := getAuthorizedLogaas(c, db, logaas_id)
+	if !ok {
+		return
+	}
+	if logaas.ID == 0 {
+		respondError(c, http.StatusNotFound, fmt.Sprintf("%s logaas not found", logaas_id))
+		return
+	}
+	if middlewares.GetProjectId(c) != logaas.ProjectId {
+		respondError(c, http.StatusForbidden, "Credentials are only available to the project that owns the logaas")
+		return
+	}
+
+	credentials, err := utilities.GetLogaasCredentials(ctx, clients.Kube, logaas_id)
+	if apierrors.IsNotFound(err) || (err == nil && credentials.User == "") {
+		respondError(c, http.StatusNotFound, fmt.Sprintf("Credentials of %s are not issued", logaas_id))
+		return
+	} else if err != nil {
+		fmt.Printf("Error getting credentials of logaas[%s]: %v\n", logaas_id, err)
+		respondError(c, http.StatusInternalServerError, fmt.Sprintf("Error getting credentials for %s\n Error messages: %s", logaas_id, err))
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, api.Success(api.LogaasCredentials{
+		User:        credentials.User,
+		Password:    credentials.Password,
+		ApiEndpoint: logaas.ApiEndpoint,
+		GuiEndpoint: logaas.GuiEndpoint,
+	}))
+}
+
+// パスワードを再生成し、OpenSearchのユーザーとSecretを更新する
+// テナントのユーザーはLOGaaSのプロジェクトのトークン、adminユーザーは管理者のみ変更できる
+func RotateLogaasCredentials(ctx context.Context, c *gin.Context, clients *Clients, db *gorm.DB) {
+	logaas_id := c.Param("logaas_id")
+
+	var requestData api.LogaasCredentialsRotateRequestData
+	if err := c.ShouldBindJSON(&requestData); err != nil && !errors.Is(err, io.EOF) {
+		respondBindError(c, err)
+		return
+	}
+	rotateAdmin := requestData.User == utilities.LogaasAdminUser
+	if requestData.User != "" && !rotateAdmin {
+		respondFieldErrors(c, []api.FieldError{{Field: "user", Message: fmt.Sprintf("must be empty or %q", utilities.LogaasAdminUser)}})
+		return
+	}
+	if rotateAdmin && !requireAdmin(c) {
+		return
+	}
+
+	logaas, logaasSpec, ok := getReadyLogaas(c, clients, db, logaas_id)
+	if !ok {
+		return
+	}
+	if !rotateAdmin && middlewares.GetProjectId(c) != logaas.ProjectId {
+		respondError(c, http.StatusForbidden, "Credentials are only available to the project that owns the logaas")
+		return
+	}
+
+	credentials, err := utilities.GetLogaasCredentials(ctx, clients.Kube, logaas_id)
+	if apierrors.IsNotFound(err) || (err == nil && credentials.User == "") {
+		respondError(c, http.StatusNotFound, fmt.Sprintf("Credentials of %s are not issued", logaas_id))
+		return
+	} else if err != nil {
+		fmt.Printf("Error getting credentials of logaas[%s]: %v\n", logaas_id, err)
+		respondError(c, http.StatusInternalServerError, fmt.Sprintf("Error getting credentials for %s\n Error messages: %s", logaas_id, err))
+		return
+	}
+
+	password, passwordHash, err := utilities.GeneratePassword()
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, fmt.Sprintf("Failed to generate password: %v", err))
+		return
+	}
+
+	// internalusersのPUTはユーザーを置き換えるため、バックエンドロールも指定する
+	user := credentials.User
+	body := gin.H{"password": password, "backend_roles": []string{config.TenantBackendRole}, "description": "Tenant user"}
+	if rotateAdmin {
+		user = utilities.LogaasAdminUser
+		body = gin.H{"password": password, "backend_roles": []string{"admin"}, "description": "Admin user"}
+	}
+	// adminユーザーのパスワードは失われるとHAM3がOpenSearchを操作できなくなるため、変更中のパスワードとして先にSecretに保存する
+	// OpenSearchの更新は現在のパスワードで認証し、更新後に変更中のパスワードを確定する
+	if rotateAdmin {
+		credentials.PendingAdminPassword, credentials.PendingAdminPasswordHash = password, passwordHash
+		if err := utilities.SaveLogaasCredentials(ctx, clients.Kube, logaas_id, credentials); err != nil {
+			fmt.Printf("Error saving credentials of logaas[%s]: %v\n", logaas_id, err)
+			respondError(c, http.StatusInternalServerError, fmt.Sprintf("Error saving credentials for %s\n Error messages: %s", logaas_id, err))
+			return
+		}
+	}
+
+	path := fmt.Sprintf("_plugins/_security/api/internalusers/%s", user)
+	if err := clients.OpenSearch.Do(ctx, logaas_id, logaasSpec.BaseDomain, http.MethodPut, path, body, nil); err != nil {
+		respondOpenSearchError(c, "rotating credentials", logaas_id, err)
+		return
+	}
+
+	if rotateAdmin {
+		// 確定できなかった場合も、OpenSearchのクライアントが変更中のパスワードで認証して確定する
+		if err := utilities.CommitPendingAdminPassword(ctx, clients.Kube, logaas_id); err != nil {
+			fmt.Printf("Error committing admin password of logaas[%s]: %v\n", logaas_id, err)
+		}
+	} else {
+		credentials.Password, credentials.PasswordHash = password, passwordHash
+		if err := utilities.SaveLogaasCredentials(ctx, clients.Kube, logaas_id, credentials); err != nil {
+			fmt.Printf("Error saving credentials of logaas[%s]: %v\n", logaas_id, err)
+			respondError(c, http.StatusInternalServerError, fmt.Sprintf("Password of %s was changed but could not be saved\n Error messages: %s", user, err))
+			return
+		}
+	}
+
+	// adminユーザーのパスワードはHAM3のみが使うため返さない
+	if rotateAdmin {
+		c.JSON(http.StatusOK, api.Success(api.LogaasCredentials{User: user}))
+		return
+	}
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, api.Success(api.LogaasCredentials{
+		User:     credentials.User,
+		Password: credentials.Password,
+	}))
+}
@@ -0,0 +1,95 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"ham3/api"
+	"ham3/middlewares"
+	"ham3/models"
+	"ham3/utilities"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PUTした時点のSecretの変更中のパスワードを記録するOpenSearchClient
+type rotatingOpenSearchClient struct {
+	fakeOpenSearchClient
+	clients *Clients
+	err     error
+	// PUTしたパスワードとその時点でSecretに保存されていた変更中のパスワード
+	putPassword     string
+	pendingAtUpdate string
+}
+
+func (f *rotatingOpenSearchClient) Do(ctx context.Context, logaas_id string, baseDomain string, method string, path string, body interface{}, out interface{}) error {
+	f.putPassword = body.(gin.H)["password"].(string)
+	credentials, err := utilities.GetLogaasCredentials(ctx, f.clients.Kube, logaas_id)
+	if err != nil {
+		return err
+	}
+	f.pendingAtUpdate = credentials.PendingAdminPassword
+	return f.err
+}
+
+func TestRotateLogaasAdminCredentials(t *testing.T) {
+	cases := []struct {
+		name       string
+		err        error
+		wantStatus int
+		// 変更に成功した場合のみadminのパスワードが変わる
+		wantRotated bool
+	}{
+		{name: "succeeded", wantStatus: http.StatusOK, wantRotated: true},
+		{name: "opensearch fails", err: &utilities.OpenSearchError{StatusCode: http.StatusInternalServerError}, wantStatus: http.StatusBadGateway},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			db := newTestDb(t)
+			clients := newTestClients()
+			openSearch := &rotatingOpenSearchClient{clients: clients, err: tc.err}
+			clients.OpenSearch = openSearch
+			ctx := context.Background()
+
+			spec, _ := json.Marshal(api.LogaasRequestData{ClusterType: "standard", BaseDomain: "example.com"})
+			logaas := models.LOGaaS{ProjectId: testProjectId, ClusterName: "logs", ClusterType: "standard", Status: models.StatusReady, Spec: string(spec)}
+			if err := db.Create(&logaas).Error; err != nil {
+				t.Fatal(err)
+			}
+			old := utilities.LogaasCredentials{AdminPassword: "old", AdminPasswordHash: "old-hash", User: "tenant", Password: "tenant-password"}
+			if err := utilities.SaveLogaasCredentials(ctx, clients.Kube, "logs", old); err != nil {
+				t.Fatal(err)
+			}
+
+			r := newTestEngine()
+			r.Use(func(c *gin.Context) { c.Set(middlewares.IsAdminContextKey, true) })
+			r.POST("/logaas/:logaas_id/credentials/rotate", func(c *gin.Context) { RotateLogaasCredentials(c.Request.Context(), c, clients, db) })
+
+			w := serve(r, http.MethodPost, "/logaas/logs/credentials/rotate", `{"user": "admin"}`)
+			if w.Code != tc.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tc.wantStatus, w.Body)
+			}
+			// OpenSearchを更新する前に新しいパスワードがSecretに保存されている
+			if openSearch.putPassword == "" || openSearch.pendingAtUpdate != openSearch.putPassword {
+				t.Errorf("pending password at update = %q, want the new password %q", openSearch.pendingAtUpdate, openSearch.putPassword)
+			}
+
+			credentials, err := utilities.GetLogaasCredentials(ctx, clients.Kube, "logs")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tc.wantRotated {
+				if credentials.AdminPassword != openSearch.putPassword || credentials.PendingAdminPassword != "" {
+					t.Errorf("admin, pending = %q, %q, want %q, empty", credentials.AdminPassword, credentials.PendingAdminPassword, openSearch.putPassword)
+				}
+			} else if credentials.AdminPassword != old.AdminPassword || credentials.PendingAdminPassword != openSearch.putPassword {
+				t.Errorf("admin, pending = %q, %q, want %q, %q", credentials.AdminPassword, credentials.PendingAdminPassword, old.AdminPassword, openSearch.putPassword)
+			}
+			if credentials.Password != old.Password {
+				t.Errorf("tenant password changed to %q", credentials.Password)
+			}
+		})
+	}
+}
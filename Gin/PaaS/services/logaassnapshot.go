@@ -0,0 +1,653 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"ham3/api"
+	"ham3/models"
+	"ham3/utilities"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// HAM3が登録するS3のスナップショットリポジトリ名
+const snapshotRepositoryName = "ham3-s3"
+
+// HAM3が登録する定期的なスナップショットのポリシー名(Snapshot Management)
+const snapshotPolicyName = "ham3-snapshots"
+
+// リポジトリの設定（認証情報を含む）を保存するSecretのキー
+const snapshotRepositorySecretKey = "repository.json"
+
+// リポジトリの設定を保存するSecret名（別のLOGaaSへのリストア時に同じリポジトリを登録するため）
+func snapshotRepositorySecretName(logaas_id string) string {
+	return fmt.Sprintf("%s-snapshot-repository", logaas_id)
+}
+
+// 別のLOGaaSのスナップショットをリストアする際に、リストア先に読み取り専用で登録するリポジトリ名
+func sourceSnapshotRepositoryName(source_logaas_id string) string {
+	return fmt.Sprintf("%s-%s", snapshotRepositoryName, source_logaas_id)
+}
+
+// OpenSearchのAPIを呼び出すLOGaaSを取得する（レスポンスはこの関数内で返す）
+// OpenSearchのAPIはreadyのLOGaaSのみ呼び出せる
+func getReadyLogaas(c *gin.Context, clients *Clients, db *gorm.DB, logaas_id string) (*models.LOGaaS, api.LogaasRequestData, bool) {
+	var requestData api.LogaasRequestData
+	logaas, ok := getAuthorizedLogaas(c, db, logaas_id)
+	if !ok {
+		return nil, requestData, false
+	}
+	if logaas.ID == 0 {
+		respondError(c, http.StatusNotFound, fmt.Sprintf("%s logaas not found", logaas_id))
+		return nil, requestData, false
+	}
+	if logaas.Status != models.StatusReady {
+		respondError(c, http.StatusConflict, fmt.Sprintf("%s logaas is not ready (status: %s)", logaas_id, logaas.Status))
+		return nil, requestData, false
+	}
+	requestData, err := logaasSpecFromModel(logaas, clients.Defaults)
+	if err != nil {
+		fmt.Printf("Error parsing spec of logaas[%s]: %v\n", logaas_id, err)
+		respondError(c, http.StatusInternalServerError, fmt.Sprintf("Error parsing spec of %s\n Error messages: %s", logaas_id, err))
+		return nil, requestData, false
+	}
+	return logaas, requestData, true
+}
+
+// OpenSearchのAPIのエラーを返す
+// OpenSearchが返した4xx（リポジトリ/スナップショットがない場合の404など）はそのまま返し、それ以外は502で返す
+func respondOpenSearchError(c *gin.Context, action string, logaas_id string, err error) {
+	fmt.Printf("Error %s for logaas[%s]: %v\n", action, logaas_id, err)
+	status := http.StatusBadGateway
+	var openSearchErr *utilities.OpenSearchError
+	if errors.As(err, &openSearchErr) && openSearchErr.StatusCode >= 400 && openSearchErr.StatusCode < 500 &&
+		openSearchErr.StatusCode != http.StatusUnauthorized && openSearchErr.StatusCode != http.StatusForbidden {
+		status = openSearchErr.StatusCode
+	}
+	respondError(c, status, fmt.Sprintf("Error %s for %s\n Error messages: %s", action, logaas_id, err))
+}
+
+// S3リポジトリの設定(PUT _snapshot/<リポジトリ名>のbody)
+// 認証情報はリポジトリの設定で指定する（opensearch.allow_insecure_settingsが必要）
+func snapshotRepositoryBody(requestData api.SnapshotRepositoryRequestData, readonly bool) gin.H {
+	settings := gin.H{"bucket": requestData.Bucket}
+	if requestData.BasePath != "" {
+		settings["base_path"] = requestData.BasePath
+	}
+	if requestData.Endpoint != "" {
+		endpoint := requestData.Endpoint
+		if strings.HasPrefix(endpoint, "http://") {
+			settings["protocol"] = "http"
+		}
+		endpoint = strings.TrimPrefix(strings.TrimPrefix(endpoint, "http://"), "https://")
+		settings["endpoint"] = strings.TrimSuffix(endpoint, "/")
+	}
+	if requestData.Region != "" {
+		settings["region"] = requestData.Region
+	}
+	if requestData.PathStyleAccess {
+		settings["path_style_access"] = true
+	}
+	if requestData.AccessKey != "" {
+		settings["access_key"] = requestData.AccessKey
+		settings["secret_key"] = requestData.SecretKey
+	}
+	if readonly {
+		settings["readonly"] = true
+	}
+	return gin.H{"type": "s3", "settings": settings}
+}
+
+// レスポンスに含めるリポジトリの設定（シークレットキーは返さない）
+func redactSnapshotRepository(requestData api.SnapshotRepositoryRequestData) api.SnapshotRepositoryRequestData {
+	if requestData.SecretKey != "" {
+		requestData.SecretKey = "********"
+	}
+	return requestData
+}
+
+// リポジトリの設定をSecretに保存する（既存のSecretは上書きする）
+func saveSnapshotRepository(ctx context.Context, clientset kubernetes.Interface, logaas_id string, requestData api.SnapshotRepositoryRequestData) error {
+	data, err := json.Marshal(requestData)
+	if err != nil {
+		return err
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      snapshotRepositorySecretName(logaas_id),
+			Namespace: utilities.OpenSearchNamespace,
+			Labels: map[string]string{
+				logaasVolumeLabel: logaas_id,
+			},
+		},
+		Type: corev1.SecretTypeOpaque,
+		Data: map[string][]byte{snapshotRepositorySecretKey: data},
+	}
+	secrets := clientset.CoreV1().Secrets(utilities.OpenSearchNamespace)
+	if _, err := secrets.Create(ctx, secret, metav1.CreateOptions{}); !apierrors.IsAlreadyExists(err) {
+		return err
+	}
+	_, err = secrets.Update(ctx, secret, metav1.UpdateOptions{})
+	return err
+}
+
+// Secretからリポジトリの設定を取得する（登録されていない場合はNotFoundのエラーを返す）
+func loadSnapshotRepository(ctx context.Context, clientset kubernetes.Interface, logaas_id string) (api.SnapshotRepositoryRequestData, error) {
+	var requestData api.SnapshotRepositoryRequestData
+	secret, err := clientset.CoreV1().Secrets(utilities.OpenSearchNamespace).Get(ctx, snapshotRepositorySecretName(logaas_id), metav1.GetOptions{})
+	if err != nil {
+		return requestData, err
+	}
+	err = json.Unmarshal(secret.Data[snapshotRepositorySecretKey], &requestData)
+	return requestData, err
+}
+
+// LOGaaSの削除時にリポジトリの設定を削除する（S3のスナップショットは削除しない）
+func deleteSnapshotRepository(ctx context.Context, clientset kubernetes.Interface, logaas_id string) error {
+	return utilities.IgnoreNotFound(clientset.CoreV1().Secrets(utilities.OpenSearchNamespace).Delete(ctx, snapshotRepositorySecretName(logaas_id), metav1.DeleteOptions{}))
+}
+
+// S3互換ストレージをスナップショットのリポジトリとして登録する
+// OpenSearchがバケットにアクセスできるか検証してから登録する
+func PutLogaasSnapshotRepository(ctx context.Context, c *gin.Context, clients *Clients, db *gorm.DB) {
+	logaas_id := c.Param("logaas_id")
+	_, logaasSpec, ok := getReadyLogaas(c, clients, db, logaas_id)
+	if !ok {
+		return
+	}
+
+	var requestData api.SnapshotRepositoryRequestData
+	if err := c.ShouldBindJSON(&requestData); err != nil {
+		respondBindError(c, err)
+		return
+	}
+	if fieldErrors := utilities.CheckSnapshotRepositoryParameters(requestData); len(fieldErrors) > 0 {
+		respondFieldErrors(c, fieldErrors)
+		return
+	}
+
+	path := fmt.Sprintf("_snapshot/%s", snapshotRepositoryName)
+	if err := clients.OpenSearch.Do(ctx, logaas_id, logaasSpec.BaseDomain, http.MethodPut, path, snapshotRepositoryBody(requestData, false), nil); err != nil {
+		respondOpenSearchError(c, "registering snapshot repository", logaas_id, err)
+		return
+	}
+	if err := saveSnapshotRepository(ctx, clients.Kube, logaas_id, requestData); err != nil {
+		fmt.Printf("Error saving snapshot repository of logaas[%s]: %v\n", logaas_id, err)
+		respondError(c, http.StatusInternalServerError, fmt.Sprintf("Error saving snapshot repository for %s\n Error messages: %s", logaas_id, err))
+		return
+	}
+
+	c.JSON(http.StatusOK, api.Success(redactSnapshotRepository(requestData)))
+}
+
+// 登録したリポジトリの設定を返す
+func GetLogaasSnapshotRepository(ctx context.Context, c *gin.Context, clients *Clients, db *gorm.DB) {
+	logaas_id := c.Param("logaas_id")
+	if _, _, ok := getReadyLogaas(c, clients, db, logaas_id); !ok {
+		return
+	}
+
+	requestData, err := loadSnapshotRepository(ctx, clients.Kube, logaas_id)
+	if apierrors.IsNotFound(err) {
+		respondError(c, http.StatusNotFound, fmt.Sprintf("Snapshot repository of %s is not registered", logaas_id))
+		return
+	} else if err != nil {
+		fmt.Printf("Error getting snapshot repository of logaas[%s]: %v\n", logaas_id, err)
+		respondError(c, http.StatusInternalServerError, fmt.Sprintf("Error getting snapshot repository for %s\n Error messages: %s", logaas_id, err))
+		return
+	}
+
+	c.JSON(http.StatusOK, api.Success(redactSnapshotRepository(requestData)))
+}
+
+// スナップショットの取得を開始する（完了はGETのstateで確認する）
+func CreateLogaasSnapshot(ctx context.Context, c *gin.Context, clients *Clients, db *gorm.DB) {
+	logaas_id := c.Param("logaas_id")
+	_, logaasSpec, ok := getReadyLogaas(c, clients, db, logaas_id)
+	if !ok {
+		return
+	}
+
+	// bodyは省略可能（すべてのインデックスを日時の名前で取得する）
+	var requestData api.SnapshotRequestData
+	if err := c.ShouldBindJSON(&requestData); err != nil && !errors.Is(err, io.EOF) {
+		respondBindError(c, err)
+		return
+	}
+	if requestData.Name == "" {
+		requestData.Name = fmt.Sprintf("%s-%s", logaas_id, time.Now().UTC().Format("20060102-150405"))
+	}
+	fieldErrors := append(utilities.CheckSnapshotName("name", requestData.Name), utilities.CheckIndexPatterns("indices", requestData.Indices)...)
+	if len(fieldErrors) > 0 {
+		respondFieldErrors(c, fieldErrors)
+		return
+	}
+
+	body := gin.H{"include_global_state": false}
+	if len(requestData.Indices) > 0 {
+		body["indices"] = strings.Join(requestData.Indices, ",")
+	}
+	path := fmt.Sprintf("_snapshot/%s/%s", snapshotRepositoryName, url.PathEscape(requestData.Name))
+	if err := clients.OpenSearch.Do(ctx, logaas_id, logaasSpec.BaseDomain, http.MethodPut, path, body, nil); err != nil {
+		respondOpenSearchError(c, "creating snapshot", logaas_id, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, api.SnapshotAcceptedResponse{
+		Status:   api.StatusAccepted,
+		Message:  fmt.Sprintf("Accepted snapshot %s for %s", requestData.Name, logaas_id),
+		Snapshot: requestData.Name,
+	})
+}
+
+// スナップショットの一覧を返す
+func GetLogaasSnapshots(ctx context.Context, c *gin.Context, clients *Clients, db *gorm.DB) {
+	logaas_id := c.Param("logaas_id")
+	_, logaasSpec, ok := getReadyLogaas(c, clients, db, logaas_id)
+	if !ok {
+		return
+	}
+
+	var response struct {
+		Snapshots []api.Snapshot `json:"snapshots"`
+	}
+	path := fmt.Sprintf("_snapshot/%s/_all", snapshotRepositoryName)
+	if err := clients.OpenSearch.Do(ctx, logaas_id, logaasSpec.BaseDomain, http.MethodGet, path, nil, &response); err != nil {
+		respondOpenSearchError(c, "getting snapshots", logaas_id, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, api.Success(response.Snapshots))
+}
+
+func GetLogaasSnapshot(ctx context.Context, c *gin.Context, clients *Clients, db *gorm.DB) {
+	logaas_id := c.Param("logaas_id")
+	snapshot := c.Param("snapshot")
+	_, logaasSpec, ok := getReadyLogaas(c, clients, db, logaas_id)
+	if !ok {
+		return
+	}
+	if fieldErrors := utilities.CheckSnapshotName("snapshot", snapshot); len(fieldErrors) > 0 {
+		respondFieldErrors(c, fieldErrors)
+		return
+	}
+
+	var response struct {
+		Snapshots []api.Snapshot `json:"snapshots"`
+	}
+	path := fmt.Sprintf("_snapshot/%s/%s", snapshotRepositoryName, url.PathEscape(snapshot))
+	if err := clients.OpenSearch.Do(ctx, logaas_id, logaasSpec.BaseDomain, http.MethodGet, path, nil, &response); err != nil {
+		respondOpenSearchError(c, "getting snapshot", logaas_id, err)
+		return
+	}
+	if len(response.Snapshots) == 0 {
+		respondError(c, http.StatusNotFound, fmt.Sprintf("Snapshot %s of %s not found", snapshot, logaas_id))
+		return
+	}
+
+	c.JSON(http.StatusOK, api.Success(response.Snapshots[0]))
+}
+
+func DeleteLogaasSnapshot(ctx context.Context, c *gin.Context, clients *Clients, db *gorm.DB) {
+	logaas_id := c.Param("logaas_id")
+	snapshot := c.Param("snapshot")
+	_, logaasSpec, ok := getReadyLogaas(c, clients, db, logaas_id)
+	if !ok {
+		return
+	}
+	if fieldErrors := utilities.CheckSnapshotName("snapshot", snapshot); len(fieldErrors) > 0 {
+		respondFieldErrors(c, fieldErrors)
+		return
+	}
+
+	path := fmt.Sprintf("_snapshot/%s/%s", snapshotRepositoryName, url.PathEscape(snapshot))
+	if err := clients.OpenSearch.Do(ctx, logaas_id, logaasSpec.BaseDomain, http.MethodDelete, path, nil, nil); err != nil {
+		respondOpenSearchError(c, "deleting snapshot", logaas_id, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, api.Success(fmt.Sprintf("Deleted snapshot %s of %s", snapshot, logaas_id)))
+}
+
+// スナップショットをリストアする（リストアの完了は待たない）
+// target-logaasを指定した場合は、リストア先のLOGaaSにスナップショットのリポジトリを読み取り専用で登録してからリストアする
+func RestoreLogaasSnapshot(ctx context.Context, c *gin.Context, clients *Clients, db *gorm.DB) {
+	logaas_id := c.Param("logaas_id")
+	snapshot := c.Param("snapshot")
+	if _, _, ok := getReadyLogaas(c, clients, db, logaas_id); !ok {
+		return
+	}
+
+	// bodyは省略可能（同じLOGaaSにすべてのインデックスをリストアする）
+	var requestData api.SnapshotRestoreRequestData
+	if err := c.ShouldBindJSON(&requestData); err != nil && !errors.Is(err, io.EOF) {
+		respondBindError(c, err)
+		return
+	}
+	fieldErrors := append(utilities.CheckSnapshotName("snapshot", snapshot), utilities.CheckSnapshotRestoreParameters(requestData)...)
+	if len(fieldErrors) > 0 {
+		respondFieldErrors(c, fieldErrors)
+		return
+	}
+
+	target := logaas_id
+	if requestData.TargetLogaas != "" {
+		target = requestData.TargetLogaas
+	}
+	_, targetSpec, ok := getReadyLogaas(c, clients, db, target)
+	if !ok {
+		return
+	}
+
+	repository := snapshotRepositoryName
+	if target != logaas_id {
+		repositoryData, err := loadSnapshotRepository(ctx, clients.Kube, logaas_id)
+		if apierrors.IsNotFound(err) {
+			respondError(c, http.StatusNotFound, fmt.Sprintf("Snapshot repository of %s is not registered", logaas_id))
+			return
+		} else if err != nil {
+			fmt.Printf("Error getting snapshot repository of logaas[%s]: %v\n", logaas_id, err)
+			respondError(c, http.StatusInternalServerError, fmt.Sprintf("Error getting snapshot repository for %s\n Error messages: %s", logaas_id, err))
+			return
+		}
+
+		// リストア先のリポジトリ(ham3-s3)を上書きしないよう、リストア元ごとのリポジトリ名で登録する
+		repository = sourceSnapshotRepositoryName(logaas_id)
+		path := fmt.Sprintf("_snapshot/%s", url.PathEscape(repository))
+		if err := clients.OpenSearch.Do(ctx, target, targetSpec.BaseDomain, http.MethodPut, path, snapshotRepositoryBody(repositoryData, true), nil); err != nil {
+			respondOpenSearchError(c, "registering snapshot repository", target, err)
+			return
+		}
+	}
+
+	body := gin.H{"include_global_state": false}
+	if len(requestData.Indices) > 0 {
+		body["indices"] = strings.Join(requestData.Indices, ",")
+	}
+	if requestData.RenamePattern != "" {
+		body["rename_pattern"] = requestData.RenamePattern
+		body["rename_replacement"] = requestData.RenameReplacement
+	}
+	path := fmt.Sprintf("_snapshot/%s/%s/_restore", url.PathEscape(repository), url.PathEscape(snapshot))
+	if err := clients.OpenSearch.Do(ctx, target, targetSpec.BaseDomain, http.MethodPost, path, body, nil); err != nil {
+		respondOpenSearchError(c, "restoring snapshot", target, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, api.RestoreAcceptedResponse{
+		Status:  api.StatusAccepted,
+		Message: fmt.Sprintf("Accepted restore of snapshot %s of %s to %s", snapshot, logaas_id, target),
+		Target:  target,
+	})
+}
+
+// OpenSearchのポリシーの更新に必要なシーケンス番号（GETのレスポンスの一部）
+type openSearchPolicyVersion struct {
+	SeqNo       int64 `json:"_seq_no"`
+	PrimaryTerm int64 `json:"_primary_term"`
+}
+
+// ポリシーを作成または更新する
+// 既存のポリシーの更新はGETで取得したシーケンス番号を指定する必要がある
+func putOpenSearchPolicy(ctx context.Context, clients *Clients, logaas_id string, baseDomain string, path string, createMethod string, body gin.H) error {
+	var current openSearchPolicyVersion
+	err := clients.OpenSearch.Do(ctx, logaas_id, baseDomain, http.MethodGet, path, nil, &current)
+	if utilities.IsOpenSearchStatus(err, http.StatusNotFound) {
+		return clients.OpenSearch.Do(ctx, logaas_id, baseDomain, createMethod, path, body, nil)
+	} else if err != nil {
+		return err
+	}
+	path = fmt.Sprintf("%s?if_seq_no=%d&if_primary_term=%d", path, current.SeqNo, current.PrimaryTerm)
+	return clients.OpenSearch.Do(ctx, logaas_id, baseDomain, http.MethodPut, path, body, nil)
+}
+
+// Snapshot ManagementはOpenSearch 2.1以降のみ
+func supportsSnapshotManagement(openSearchVersion string) bool {
+	return !strings.HasPrefix(openSearchVersion, "1.")
+}
+
+// 定期的なスナップショットの取得と保持期間(Snapshot Managementのポリシー)を設定する
+func PutLogaasSnapshotPolicy(ctx context.Context, c *gin.Context, clients *Clients, db *gorm.DB) {
+	logaas_id := c.Param("logaas_id")
+	_, logaasSpec, ok := getReadyLogaas(c, clients, db, logaas_id)
+	if !ok {
+		return
+	}
+	if !supportsSnapshotManagement(logaasSpec.OpenSearchVersion) {
+		respondError(c, http.StatusBadRequest, fmt.Sprintf("Snapshot policy requires OpenSearch 2.1 or later (%s is %s)", logaas_id, logaasSpec.OpenSearchVersion))
+		return
+	}
+
+	var requestData api.SnapshotPolicyRequestData
+	if err := c.ShouldBindJSON(&requestData); err != nil {
+		respondBindError(c, err)
+		return
+	}
+	if fieldErrors := utilities.CheckSnapshotPolicyParameters(requestData); len(fieldErrors) > 0 {
+		respondFieldErrors(c, fieldErrors)
+		return
+	}
+	if requestData.Timezone == "" {
+		requestData.Timezone = "UTC"
+	}
+	indices := "*"
+	if len(requestData.Indices) > 0 {
+		indices = strings.Join(requestData.Indices, ",")
+	}
+
+	condition := gin.H{}
+	if requestData.MaxAge != "" {
+		condition["max_age"] = requestData.MaxAge
+	}
+	if requestData.MaxCount > 0 {
+		condition["max_count"] = requestData.MaxCount
+	}
+	if requestData.MinCount > 0 {
+		condition["min_count"] = requestData.MinCount
+	}
+	body := gin.H{
+		"description": "Scheduled snapshots managed by HAM3",
+		"creation": gin.H{
+			"schedule": gin.H{"cron": gin.H{"expression": requestData.Schedule, "timezone": requestData.Timezone}},
+		},
+		"deletion": gin.H{
+			"schedule":  gin.H{"cron": gin.H{"expression": requestData.Schedule, "timezone": requestData.Timezone}},
+			"condition": condition,
+		},
+		"snapshot_config": gin.H{
+			"repository":           snapshotRepositoryName,
+			"indices":              indices,
+			"include_global_state": false,
+		},
+	}
+
+	path := fmt.Sprintf("_plugins/_sm/policies/%s", snapshotPolicyName)
+	if err := putOpenSearchPolicy(ctx, clients, logaas_id, logaasSpec.BaseDomain, path, http.MethodPost, body); err != nil {
+		respondOpenSearchError(c, "setting snapshot policy", logaas_id, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, api.Success(requestData))
+}
+
+// Snapshot Managementのポリシーと実行状態を返す
+func GetLogaasSnapshotPolicy(ctx context.Context, c *gin.Context, clients *Clients, db *gorm.DB) {
+	logaas_id := c.Param("logaas_id")
+	_, logaasSpec, ok := getReadyLogaas(c, clients, db, logaas_id)
+	if !ok {
+		return
+	}
+
+	var policy struct {
+		Policy map[string]interface{} `json:"sm_policy"`
+	}
+	path := fmt.Sprintf("_plugins/_sm/policies/%s", snapshotPolicyName)
+	if err := clients.OpenSearch.Do(ctx, logaas_id, logaasSpec.BaseDomain, http.MethodGet, path, nil, &policy); err != nil {
+		respondOpenSearchError(c, "getting snapshot policy", logaas_id, err)
+		return
+	}
+	var explain struct {
+		Policies []map[string]interface{} `json:"policies"`
+	}
+	if err := clients.OpenSearch.Do(ctx, logaas_id, logaasSpec.BaseDomain, http.MethodGet, path+"/_explain", nil, &explain); err != nil {
+		fmt.Printf("Error explaining snapshot policy of logaas[%s]: %v\n", logaas_id, err)
+	}
+
+	result := api.SnapshotPolicyStatus{Policy: policy.Policy}
+	if len(explain.Policies) > 0 {
+		result.Explain = explain.Policies[0]
+	}
+	c.JSON(http.StatusOK, api.Success(result))
+}
+
+func DeleteLogaasSnapshotPolicy(ctx context.Context, c *gin.Context, clients *Clients, db *gorm.DB) {
+	logaas_id := c.Param("logaas_id")
+	_, logaasSpec, ok := getReadyLogaas(c, clients, db, logaas_id)
+	if !ok {
+		return
+	}
+
+	path := fmt.Sprintf("_plugins/_sm/policies/%s", snapshotPolicyName)
+	if err := clients.OpenSearch.Do(ctx, logaas_id, logaasSpec.BaseDomain, http.MethodDelete, path, nil, nil); err != nil {
+		respondOpenSearchError(c, "deleting snapshot policy", logaas_id, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, api.Success(fmt.Sprintf("Deleted snapshot policy of %s", logaas_id)))
+}
+
+// インデックスの保持期間(ISMのポリシー)を設定する
+// 作成からdelete-afterが経過したインデックスを削除する。新しいインデックスにはism_templateで、既存のインデックスには追加で適用する
+func PutLogaasIsmPolicy(ctx context.Context, c *gin.Context, clients *Clients, db *gorm.DB) {
+	logaas_id := c.Param("logaas_id")
+	policy_id := c.Param("policy_id")
+	_, logaasSpec, ok := getReadyLogaas(c, clients, db, logaas_id)
+	if !ok {
+		return
+	}
+
+	var requestData api.IsmPolicyRequestData
+	if err := c.ShouldBindJSON(&requestData); err != nil {
+		respondBindError(c, err)
+		return
+	}
+	if fieldErrors := utilities.CheckIsmPolicyParameters(policy_id, requestData); len(fieldErrors) > 0 {
+		respondFieldErrors(c, fieldErrors)
+		return
+	}
+	if requestData.Description == "" {
+		requestData.Description = fmt.Sprintf("Delete indices %s after creation (managed by HAM3)", requestData.DeleteAfter)
+	}
+
+	body := gin.H{
+		"policy": gin.H{
+			"description":   requestData.Description,
+			"default_state": "hot",
+			"states": []gin.H{
+				{
+					"name":        "hot",
+					"actions":     []gin.H{},
+					"transitions": []gin.H{{"state_name": "delete", "conditions": gin.H{"min_index_age": requestData.DeleteAfter}}},
+				},
+				{
+					"name":        "delete",
+					"actions":     []gin.H{{"delete": gin.H{}}},
+					"transitions": []gin.H{},
+				},
+			},
+			"ism_template": []gin.H{{"index_patterns": requestData.IndexPatterns, "priority": 100}},
+		},
+	}
+
+	path := fmt.Sprintf("_plugins/_ism/policies/%s", url.PathEscape(policy_id))
+	if err := putOpenSearchPolicy(ctx, clients, logaas_id, logaasSpec.BaseDomain, path, http.MethodPut, body); err != nil {
+		respondOpenSearchError(c, "setting ISM policy", logaas_id, err)
+		return
+	}
+
+	// ポリシーが適用されていない既存のインデックスに適用する（適用済みのインデックスは失敗として返るため無視する）
+	for _, pattern := range requestData.IndexPatterns {
+		addPath := fmt.Sprintf("_plugins/_ism/add/%s", url.PathEscape(pattern))
+		if err := clients.OpenSearch.Do(ctx, logaas_id, logaasSpec.BaseDomain, http.MethodPost, addPath, gin.H{"policy_id": policy_id}, nil); err != nil && !utilities.IsOpenSearchStatus(err, http.StatusNotFound) {
+			fmt.Printf("Error applying ISM policy %s to %s of logaas[%s]: %v\n", policy_id, pattern, logaas_id, err)
+		}
+	}
+
+	c.JSON(http.StatusOK, api.Success(requestData))
+}
+
+func GetLogaasIsmPolicies(ctx context.Context, c *gin.Context, clients *Clients, db *gorm.DB) {
+	logaas_id := c.Param("logaas_id")
+	_, logaasSpec, ok := getReadyLogaas(c, clients, db, logaas_id)
+	if !ok {
+		return
+	}
+
+	var response struct {
+		Policies []api.IsmPolicy `json:"policies"`
+	}
+	if err := clients.OpenSearch.Do(ctx, logaas_id, logaasSpec.BaseDomain, http.MethodGet, "_plugins/_ism/policies", nil, &response); err != nil {
+		respondOpenSearchError(c, "getting ISM policies", logaas_id, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, api.Success(response.Policies))
+}
+
+func GetLogaasIsmPolicy(ctx context.Context, c *gin.Context, clients *Clients, db *gorm.DB) {
+	logaas_id := c.Param("logaas_id")
+	policy_id := c.Param("policy_id")
+	_, logaasSpec, ok := getReadyLogaas(c, clients, db, logaas_id)
+	if !ok {
+		return
+	}
+	if fieldErrors := utilities.CheckSnapshotName("policy_id", policy_id); len(fieldErrors) > 0 {
+		respondFieldErrors(c, fieldErrors)
+		return
+	}
+
+	var policy struct {
+		Policy api.IsmPolicy `json:"policy"`
+	}
+	path := fmt.Sprintf("_plugins/_ism/policies/%s", url.PathEscape(policy_id))
+	if err := clients.OpenSearch.Do(ctx, logaas_id, logaasSpec.BaseDomain, http.MethodGet, path, nil, &policy); err != nil {
+		respondOpenSearchError(c, "getting ISM policy", logaas_id, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, api.Success(policy.Policy))
+}
+
+// ISMのポリシーを削除する（適用済みのインデックスからはポリシーを外さない）
+func DeleteLogaasIsmPolicy(ctx context.Context, c *gin.Context, clients *Clients, db *gorm.DB) {
+	logaas_id := c.Param("logaas_id")
+	policy_id := c.Param("policy_id")
+	_, logaasSpec, ok := getReadyLogaas(c, clients, db, logaas_id)
+	if !ok {
+		return
+	}
+	if fieldErrors := utilities.CheckSnapshotName("policy_id", policy_id); len(fieldErrors) > 0 {
+		respondFieldErrors(c, fieldErrors)
+		return
+	}
+
+	path := fmt.Sprintf("_plugins/_ism/policies/%s", url.PathEscape(policy_id))
+	if err := clients.OpenSearch.Do(ctx, logaas_id, logaasSpec.BaseDomain, http.MethodDelete, path, nil, nil); err != nil {
+		respondOpenSearchError(c, "deleting ISM policy", logaas_id, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, api.Success(fmt.Sprintf("Deleted ISM policy %s of %s", policy_id, logaas_id)))
+}
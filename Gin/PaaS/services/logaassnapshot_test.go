@@ -0,0 +1,159 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"ham3/api"
+	"ham3/models"
+	"ham3/utilities"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 呼び出されたAPIを"<method> <path> <body>"として記録するOpenSearchClient
+// ポリシーのGETは未作成として404を返す
+type recordingOpenSearchClient struct {
+	fakeOpenSearchClient
+	mu       sync.Mutex
+	requests []string
+}
+
+func (f *recordingOpenSearchClient) Do(ctx context.Context, logaas_id string, baseDomain string, method string, path string, body interface{}, out interface{}) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	request := method + " " + path
+	if body != nil {
+		data, _ := json.Marshal(body)
+		request += " " + string(data)
+	}
+	f.requests = append(f.requests, request)
+	if method == http.MethodGet && strings.Contains(path, "/policies/") {
+		return &utilities.OpenSearchError{StatusCode: http.StatusNotFound}
+	}
+	return nil
+}
+
+// readyのLOGaaSを登録する
+func createReadyLogaas(t *testing.T, db *gorm.DB, logaas_id string) {
+	t.Helper()
+	spec, _ := json.Marshal(api.LogaasRequestData{ClusterType: "standard", BaseDomain: "example.com", OpenSearchVersion: "2.11.1"})
+	logaas := models.LOGaaS{ProjectId: testProjectId, ClusterName: logaas_id, ClusterType: "standard", Status: models.StatusReady, Spec: string(spec)}
+	if err := db.Create(&logaas).Error; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func newSnapshotTestEngine(t *testing.T) (*gin.Engine, *recordingOpenSearchClient, *Clients) {
+	t.Helper()
+	db := newTestDb(t)
+	createReadyLogaas(t, db, "logs")
+	createReadyLogaas(t, db, "restored")
+	clients := newTestClients()
+	openSearch := &recordingOpenSearchClient{}
+	clients.OpenSearch = openSearch
+
+	r := newTestEngine()
+	r.POST("/logaas/:logaas_id/snapshots", func(c *gin.Context) { CreateLogaasSnapshot(c.Request.Context(), c, clients, db) })
+	r.POST("/logaas/:logaas_id/snapshots/:snapshot/restore", func(c *gin.Context) { RestoreLogaasSnapshot(c.Request.Context(), c, clients, db) })
+	r.PUT("/logaas/:logaas_id/ism-policies/:policy_id", func(c *gin.Context) { PutLogaasIsmPolicy(c.Request.Context(), c, clients, db) })
+	return r, openSearch, clients
+}
+
+func TestSnapshotAndIsmPolicyRequests(t *testing.T) {
+	cases := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		wantStatus int
+		// OpenSearchに送信するリクエスト（400の場合は送信しない）
+		wantRequests []string
+	}{
+		{
+			name:         "create snapshot",
+			method:       http.MethodPost,
+			path:         "/logaas/logs/snapshots",
+			body:         `{"name": "daily", "indices": ["app-*", "audit"]}`,
+			wantStatus:   http.StatusAccepted,
+			wantRequests: []string{`PUT _snapshot/ham3-s3/daily {"include_global_state":false,"indices":"app-*,audit"}`},
+		},
+		{
+			name:       "create snapshot of system and excluded indices",
+			method:     http.MethodPost,
+			path:       "/logaas/logs/snapshots",
+			body:       `{"name": "daily", "indices": [".opendistro_security", "-audit", "app,audit"]}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "restore to another logaas",
+			method:     http.MethodPost,
+			path:       "/logaas/logs/snapshots/daily/restore",
+			body:       `{"target-logaas": "restored", "indices": ["app-*"]}`,
+			wantStatus: http.StatusAccepted,
+			wantRequests: []string{
+				`PUT _snapshot/ham3-s3-logs {"settings":{"bucket":"backups","readonly":true},"type":"s3"}`,
+				`POST _snapshot/ham3-s3-logs/daily/_restore {"include_global_state":false,"indices":"app-*"}`,
+			},
+		},
+		{
+			name:       "restore indices with path characters",
+			method:     http.MethodPost,
+			path:       "/logaas/logs/snapshots/daily/restore",
+			body:       `{"indices": ["app/../_security"]}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "put ism policy",
+			method:     http.MethodPut,
+			path:       "/logaas/logs/ism-policies/app-retention",
+			body:       `{"index-patterns": ["app-*"], "delete-after": "30d", "description": "app"}`,
+			wantStatus: http.StatusOK,
+			wantRequests: []string{
+				`GET _plugins/_ism/policies/app-retention`,
+				`PUT _plugins/_ism/policies/app-retention {"policy":{"default_state":"hot","description":"app","ism_template":[{"index_patterns":["app-*"],"priority":100}],"states":[{"actions":[],"name":"hot","transitions":[{"conditions":{"min_index_age":"30d"},"state_name":"delete"}]},{"actions":[{"delete":{}}],"name":"delete","transitions":[]}]}}`,
+				`POST _plugins/_ism/add/app-%2A {"policy_id":"app-retention"}`,
+			},
+		},
+		{
+			name:       "put ism policy for invalid index patterns",
+			method:     http.MethodPut,
+			path:       "/logaas/logs/ism-policies/app-retention",
+			body:       `{"index-patterns": ["*", "**", ".kibana", "app/../../_security", "app?x=1", "App-*"], "delete-after": "30d"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			r, openSearch, clients := newSnapshotTestEngine(t)
+			repository := api.SnapshotRepositoryRequestData{Bucket: "backups"}
+			if err := saveSnapshotRepository(context.Background(), clients.Kube, "logs", repository); err != nil {
+				t.Fatal(err)
+			}
+
+			w := serve(r, tc.method, tc.path, tc.body)
+			if w.Code != tc.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tc.wantStatus, w.Body)
+			}
+			if strings.Join(openSearch.requests, "\n") != strings.Join(tc.wantRequests, "\n") {
+				t.Errorf("requests:\n%s\nwant:\n%s", strings.Join(openSearch.requests, "\n"), strings.Join(tc.wantRequests, "\n"))
+			}
+			if tc.wantStatus != http.StatusBadRequest {
+				return
+			}
+			// 不正なパターンごとにフィールドエラーを返す
+			var response api.ErrorResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+				t.Fatal(err)
+			}
+			if response.Code != api.CodeValidationFailed || len(response.Errors) == 0 {
+				t.Errorf("response = %+v, want field errors", response)
+			}
+		})
+	}
+}
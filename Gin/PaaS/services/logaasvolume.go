@@ -0,0 +1,187 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"ham3/api"
+	"ham3/jobs"
+	"ham3/models"
+	"ham3/utilities"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// PV/PVCに付与するLOGaaS名のラベル（削除時にLOGaaSのPV/PVCをまとめて取得する）
+const logaasVolumeLabel = utilities.LogaasLabel
+
+// Cinderボリュームがavailableになるまで待つ秒数
+const cinderVolumeTimeout = 300
+
+// StatefulSetのvolumeClaimTemplatesから作られるPVC名（<clusterName>-<nodeGroup>が2回続き、末尾がPodの連番）
+// 同名のPVCを事前に作成しておくことで、各Podが対応するCinderボリュームを使う
+func logaasPvcName(logaas_id string, nodeGroup string, index int) string {
+	uname := fmt.Sprintf("%s-%s", logaas_id, nodeGroup)
+	return fmt.Sprintf("%s-%s-%d", uname, uname, index)
+}
+
+func logaasPersistentVolume(logaas_id string, volume utilities.OpenSearchVolume) *corev1.PersistentVolume {
+	pvcName := logaasPvcName(logaas_id, volume.NodeGroup, volume.Index)
+	return &corev1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: pvcName,
+			Labels: map[string]string{
+				logaasVolumeLabel: logaas_id,
+			},
+		},
+		Spec: corev1.PersistentVolumeSpec{
+			Capacity: corev1.ResourceList{
+				corev1.ResourceStorage: resource.MustParse(fmt.Sprintf("%dGi", volume.Size)),
+			},
+			AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+			// Cinderボリュームはこのサービスで削除するため、PVの削除時に削除しない
+			PersistentVolumeReclaimPolicy: corev1.PersistentVolumeReclaimRetain,
+			StorageClassName:              "",
+			ClaimRef: &corev1.ObjectReference{
+				Namespace: utilities.OpenSearchNamespace,
+				Name:      pvcName,
+			},
+			PersistentVolumeSource: corev1.PersistentVolumeSource{
+				CSI: &corev1.CSIPersistentVolumeSource{
+					Driver:       "cinder.csi.openstack.org",
+					VolumeHandle: volume.ID,
+					FSType:       "ext4",
+				},
+			},
+			// ボリュームと同じAZのノードにのみPodをスケジュールする
+			NodeAffinity: &corev1.VolumeNodeAffinity{
+				Required: &corev1.NodeSelector{
+					NodeSelectorTerms: []corev1.NodeSelectorTerm{
+						{
+							MatchExpressions: []corev1.NodeSelectorRequirement{
+								{
+									Key:      "topology.cinder.csi.openstack.org/zone",
+									Operator: corev1.NodeSelectorOpIn,
+									Values:   []string{volume.Zone},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func logaasPersistentVolumeClaim(logaas_id string, volume utilities.OpenSearchVolume) *corev1.PersistentVolumeClaim {
+	pvcName := logaasPvcName(logaas_id, volume.NodeGroup, volume.Index)
+	storageClassName := ""
+	return &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      pvcName,
+			Namespace: utilities.OpenSearchNamespace,
+			Labels: map[string]string{
+				logaasVolumeLabel: logaas_id,
+			},
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			AccessModes:      []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+			StorageClassName: &storageClassName,
+			VolumeName:       pvcName,
+			Resources: corev1.VolumeResourceRequirements{
+				Requests: corev1.ResourceList{
+					corev1.ResourceStorage: resource.MustParse(fmt.Sprintf("%dGi", volume.Size)),
+				},
+			},
+		},
+	}
+}
+
+// CinderボリュームごとにPVとPVCを作成する（すでに存在する場合はスキップ）
+func createLogaasVolumes(ctx context.Context, clientset kubernetes.Interface, logaas_id string, volumes []utilities.OpenSearchVolume) error {
+	// PVCはHelmのインストールより先に作成するため、Namespaceがない場合はここで作成する
+	namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: utilities.OpenSearchNamespace}}
+	if _, err := clientset.CoreV1().Namespaces().Create(ctx, namespace, metav1.CreateOptions{}); utilities.IgnoreAlreadyExists(err) != nil {
+		return fmt.Errorf("Failed to create namespace: %v", err)
+	}
+
+	for _, volume := range volumes {
+		pv := logaasPersistentVolume(logaas_id, volume)
+		if _, err := clientset.CoreV1().PersistentVolumes().Create(ctx, pv, metav1.CreateOptions{}); utilities.IgnoreAlreadyExists(err) != nil {
+			return fmt.Errorf("Failed to create PersistentVolume %s: %v", pv.Name, err)
+		}
+		pvc := logaasPersistentVolumeClaim(logaas_id, volume)
+		if _, err := clientset.CoreV1().PersistentVolumeClaims(utilities.OpenSearchNamespace).Create(ctx, pvc, metav1.CreateOptions{}); utilities.IgnoreAlreadyExists(err) != nil {
+			return fmt.Errorf("Failed to create PersistentVolumeClaim %s: %v", pvc.Name, err)
+		}
+		fmt.Printf("Created PersistentVolume/PersistentVolumeClaim %s for volume %s\n", pv.Name, volume.Name)
+	}
+	return nil
+}
+
+// LOGaaSのPVCとPVを削除する
+func deleteLogaasVolumes(ctx context.Context, clientset kubernetes.Interface, logaas_id string) error {
+	listOptions := metav1.ListOptions{LabelSelector: fmt.Sprintf("%s=%s", logaasVolumeLabel, logaas_id)}
+
+	if err := clientset.CoreV1().PersistentVolumeClaims(utilities.OpenSearchNamespace).DeleteCollection(ctx, metav1.DeleteOptions{}, listOptions); utilities.IgnoreNotFound(err) != nil {
+		return fmt.Errorf("Failed to delete PersistentVolumeClaims: %v", err)
+	}
+	if err := clientset.CoreV1().PersistentVolumes().DeleteCollection(ctx, metav1.DeleteOptions{}, listOptions); utilities.IgnoreNotFound(err) != nil {
+		return fmt.Errorf("Failed to delete PersistentVolumes: %v", err)
+	}
+	return nil
+}
+
+// 対応するLOGaaSが存在しない（削除済みまたは登録されていない）Cinderボリュームを返す（管理者のみ）
+func GetLeakedLogaasVolumes(ctx context.Context, c *gin.Context, clients *Clients, db *gorm.DB) {
+	if !requireAdmin(c) {
+		return
+	}
+
+	ocpCluster := c.DefaultQuery("ocp_cluster", clients.Defaults.OcpCluster)
+	leakedVolumes, err := clients.Volumes.FindLeakedVolumes(ocpCluster, func(logaas_id string) (bool, error) {
+		var logaas models.LOGaaS
+		err := db.Where("cluster_name = ?", logaas_id).First(&logaas).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, nil
+		}
+		return err == nil, err
+	})
+	if err != nil {
+		fmt.Printf("Error finding leaked volumes: %v\n", err)
+		respondError(c, http.StatusInternalServerError, fmt.Sprintf("Error finding leaked volumes\n Error messages: %s", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, api.Success(convertAll(leakedVolumes, func(volume *utilities.LeakedVolume) api.LeakedVolume {
+		return api.LeakedVolume(*volume)
+	})))
+}
+
+// LOGaaSのボリュームを準備する（作成済みのボリューム、PV、PVCはそのまま使う）
+func provisionLogaasVolumes(ctx context.Context, clients *Clients, logaas_id string, requestData api.LogaasRequestData, rec *jobs.Recorder) error {
+	var volumes []utilities.OpenSearchVolume
+	err := rec.Step("Create Cinder volumes", func() error {
+		var err error
+		volumes, err = clients.Volumes.CreateVolumes(logaas_id, requestData)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	err = rec.Step("Wait for Cinder volumes", func() error {
+		return clients.Volumes.WaitVolumesAvailable(volumes, cinderVolumeTimeout)
+	})
+	if err != nil {
+		return err
+	}
+	return rec.Step("Create PersistentVolumes", func() error {
+		return createLogaasVolumes(ctx, clients.Kube, logaas_id, volumes)
+	})
+}
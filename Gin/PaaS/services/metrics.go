@@ -0,0 +1,60 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"ham3/models"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"gorm.io/gorm"
+)
+
+// スクレイプ時にDBから数を取得する際のタイムアウト
+const resourceMetricsTimeout = 5 * time.Second
+
+// CaaS/LOGaaS/AAPaaSの数をステータスごとにDBから取得するコレクター（削除済みのレコードは含まない）
+type resourceCollector struct {
+	db   *gorm.DB
+	desc *prometheus.Desc
+}
+
+func NewResourceCollector(db *gorm.DB) prometheus.Collector {
+	return &resourceCollector{
+		db: db,
+		desc: prometheus.NewDesc(
+			"paas_resources",
+			"The number of CaaS, LOGaaS and AAPaaS by status",
+			[]string{"resource_type", "status"}, nil,
+		),
+	}
+}
+
+func (r *resourceCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- r.desc
+}
+
+func (r *resourceCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), resourceMetricsTimeout)
+	defer cancel()
+
+	for resourceType, model := range map[string]interface{}{
+		models.ResourceCaaS:   &models.CaaS{},
+		models.ResourceLOGaaS: &models.LOGaaS{},
+		models.ResourceAAPaaS: &models.AAPaaS{},
+	} {
+		var rows []struct {
+			Status string
+			Count  int64
+		}
+		if err := r.db.WithContext(ctx).Model(model).Select("status, count(*) AS count").Group("status").Scan(&rows).Error; err != nil {
+			fmt.Printf("Error counting %s for metrics: %v\n", resourceType, err)
+			ch <- prometheus.NewInvalidMetric(r.desc, err)
+			continue
+		}
+		for _, row := range rows {
+			ch <- prometheus.MustNewConstMetric(r.desc, prometheus.GaugeValue, float64(row.Count), resourceType, row.Status)
+		}
+	}
+}
@@ -0,0 +1,13 @@
+package services
+
+import (
+	"ham3/api"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// APIのOpenAPI 3のドキュメント（認証なしで取得できる）
+func GetOpenAPI(c *gin.Context) {
+	c.JSON(http.StatusOK, api.OpenAPI())
+}
@@ -0,0 +1,276 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"ham3/api"
+	"ham3/middlewares"
+	"ham3/models"
+	"ham3/utilities"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// プロジェクトが所有するリソース（削除済みのリソースは含まない）
+type projectResources struct {
+	Caas   []models.CaaS   `json:"caas"`
+	Logaas []models.LOGaaS `json:"logaas"`
+	Aapaas []models.AAPaaS `json:"aapaas"`
+}
+
+func (r projectResources) counts() api.ResourceCounts {
+	return api.ResourceCounts{
+		Caas:   len(r.Caas),
+		Logaas: len(r.Logaas),
+		Aapaas: len(r.Aapaas),
+	}
+}
+
+func (r projectResources) response() api.ProjectResourceList {
+	return api.ProjectResourceList{
+		Caas:   convertAll(r.Caas, caasResponse),
+		Logaas: convertAll(r.Logaas, logaasResponse),
+		Aapaas: convertAll(r.Aapaas, aapaasResponse),
+	}
+}
+
+func (r projectResources) empty() bool {
+	return len(r.Caas) == 0 && len(r.Logaas) == 0 && len(r.Aapaas) == 0
+}
+
+func getProjectResources(db *gorm.DB, projectId string) (projectResources, error) {
+	var resources projectResources
+	if err := db.Where("project_id = ?", projectId).Order("id").Find(&resources.Caas).Error; err != nil {
+		return resources, err
+	}
+	if err := db.Where("project_id = ?", projectId).Order("id").Find(&resources.Logaas).Error; err != nil {
+		return resources, err
+	}
+	if err := db.Where("project_id = ?", projectId).Order("id").Find(&resources.Aapaas).Error; err != nil {
+		return resources, err
+	}
+	return resources, nil
+}
+
+// 管理者以外は403を返してfalseを返す
+func requireAdmin(c *gin.Context) bool {
+	if middlewares.IsAdmin(c) {
+		return true
+	}
+	respondError(c, http.StatusForbidden, "Forbidden")
+	return false
+}
+
+// DBからプロジェクトを取得する（レスポンスはこの関数内で返す）
+func getProject(c *gin.Context, db *gorm.DB, projectId string) (*models.Projects, bool) {
+	var project models.Projects
+	err := db.Where("project_id = ?", projectId).First(&project).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		respondError(c, http.StatusNotFound, fmt.Sprintf("%s project not found", projectId))
+		return nil, false
+	} else if err != nil {
+		fmt.Printf("Error getting project from db: %v\n", err)
+		respondError(c, http.StatusInternalServerError, fmt.Sprintf("Error getting project for %s\n Error messages: %s", projectId, err))
+		return nil, false
+	}
+	return &project, true
+}
+
+// プロジェクト名（DBに登録されていない場合はプロジェクトID）
+func projectName(db *gorm.DB, projectId string) string {
+	var project models.Projects
+	if err := db.Where("project_id = ?", projectId).First(&project).Error; err != nil || project.ProjectName == "" {
+		return projectId
+	}
+	return project.ProjectName
+}
+
+// プロジェクトを登録する（管理者のみ）
+// 通常はリクエストしたトークンのプロジェクトが自動で登録されるため、事前に登録する場合に使う
+func CreateProject(ctx context.Context, c *gin.Context, db *gorm.DB) {
+	project_id := c.Param("project_id")
+	if !requireAdmin(c) {
+		return
+	}
+
+	var requestData api.ProjectRequestData
+	if err := c.ShouldBindJSON(&requestData); err != nil {
+		respondBindError(c, err)
+		return
+	}
+	if requestData.ProjectName == "" {
+		respondFieldErrors(c, []api.FieldError{{Field: "project-name", Message: "is required"}})
+		return
+	}
+
+	var project models.Projects
+	err := db.Where("project_id = ?", project_id).First(&project).Error
+	if err == nil {
+		respondError(c, http.StatusConflict, fmt.Sprintf("%s project already exists", project_id))
+		return
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		fmt.Printf("Error getting project from db: %v\n", err)
+		respondError(c, http.StatusInternalServerError, fmt.Sprintf("Error getting project for %s\n Error messages: %s", project_id, err))
+		return
+	}
+
+	project = models.Projects{ProjectId: project_id, ProjectName: requestData.ProjectName}
+	if err := db.Create(&project).Error; errors.Is(err, gorm.ErrDuplicatedKey) {
+		// 確認後に同じプロジェクトが登録された（トークンのプロジェクトの自動登録など）
+		respondError(c, http.StatusConflict, fmt.Sprintf("%s project already exists", project_id))
+		return
+	} else if err != nil {
+		fmt.Printf("Error creating project: %v\n", err)
+		respondError(c, http.StatusInternalServerError, fmt.Sprintf("Error creating project for %s\n Error messages: %s", project_id, err))
+		return
+	}
+
+	c.JSON(http.StatusOK, api.Success(projectResponse(&project)))
+}
+
+func GetProject(ctx context.Context, c *gin.Context, db *gorm.DB) {
+	project_id := c.Param("project_id")
+	if !middlewares.AuthorizeProject(c, project_id) {
+		return
+	}
+
+	project, ok := getProject(c, db, project_id)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, api.Success(projectResponse(project)))
+}
+
+// プロジェクト名を変更する（管理者のみ）
+// トークンのプロジェクト名が変わった場合は、次のリクエストで自動的に上書きされる
+func UpdateProject(ctx context.Context, c *gin.Context, db *gorm.DB) {
+	project_id := c.Param("project_id")
+	if !requireAdmin(c) {
+		return
+	}
+
+	project, ok := getProject(c, db, project_id)
+	if !ok {
+		return
+	}
+
+	var requestData api.ProjectRequestData
+	if err := c.ShouldBindJSON(&requestData); err != nil {
+		respondBindError(c, err)
+		return
+	}
+	if requestData.ProjectName == "" {
+		respondFieldErrors(c, []api.FieldError{{Field: "project-name", Message: "is required"}})
+		return
+	}
+
+	if err := db.Model(project).Update("project_name", requestData.ProjectName).Error; err != nil {
+		fmt.Printf("Error updating project: %v\n", err)
+		respondError(c, http.StatusInternalServerError, fmt.Sprintf("Error updating project for %s\n Error messages: %s", project_id, err))
+		return
+	}
+	middlewares.ForgetProject(project_id)
+
+	c.JSON(http.StatusOK, api.Success(projectResponse(project)))
+}
+
+// プロジェクトを削除する（管理者のみ）
+// CaaS/LOGaaS/AAPaaSが残っている場合は削除しない
+func DeleteProject(ctx context.Context, c *gin.Context, db *gorm.DB) {
+	project_id := c.Param("project_id")
+	if !requireAdmin(c) {
+		return
+	}
+
+	project, ok := getProject(c, db, project_id)
+	if !ok {
+		return
+	}
+
+	resources, err := getProjectResources(db, project_id)
+	if err != nil {
+		fmt.Printf("Error getting resources of project: %v\n", err)
+		respondError(c, http.StatusInternalServerError, fmt.Sprintf("Error getting resources of project %s\n Error messages: %s", project_id, err))
+		return
+	}
+	if !resources.empty() {
+		counts := resources.counts()
+		respondError(c, http.StatusConflict, fmt.Sprintf("%s project still has resources (caas: %d, logaas: %d, aapaas: %d)", project_id, counts.Caas, counts.Logaas, counts.Aapaas))
+		return
+	}
+
+	if err := db.Delete(project).Error; err != nil {
+		fmt.Printf("Error deleting project: %v\n", err)
+		respondError(c, http.StatusInternalServerError, fmt.Sprintf("Error deleting project for %s\n Error messages: %s", project_id, err))
+		return
+	}
+	middlewares.ForgetProject(project_id)
+
+	c.JSON(http.StatusOK, api.Success(fmt.Sprintf("%s project deleted", project_id)))
+}
+
+// プロジェクト一覧（管理者以外は自身のプロジェクトのみ）
+func GetProjects(ctx context.Context, c *gin.Context, db *gorm.DB) {
+	page, pageSize, err := utilities.GetPagination(c)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	query := db.Model(&models.Projects{})
+	if !middlewares.IsAdmin(c) {
+		query = query.Where("project_id = ?", middlewares.GetProjectId(c))
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		fmt.Printf("Error counting projects: %v\n", err)
+		respondError(c, http.StatusInternalServerError, fmt.Sprintf("Error getting projects\n Error messages: %s", err))
+		return
+	}
+
+	var projects []models.Projects
+	if err := query.Order("project_id").Offset((page - 1) * pageSize).Limit(pageSize).Find(&projects).Error; err != nil {
+		fmt.Printf("Error getting projects: %v\n", err)
+		respondError(c, http.StatusInternalServerError, fmt.Sprintf("Error getting projects\n Error messages: %s", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, api.Success(api.Page[api.Project]{
+		Items:    convertAll(projects, projectResponse),
+		Total:    total,
+		Page:     page,
+		PageSize: pageSize,
+	}))
+}
+
+// プロジェクトが所有するCaaS/LOGaaS/AAPaaSの一覧
+func GetProjectResources(ctx context.Context, c *gin.Context, db *gorm.DB) {
+	project_id := c.Param("project_id")
+	if !middlewares.AuthorizeProject(c, project_id) {
+		return
+	}
+
+	project, ok := getProject(c, db, project_id)
+	if !ok {
+		return
+	}
+
+	resources, err := getProjectResources(db, project_id)
+	if err != nil {
+		fmt.Printf("Error getting resources of project: %v\n", err)
+		respondError(c, http.StatusInternalServerError, fmt.Sprintf("Error getting resources of project %s\n Error messages: %s", project_id, err))
+		return
+	}
+
+	c.JSON(http.StatusOK, api.Success(api.ProjectResources{
+		Project:   projectResponse(project),
+		Counts:    resources.counts(),
+		Resources: resources.response(),
+	}))
+}
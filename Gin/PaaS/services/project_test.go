@@ -0,0 +1,122 @@
+package services
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"ham3/api"
+	"ham3/middlewares"
+	"ham3/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 管理者のトークンとして認証済みにするテスト用のEngine
+func newAdminTestEngine() *gin.Engine {
+	r := newTestEngine()
+	r.Use(func(c *gin.Context) {
+		c.Set(middlewares.IsAdminContextKey, true)
+		c.Next()
+	})
+	return r
+}
+
+func registerProjectRoutes(r *gin.Engine, db *gorm.DB) {
+	r.POST("/projects/:project_id", func(c *gin.Context) { CreateProject(c.Request.Context(), c, db) })
+	r.GET("/projects/:project_id", func(c *gin.Context) { GetProject(c.Request.Context(), c, db) })
+	r.PUT("/projects/:project_id", func(c *gin.Context) { UpdateProject(c.Request.Context(), c, db) })
+	r.DELETE("/projects/:project_id", func(c *gin.Context) { DeleteProject(c.Request.Context(), c, db) })
+	r.GET("/projects/:project_id/resources", func(c *gin.Context) { GetProjectResources(c.Request.Context(), c, db) })
+	r.GET("/projects/", func(c *gin.Context) { GetProjects(c.Request.Context(), c, db) })
+}
+
+func TestProjectLifecycleByAdmin(t *testing.T) {
+	db := newTestDb(t)
+	r := newAdminTestEngine()
+	registerProjectRoutes(r, db)
+
+	if w := serve(r, http.MethodPost, "/projects/project-2", `{"project-name": "team-2"}`); w.Code != http.StatusOK {
+		t.Fatalf("create status = %d: %s", w.Code, w.Body)
+	}
+	if w := serve(r, http.MethodPost, "/projects/project-2", `{"project-name": "team-2"}`); w.Code != http.StatusConflict {
+		t.Errorf("second create status = %d, want 409", w.Code)
+	}
+	if w := serve(r, http.MethodPost, "/projects/project-3", `{}`); w.Code != http.StatusBadRequest {
+		t.Errorf("create without project-name status = %d, want 400", w.Code)
+	}
+
+	if w := serve(r, http.MethodPut, "/projects/project-2", `{"project-name": "renamed"}`); w.Code != http.StatusOK {
+		t.Fatalf("update status = %d: %s", w.Code, w.Body)
+	}
+	if name := projectName(db, "project-2"); name != "renamed" {
+		t.Errorf("project name = %s, want renamed", name)
+	}
+
+	// リソースが残っているプロジェクトは削除できない
+	caas := models.CaaS{ProjectId: "project-2", Namespace: "tenant", Status: models.StatusReady}
+	if err := db.Create(&caas).Error; err != nil {
+		t.Fatal(err)
+	}
+	w := serve(r, http.MethodGet, "/projects/project-2/resources", "")
+	var resources api.Response[api.ProjectResources]
+	if err := json.Unmarshal(w.Body.Bytes(), &resources); err != nil {
+		t.Fatal(err)
+	}
+	if w.Code != http.StatusOK || resources.Message.Counts != (api.ResourceCounts{Caas: 1}) || len(resources.Message.Resources.Caas) != 1 {
+		t.Errorf("resources = %d %+v", w.Code, resources.Message)
+	}
+	if w := serve(r, http.MethodDelete, "/projects/project-2", ""); w.Code != http.StatusConflict {
+		t.Errorf("delete with resources status = %d, want 409: %s", w.Code, w.Body)
+	}
+
+	// 削除済みのリソースは含まない
+	if err := db.Delete(&caas).Error; err != nil {
+		t.Fatal(err)
+	}
+	if w := serve(r, http.MethodDelete, "/projects/project-2", ""); w.Code != http.StatusOK {
+		t.Fatalf("delete status = %d: %s", w.Code, w.Body)
+	}
+	if w := serve(r, http.MethodGet, "/projects/project-2", ""); w.Code != http.StatusNotFound {
+		t.Errorf("get after delete status = %d, want 404", w.Code)
+	}
+}
+
+// 管理者以外は自身のプロジェクトの参照のみ
+func TestProjectAccessByMember(t *testing.T) {
+	db := newTestDb(t)
+	for _, project := range []models.Projects{{ProjectId: testProjectId, ProjectName: "team-1"}, {ProjectId: "project-2", ProjectName: "team-2"}} {
+		if err := db.Create(&project).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	r := newTestEngine()
+	registerProjectRoutes(r, db)
+
+	for _, tc := range []struct {
+		method, path, body string
+		want               int
+	}{
+		{http.MethodGet, "/projects/" + testProjectId, "", http.StatusOK},
+		{http.MethodGet, "/projects/" + testProjectId + "/resources", "", http.StatusOK},
+		{http.MethodGet, "/projects/project-2", "", http.StatusForbidden},
+		{http.MethodGet, "/projects/project-2/resources", "", http.StatusForbidden},
+		{http.MethodPost, "/projects/project-3", `{"project-name": "team-3"}`, http.StatusForbidden},
+		{http.MethodPut, "/projects/" + testProjectId, `{"project-name": "renamed"}`, http.StatusForbidden},
+		{http.MethodDelete, "/projects/" + testProjectId, "", http.StatusForbidden},
+	} {
+		if w := serve(r, tc.method, tc.path, tc.body); w.Code != tc.want {
+			t.Errorf("%s %s status = %d, want %d", tc.method, tc.path, w.Code, tc.want)
+		}
+	}
+
+	w := serve(r, http.MethodGet, "/projects/", "")
+	var page api.Response[api.Page[api.Project]]
+	if err := json.Unmarshal(w.Body.Bytes(), &page); err != nil {
+		t.Fatal(err)
+	}
+	if page.Message.Total != 1 || len(page.Message.Items) != 1 || page.Message.Items[0].ProjectId != testProjectId {
+		t.Errorf("projects = %+v, want only %s", page.Message, testProjectId)
+	}
+}
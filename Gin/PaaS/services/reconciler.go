@@ -0,0 +1,325 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"ham3/config"
+	"ham3/models"
+	"ham3/utilities"
+
+	"github.com/gin-gonic/gin"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	rbaclisters "k8s.io/client-go/listers/rbac/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+
+	"gorm.io/gorm"
+)
+
+// CaaSのNamespaceと、その中でこのサービスが作成するリソースに付与しているラベル
+const caasLabelSelector = "app=caas"
+
+// DBに登録されているCaaS/LOGaaSとクラスタの状態を比較し、差分をDBのdriftに記録する
+// CaaSはInformerでNamespace/ResourceQuota/LimitRange/RoleBindingの変更を監視し、変更があったCaaSをすぐにチェックする
+// LOGaaSは定期的な全件チェックでHelmリリースの有無を確認する
+type Reconciler struct {
+	clients *Clients
+	db      *gorm.DB
+	cfg     config.ReconcileConfig
+
+	factory      informers.SharedInformerFactory
+	namespaces   corelisters.NamespaceLister
+	quotas       corelisters.ResourceQuotaLister
+	limitRanges  corelisters.LimitRangeLister
+	roleBindings rbaclisters.RoleBindingLister
+
+	// チェックするCaaSのNamespace名（同じNamespaceの変更はまとめて1回チェックする）
+	queue workqueue.RateLimitingInterface
+}
+
+func NewReconciler(clients *Clients, db *gorm.DB, cfg config.ReconcileConfig) *Reconciler {
+	factory := informers.NewSharedInformerFactoryWithOptions(clients.Kube, 0,
+		informers.WithTweakListOptions(func(options *metav1.ListOptions) {
+			options.LabelSelector = caasLabelSelector
+		}),
+	)
+
+	r := &Reconciler{
+		clients:      clients,
+		db:           db,
+		cfg:          cfg,
+		factory:      factory,
+		namespaces:   factory.Core().V1().Namespaces().Lister(),
+		quotas:       factory.Core().V1().ResourceQuotas().Lister(),
+		limitRanges:  factory.Core().V1().LimitRanges().Lister(),
+		roleBindings: factory.Rbac().V1().RoleBindings().Lister(),
+		queue:        workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter()),
+	}
+
+	handler := cache.ResourceEventHandlerFuncs{
+		AddFunc:    r.enqueueObject,
+		UpdateFunc: func(_, obj interface{}) { r.enqueueObject(obj) },
+		DeleteFunc: r.enqueueObject,
+	}
+	factory.Core().V1().Namespaces().Informer().AddEventHandler(handler)
+	factory.Core().V1().ResourceQuotas().Informer().AddEventHandler(handler)
+	factory.Core().V1().LimitRanges().Informer().AddEventHandler(handler)
+	factory.Rbac().V1().RoleBindings().Informer().AddEventHandler(handler)
+
+	return r
+}
+
+// 変更されたオブジェクトが属するCaaSのNamespaceをキューに追加する
+func (r *Reconciler) enqueueObject(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	object, err := meta.Accessor(obj)
+	if err != nil {
+		return
+	}
+	namespace := object.GetNamespace()
+	if namespace == "" {
+		// Namespace自体の変更
+		namespace = object.GetName()
+	}
+	r.queue.Add(namespace)
+}
+
+// ctxがキャンセルされるまでInformerと定期チェックを実行する
+func (r *Reconciler) Run(ctx context.Context) {
+	defer r.queue.ShutDown()
+
+	r.factory.Start(ctx.Done())
+	for informerType, synced := range r.factory.WaitForCacheSync(ctx.Done()) {
+		if !synced {
+			fmt.Printf("Reconciler: failed to sync informer cache for %v\n", informerType)
+			return
+		}
+	}
+	fmt.Printf("Reconciler started (interval: %s, repair: %t)\n", r.cfg.Interval, r.cfg.Repair)
+
+	go r.caasWorker(ctx)
+
+	ticker := time.NewTicker(r.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		r.reconcileAll(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// 全件チェック（CaaSはキューに追加し、LOGaaSはこの場でチェックする）
+func (r *Reconciler) reconcileAll(ctx context.Context) {
+	var namespaces []string
+	if err := r.db.Model(&models.CaaS{}).Where("status = ?", models.StatusReady).Pluck("namespace", &namespaces).Error; err != nil {
+		fmt.Printf("Reconciler: error getting caas: %v\n", err)
+	}
+	for _, namespace := range namespaces {
+		r.queue.Add(namespace)
+	}
+
+	var logaases []models.LOGaaS
+	if err := r.db.Where("status = ?", models.StatusReady).Find(&logaases).Error; err != nil {
+		fmt.Printf("Reconciler: error getting logaas: %v\n", err)
+	}
+	for i := range logaases {
+		if ctx.Err() != nil {
+			return
+		}
+		if err := r.reconcileLogaas(ctx, &logaases[i]); err != nil {
+			fmt.Printf("Reconciler: error checking logaas[%s]: %v\n", logaases[i].ClusterName, err)
+		}
+	}
+
+	r.refreshDriftMetrics()
+}
+
+func (r *Reconciler) caasWorker(ctx context.Context) {
+	for {
+		item, shutdown := r.queue.Get()
+		if shutdown {
+			return
+		}
+		namespace := item.(string)
+		if err := r.reconcileCaas(ctx, namespace); err != nil {
+			fmt.Printf("Reconciler: error checking caas[%s]: %v\n", namespace, err)
+			r.queue.AddRateLimited(namespace)
+		} else {
+			r.queue.Forget(namespace)
+		}
+		r.queue.Done(item)
+		r.refreshDriftMetrics()
+	}
+}
+
+// CaaSのNamespaceと、その中のResourceQuota/LimitRange/RoleBindingが存在するか確認する
+// 作成・更新・削除中のCaaSは処理中のジョブと競合するためチェックしない
+func (r *Reconciler) reconcileCaas(ctx context.Context, namespace string) error {
+	var caas models.CaaS
+	err := r.db.Where("namespace = ?", namespace).First(&caas).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if caas.Status != models.StatusReady {
+		return nil
+	}
+
+	var missing []string
+	if _, err := r.namespaces.Get(namespace); apierrors.IsNotFound(err) {
+		// Namespaceごと削除された場合は中のリソースも消えているため、再作成しない
+		missing = append(missing, "Namespace")
+		return r.recordDrift(fmt.Sprintf("caas[%s]", namespace), &caas, &caas.Drift, &caas.DriftCheckedAt, missing)
+	}
+
+	plan := caasPlanFromModel(&caas)
+	objects := []struct {
+		kind   string
+		exists func() bool
+		create func() error
+	}{
+		{
+			kind: "ResourceQuota",
+			exists: func() bool {
+				_, err := r.quotas.ResourceQuotas(namespace).Get(fmt.Sprintf("quota-%s", namespace))
+				return !apierrors.IsNotFound(err)
+			},
+			create: func() error {
+				_, err := r.clients.Kube.CoreV1().ResourceQuotas(namespace).Create(ctx, caasResourceQuota(namespace, plan), metav1.CreateOptions{})
+				return utilities.IgnoreAlreadyExists(err)
+			},
+		},
+		{
+			kind: "LimitRange",
+			exists: func() bool {
+				_, err := r.limitRanges.LimitRanges(namespace).Get(fmt.Sprintf("limit-%s", namespace))
+				return !apierrors.IsNotFound(err)
+			},
+			create: func() error {
+				_, err := r.clients.Kube.CoreV1().LimitRanges(namespace).Create(ctx, caasLimitRange(namespace, plan), metav1.CreateOptions{})
+				return utilities.IgnoreAlreadyExists(err)
+			},
+		},
+		{
+			kind: "RoleBinding",
+			exists: func() bool {
+				_, err := r.roleBindings.RoleBindings(namespace).Get(fmt.Sprintf("cass-user-role-%s", namespace))
+				return !apierrors.IsNotFound(err)
+			},
+			create: func() error {
+				_, err := r.clients.Kube.RbacV1().RoleBindings(namespace).Create(ctx, caasRoleBinding(namespace), metav1.CreateOptions{})
+				return utilities.IgnoreAlreadyExists(err)
+			},
+		},
+	}
+
+	for _, object := range objects {
+		if object.exists() {
+			continue
+		}
+		if r.cfg.Repair {
+			if err := object.create(); err != nil {
+				fmt.Printf("Reconciler: error re-creating %s in caas[%s]: %v\n", object.kind, namespace, err)
+			} else {
+				fmt.Printf("Reconciler: re-created %s in caas[%s]\n", object.kind, namespace)
+				CaasRepairCounter.WithLabelValues(object.kind).Inc()
+				continue
+			}
+		}
+		missing = append(missing, object.kind)
+	}
+
+	return r.recordDrift(fmt.Sprintf("caas[%s]", namespace), &caas, &caas.Drift, &caas.DriftCheckedAt, missing)
+}
+
+// LOGaaSのHelmリリース（OpenSearchのノードグループとDashboards）が存在するか確認する
+func (r *Reconciler) reconcileLogaas(ctx context.Context, logaas *models.LOGaaS) error {
+	requestData, err := logaasSpecFromModel(logaas, r.clients.Defaults)
+	if err != nil {
+		return fmt.Errorf("invalid spec: %v", err)
+	}
+
+	releaseNames := utilities.OpenSearchReleaseNames(logaas.ClusterName, requestData.ClusterType)
+	// Dashboardsのステータスがない（Dashboards対応以前に作成された）LOGaaSはDashboardsをチェックしない
+	if logaas.GuiStatus == models.StatusReady {
+		releaseNames = append(releaseNames, utilities.OpenSearchDashboardsReleaseName(logaas.ClusterName))
+	}
+
+	var missing []string
+	for _, releaseName := range releaseNames {
+		exists, err := helmReleaseExists(ctx, r.clients.Helm, releaseName)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			missing = append(missing, fmt.Sprintf("HelmRelease/%s", releaseName))
+		}
+	}
+
+	return r.recordDrift(fmt.Sprintf("logaas[%s]", logaas.ClusterName), logaas, &logaas.Drift, &logaas.DriftCheckedAt, missing)
+}
+
+// 差分をDBに記録する（差分が変わった場合のみログに出力する）
+func (r *Reconciler) recordDrift(name string, model interface{}, drift *string, checkedAt **time.Time, missing []string) error {
+	newDrift := ""
+	if len(missing) > 0 {
+		newDrift = fmt.Sprintf("missing: %s", strings.Join(missing, ", "))
+	}
+	if newDrift != *drift {
+		if newDrift == "" {
+			fmt.Printf("Reconciler: drift resolved for %s (was %q)\n", name, *drift)
+		} else {
+			fmt.Printf("Reconciler: drift detected for %s: %s\n", name, newDrift)
+		}
+	}
+
+	now := time.Now()
+	*drift = newDrift
+	*checkedAt = &now
+	return r.db.Model(model).Updates(map[string]interface{}{"drift": newDrift, "drift_checked_at": now}).Error
+}
+
+// 差分があるCaaS/LOGaaSの数をメトリクスに設定する
+func (r *Reconciler) refreshDriftMetrics() {
+	var count int64
+	if err := r.db.Model(&models.CaaS{}).Where("drift <> ''").Count(&count).Error; err == nil {
+		CaasDriftGauge.Set(float64(count))
+	}
+	if err := r.db.Model(&models.LOGaaS{}).Where("drift <> ''").Count(&count).Error; err == nil {
+		LOGaasDriftGauge.Set(float64(count))
+	}
+}
+
+// 一覧の?drifted=true/falseで、Reconcilerが検出した差分の有無で絞り込む
+// 値が不正な場合は400を返してfalseを返す
+func filterDrifted(c *gin.Context, query *gorm.DB) (*gorm.DB, bool) {
+	value := c.Query("drifted")
+	if value == "" {
+		return query, true
+	}
+	drifted, err := strconv.ParseBool(value)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, fmt.Sprintf("Invalid drifted %q", value))
+		return nil, false
+	}
+	if drifted {
+		return query.Where("drift <> ''"), true
+	}
+	return query.Where("drift IS NULL OR drift = ''"), true
+}
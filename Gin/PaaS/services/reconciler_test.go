@@ -0,0 +1,159 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"ham3/api"
+	"ham3/config"
+	"ham3/models"
+	"ham3/utilities"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Informerのキャッシュを同期したReconciler（定期チェックとワーカーは起動しない）
+func newSyncedReconciler(t *testing.T, clients *Clients, db *gorm.DB, cfg config.ReconcileConfig) *Reconciler {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	r := NewReconciler(clients, db, cfg)
+	r.factory.Start(ctx.Done())
+	for informerType, synced := range r.factory.WaitForCacheSync(ctx.Done()) {
+		if !synced {
+			t.Fatalf("failed to sync informer cache for %v", informerType)
+		}
+	}
+	return r
+}
+
+func caasDrift(t *testing.T, db *gorm.DB, namespace string) string {
+	t.Helper()
+	var caas models.CaaS
+	if err := db.Where("namespace = ?", namespace).First(&caas).Error; err != nil {
+		t.Fatal(err)
+	}
+	if caas.DriftCheckedAt == nil {
+		t.Errorf("drift_checked_at of caas[%s] is not recorded", namespace)
+	}
+	return caas.Drift
+}
+
+func TestReconcileCaasDrift(t *testing.T) {
+	db := newTestDb(t)
+	jm := newTestJobManager(t, db)
+	clients := newTestClients()
+	ctx := context.Background()
+
+	r := newTestEngine()
+	r.POST("/caas/:caas_id", func(c *gin.Context) { CreateCaas(c.Request.Context(), c, clients, db, jm) })
+	if job := waitAcceptedJob(t, jm, serve(r, http.MethodPost, "/caas/tenant", "")); job.Status != models.JobSucceeded {
+		t.Fatalf("create job = %s (%s)", job.Status, job.Error)
+	}
+
+	// 差分がない場合は空のdriftを記録する
+	if err := newSyncedReconciler(t, clients, db, config.ReconcileConfig{}).reconcileCaas(ctx, "tenant"); err != nil {
+		t.Fatal(err)
+	}
+	if drift := caasDrift(t, db, "tenant"); drift != "" {
+		t.Errorf("drift = %q, want none", drift)
+	}
+
+	// CaaSのLimitRangeとRoleBindingを手動で削除した
+	if err := clients.Kube.CoreV1().LimitRanges("tenant").Delete(ctx, "limit-tenant", metav1.DeleteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := clients.Kube.RbacV1().RoleBindings("tenant").Delete(ctx, "cass-user-role-tenant", metav1.DeleteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := newSyncedReconciler(t, clients, db, config.ReconcileConfig{}).reconcileCaas(ctx, "tenant"); err != nil {
+		t.Fatal(err)
+	}
+	if drift, want := caasDrift(t, db, "tenant"), "missing: LimitRange, RoleBinding"; drift != want {
+		t.Errorf("drift = %q, want %q", drift, want)
+	}
+
+	// repairの場合は再作成し、差分を解消する
+	if err := newSyncedReconciler(t, clients, db, config.ReconcileConfig{Repair: true}).reconcileCaas(ctx, "tenant"); err != nil {
+		t.Fatal(err)
+	}
+	if drift := caasDrift(t, db, "tenant"); drift != "" {
+		t.Errorf("drift after repair = %q, want none", drift)
+	}
+	if _, err := clients.Kube.CoreV1().LimitRanges("tenant").Get(ctx, "limit-tenant", metav1.GetOptions{}); err != nil {
+		t.Errorf("limit range after repair: %v", err)
+	}
+	if _, err := clients.Kube.RbacV1().RoleBindings("tenant").Get(ctx, "cass-user-role-tenant", metav1.GetOptions{}); err != nil {
+		t.Errorf("role binding after repair: %v", err)
+	}
+
+	// Namespaceごと削除された場合は再作成しない
+	if err := clients.Kube.CoreV1().Namespaces().Delete(ctx, "tenant", metav1.DeleteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := newSyncedReconciler(t, clients, db, config.ReconcileConfig{Repair: true}).reconcileCaas(ctx, "tenant"); err != nil {
+		t.Fatal(err)
+	}
+	if drift, want := caasDrift(t, db, "tenant"), "missing: Namespace"; drift != want {
+		t.Errorf("drift = %q, want %q", drift, want)
+	}
+}
+
+func TestReconcileLogaasDrift(t *testing.T) {
+	db := newTestDb(t)
+	clients := newTestClients()
+	ctx := context.Background()
+	createReadyLogaas(t, db, "logs")
+
+	releaseNames := utilities.OpenSearchReleaseNames("logs", "standard")
+	for _, name := range releaseNames {
+		if _, err := clients.Helm.Install(ctx, utilities.OpenSearchNamespace, name, utilities.OpenSearchChart, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	reconciler := NewReconciler(clients, db, config.ReconcileConfig{})
+	reconcile := func() string {
+		t.Helper()
+		var logaas models.LOGaaS
+		if err := db.Where("cluster_name = ?", "logs").First(&logaas).Error; err != nil {
+			t.Fatal(err)
+		}
+		if err := reconciler.reconcileLogaas(ctx, &logaas); err != nil {
+			t.Fatal(err)
+		}
+		if err := db.First(&logaas, logaas.ID).Error; err != nil {
+			t.Fatal(err)
+		}
+		return logaas.Drift
+	}
+
+	if drift := reconcile(); drift != "" {
+		t.Errorf("drift = %q, want none", drift)
+	}
+
+	// Helmリリースを手動で削除した
+	if err := clients.Helm.Uninstall(ctx, utilities.OpenSearchNamespace, releaseNames[0]); err != nil {
+		t.Fatal(err)
+	}
+	if drift, want := reconcile(), "missing: HelmRelease/"+releaseNames[0]; drift != want {
+		t.Errorf("drift = %q, want %q", drift, want)
+	}
+
+	// 差分があるLOGaaSは一覧の?drifted=trueで絞り込める
+	r := newTestEngine()
+	r.GET("/logaas/", func(c *gin.Context) { GetLogaases(c.Request.Context(), c, clients, db) })
+	for query, want := range map[string]int64{"true": 1, "false": 0} {
+		w := serve(r, http.MethodGet, "/logaas/?drifted="+query, "")
+		var page api.Response[api.Page[api.Logaas]]
+		if err := json.Unmarshal(w.Body.Bytes(), &page); err != nil {
+			t.Fatal(err)
+		}
+		if w.Code != http.StatusOK || page.Message.Total != want {
+			t.Errorf("drifted=%s: status %d, total %d, want %d", query, w.Code, page.Message.Total, want)
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"ham3/api"
+	"ham3/config"
+	"ham3/models"
+
+	"github.com/gin-gonic/gin"
+	"helm.sh/helm/v3/pkg/release"
+)
+
+// エラーのレスポンス（codeはステータスコードから決める）
+func respondError(c *gin.Context, status int, message string) {
+	c.JSON(status, api.NewError(status, message))
+}
+
+// リクエストのJSONを読み込めない場合の400
+func respondBindError(c *gin.Context, err error) {
+	c.JSON(http.StatusBadRequest, api.ErrorResponse{
+		Status:  api.StatusError,
+		Code:    api.CodeInvalidRequest,
+		Message: err.Error(),
+	})
+}
+
+// バリデーションエラーを400で返す（errorsはフィールドごとのエラーの一覧）
+func respondFieldErrors(c *gin.Context, fieldErrors []api.FieldError) {
+	c.JSON(http.StatusBadRequest, api.ErrorResponse{
+		Status:  api.StatusError,
+		Code:    api.CodeValidationFailed,
+		Message: "Invalid parameters",
+		Errors:  fieldErrors,
+	})
+}
+
+// DBのモデルをAPIのレスポンスの型に変換する
+func convertAll[M any, T any](items []M, convert func(*M) T) []T {
+	converted := make([]T, 0, len(items))
+	for i := range items {
+		converted = append(converted, convert(&items[i]))
+	}
+	return converted
+}
+
+// Helmリリースの状態（取得に失敗した場合はerrorのみ）
+func helmStatusResponse(rel *release.Release, err error) api.HelmReleaseStatus {
+	if err != nil {
+		return api.HelmReleaseStatus{Error: err.Error()}
+	}
+	lastDeployed := rel.Info.LastDeployed.Time
+	return api.HelmReleaseStatus{
+		Status:       rel.Info.Status.String(),
+		Revision:     rel.Version,
+		ChartVersion: rel.Chart.Metadata.Version,
+		AppVersion:   rel.Chart.Metadata.AppVersion,
+		LastDeployed: &lastDeployed,
+	}
+}
+
+func caasResponse(caas *models.CaaS) api.Caas {
+	return api.Caas{
+		ID:             caas.ID,
+		CreatedAt:      caas.CreatedAt,
+		UpdatedAt:      caas.UpdatedAt,
+		ProjectId:      caas.ProjectId,
+		Namespace:      caas.Namespace,
+		Status:         caas.Status,
+		Plan:           caas.Plan,
+		RequestsCpu:    caas.RequestsCpu,
+		RequestsMemory: caas.RequestsMemory,
+		Pods:           caas.Pods,
+		LimitCpu:       caas.LimitCpu,
+		LimitMemory:    caas.LimitMemory,
+		Drift:          caas.Drift,
+		DriftCheckedAt: caas.DriftCheckedAt,
+	}
+}
+
+func logaasResponse(logaas *models.LOGaaS) api.Logaas {
+	return api.Logaas{
+		ID:             logaas.ID,
+		CreatedAt:      logaas.CreatedAt,
+		UpdatedAt:      logaas.UpdatedAt,
+		ProjectId:      logaas.ProjectId,
+		ClusterName:    logaas.ClusterName,
+		ClusterType:    logaas.ClusterType,
+		GuiEndpoint:    logaas.GuiEndpoint,
+		ApiEndpoint:    logaas.ApiEndpoint,
+		Status:         logaas.Status,
+		GuiStatus:      logaas.GuiStatus,
+		Spec:           logaas.Spec,
+		Drift:          logaas.Drift,
+		DriftCheckedAt: logaas.DriftCheckedAt,
+	}
+}
+
+func aapaasResponse(aapaas *models.AAPaaS) api.Aapaas {
+	return api.Aapaas{
+		ID:              aapaas.ID,
+		CreatedAt:       aapaas.CreatedAt,
+		UpdatedAt:       aapaas.UpdatedAt,
+		ProjectId:       aapaas.ProjectId,
+		Name:            aapaas.Name,
+		Namespace:       aapaas.Namespace,
+		Endpoint:        aapaas.Endpoint,
+		AdminUser:       aapaas.AdminUser,
+		AdminSecret:     aapaas.AdminSecret,
+		OperatorVersion: aapaas.OperatorVersion,
+		Status:          aapaas.Status,
+	}
+}
+
+func jobResponse(job *models.Job) api.Job {
+	return api.Job{
+		ID:           job.ID,
+		ProjectId:    job.ProjectId,
+		ResourceType: job.ResourceType,
+		ResourceId:   job.ResourceId,
+		Action:       job.Action,
+		Status:       job.Status,
+		Error:        job.Error,
+		CreatedAt:    job.CreatedAt,
+		UpdatedAt:    job.UpdatedAt,
+		StartedAt:    job.StartedAt,
+		FinishedAt:   job.FinishedAt,
+		Steps: convertAll(job.Steps, func(step *models.JobStep) api.JobStep {
+			return api.JobStep{
+				Name:       step.Name,
+				Status:     step.Status,
+				Error:      step.Error,
+				StartedAt:  step.StartedAt,
+				FinishedAt: step.FinishedAt,
+			}
+		}),
+	}
+}
+
+func projectResponse(project *models.Projects) api.Project {
+	return api.Project{
+		ProjectId:   project.ProjectId,
+		ProjectName: project.ProjectName,
+		CreatedAt:   project.CreatedAt,
+		UpdatedAt:   project.UpdatedAt,
+	}
+}
+
+func flavorResponse(flavor *config.Flavor) api.Flavor {
+	return api.Flavor{
+		Name:     flavor.Name,
+		Requests: api.FlavorResources{Cpu: flavor.Requests.Cpu, Memory: flavor.Requests.Memory},
+		Limits:   api.FlavorResources{Cpu: flavor.Limits.Cpu, Memory: flavor.Limits.Memory},
+		JvmHeap:  flavor.JvmHeap,
+		JvmPerm:  flavor.JvmPerm,
+	}
+}
+
+func auditLogResponse(log *models.AuditLog) api.AuditLog {
+	response := api.AuditLog{
+		ID:           log.ID,
+		CreatedAt:    log.CreatedAt,
+		UserId:       log.UserId,
+		UserName:     log.UserName,
+		ProjectId:    log.ProjectId,
+		ProjectName:  log.ProjectName,
+		IsAdmin:      log.IsAdmin,
+		ClientIp:     log.ClientIp,
+		Method:       log.Method,
+		Route:        log.Route,
+		Path:         log.Path,
+		Action:       log.Action,
+		ResourceType: log.ResourceType,
+		ResourceId:   log.ResourceId,
+		Payload:      log.Payload,
+		StatusCode:   log.StatusCode,
+		Outcome:      log.Outcome,
+		Error:        log.Error,
+		ErrorCode:    api.ErrorCode(log.ErrorCode),
+		JobId:        log.JobId,
+		TraceId:      log.TraceId,
+	}
+	if log.ErrorFields != "" {
+		if err := json.Unmarshal([]byte(log.ErrorFields), &response.Errors); err != nil {
+			fmt.Printf("Error parsing error fields of audit log[%d]: %v\n", log.ID, err)
+		}
+	}
+	return response
+}
@@ -0,0 +1,56 @@
+package telemetry
+
+import (
+	"net/http"
+	"strings"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/trace"
+	"k8s.io/client-go/rest"
+)
+
+// KubernetesのAPIの呼び出しをスパンにする（ctxにスパンがある呼び出しのみ、親のスパンの子になる）
+// InformerのList/Watchなど、リクエストと関係のない呼び出しはスパンにしない
+func InstrumentKubeConfig(config *rest.Config) *rest.Config {
+	config = rest.CopyConfig(config)
+	config.Wrap(func(rt http.RoundTripper) http.RoundTripper {
+		return otelhttp.NewTransport(rt,
+			otelhttp.WithSpanNameFormatter(kubeSpanName),
+			otelhttp.WithFilter(func(r *http.Request) bool {
+				return trace.SpanContextFromContext(r.Context()).IsValid()
+			}),
+		)
+	})
+	return config
+}
+
+// "kubernetes <メソッド> <リソース>[/<サブリソース>]"（名前やNamespaceを含めない）
+// 例: GET /api/v1/namespaces/caas-1/pods/web-0/log -> kubernetes GET pods/log
+func kubeSpanName(_ string, r *http.Request) string {
+	return "kubernetes " + r.Method + " " + kubeResource(r.URL.Path)
+}
+
+func kubeResource(path string) string {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	switch {
+	case len(segments) >= 2 && segments[0] == "api":
+		// /api/<バージョン>/...
+		segments = segments[2:]
+	case len(segments) >= 3 && segments[0] == "apis":
+		// /apis/<グループ>/<バージョン>/...
+		segments = segments[3:]
+	default:
+		return "other"
+	}
+	if len(segments) >= 3 && segments[0] == "namespaces" {
+		segments = segments[2:]
+	}
+	switch len(segments) {
+	case 0:
+		return "discovery"
+	case 1, 2:
+		return segments[0]
+	default:
+		return segments[0] + "/" + segments[2]
+	}
+}
@@ -0,0 +1,49 @@
+package telemetry
+
+import (
+	"context"
+	"io"
+	"log/slog"
+
+	"ham3/config"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+var logLevels = map[string]slog.Level{
+	"debug": slog.LevelDebug,
+	"info":  slog.LevelInfo,
+	"warn":  slog.LevelWarn,
+	"error": slog.LevelError,
+}
+
+// ctxにスパンがある場合、ログにtrace_id/span_idを付けるハンドラー
+type logHandler struct {
+	slog.Handler
+}
+
+func newLogHandler(w io.Writer, cfg config.TelemetryConfig) slog.Handler {
+	options := &slog.HandlerOptions{Level: logLevels[cfg.LogLevel]}
+	if cfg.LogFormat == "json" {
+		return logHandler{slog.NewJSONHandler(w, options)}
+	}
+	return logHandler{slog.NewTextHandler(w, options)}
+}
+
+func (h logHandler) Handle(ctx context.Context, record slog.Record) error {
+	if spanContext := trace.SpanContextFromContext(ctx); spanContext.IsValid() {
+		record.AddAttrs(
+			slog.String("trace_id", spanContext.TraceID().String()),
+			slog.String("span_id", spanContext.SpanID().String()),
+		)
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h logHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return logHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h logHandler) WithGroup(name string) slog.Handler {
+	return logHandler{h.Handler.WithGroup(name)}
+}
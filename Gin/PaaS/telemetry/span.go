@@ -0,0 +1,43 @@
+package telemetry
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// HAM3のスパンを作成するTracerの名前
+const TracerName = "ham3"
+
+// ctxの子のスパンを開始する
+// 返したctxをKubernetes/Helmのクライアントに渡すと、クライアントの呼び出しがこのスパンの子になる
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(TracerName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// エラーをスパンに記録し、ステータスをErrorにする（errがnilの場合は何もしない）
+func RecordError(span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
+
+// エラーを記録してスパンを終了する
+// 例: defer func() { telemetry.End(span, err) }()
+func End(span trace.Span, err error) {
+	RecordError(span, err)
+	span.End()
+}
+
+// fnをnameのスパンの中で実行し、返したエラーを記録する
+func WithSpan(ctx context.Context, name string, fn func(ctx context.Context) error, attrs ...attribute.KeyValue) error {
+	ctx, span := Start(ctx, name, attrs...)
+	err := fn(ctx)
+	End(span, err)
+	return err
+}
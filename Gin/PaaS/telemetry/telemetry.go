@@ -0,0 +1,82 @@
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"ham3/config"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	otelprometheus "go.opentelemetry.io/otel/exporters/prometheus"
+	"go.opentelemetry.io/otel/propagation"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+)
+
+// 起動時に設定したトレース・メトリクスのプロバイダー
+type Provider struct {
+	tracerProvider *sdktrace.TracerProvider
+	meterProvider  *sdkmetric.MeterProvider
+}
+
+// 設定に従ってトレース・メトリクス・ログを設定し、グローバルのプロバイダーとして登録する
+//   - トレース: OTLP/HTTPに送信（送信先が空の場合は記録のみ）、親のないトレースはsample-ratioの割合で記録
+//   - メトリクス: OpenTelemetryの計装（Kubernetesクライアントなど）のメトリクスをPrometheusの/metricsに公開
+//   - ログ: slogのデフォルトのロガー（logパッケージの出力も含む）にトレースID/スパンIDを付ける
+func Setup(ctx context.Context, cfg config.TelemetryConfig) (*Provider, error) {
+	res := resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceNameKey.String(cfg.ServiceName),
+	)
+
+	tpOptions := []sdktrace.TracerProviderOption{
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+	}
+	if cfg.OtlpEndpoint != "" {
+		exporterOptions := []otlptracehttp.Option{otlptracehttp.WithEndpoint(cfg.OtlpEndpoint)}
+		if cfg.OtlpInsecure {
+			exporterOptions = append(exporterOptions, otlptracehttp.WithInsecure()) // TLSを無効にする場合に指定
+		}
+		exporter, err := otlptracehttp.New(ctx, exporterOptions...)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to create trace exporter: %v", err)
+		}
+		tpOptions = append(tpOptions, sdktrace.WithBatcher(exporter))
+	}
+
+	metricExporter, err := otelprometheus.New(otelprometheus.WithRegisterer(prometheus.DefaultRegisterer))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create metric exporter: %v", err)
+	}
+
+	p := &Provider{
+		tracerProvider: sdktrace.NewTracerProvider(tpOptions...),
+		meterProvider:  sdkmetric.NewMeterProvider(sdkmetric.WithResource(res), sdkmetric.WithReader(metricExporter)),
+	}
+	otel.SetTracerProvider(p.tracerProvider)
+	otel.SetMeterProvider(p.meterProvider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	slog.SetDefault(slog.New(newLogHandler(os.Stdout, cfg)))
+	return p, nil
+}
+
+// 未送信のトレースを送信してプロバイダーを停止する
+func (p *Provider) Shutdown(ctx context.Context) error {
+	var errs []error
+	if err := p.tracerProvider.Shutdown(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("Failed to shutdown tracer provider: %v", err))
+	}
+	if err := p.meterProvider.Shutdown(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("Failed to shutdown meter provider: %v", err))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,67 @@
+package utilities
+
+import (
+	"fmt"
+	"ham3/api"
+	"ham3/config"
+	"sort"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// リクエストからCaaSのサイズを決定する
+// baseは変更前のサイズ（新規作成の場合はnil）で、Planが指定されていない場合のベースになる
+func ResolveCaasPlan(requestData api.CaasRequestData, base *config.CaasPlan) (config.CaasPlan, error) {
+	var plan config.CaasPlan
+
+	if requestData.Plan != "" {
+		p, ok := config.CaasPlans[requestData.Plan]
+		if !ok {
+			return plan, fmt.Errorf("plan must be one of %v", caasPlanNames())
+		}
+		plan = p
+	} else if base != nil {
+		plan = *base
+	} else {
+		plan = config.CaasPlans[config.CaasDefaultPlan]
+	}
+
+	// 個別に指定された値でPlanの値を上書き
+	overrides := []struct {
+		name  string
+		value string
+		dst   *string
+		min   string
+		max   string
+	}{
+		{"requests-cpu", requestData.RequestsCpu, &plan.RequestsCpu, config.CaasPlanMin.RequestsCpu, config.CaasPlanMax.RequestsCpu},
+		{"requests-memory", requestData.RequestsMemory, &plan.RequestsMemory, config.CaasPlanMin.RequestsMemory, config.CaasPlanMax.RequestsMemory},
+		{"pods", requestData.Pods, &plan.Pods, config.CaasPlanMin.Pods, config.CaasPlanMax.Pods},
+		{"limit-cpu", requestData.LimitCpu, &plan.LimitCpu, config.CaasPlanMin.LimitCpu, config.CaasPlanMax.LimitCpu},
+		{"limit-memory", requestData.LimitMemory, &plan.LimitMemory, config.CaasPlanMin.LimitMemory, config.CaasPlanMax.LimitMemory},
+	}
+	for _, o := range overrides {
+		if o.value == "" {
+			continue
+		}
+		q, err := resource.ParseQuantity(o.value)
+		if err != nil {
+			return plan, fmt.Errorf("%s is not a valid quantity: %s", o.name, o.value)
+		}
+		if q.Cmp(resource.MustParse(o.min)) < 0 || q.Cmp(resource.MustParse(o.max)) > 0 {
+			return plan, fmt.Errorf("%s must be between %s and %s", o.name, o.min, o.max)
+		}
+		*o.dst = o.value
+	}
+
+	return plan, nil
+}
+
+func caasPlanNames() []string {
+	var names []string
+	for name := range config.CaasPlans {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,163 @@
+package utilities
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"ham3/config"
+
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/cli"
+	"helm.sh/helm/v3/pkg/registry"
+)
+
+// バージョンを指定していない（最新の）チャートをキャッシュする時間
+// バージョンを指定したチャートはプロセスが終了するまでキャッシュする
+const unpinnedChartCacheTTL = time.Hour
+
+type cachedChart struct {
+	chart    *chart.Chart
+	loadedAt time.Time
+}
+
+// 取得済みのチャート（取得元、チャート名、バージョンごと）
+var chartCache = struct {
+	sync.Mutex
+	charts map[string]cachedChart
+}{charts: map[string]cachedChart{}}
+
+// "<リポジトリ名>/<チャート名>"のチャート名
+func chartBaseName(name string) string {
+	return name[strings.LastIndex(name, "/")+1:]
+}
+
+// sourcesのチャート名ごとの取得元からチャートを取得する（取得元の指定がないチャートは公開リポジトリ）
+// 取得したチャートはメモリにキャッシュし、インストールのたびにチャートが変更されないよう、キャッシュのコピーを返す
+func LoadChart(sources map[string]config.ChartSource, helmChart HelmChart) (*chart.Chart, error) {
+	source, ok := sources[chartBaseName(helmChart.Name)]
+	if !ok {
+		source = config.ChartSource{Type: config.ChartSourceRepo}
+	}
+	key := strings.Join([]string{source.Type, source.Location, helmChart.Name, helmChart.Version}, "|")
+
+	chartCache.Lock()
+	cached, ok := chartCache.charts[key]
+	chartCache.Unlock()
+	if ok && (helmChart.Version != "" || time.Since(cached.loadedAt) < unpinnedChartCacheTTL) {
+		return copyChart(cached.chart), nil
+	}
+
+	loaded, err := loadChartFromSource(helmChart, source)
+	if err != nil {
+		location := source.Location
+		if location == "" {
+			location = helmChart.Repo.URL
+		}
+		return nil, fmt.Errorf("Failed to load chart %s (%s %s): %v", helmChart.Name, source.Type, location, err)
+	}
+	// ディレクトリ/アーカイブのチャートが指定したバージョンと異なる場合はインストールしない
+	if helmChart.Version != "" && loaded.Metadata.Version != helmChart.Version {
+		return nil, fmt.Errorf("Chart %s has version %s, but %s is required", helmChart.Name, loaded.Metadata.Version, helmChart.Version)
+	}
+
+	chartCache.Lock()
+	chartCache.charts[key] = cachedChart{chart: loaded, loadedAt: time.Now()}
+	chartCache.Unlock()
+	return copyChart(loaded), nil
+}
+
+func loadChartFromSource(helmChart HelmChart, source config.ChartSource) (*chart.Chart, error) {
+	switch source.Type {
+	case config.ChartSourceDir:
+		return loader.LoadDir(expandChartPath(source.Location, helmChart.Version))
+	case config.ChartSourceArchive:
+		return loader.LoadFile(expandChartPath(source.Location, helmChart.Version))
+	case config.ChartSourceOci, config.ChartSourceRepo:
+		settings := cli.New()
+		registryClient, err := registry.NewClient(registry.ClientOptCredentialsFile(settings.RegistryConfig))
+		if err != nil {
+			return nil, fmt.Errorf("Failed to create registry client: %v", err)
+		}
+
+		// ChartPathOptionsを使うためにInstallを作成する（リポジトリはrepositories.yamlに登録せずURLで指定する）
+		pathOptions := action.NewInstall(&action.Configuration{})
+		pathOptions.SetRegistryClient(registryClient)
+		pathOptions.Version = helmChart.Version
+
+		chartRef := source.Location
+		if source.Type == config.ChartSourceRepo {
+			pathOptions.RepoURL = helmChart.Repo.URL
+			if source.Location != "" {
+				pathOptions.RepoURL = source.Location
+			}
+			chartRef = chartBaseName(helmChart.Name)
+		}
+
+		chartPath, err := pathOptions.ChartPathOptions.LocateChart(chartRef, settings)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to locate chart: %v", err)
+		}
+		return loader.Load(chartPath)
+	}
+	return nil, fmt.Errorf("Unsupported chart source %q", source.Type)
+}
+
+func expandChartPath(path string, version string) string {
+	return strings.ReplaceAll(path, config.ChartVersionPlaceholder, version)
+}
+
+// キャッシュのチャートを共有しないよう、Installのたびに変更される部分を複製する
+// Metadata（依存チャートの有効/無効）、依存チャート、values（ネストしたmapとリストを含む）は複製し、
+// テンプレートとファイルは内容を変更しないためスライスのみ複製する
+func copyChart(c *chart.Chart) *chart.Chart {
+	copied := *c
+	copied.Values = copyValues(c.Values)
+	copied.Templates = append([]*chart.File(nil), c.Templates...)
+	copied.Files = append([]*chart.File(nil), c.Files...)
+	if c.Metadata != nil {
+		metadata := *c.Metadata
+		metadata.Dependencies = make([]*chart.Dependency, 0, len(c.Metadata.Dependencies))
+		for _, dependency := range c.Metadata.Dependencies {
+			d := *dependency
+			metadata.Dependencies = append(metadata.Dependencies, &d)
+		}
+		copied.Metadata = &metadata
+	}
+	dependencies := make([]*chart.Chart, 0, len(c.Dependencies()))
+	for _, dependency := range c.Dependencies() {
+		dependencies = append(dependencies, copyChart(dependency))
+	}
+	copied.SetDependencies(dependencies...)
+	return &copied
+}
+
+// valuesのmapとリストを再帰的に複製する（文字列や数値などの値はそのまま使う）
+func copyValues(values map[string]interface{}) map[string]interface{} {
+	if values == nil {
+		return nil
+	}
+	copied := make(map[string]interface{}, len(values))
+	for key, value := range values {
+		copied[key] = copyValue(value)
+	}
+	return copied
+}
+
+func copyValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return copyValues(v)
+	case []interface{}:
+		list := make([]interface{}, len(v))
+		for i, item := range v {
+			list[i] = copyValue(item)
+		}
+		return list
+	default:
+		return v
+	}
+}
@@ -0,0 +1,53 @@
+package utilities
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"ham3/config"
+)
+
+// キャッシュから取得したチャートのvaluesを変更しても、キャッシュと次に取得したチャートは変わらない
+func TestLoadChartDoesNotShareCachedValues(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"Chart.yaml":               "apiVersion: v2\nname: opensearch\nversion: 2.17.0\n",
+		"values.yaml":              "image:\n  tag: \"2.11.1\"\nextraEnvs:\n  - name: A\n    value: \"1\"\n",
+		"templates/configmap.yaml": "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: test\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sources := map[string]config.ChartSource{"opensearch": {Type: config.ChartSourceDir, Location: dir}}
+
+	first, err := LoadChart(sources, OpenSearchChart)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := copyValues(first.Values)
+
+	// Helmがvaluesを合成する際と同じく、ネストしたmapとリストを変更する
+	first.Values["image"].(map[string]interface{})["tag"] = "changed"
+	first.Values["extraEnvs"].([]interface{})[0].(map[string]interface{})["value"] = "changed"
+	first.Values["replicas"] = 5
+	first.Templates = append(first.Templates, first.Templates[0])
+
+	second, err := LoadChart(sources, OpenSearchChart)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(second.Values, want) {
+		t.Errorf("values of the second load = %v, want %v", second.Values, want)
+	}
+	if len(second.Templates) != 1 {
+		t.Errorf("templates of the second load = %d, want 1", len(second.Templates))
+	}
+}
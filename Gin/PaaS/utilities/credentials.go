@@ -0,0 +1,237 @@
+package utilities
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strings"
+
+	"ham3/config"
+
+	"golang.org/x/crypto/bcrypt"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// LOGaaSのリソース（PV/PVC、Secret）に付与するLOGaaS名のラベル
+const LogaasLabel = "ham3/logaas"
+
+// OpenSearchのadminユーザー名（HAM3がスナップショットなどの操作に使う）
+const LogaasAdminUser = "admin"
+
+// OpenSearch DashboardsがOpenSearchへの接続に使うユーザー名（kibana_serverロールを付与する）
+const LogaasDashboardsUser = "kibanaserver"
+
+// 生成するパスワードの長さと文字種
+const passwordLength = 24
+
+const passwordChars = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+// opensearch-securityのデフォルトと同じbcryptのコスト
+const passwordHashCost = 12
+
+// LOGaaSの認証情報を保存するSecretのキー
+const (
+	credentialsAdminPasswordKey     = "admin-password"
+	credentialsAdminPasswordHashKey = "admin-password-hash"
+	credentialsUserKey              = "user"
+	credentialsPasswordKey          = "password"
+	credentialsPasswordHashKey      = "password-hash"
+	// 変更中のadminユーザーのパスワード（OpenSearchへの反映後にadmin-passwordに移す）
+	credentialsPendingAdminPasswordKey     = "pending-admin-password"
+	credentialsPendingAdminPasswordHashKey = "pending-admin-password-hash"
+	credentialsDashboardsPasswordKey       = "dashboards-password"
+	credentialsDashboardsPasswordHashKey   = "dashboards-password-hash"
+)
+
+// LOGaaSの認証情報（OpenSearchのinternal_users.ymlとSecretに保存する）
+// パスワードはログに出力しないこと
+type LogaasCredentials struct {
+	AdminPassword     string
+	AdminPasswordHash string
+	// テナントのユーザー
+	User         string
+	Password     string
+	PasswordHash string
+	// 変更中のadminユーザーのパスワード（OpenSearchに反映済みかは不明のため、adminの認証に失敗した場合に使う）
+	PendingAdminPassword     string
+	PendingAdminPasswordHash string
+	// OpenSearch Dashboardsのユーザー
+	DashboardsPassword     string
+	DashboardsPasswordHash string
+}
+
+// LOGaaSの認証情報を保存するSecret名
+func LogaasCredentialsSecretName(logaas_id string) string {
+	return fmt.Sprintf("%s-credentials", logaas_id)
+}
+
+// OpenSearch Dashboardsのユーザーの認証情報を保存するSecret名（チャートのopensearchAccount.secretに指定する）
+func LogaasDashboardsSecretName(logaas_id string) string {
+	return fmt.Sprintf("%s-dashboards-account", logaas_id)
+}
+
+var tenantUserInvalidChars = regexp.MustCompile(`[^a-z0-9_-]+`)
+
+// プロジェクト名からテナントのユーザー名を決める（使えない文字は'-'に置き換える）
+func LogaasTenantUser(projectName string) string {
+	user := strings.Trim(tenantUserInvalidChars.ReplaceAllString(strings.ToLower(projectName), "-"), "-")
+	if user == "" || user == LogaasAdminUser {
+		return "tenant"
+	}
+	return user
+}
+
+// adminユーザー、テナントのユーザーとDashboardsのユーザーのパスワードを生成する
+func GenerateLogaasCredentials(user string) (LogaasCredentials, error) {
+	credentials := LogaasCredentials{User: user}
+	var err error
+	if credentials.AdminPassword, credentials.AdminPasswordHash, err = GeneratePassword(); err != nil {
+		return credentials, err
+	}
+	if credentials.Password, credentials.PasswordHash, err = GeneratePassword(); err != nil {
+		return credentials, err
+	}
+	if credentials.DashboardsPassword, credentials.DashboardsPasswordHash, err = GeneratePassword(); err != nil {
+		return credentials, err
+	}
+	return credentials, nil
+}
+
+// 認証情報を発行する前に作成したLOGaaSの認証情報（デモ設定のadminユーザーとkibanaserverユーザーのみ）
+func LegacyLogaasCredentials() LogaasCredentials {
+	return LogaasCredentials{
+		AdminPassword:          "admin",
+		AdminPasswordHash:      config.DemoAdminPasswordHash,
+		DashboardsPassword:     "kibanaserver",
+		DashboardsPasswordHash: config.DemoDashboardsPasswordHash,
+	}
+}
+
+// ランダムなパスワードとそのbcryptのハッシュを生成する
+func GeneratePassword() (string, string, error) {
+	password := make([]byte, passwordLength)
+	for i := range password {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(passwordChars))))
+		if err != nil {
+			return "", "", err
+		}
+		password[i] = passwordChars[n.Int64()]
+	}
+	hash, err := bcrypt.GenerateFromPassword(password, passwordHashCost)
+	if err != nil {
+		return "", "", err
+	}
+	return string(password), string(hash), nil
+}
+
+// Secretから認証情報を取得する（Secretがない場合はNotFoundのエラーを返す）
+func GetLogaasCredentials(ctx context.Context, clientset kubernetes.Interface, logaas_id string) (LogaasCredentials, error) {
+	secret, err := clientset.CoreV1().Secrets(OpenSearchNamespace).Get(ctx, LogaasCredentialsSecretName(logaas_id), metav1.GetOptions{})
+	if err != nil {
+		return LogaasCredentials{}, err
+	}
+	return LogaasCredentials{
+		AdminPassword:     string(secret.Data[credentialsAdminPasswordKey]),
+		AdminPasswordHash: string(secret.Data[credentialsAdminPasswordHashKey]),
+		User:              string(secret.Data[credentialsUserKey]),
+		Password:          string(secret.Data[credentialsPasswordKey]),
+		PasswordHash:      string(secret.Data[credentialsPasswordHashKey]),
+
+		PendingAdminPassword:     string(secret.Data[credentialsPendingAdminPasswordKey]),
+		PendingAdminPasswordHash: string(secret.Data[credentialsPendingAdminPasswordHashKey]),
+		DashboardsPassword:       string(secret.Data[credentialsDashboardsPasswordKey]),
+		DashboardsPasswordHash:   string(secret.Data[credentialsDashboardsPasswordHashKey]),
+	}, nil
+}
+
+// 認証情報をSecretに保存する（既存のSecretは上書きする）
+// Dashboardsのユーザーは別のSecretにも保存する
+func SaveLogaasCredentials(ctx context.Context, clientset kubernetes.Interface, logaas_id string, credentials LogaasCredentials) error {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      LogaasCredentialsSecretName(logaas_id),
+			Namespace: OpenSearchNamespace,
+			Labels: map[string]string{
+				LogaasLabel: logaas_id,
+			},
+		},
+		Type: corev1.SecretTypeOpaque,
+		Data: map[string][]byte{
+			credentialsAdminPasswordKey:     []byte(credentials.AdminPassword),
+			credentialsAdminPasswordHashKey: []byte(credentials.AdminPasswordHash),
+			credentialsUserKey:              []byte(credentials.User),
+			credentialsPasswordKey:          []byte(credentials.Password),
+			credentialsPasswordHashKey:      []byte(credentials.PasswordHash),
+		},
+	}
+	if credentials.PendingAdminPassword != "" {
+		secret.Data[credentialsPendingAdminPasswordKey] = []byte(credentials.PendingAdminPassword)
+		secret.Data[credentialsPendingAdminPasswordHashKey] = []byte(credentials.PendingAdminPasswordHash)
+	}
+	if credentials.DashboardsPassword != "" {
+		secret.Data[credentialsDashboardsPasswordKey] = []byte(credentials.DashboardsPassword)
+		secret.Data[credentialsDashboardsPasswordHashKey] = []byte(credentials.DashboardsPasswordHash)
+	}
+	if err := applySecret(ctx, clientset, secret); err != nil {
+		return err
+	}
+	if credentials.DashboardsPassword == "" {
+		return nil
+	}
+	return SaveLogaasDashboardsSecret(ctx, clientset, logaas_id, credentials.DashboardsPassword)
+}
+
+// Dashboardsのユーザーの認証情報をチャートが参照するusername/passwordのキーでSecretに保存する
+func SaveLogaasDashboardsSecret(ctx context.Context, clientset kubernetes.Interface, logaas_id string, password string) error {
+	return applySecret(ctx, clientset, &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      LogaasDashboardsSecretName(logaas_id),
+			Namespace: OpenSearchNamespace,
+			Labels: map[string]string{
+				LogaasLabel: logaas_id,
+			},
+		},
+		Type: corev1.SecretTypeOpaque,
+		Data: map[string][]byte{
+			"username": []byte(LogaasDashboardsUser),
+			"password": []byte(password),
+		},
+	})
+}
+
+// Secretを作成する（既存のSecretは上書きする）
+func applySecret(ctx context.Context, clientset kubernetes.Interface, secret *corev1.Secret) error {
+	secrets := clientset.CoreV1().Secrets(secret.Namespace)
+	if _, err := secrets.Create(ctx, secret, metav1.CreateOptions{}); !apierrors.IsAlreadyExists(err) {
+		return err
+	}
+	_, err := secrets.Update(ctx, secret, metav1.UpdateOptions{})
+	return err
+}
+
+// 変更中のadminユーザーのパスワードを現在のパスワードとしてSecretに保存する（OpenSearchに反映した後に呼ぶ）
+func CommitPendingAdminPassword(ctx context.Context, clientset kubernetes.Interface, logaas_id string) error {
+	credentials, err := GetLogaasCredentials(ctx, clientset, logaas_id)
+	if err != nil {
+		return err
+	}
+	if credentials.PendingAdminPassword == "" {
+		return nil
+	}
+	credentials.AdminPassword, credentials.AdminPasswordHash = credentials.PendingAdminPassword, credentials.PendingAdminPasswordHash
+	credentials.PendingAdminPassword, credentials.PendingAdminPasswordHash = "", ""
+	return SaveLogaasCredentials(ctx, clientset, logaas_id, credentials)
+}
+
+func DeleteLogaasCredentials(ctx context.Context, clientset kubernetes.Interface, logaas_id string) error {
+	secrets := clientset.CoreV1().Secrets(OpenSearchNamespace)
+	if err := IgnoreNotFound(secrets.Delete(ctx, LogaasDashboardsSecretName(logaas_id), metav1.DeleteOptions{})); err != nil {
+		return err
+	}
+	return IgnoreNotFound(secrets.Delete(ctx, LogaasCredentialsSecretName(logaas_id), metav1.DeleteOptions{}))
+}
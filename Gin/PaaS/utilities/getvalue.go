@@ -3,289 +3,495 @@ package utilities
 import (
 	"bytes"
 	"fmt"
+	"ham3/api"
 	"ham3/config"
 	"math"
-	"os"
 	"strconv"
 	"text/template"
 )
 
-func LogaasGetDefaultValue(requestData *config.LogaasRequestData) {
+// defaultsは設定のbase-domainなどの環境ごとのデフォルト値
+func LogaasGetDefaultValue(requestData *api.LogaasRequestData, defaults config.DefaultsConfig) {
 	// デフォルト値の設定
 	requestData.OpenSearchVersion = "2.9.0"
 	requestData.OpenSearchDashboardsVersion = "2.9.0"
 	requestData.ClusterType = "standard"
 	requestData.ScaleSize = 3
-	requestData.BaseDomain = os.Getenv("BASE_DOMAIN")
-	requestData.K8sName = os.Getenv("KUBE_NAME")
+	requestData.BaseDomain = defaults.BaseDomain
+	requestData.K8sName = defaults.K8sName
 	requestData.MasterFlavor = "m1.small"
 	requestData.ClientFlavor = "m1.small"
 	requestData.DataFlavor = "d1.medium"
 	requestData.GuiFlavor = "m1.small"
 	requestData.DataDiskSize = 8
 	requestData.DiskType = "economy-medium"
-	requestData.Site = os.Getenv("SITE")
+	requestData.Site = defaults.Site
 	requestData.Zone = "az-a"
-	requestData.OcpCluster = os.Getenv("OCP_CLUSTER")
+	requestData.OcpCluster = defaults.OcpCluster
 }
 
-func OpensearchGetHelmValue(logaas_id string, requestData config.LogaasRequestData) (map[string]interface{}, error) {
+func AapaasGetDefaultValue(requestData *api.AapaasRequestData, defaults config.DefaultsConfig) {
+	// デフォルト値の設定（operator-versionが空の場合は最新のチャート）
+	requestData.OperatorVersion = ""
+	requestData.AdminUser = "admin"
+	requestData.BaseDomain = defaults.BaseDomain
+}
 
-	type OpensearchData struct {
-		Nproc            int
-		OpenSearchVer    string
-		ExporterAivenVer []string
-		ClusterType      string
+// AAPaaS(AWX)のホスト名（Routeのホスト）
+func AapaasHost(aapaas_id string, baseDomain string) string {
+	return fmt.Sprintf("%s.aap.%s", aapaas_id, baseDomain)
+}
+
+// AAPaaSの管理者パスワードのSecret名（AWX Operatorが<AWX名>-admin-passwordで作成する）
+func AapaasAdminSecretName(aapaas_id string) string {
+	return fmt.Sprintf("%s-admin-password", aapaas_id)
+}
+
+// AWX OperatorのHelm values（Operatorと同時にAWXのインスタンスを作成する）
+func AapaasGetHelmValue(aapaas_id string, requestData api.AapaasRequestData) map[string]interface{} {
+	return map[string]interface{}{
+		"AWX": map[string]interface{}{
+			"enabled": true,
+			"name":    aapaas_id,
+			"spec": map[string]interface{}{
+				"admin_user":                      requestData.AdminUser,
+				"ingress_type":                    "Route",
+				"route_host":                      AapaasHost(aapaas_id, requestData.BaseDomain),
+				"route_tls_termination_mechanism": "Edge",
+			},
+		},
+	}
+}
+
+// OpenSearch APIのホスト名（Ingress/Routeのホスト）
+func OpenSearchApiHost(logaas_id string, baseDomain string) string {
+	return fmt.Sprintf("%s-api.es.%s", logaas_id, baseDomain)
+}
+
+// OpenSearch Dashboardsのホスト名（Ingress/Routeのホスト）
+func OpenSearchGuiHost(logaas_id string, baseDomain string) string {
+	return fmt.Sprintf("%s-gui.es.%s", logaas_id, baseDomain)
+}
+
+// OpenSearchのノードグループ（ノードグループごとに1つのHelmリリースとしてインストールする）
+type OpenSearchNodeGroup struct {
+	Name        string
+	ReleaseName string
+	Values      map[string]interface{}
+}
+
+// ノードグループのリリース名（standardはリリース1つのためLOGaaS名をそのまま使う）
+func OpenSearchReleaseName(logaas_id string, clusterType string, nodeGroup string) string {
+	if clusterType == "scalable" {
+		return fmt.Sprintf("%s-%s", logaas_id, nodeGroup)
+	}
+	return logaas_id
+}
+
+// LOGaaSを構成するリリース名の一覧（インストール順）
+func OpenSearchReleaseNames(logaas_id string, clusterType string) []string {
+	if clusterType == "scalable" {
+		var releaseNames []string
+		for _, nodeGroup := range []string{"master", "data", "client"} {
+			releaseNames = append(releaseNames, OpenSearchReleaseName(logaas_id, clusterType, nodeGroup))
+		}
+		return releaseNames
+	}
+	return []string{logaas_id}
+}
+
+// ノードグループごとのHelm values（scalableはmaster/data/clientの3リリース、standardはmasterの1リリース）
+// internal_users.ymlにはcredentialsのパスワードのハッシュを埋め込む（セキュリティのインデックスの初期化時のみ使われる）
+func OpensearchGetHelmValue(logaas_id string, requestData api.LogaasRequestData, credentials LogaasCredentials, cfg config.LogaasConfig) ([]OpenSearchNodeGroup, error) {
+	var nodeGroups []OpenSearchNodeGroup
+
+	internalUsers, err := renderInternalUsersYaml(credentials)
+	if err != nil {
+		return nil, err
 	}
-	var err error
-	var values map[string]interface{}
 
-	if requestData.ClusterType == "scalable" {
-		opensearchTypeList := []string{"master", "data", "client"}
-		var flavor map[string]interface{}
-		for _, opensearchType := range opensearchTypeList {
+	switch requestData.ClusterType {
+	case "scalable":
+		for _, opensearchType := range []string{"master", "data", "client"} {
+			var flavorName string
+			var roles []string
+			var replicas int
 			switch opensearchType {
 			case "master":
-				flavor = config.Flavors[requestData.MasterFlavor].(map[string]interface{})
+				flavorName = requestData.MasterFlavor
+				roles = []string{"master"}
+				replicas = 3
 			case "data":
-				flavor = config.Flavors[requestData.DataFlavor].(map[string]interface{})
+				flavorName = requestData.DataFlavor
+				roles = []string{"ingest", "data"}
+				replicas = requestData.ScaleSize
 			case "client":
-				flavor = config.Flavors[requestData.ClientFlavor].(map[string]interface{})
-			}
-			requests := flavor["requests"].(map[string]string)
-			limits := flavor["limits"].(map[string]string)
-			requests_cpu := requests["cpu"]
-			requests_memory := requests["memory"]
-			limits_cpu := limits["cpu"]
-			limits_memory := limits["memory"]
-			jvm_heap := flavor["jvm_heap"]
-			jvm_perm := flavor["jvm_perm"]
-
-			var n_proc int
-			if limits_cpu[len(limits_cpu)-1:] == "m" {
-				n, err := strconv.Atoi(limits_cpu[:len(limits_cpu)-1])
-				if err != nil {
-					return nil, err
-				}
-				n_proc = int(math.Ceil(float64(n) / 1000))
-			} else {
-				n, err := strconv.Atoi(limits_cpu)
-				if err != nil {
-					return nil, err
-				}
-				n_proc = n
-			}
-
-			funcMap := template.FuncMap{
-				"contains": Contains,
+				flavorName = requestData.ClientFlavor
+				roles = []string{"ingest"}
+				replicas = 2
 			}
-			t, err := template.New(logaas_id).Funcs(funcMap).Parse(config.OpensearchYamlTmpl)
+			values, err := opensearchNodeGroupValue(logaas_id, requestData, cfg, internalUsers, opensearchType, flavorName, roles, replicas)
 			if err != nil {
 				return nil, err
 			}
+			nodeGroups = append(nodeGroups, OpenSearchNodeGroup{
+				Name:        opensearchType,
+				ReleaseName: OpenSearchReleaseName(logaas_id, requestData.ClusterType, opensearchType),
+				Values:      values,
+			})
+		}
+	case "standard":
+		// standardはすべてのロールを持つmasterノードのみで構成する
+		values, err := opensearchNodeGroupValue(logaas_id, requestData, cfg, internalUsers, "master", requestData.DataFlavor, []string{"master", "ingest", "data"}, requestData.ScaleSize)
+		if err != nil {
+			return nil, err
+		}
+		nodeGroups = append(nodeGroups, OpenSearchNodeGroup{
+			Name:        "master",
+			ReleaseName: OpenSearchReleaseName(logaas_id, requestData.ClusterType, "master"),
+			Values:      values,
+		})
+	default:
+		return nil, fmt.Errorf("Invalid cluster type: %s", requestData.ClusterType)
+	}
 
-			OpenSearchTmpldata := OpensearchData{
-				Nproc:            n_proc,
-				OpenSearchVer:    requestData.OpenSearchVersion,
-				ExporterAivenVer: config.Exporter.(map[string]interface{})["aiven_ver"],
-				ClusterType:      requestData.ClusterType,
-			}
+	return nodeGroups, nil
+}
 
-			// Templateの結果を格納する変数を定義
-			var buf bytes.Buffer
-			err = t.Execute(&buf, OpenSearchTmpldata)
-			if err != nil {
-				return nil, err
-			}
+// adminユーザーとテナントのユーザーを含むinternal_users.yml
+func renderInternalUsersYaml(credentials LogaasCredentials) (string, error) {
+	t, err := template.New("internal_users").Parse(config.InternalUsersYamlTmpl)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, credentials); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
 
-			// Templateの結果を文字列に変換
-			opensearchYaml := buf.String()
-
-			values = map[string]interface{}{
-				"clusterName": logaas_id,
-				"nodeGroup":   opensearchType,
-				"roles": func() []string {
-					var opensearch_type []string
-					switch opensearchType {
-					case "master":
-						opensearch_type = []string{"master"}
-					case "data":
-						opensearch_type = []string{"ingest", "data"}
-					case "client":
-						opensearch_type = []string{"ingest"}
-					}
-					return opensearch_type
-				}(),
-				"masterService": fmt.Sprintf("%s-master", logaas_id),
-				"replicas": func() int {
-					var replicas int
-					switch opensearchType {
-					case "master":
-						replicas = 3
-					case "data":
-						replicas = requestData.ScaleSize
-					case "client":
-						replicas = 2
-					}
-					return replicas
-				}(),
-				"rbac": map[string]interface{}{
-					"create": func() bool {
-						var rbacCreate bool
-						if requestData.OpenSearchVersion == "1.1.0" {
-							rbacCreate = false
-						} else {
-							rbacCreate = true
-						}
-						return rbacCreate
-					}(),
-					"serviceAccountName": func() string {
-						var saName string
-						if requestData.OpenSearchVersion == "1.1.0" {
-							saName = "es"
-						} else {
-							saName = fmt.Sprintf("%s-client", logaas_id)
-						}
-						return saName
-					}(),
-				},
-				"persistence": map[string]interface{}{
-					"enabled":         false,
-					"enableInitChown": false,
-				},
-				"podSecurityContext": map[string]interface{}{
-					"runAsUser": 1000,
-				},
-				"ingress": map[string]interface{}{
-					"ingressClassName": "openshift-default",
-					"enabled":          true,
-					"annotations": map[string]interface{}{
-						"route.openshift.io/termination": "edge",
-					},
-					"hosts": []string{
-						fmt.Sprintf("%s-api.es.%s", logaas_id, requestData.BaseDomain),
-					},
-				},
-				"opensearchJavaOpts": fmt.Sprintf("-Xms%s -Xmx%s -XX:MaxMetaspaceSize=%s -Dhttp.proxyHost=%s -Dhttp.proxyPort=%s -Dhttps.proxyHost=%s -Dhttps.proxyPort=%s", jvm_heap, jvm_heap, jvm_perm, config.HttpProxyUrl, config.HttpProxyPort, config.HttpProxyUrl, config.HttpProxyPort),
-				"resources": map[string]interface{}{
-					"limits": map[string]string{
-						"cpu":    limits_cpu,
-						"memory": limits_memory,
-					},
-					"requests": map[string]string{
-						"cpu":    requests_cpu,
-						"memory": requests_memory,
-					},
-				},
-				"antiAffinityTopologyKey": "kubernetes.io/hostname",
-				"plugins": map[string]interface{}{
-					"enabled": true,
-					"intallList": func() []string {
-						var prometheusExporter []string
-						if Contains(config.Exporter["aparo_ver"].([]string), requestData.OpenSearchVersion) {
-							prometheusExporter = []string{fmt.Sprintf("https://github.com/aparo/opensearch-prometheus-exporter/releases/download/%s/prometheus-exporter-%s.zip", requestData.OpenSearchVersion, requestData.OpenSearchVersion), "repository-s3"}
-						} else if Contains(config.Exporter["aiven_ver"].([]string), requestData.OpenSearchVersion) {
-							prometheusExporter = []string{fmt.Sprintf("https://github.com/aiven/prometheus-exporter-plugin-for-opensearch/releases/download/%s.0/prometheus-exporter-%s.0.zip", requestData.OpenSearchVersion, requestData.OpenSearchVersion), "repository-s3"}
-						}
-						return prometheusExporter
-					}(),
+// ノードグループ1つ分のHelm values
+// 全ノードグループでclusterNameとmasterServiceを揃えることで、別リリースのノードが同じクラスタに参加する
+func opensearchNodeGroupValue(logaas_id string, requestData api.LogaasRequestData, cfg config.LogaasConfig, internalUsers string, opensearchType string, flavorName string, roles []string, replicas int) (map[string]interface{}, error) {
+	type OpensearchData struct {
+		ClusterName      string
+		Nproc            int
+		OpenSearchVer    string
+		ExporterAivenVer []string
+	}
+
+	flavor, ok := config.GetFlavor(flavorName)
+	if !ok {
+		return nil, fmt.Errorf("Invalid flavor for %s: %s", opensearchType, flavorName)
+	}
+	requests_cpu := flavor.Requests.Cpu
+	requests_memory := flavor.Requests.Memory
+	limits_cpu := flavor.Limits.Cpu
+	limits_memory := flavor.Limits.Memory
+	jvm_heap := flavor.JvmHeap
+	jvm_perm := flavor.JvmPerm
+
+	var n_proc int
+	if limits_cpu[len(limits_cpu)-1:] == "m" {
+		n, err := strconv.Atoi(limits_cpu[:len(limits_cpu)-1])
+		if err != nil {
+			return nil, err
+		}
+		n_proc = int(math.Ceil(float64(n) / 1000))
+	} else {
+		n, err := strconv.Atoi(limits_cpu)
+		if err != nil {
+			return nil, err
+		}
+		n_proc = n
+	}
+
+	funcMap := template.FuncMap{
+		"contains": Contains,
+	}
+	t, err := template.New(logaas_id).Funcs(funcMap).Parse(config.OpensearchYamlTmpl)
+	if err != nil {
+		return nil, err
+	}
+
+	OpenSearchTmpldata := OpensearchData{
+		ClusterName:      logaas_id,
+		Nproc:            n_proc,
+		OpenSearchVer:    requestData.OpenSearchVersion,
+		ExporterAivenVer: config.Exporter["aiven_ver"].([]string),
+	}
+
+	// Templateの結果を格納する変数を定義
+	var buf bytes.Buffer
+	err = t.Execute(&buf, OpenSearchTmpldata)
+	if err != nil {
+		return nil, err
+	}
+
+	// Templateの結果を文字列に変換
+	opensearchYaml := buf.String()
+
+	// ServiceAccountはノードグループ（リリース）ごとに作成する
+	serviceAccountName := fmt.Sprintf("%s-%s", logaas_id, opensearchType)
+
+	values := map[string]interface{}{
+		"clusterName":   logaas_id,
+		"nodeGroup":     opensearchType,
+		"roles":         roles,
+		"masterService": fmt.Sprintf("%s-master", logaas_id),
+		"replicas":      replicas,
+		"rbac": map[string]interface{}{
+			"create": func() bool {
+				var rbacCreate bool
+				if requestData.OpenSearchVersion == "1.1.0" {
+					rbacCreate = false
+				} else {
+					rbacCreate = true
+				}
+				return rbacCreate
+			}(),
+			"serviceAccountName": func() string {
+				var saName string
+				if requestData.OpenSearchVersion == "1.1.0" {
+					saName = "es"
+				} else {
+					saName = serviceAccountName
+				}
+				return saName
+			}(),
+		},
+		"podSecurityContext": map[string]interface{}{
+			"runAsUser": 1000,
+		},
+		// allow_insecure_settingsはスナップショットのS3リポジトリの認証情報をリポジトリの設定で指定するため（キーストアの更新にはPodの再起動が必要）
+		"opensearchJavaOpts": fmt.Sprintf("-Xms%s -Xmx%s -XX:MaxMetaspaceSize=%s -Dhttp.proxyHost=%s -Dhttp.proxyPort=%s -Dhttps.proxyHost=%s -Dhttps.proxyPort=%s -Dopensearch.allow_insecure_settings=true", jvm_heap, jvm_heap, jvm_perm, cfg.HttpProxyUrl, cfg.HttpProxyPort, cfg.HttpProxyUrl, cfg.HttpProxyPort),
+		"resources": map[string]interface{}{
+			"limits": map[string]string{
+				"cpu":    limits_cpu,
+				"memory": limits_memory,
+			},
+			"requests": map[string]string{
+				"cpu":    requests_cpu,
+				"memory": requests_memory,
+			},
+		},
+		"antiAffinityTopologyKey": "kubernetes.io/hostname",
+		"plugins": map[string]interface{}{
+			"enabled": true,
+			"installList": func() []string {
+				var prometheusExporter []string
+				if Contains(config.Exporter["aparo_ver"].([]string), requestData.OpenSearchVersion) {
+					prometheusExporter = []string{fmt.Sprintf("https://github.com/aparo/opensearch-prometheus-exporter/releases/download/%s/prometheus-exporter-%s.zip", requestData.OpenSearchVersion, requestData.OpenSearchVersion), "repository-s3"}
+				} else if Contains(config.Exporter["aiven_ver"].([]string), requestData.OpenSearchVersion) {
+					prometheusExporter = []string{fmt.Sprintf("https://github.com/aiven/prometheus-exporter-plugin-for-opensearch/releases/download/%s.0/prometheus-exporter-%s.0.zip", requestData.OpenSearchVersion, requestData.OpenSearchVersion), "repository-s3"}
+				}
+				return prometheusExporter
+			}(),
+		},
+		"config": map[string]interface{}{
+			"opensearch.yml": opensearchYaml,
+		},
+		"extraEnvs": []map[string]interface{}{
+			{
+				"name":  "DISABLE_INSTALL_DEMO_CONFIG",
+				"value": "true",
+			},
+		},
+		"extraVolumes": []map[string]interface{}{
+			{
+				"name": "pem",
+				"configMap": map[string]string{
+					"name": "opensearch-pem-config",
 				},
-				"config": map[string]interface{}{
-					"opensearch.yml": opensearchYaml,
+			},
+		},
+		"extraVolumeMounts": []map[string]interface{}{
+			{
+				"name":      "pem",
+				"mountPath": "/usr/share/opensearch/config/esnode-key.pem",
+				"subPath":   "esnode-key.pem",
+			},
+			{
+				"name":      "pem",
+				"mountPath": "/usr/share/opensearch/config/esnode.pem",
+				"subPath":   "esnode.pem",
+			},
+			{
+				"name":      "pem",
+				"mountPath": "/usr/share/opensearch/config/root-ca.pem",
+				"subPath":   "root-ca.pem",
+			},
+		},
+		"securityConfig": map[string]interface{}{
+			"config": map[string]interface{}{
+				"data": map[string]interface{}{
+					"action_groups.yml":  config.ActionGroupsYaml,
+					"audit.yml":          config.AuditYaml,
+					"config.yml":         config.ConfigYaml,
+					"internal_users.yml": internalUsers,
+					"nodes_dn.yml":       config.NodesDnYaml,
+					"roles.yml":          config.RolesYaml,
+					"roles_mapping.yml":  config.RolesMappingYaml,
+					"tenants.yml":        config.TenantsYaml,
+					"whitelist.yml":      config.WhitelistYaml,
 				},
-				"extraEnvs": []map[string]interface{}{
-					{
-						"name":  "DISABLE_INSTALL_DEMO_CONFIG",
-						"value": "true",
-					},
+			},
+		},
+	}
+
+	// ボリュームを持つノードグループは事前に作成したPVCを使う（StorageClassによる動的プロビジョニングはしない）
+	if size, _, ok := OpenSearchVolumeSize(requestData, opensearchType); ok {
+		values["persistence"] = map[string]interface{}{
+			"enabled":         true,
+			"enableInitChown": false,
+			"storageClass":    "-",
+			"accessModes":     []string{"ReadWriteOnce"},
+			"size":            fmt.Sprintf("%dGi", size),
+		}
+	} else {
+		values["persistence"] = map[string]interface{}{
+			"enabled":         false,
+			"enableInitChown": false,
+		}
+	}
+
+	// APIのエンドポイント（Ingress/Route）はリクエストを受けるノードグループにのみ作成する
+	// scalableはclientノード、standardはmasterノード
+	if opensearchType == "client" || requestData.ClusterType == "standard" {
+		values["ingress"] = map[string]interface{}{
+			"ingressClassName": "openshift-default",
+			"enabled":          true,
+			"annotations": map[string]interface{}{
+				"route.openshift.io/termination": "edge",
+			},
+			"hosts": []string{
+				OpenSearchApiHost(logaas_id, requestData.BaseDomain),
+			},
+		}
+	}
+
+	// OpenSearchのバージョンが1.1.0より上の場合、valuesにextraObjectsを追加する
+	if requestData.OpenSearchVersion > "1.1.0" {
+		extraObjectsValue := []map[string]interface{}{
+			{
+				"apiVersion": "rbac.authorization.k8s.io/v1",
+				"kind":       "RoleBinding",
+				"metadata": map[string]string{
+					"name":      fmt.Sprintf("scc:nonroot:%s-%s", logaas_id, opensearchType),
+					"namespace": OpenSearchNamespace,
 				},
-				"extraVolumes": []map[string]interface{}{
-					{
-						"name": "pem",
-						"configMap": map[string]string{
-							"name": "opensearch-pem-config",
-						},
-					},
+				"roleRef": map[string]string{
+					"apiGroup": "rbac.authorization.k8s.io",
+					"kind":     "ClusterRole",
+					"name":     "system:openshift:scc:nonroot",
 				},
-				"extraVolumeMounts": []map[string]interface{}{
-					{
-						"name":      "pem",
-						"mountPath": "/usr/share/opensearch/config/esnode-key.pem",
-						"subPath":   "esnode-key.pem",
-					},
+				"subjects": []map[string]string{
 					{
-						"name":      "pem",
-						"mountPath": "/usr/share/opensearch/config/esnode.pem",
-						"subPath":   "esnode.pem",
-					},
-					{
-						"name":      "pem",
-						"mountPath": "/usr/share/opensearch/config/root-ca.pem",
-						"subPath":   "root-ca.pem",
-					},
-				},
-				"securityConfig": map[string]interface{}{
-					"config": map[string]interface{}{
-						"data": map[string]interface{}{
-							"action_groups.yml":  config.ActionGroupsYaml,
-							"audit.yml":          auditYaml,
-							"config.yml":         configYaml,
-							"internal_users.yml": internalUsersYaml,
-							"nodes_dn.yml":       nodesDnYaml,
-							"roles.yml":          rolesYaml,
-							"roles_mapping.yml":  rolesMappingYaml,
-							"tenants.yml":        tenantsYaml,
-							"whitelist.yml":      whitelistYaml,
-						},
+						"kind":      "ServiceAccount",
+						"name":      serviceAccountName,
+						"namespace": OpenSearchNamespace,
 					},
 				},
-			}
+			},
+		}
+		AddToMapWithCondition(values, "extraObjects", extraObjectsValue)
+	}
 
-			// OpenSearchのバージョンが1.1.0より上の場合、valuesにextraObjectsを追加する
-			if requestData.OpenSearchVersion > "1.1.0" {
-				extraObjectsValue := []map[string]interface{}{
-					{
-						"apiVersion": "rbac.authorization.k8s.io/v1",
-						"kind":       "RoleBinding",
-						"metadata": map[string]string{
-							"name":      fmt.Sprintf("scc:nonroot:%s-%s", logaas_id, opensearchType),
-							"namespace": "opensearch",
-						},
-						"roleRef": map[string]string{
-							"apiGroup": "rbac.authorization.k8s.io",
-							"kind":     "ClusterRole",
-							"name":     "system:openshift:scc:nonroot",
-						},
-						"subjects": []map[string]string{
-							{
-								"kind":      "ServiceAccount",
-								"name":      fmt.Sprintf("%s-%s", logaas_id, opensearchType),
-								"namespace": "opensearch",
+	// OpenSearchのバージョンが2.0.0より上の場合、valuesのsecurityConfigフィールドにpathを追加する
+	if requestData.OpenSearchVersion > "2.0.0" {
+		pathValue := "/usr/share/opensearch/config/opensearch-security"
+		AddToMapWithCondition(values["securityConfig"].(map[string]interface{}), "path", pathValue)
+	}
+
+	return values, nil
+}
+
+// opensearch_dashboards.yml（Routeでedge終端のTLSのため、セッションのCookieはsecureにする）
+const dashboardsYaml = `server.host: '0.0.0.0'
+opensearch.requestHeadersWhitelist: [authorization, securitytenant]
+opensearch_security.multitenancy.enabled: true
+opensearch_security.multitenancy.tenants.preferred: [Global]
+opensearch_security.cookie.secure: true
+`
+
+// OpenSearch DashboardsのHelm values（接続先はscalableの場合はclientノード、standardの場合はmasterノードのService）
+func OpensearchDashboardsGetHelmValue(logaas_id string, requestData api.LogaasRequestData) (map[string]interface{}, error) {
+	flavor, ok := config.GetFlavor(requestData.GuiFlavor)
+	if !ok {
+		return nil, fmt.Errorf("Invalid gui-flavor: %s", requestData.GuiFlavor)
+	}
+
+	var opensearchHost string
+	switch requestData.ClusterType {
+	case "scalable":
+		opensearchHost = fmt.Sprintf("http://%s-client:9200", logaas_id)
+	case "standard":
+		opensearchHost = fmt.Sprintf("http://%s-master:9200", logaas_id)
+	default:
+		return nil, fmt.Errorf("Invalid cluster type: %s", requestData.ClusterType)
+	}
+
+	values := map[string]interface{}{
+		"fullnameOverride": OpenSearchDashboardsReleaseName(logaas_id),
+		"opensearchHosts":  opensearchHost,
+		"replicaCount": func() int {
+			var replicas int
+			if requestData.ClusterType == "scalable" {
+				replicas = 2
+			} else {
+				replicas = 1
+			}
+			return replicas
+		}(),
+		"image": map[string]interface{}{
+			"tag": requestData.OpenSearchDashboardsVersion,
+		},
+		// セキュリティプラグインを有効にしたまま、Dashboardsのユーザーの認証情報でOpenSearchに接続する
+		// ユーザーはDashboardsのログイン画面でテナントのユーザーとして認証する
+		"opensearchAccount": map[string]interface{}{
+			"secret": LogaasDashboardsSecretName(logaas_id),
+		},
+		"config": map[string]interface{}{
+			"opensearch_dashboards.yml": dashboardsYaml,
+		},
+		"ingress": map[string]interface{}{
+			"ingressClassName": "openshift-default",
+			"enabled":          true,
+			"annotations": map[string]interface{}{
+				"route.openshift.io/termination": "edge",
+			},
+			"hosts": []map[string]interface{}{
+				{
+					"host": OpenSearchGuiHost(logaas_id, requestData.BaseDomain),
+					"paths": []map[string]interface{}{
+						{
+							"path": "/",
+							"backend": map[string]interface{}{
+								"serviceName": OpenSearchDashboardsReleaseName(logaas_id),
+								"servicePort": 5601,
 							},
 						},
 					},
-				}
-				AddToMapWithCondition(values, "extraObjects", extraObjectsValue)
-			}
-
-			// OpenSearchのバージョンが2.0.0より上の場合、valuesのsecurityConfigフィールドにpathを追加する
-			if requestData.OpenSearchVersion > "2.0.0" {
-				pathValue := "/usr/share/opensearch/config/opensearch-security"
-				AddToMapWithCondition(values["securityConfig"].(map[string]interface{}), "path", pathValue)
-			}
-		}
-	} else if requestData.ClusterType == "standard" {
-		opensearchTypeList := []string{"api"}
-		for _, opensearchType := range opensearchTypeList {
-			values = map[string]interface{}{
-				"clusterName": logaas_id,
-			}
-		}
-	} else {
-		err := "Invalid cluster type"
+				},
+			},
+		},
+		"resources": map[string]interface{}{
+			"limits": map[string]string{
+				"cpu":    flavor.Limits.Cpu,
+				"memory": flavor.Limits.Memory,
+			},
+			"requests": map[string]string{
+				"cpu":    flavor.Requests.Cpu,
+				"memory": flavor.Requests.Memory,
+			},
+		},
+		"podSecurityContext": map[string]interface{}{
+			"runAsUser": 1000,
+		},
 	}
 
-	return values, err
+	return values, nil
 }
 
 func Contains(slice []string, value string) bool {
@@ -0,0 +1,76 @@
+package utilities
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"ham3/api"
+	"ham3/config"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// DashboardsはセキュリティプラグインのままDashboardsのユーザーのSecretでOpenSearchに接続する
+func TestOpensearchDashboardsGetHelmValue(t *testing.T) {
+	if err := config.LoadFlavors("../" + config.DefaultFlavorFile); err != nil {
+		t.Fatal(err)
+	}
+	requestData := api.LogaasRequestData{ClusterType: "scalable", GuiFlavor: "m1.small", BaseDomain: "example.com"}
+
+	values, err := OpensearchDashboardsGetHelmValue("logs", requestData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	account, _ := values["opensearchAccount"].(map[string]interface{})
+	if secret := account["secret"]; secret != LogaasDashboardsSecretName("logs") {
+		t.Errorf("opensearchAccount.secret = %v, want %s", secret, LogaasDashboardsSecretName("logs"))
+	}
+	dashboardsYml := values["config"].(map[string]interface{})["opensearch_dashboards.yml"].(string)
+	if strings.Contains(dashboardsYml, "opensearch_security.enabled") {
+		t.Errorf("opensearch_dashboards.yml must not disable the security plugin:\n%s", dashboardsYml)
+	}
+}
+
+// Dashboardsのユーザーはinternal_users.ymlとチャートが参照するSecretの両方に含まれる
+func TestDashboardsUserCredentials(t *testing.T) {
+	credentials, err := GenerateLogaasCredentials("tenant")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	internalUsers, err := renderInternalUsersYaml(credentials)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		LogaasDashboardsUser + ":",
+		`hash: "` + credentials.DashboardsPasswordHash + `"`,
+		`- "` + config.DashboardsSecurityRole + `"`,
+	} {
+		if !strings.Contains(internalUsers, want) {
+			t.Errorf("internal_users.yml does not contain %q:\n%s", want, internalUsers)
+		}
+	}
+
+	ctx := context.Background()
+	clientset := fake.NewSimpleClientset()
+	if err := SaveLogaasCredentials(ctx, clientset, "logs", credentials); err != nil {
+		t.Fatal(err)
+	}
+	secret, err := clientset.CoreV1().Secrets(OpenSearchNamespace).Get(ctx, LogaasDashboardsSecretName("logs"), metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(secret.Data["username"]) != LogaasDashboardsUser || string(secret.Data["password"]) != credentials.DashboardsPassword {
+		t.Errorf("dashboards secret = %s/%s, want %s and the generated password", secret.Data["username"], secret.Data["password"], LogaasDashboardsUser)
+	}
+
+	if err := DeleteLogaasCredentials(ctx, clientset, "logs"); err != nil {
+		t.Fatal(err)
+	}
+	if secrets, _ := clientset.CoreV1().Secrets(OpenSearchNamespace).List(ctx, metav1.ListOptions{}); len(secrets.Items) != 0 {
+		t.Errorf("%d secrets left after delete", len(secrets.Items))
+	}
+}
@@ -1,115 +1,299 @@
 package utilities
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
 	"log"
-	"os"
-	"path/filepath"
-	_ "time"
+	"sync"
+	"time"
+
+	"ham3/config"
+	"ham3/telemetry"
+
+	"go.opentelemetry.io/otel/attribute"
 
 	"helm.sh/helm/v3/pkg/action"
 	"helm.sh/helm/v3/pkg/chart"
-	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/chartutil"
 	"helm.sh/helm/v3/pkg/cli"
-	"helm.sh/helm/v3/pkg/getter"
+	kubefake "helm.sh/helm/v3/pkg/kube/fake"
+	"helm.sh/helm/v3/pkg/release"
 	"helm.sh/helm/v3/pkg/repo"
+	"helm.sh/helm/v3/pkg/storage"
+	"helm.sh/helm/v3/pkg/storage/driver"
 )
 
+// OpenSearchをデプロイするNamespace（起動時に設定のkubernetes.opensearch-namespaceで上書きする）
+var OpenSearchNamespace = config.DefaultOpenSearchNamespace
+
+// OpenSearch Dashboardsのリリース名（OpenSearchのリリースとは別に管理する）
+func OpenSearchDashboardsReleaseName(logaas_id string) string {
+	return fmt.Sprintf("%s-dashboards", logaas_id)
+}
+
+// Helmのチャート（Repoのリポジトリに含まれる"<リポジトリ名>/<チャート名>"のチャート）
+type HelmChart struct {
+	Repo *repo.Entry
+	Name string
+	// 空の場合は最新のチャート
+	Version string
+}
+
+// 指定したバージョンのチャート
+func (c HelmChart) WithVersion(version string) HelmChart {
+	c.Version = version
+	return c
+}
+
 var (
 	repoEntry = &repo.Entry{
 		Name: "opensearch",
 		URL:  "https://opensearch-project.github.io/helm-charts/",
 	}
+
+	// AWX Operatorのチャートリポジトリ
+	AwxOperatorRepo = &repo.Entry{
+		Name: "awx-operator",
+		URL:  "https://ansible-community.github.io/awx-operator-helm/",
+	}
 )
 
-func OpenSearchHelmSetting(releaseName string, actionType string) (*action.Install, *action.Uninstall, *chart.Chart) {
-	// Helm CLI設定の取得
-	settings := cli.New()
-	settings.Debug = true
+// OpenSearch/OpenSearch Dashboards/AWX Operatorのチャート
+var (
+	OpenSearchChart           = HelmChart{Repo: repoEntry, Name: "opensearch/opensearch"}
+	OpenSearchDashboardsChart = HelmChart{Repo: repoEntry, Name: "opensearch/opensearch-dashboards"}
+	AwxOperatorChart          = HelmChart{Repo: AwxOperatorRepo, Name: "awx-operator/awx-operator"}
+)
 
-	// Namespaceを設定
-	settings.SetNamespace("opensearch")
+// Helmリリースの操作（ルーターの設定時に作成してサービスに渡す）
+// リリースが存在しない場合、Uninstall/Statusはdriver.ErrReleaseNotFoundを返す
+// Install/Upgradeはctxがキャンセルされると待機を中断して失敗する
+// ctxのスパンはTracedHelmInstallerで操作のスパンの親になる
+type HelmInstaller interface {
+	Install(ctx context.Context, namespace string, releaseName string, chart HelmChart, values map[string]interface{}) (*release.Release, error)
+	// valuesは既存の値を引き継がずすべて置き換える
+	Upgrade(ctx context.Context, namespace string, releaseName string, chart HelmChart, values map[string]interface{}) (*release.Release, error)
+	// リリースをrevisionのチャートとvaluesに戻す（新しいリビジョンとして記録される）
+	Rollback(ctx context.Context, namespace string, releaseName string, revision int) error
+	Uninstall(ctx context.Context, namespace string, releaseName string) error
+	Status(ctx context.Context, namespace string, releaseName string) (*release.Release, error)
+}
 
-	// リポジトリファイルのパスを設定
-	repoFile := settings.RepositoryConfig
+// Helm SDKを使ったHelmInstaller
+type HelmClient struct {
+	// Namespaceごとのaction.Configuration（リリース情報の保存先とKubernetesのクライアント）
+	ActionConfig func(namespace string) (*action.Configuration, error)
+	// チャートの取得
+	LoadChart func(chart HelmChart) (*chart.Chart, error)
 
-	// リポジトリファイルを読み込むか、存在しない場合は新規作成する
-	var r *repo.File
-	if _, err := os.Stat(repoFile); os.IsNotExist(err) {
-		r = repo.NewFile()
-	} else {
-		var err error
-		r, err = repo.LoadFile(repoFile)
-		if err != nil {
-			log.Fatalf("Failed to load repo file: %v", err)
-		}
+	// リソースがReadyになるまで待つか、待ち時間、失敗時にロールバックするか（config.HelmConfig）
+	Wait    bool
+	Timeout time.Duration
+	Atomic  bool
+}
+
+// kubeconfig/HELM_*の環境変数の設定でクラスタに接続するHelmClient
+// リリース情報はNamespace内のSecretに保存し、チャートはcfgのチャートの取得元から取得する
+func NewHelmClient(cfg config.HelmConfig) (*HelmClient, error) {
+	sources, err := cfg.ChartSources()
+	if err != nil {
+		return nil, err
 	}
+	return &HelmClient{
+		Wait:    cfg.Wait,
+		Timeout: cfg.Timeout,
+		Atomic:  cfg.Atomic,
+		ActionConfig: func(namespace string) (*action.Configuration, error) {
+			settings := cli.New()
+			settings.SetNamespace(namespace)
+			return helmActionConfig(settings)
+		},
+		LoadChart: func(helmChart HelmChart) (*chart.Chart, error) {
+			return LoadChart(sources, helmChart)
+		},
+	}, nil
+}
 
-	// リポジトリファイルにリポジトリを追加する
-	if !r.Has(repoEntry.Name) {
-		r.Update(repoEntry)
+// リリース情報をメモリに保存し、クラスタにはマニフェストを適用しないHelmClient（テスト用）
+func NewMemoryHelmClient(loadChart func(chart HelmChart) (*chart.Chart, error)) *HelmClient {
+	var mu sync.Mutex
+	configs := map[string]*action.Configuration{}
+	return &HelmClient{
+		ActionConfig: func(namespace string) (*action.Configuration, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			if actionConfig, ok := configs[namespace]; ok {
+				return actionConfig, nil
+			}
+			memory := driver.NewMemory()
+			memory.SetNamespace(namespace)
+			actionConfig := &action.Configuration{
+				Releases:     storage.Init(memory),
+				KubeClient:   &kubefake.PrintingKubeClient{Out: io.Discard},
+				Capabilities: chartutil.DefaultCapabilities,
+				Log:          func(format string, v ...interface{}) {},
+			}
+			configs[namespace] = actionConfig
+			return actionConfig, nil
+		},
+		LoadChart: loadChart,
+	}
+}
 
-		// リポジトリファイルを保存するディレクトリを作成
-		if err := os.MkdirAll(filepath.Dir(repoFile), os.ModePerm); err != nil {
-			log.Fatalf("Failed to create directory for repo file: %v", err)
-		}
+// Helm設定の初期化（リリース情報はNamespace内のSecretに保存される）
+func helmActionConfig(settings *cli.EnvSettings) (*action.Configuration, error) {
+	actionConfig := new(action.Configuration)
+	if err := actionConfig.Init(settings.RESTClientGetter(), settings.Namespace(), "secret", func(format string, v ...interface{}) {
+		log.Printf(format, v...)
+	}); err != nil {
+		return nil, fmt.Errorf("Failed to initialize Helm configuration: %v", err)
+	}
+	return actionConfig, nil
+}
 
-		// リポジトリファイルを保存する
-		if err := r.WriteFile(repoFile, 0644); err != nil {
-			log.Fatalf("Failed to write repo file: %v", err)
-		}
+// 指定したNamespaceにチャートをインストールする
+// Atomicの場合、失敗したリリースはアンインストールされる
+func (h *HelmClient) Install(ctx context.Context, namespace string, releaseName string, helmChart HelmChart, values map[string]interface{}) (*release.Release, error) {
+	actionConfig, err := h.ActionConfig(namespace)
+	if err != nil {
+		return nil, err
 	}
+	loaded, err := h.LoadChart(helmChart)
+	if err != nil {
+		return nil, err
+	}
+
+	installClient := action.NewInstall(actionConfig)
+	installClient.Namespace = namespace
+	installClient.ReleaseName = releaseName
+	installClient.Version = helmChart.Version
+	installClient.CreateNamespace = true
+	// k8sリソースがetcdに登録されるだけではなく、実際にrunning状態になるまで待つ
+	installClient.Wait = h.Wait
+	installClient.Timeout = h.Timeout
+	installClient.Atomic = h.Atomic
+	return installClient.RunWithContext(ctx, loaded, values)
+}
 
-	// チャートリポジトリを作成し、インデックスファイルをダウンロードする
-	chartRepo, err := repo.NewChartRepository(repoEntry, getter.All(settings))
+// 変更後のvaluesでリリースをアップグレードする
+// Atomicの場合、失敗すると前のリビジョンにロールバックする
+func (h *HelmClient) Upgrade(ctx context.Context, namespace string, releaseName string, helmChart HelmChart, values map[string]interface{}) (*release.Release, error) {
+	actionConfig, err := h.ActionConfig(namespace)
 	if err != nil {
-		log.Fatalf("Failed to create new chart repository: %v", err)
+		return nil, err
 	}
-	_, err = chartRepo.DownloadIndexFile()
+	loaded, err := h.LoadChart(helmChart)
 	if err != nil {
-		log.Fatalf("Failed to download index file: %v", err)
+		return nil, err
 	}
 
-	// Helm設定の初期化
-	actionConfig := new(action.Configuration)
-	if err := actionConfig.Init(settings.RESTClientGetter(), "opensearch", "secret", func(format string, v ...interface{}) {
-		log.Printf(format, v...)
-	}); err != nil {
-		log.Fatalf("Failed to initialize Helm configuration: %v", err)
-	}
-
-	var installClient *action.Install
-	var uninstallClient *action.Uninstall
-	if actionType == "install" {
-		installClient = action.NewInstall(actionConfig)
-		// インストールクライアントの設定
-		installClient.Namespace = "opensearch"
-		installClient.ReleaseName = releaseName
-		installClient.CreateNamespace = true
-		// installClient.Wait = true ## k8sリソースがetcdに登録されるだけではなく、実際にrunning状態になるまで待つ。デフォルトはfalseでetcdに登録されたらプロンプトを返す
-		// installClient.Timeout = 30 * time.Second ## Waitをtrueにした場合、どれくらい待つかを設定
-		uninstallClient = nil
-	} else if actionType == "uninstall" {
-		uninstallClient = action.NewUninstall(actionConfig)
-		installClient = nil
-	}
-
-	// チャートのパスを見つける
-	chartName := "opensearch/opensearch"
-	chartPathOptions := action.ChartPathOptions{}
-	chartPath, err := chartPathOptions.LocateChart(chartName, settings)
+	upgradeClient := action.NewUpgrade(actionConfig)
+	upgradeClient.Namespace = namespace
+	upgradeClient.Version = helmChart.Version
+	upgradeClient.Wait = h.Wait
+	upgradeClient.Timeout = h.Timeout
+	upgradeClient.Atomic = h.Atomic
+	return upgradeClient.RunWithContext(ctx, releaseName, loaded, values)
+}
+
+// 指定したリビジョンにロールバックする
+// Helm SDKのロールバックはctxを受け取らないため、開始前にキャンセルを確認する
+func (h *HelmClient) Rollback(ctx context.Context, namespace string, releaseName string, revision int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	actionConfig, err := h.ActionConfig(namespace)
 	if err != nil {
-		log.Fatalf("Failed to locate chart: %v", err)
+		return err
 	}
+	rollbackClient := action.NewRollback(actionConfig)
+	rollbackClient.Version = revision
+	rollbackClient.Wait = h.Wait
+	rollbackClient.Timeout = h.Timeout
+	return rollbackClient.Run(releaseName)
+}
 
-	// チャートをロードする
-	chart, err := loader.Load(chartPath)
+// 指定したNamespaceのリリースをアンインストールする
+// Helm SDKのアンインストールはctxを受け取らないため、開始前にキャンセルを確認する
+func (h *HelmClient) Uninstall(ctx context.Context, namespace string, releaseName string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	actionConfig, err := h.ActionConfig(namespace)
 	if err != nil {
-		log.Fatalf("Failed to load chart: %v", err)
+		return err
+	}
+	_, err = action.NewUninstall(actionConfig).Run(releaseName)
+	return err
+}
+
+// 指定したNamespaceのリリースの状態を取得する
+func (h *HelmClient) Status(ctx context.Context, namespace string, releaseName string) (*release.Release, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	actionConfig, err := h.ActionConfig(namespace)
+	if err != nil {
+		return nil, err
+	}
+	return action.NewStatus(actionConfig).Run(releaseName)
+}
+
+// Helmの操作をスパン（"helm install"など）にするHelmInstaller
+// スパンにはNamespace、リリース名、チャートを記録し、失敗した場合はエラーを記録する
+type TracedHelmInstaller struct {
+	Next HelmInstaller
+}
+
+func helmSpanAttributes(namespace string, releaseName string) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("helm.namespace", namespace),
+		attribute.String("helm.release", releaseName),
+	}
+}
+
+func helmChartAttributes(helmChart HelmChart) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("helm.chart", helmChart.Name),
+		attribute.String("helm.chart_version", helmChart.Version),
 	}
+}
 
-	return installClient, uninstallClient, chart
+func (t TracedHelmInstaller) Install(ctx context.Context, namespace string, releaseName string, helmChart HelmChart, values map[string]interface{}) (rel *release.Release, err error) {
+	ctx, span := telemetry.Start(ctx, "helm install", append(helmSpanAttributes(namespace, releaseName), helmChartAttributes(helmChart)...)...)
+	defer func() { telemetry.End(span, err) }()
+	return t.Next.Install(ctx, namespace, releaseName, helmChart, values)
 }
 
-// func ValuesSetting() {
+func (t TracedHelmInstaller) Upgrade(ctx context.Context, namespace string, releaseName string, helmChart HelmChart, values map[string]interface{}) (rel *release.Release, err error) {
+	ctx, span := telemetry.Start(ctx, "helm upgrade", append(helmSpanAttributes(namespace, releaseName), helmChartAttributes(helmChart)...)...)
+	defer func() { telemetry.End(span, err) }()
+	return t.Next.Upgrade(ctx, namespace, releaseName, helmChart, values)
+}
 
-// }
+func (t TracedHelmInstaller) Rollback(ctx context.Context, namespace string, releaseName string, revision int) (err error) {
+	ctx, span := telemetry.Start(ctx, "helm rollback", append(helmSpanAttributes(namespace, releaseName), attribute.Int("helm.revision", revision))...)
+	defer func() { telemetry.End(span, err) }()
+	return t.Next.Rollback(ctx, namespace, releaseName, revision)
+}
+
+func (t TracedHelmInstaller) Uninstall(ctx context.Context, namespace string, releaseName string) (err error) {
+	ctx, span := telemetry.Start(ctx, "helm uninstall", helmSpanAttributes(namespace, releaseName)...)
+	defer func() { telemetry.End(span, err) }()
+	return t.Next.Uninstall(ctx, namespace, releaseName)
+}
+
+func (t TracedHelmInstaller) Status(ctx context.Context, namespace string, releaseName string) (*release.Release, error) {
+	ctx, span := telemetry.Start(ctx, "helm status", helmSpanAttributes(namespace, releaseName)...)
+	defer span.End()
+	rel, err := t.Next.Status(ctx, namespace, releaseName)
+	if errors.Is(err, driver.ErrReleaseNotFound) {
+		// 存在確認にも使うため、リリースがないことは失敗として記録しない
+		span.SetAttributes(attribute.Bool("helm.release_found", false))
+	} else {
+		telemetry.RecordError(span, err)
+	}
+	return rel, err
+}
@@ -0,0 +1,60 @@
+package utilities
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/storage/driver"
+)
+
+func testChart(helmChart HelmChart) (*chart.Chart, error) {
+	return &chart.Chart{Metadata: &chart.Metadata{APIVersion: chart.APIVersionV2, Name: helmChart.Name, Version: "0.1.0"}}, nil
+}
+
+// リリース情報をメモリに保存するHelmClientでインストールから削除までを実行する
+func TestMemoryHelmClient(t *testing.T) {
+	helm := NewMemoryHelmClient(testChart)
+	ctx := context.Background()
+
+	if _, err := helm.Install(ctx, "ns", "logs", OpenSearchChart, map[string]interface{}{"replicas": 1}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := helm.Upgrade(ctx, "ns", "logs", OpenSearchChart, map[string]interface{}{"replicas": 3}); err != nil {
+		t.Fatal(err)
+	}
+	rel, err := helm.Status(ctx, "ns", "logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rel.Version != 2 || rel.Config["replicas"] != 3 {
+		t.Errorf("release version %d with values %v, want version 2 with replicas 3", rel.Version, rel.Config)
+	}
+
+	// ロールバックは前のリビジョンのvaluesで新しいリビジョンを作成する
+	if err := helm.Rollback(ctx, "ns", "logs", 1); err != nil {
+		t.Fatal(err)
+	}
+	if rel, err = helm.Status(ctx, "ns", "logs"); err != nil {
+		t.Fatal(err)
+	}
+	if rel.Version != 3 || rel.Config["replicas"] != 1 {
+		t.Errorf("release version %d with values %v after rollback, want version 3 with replicas 1", rel.Version, rel.Config)
+	}
+
+	// 別のNamespaceのリリースとは区別される
+	if _, err := helm.Status(ctx, "other", "logs"); !errors.Is(err, driver.ErrReleaseNotFound) {
+		t.Errorf("status in other namespace: %v", err)
+	}
+
+	if err := helm.Uninstall(ctx, "ns", "logs"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := helm.Status(ctx, "ns", "logs"); !errors.Is(err, driver.ErrReleaseNotFound) {
+		t.Errorf("status after uninstall: %v", err)
+	}
+	if err := helm.Uninstall(ctx, "ns", "logs"); !errors.Is(err, driver.ErrReleaseNotFound) {
+		t.Errorf("second uninstall: %v", err)
+	}
+}
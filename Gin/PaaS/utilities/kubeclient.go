@@ -1,42 +1,51 @@
 package utilities
 
 import (
-	"flag"
 	"fmt"
-	"os"
 	"path/filepath"
 
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/client-go/util/homedir"
 )
 
-func GetKubeconfig() (*rest.Config, error) {
-	// Kubernetesのクラスター設定を取得
-	config, err := rest.InClusterConfig()
-	// k8s pod内でないなら、エラーが返ってくる
-	// その場合は次のローカルのkubeconfig取得処理へ
-	if err == nil {
-		return config, nil
-	} else {
-		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+// kubeconfigのパスが空の場合は、Pod内ならServiceAccount、それ以外は~/.kube/configを使う
+func GetKubeconfig(kubeconfig string) (*rest.Config, error) {
+	if kubeconfig == "" {
+		// Kubernetesのクラスター設定を取得
+		config, err := rest.InClusterConfig()
+		// k8s pod内でないなら、エラーが返ってくる
+		// その場合は次のローカルのkubeconfig取得処理へ
+		if err == nil {
+			return config, nil
+		}
+		// ホームディレクトリからkubeconfigのパスを取得
+		if home := homedir.HomeDir(); home != "" {
+			kubeconfig = filepath.Join(home, ".kube", "config")
+		}
 	}
 
-	// ホームディレクトリからkubeconfigのパスを取得
-	var kubeconfig *string
-	if home := homedir.HomeDir(); home != "" {
-		kubeconfig = flag.String("kubeconfig", filepath.Join(home, ".kube", "config"), "(optional) absolute path to the kubeconfig file")
-	} else {
-		kubeconfig = flag.String("kubeconfig", "", "absolute path to the kubeconfig file")
-	}
-	flag.Parse()
-
 	// kubeconfigファイルを使用して設定をロード
-	config, err = clientcmd.BuildConfigFromFlags("", *kubeconfig)
-
+	config, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error creating config from kubeconfig: %v\n", err)
-		return nil, err
+		return nil, fmt.Errorf("Error creating config from kubeconfig %s: %v", kubeconfig, err)
 	}
 	return config, nil
 }
+
+// 削除対象のリソースがすでに存在しない場合はエラーとしない
+func IgnoreNotFound(err error) error {
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// 作成対象のリソースがすでに存在する場合はエラーとしない
+func IgnoreAlreadyExists(err error) error {
+	if apierrors.IsAlreadyExists(err) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,211 @@
+package utilities
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"ham3/config"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/kubernetes"
+)
+
+// OpenSearchのクラスタの状態(_cluster/healthのstatus)
+const (
+	OpenSearchHealthGreen  = "green"
+	OpenSearchHealthYellow = "yellow"
+	OpenSearchHealthRed    = "red"
+)
+
+// OpenSearchのクラスタの状態の確認
+type OpenSearchHealthChecker interface {
+	// クラスタの状態がgreen/yellowになるまで待ち、最後に取得した状態を返す
+	// タイムアウトまたはctxがキャンセルされた場合はエラーを返す
+	WaitHealthy(ctx context.Context, logaas_id string, baseDomain string) (string, error)
+}
+
+// LOGaaSのOpenSearchのREST APIのクライアント（ルーターの設定時に作成してサービスに渡す）
+type OpenSearchClient interface {
+	OpenSearchHealthChecker
+	// pathのAPIを呼び出す（bodyはJSONで送信し、レスポンスのJSONをoutにデコードする。nilの場合は送信/デコードしない）
+	// 2xx以外のレスポンスは*OpenSearchErrorを返す
+	Do(ctx context.Context, logaas_id string, baseDomain string, method string, path string, body interface{}, out interface{}) error
+}
+
+// OpenSearchが2xx以外を返した場合のエラー
+type OpenSearchError struct {
+	StatusCode int
+	// OpenSearchのエラーレスポンス（JSON）
+	Body string
+}
+
+func (e *OpenSearchError) Error() string {
+	return fmt.Sprintf("OpenSearch returned %d: %s", e.StatusCode, e.Body)
+}
+
+// OpenSearchが指定したステータスコードを返したか
+func IsOpenSearchStatus(err error, statusCode int) bool {
+	var openSearchErr *OpenSearchError
+	return errors.As(err, &openSearchErr) && openSearchErr.StatusCode == statusCode
+}
+
+// OpenSearch APIのIngress/Route経由で呼び出すOpenSearchClient
+// adminユーザーのパスワードはLOGaaSごとのSecretから取得する
+type HttpOpenSearchClient struct {
+	Client *http.Client
+	// 認証情報のSecretを取得するクライアント
+	Kube kubernetes.Interface
+	// 認証情報のSecretがないLOGaaSのユーザー/パスワード
+	Username string
+	Password string
+	// WaitHealthyの待ち時間と確認の間隔
+	HealthTimeout  time.Duration
+	HealthInterval time.Duration
+}
+
+func NewHttpOpenSearchClient(cfg config.OpenSearchClientConfig, clientset kubernetes.Interface) *HttpOpenSearchClient {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if cfg.InsecureSkipVerify {
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+	return &HttpOpenSearchClient{
+		Client:         &http.Client{Transport: transport, Timeout: 30 * time.Second},
+		Kube:           clientset,
+		Username:       cfg.Username,
+		Password:       cfg.Password,
+		HealthTimeout:  cfg.HealthTimeout,
+		HealthInterval: cfg.HealthInterval,
+	}
+}
+
+func (h *HttpOpenSearchClient) Do(ctx context.Context, logaas_id string, baseDomain string, method string, path string, body interface{}, out interface{}) error {
+	url := fmt.Sprintf("https://%s/%s", OpenSearchApiHost(logaas_id, baseDomain), path)
+
+	var data []byte
+	if body != nil {
+		var err error
+		if data, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+	username, passwords, err := h.adminCredentials(ctx, logaas_id)
+	if err != nil {
+		return err
+	}
+
+	// adminユーザーのパスワードの変更が中断した場合、OpenSearchには変更中のパスワードが設定されている可能性がある
+	// 現在のパスワードで認証に失敗した場合は変更中のパスワードで認証し、成功した場合はSecretに確定する
+	for i, password := range passwords {
+		err = h.do(ctx, method, url, data, username, password, out)
+		if !IsOpenSearchStatus(err, http.StatusUnauthorized) {
+			if err == nil && i > 0 {
+				if err := CommitPendingAdminPassword(ctx, h.Kube, logaas_id); err != nil {
+					fmt.Printf("Error committing pending admin password of logaas[%s]: %v\n", logaas_id, err)
+				}
+			}
+			return err
+		}
+	}
+	return err
+}
+
+func (h *HttpOpenSearchClient) do(ctx context.Context, method string, url string, data []byte, username string, password string, out interface{}) error {
+	var reqBody io.Reader
+	if data != nil {
+		reqBody = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, reqBody)
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(username, password)
+	if data != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := h.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+		return &OpenSearchError{StatusCode: resp.StatusCode, Body: string(data)}
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("Failed to decode response of %s %s: %v", req.Method, req.URL.Path, err)
+	}
+	return nil
+}
+
+// adminユーザーの認証情報（Secretがない場合は設定のユーザー/パスワード）
+// 変更中のパスワードがある場合は現在のパスワードの次に返す
+func (h *HttpOpenSearchClient) adminCredentials(ctx context.Context, logaas_id string) (string, []string, error) {
+	if h.Kube == nil {
+		return h.Username, []string{h.Password}, nil
+	}
+	credentials, err := GetLogaasCredentials(ctx, h.Kube, logaas_id)
+	if apierrors.IsNotFound(err) {
+		return h.Username, []string{h.Password}, nil
+	} else if err != nil {
+		return "", nil, fmt.Errorf("Failed to get credentials of %s: %v", logaas_id, err)
+	}
+	passwords := []string{credentials.AdminPassword}
+	if credentials.PendingAdminPassword != "" {
+		passwords = append(passwords, credentials.PendingAdminPassword)
+	}
+	return LogaasAdminUser, passwords, nil
+}
+
+// _cluster/healthのstatusを取得する
+func (h *HttpOpenSearchClient) ClusterHealth(ctx context.Context, logaas_id string, baseDomain string) (string, error) {
+	var health struct {
+		Status string `json:"status"`
+	}
+	if err := h.Do(ctx, logaas_id, baseDomain, http.MethodGet, "_cluster/health", nil, &health); err != nil {
+		return "", err
+	}
+	return health.Status, nil
+}
+
+// HealthIntervalごとに_cluster/healthを確認し、green/yellowになるまで待つ
+// 起動直後はIngressやノードが応答しないため、取得のエラーはタイムアウトまで再試行する
+func (h *HttpOpenSearchClient) WaitHealthy(ctx context.Context, logaas_id string, baseDomain string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, h.HealthTimeout)
+	defer cancel()
+
+	ticker := time.NewTicker(h.HealthInterval)
+	defer ticker.Stop()
+
+	var status string
+	var lastErr error
+	for {
+		s, err := h.ClusterHealth(ctx, logaas_id, baseDomain)
+		if err == nil && (s == OpenSearchHealthGreen || s == OpenSearchHealthYellow) {
+			return s, nil
+		}
+		// タイムアウトで中断したリクエストのエラーではなく、その前の結果を返す
+		if ctx.Err() == nil {
+			status, lastErr = s, err
+		}
+
+		select {
+		case <-ctx.Done():
+			if lastErr != nil {
+				return status, fmt.Errorf("OpenSearch cluster %s did not become healthy: %v (last error: %v)", logaas_id, ctx.Err(), lastErr)
+			}
+			return status, fmt.Errorf("OpenSearch cluster %s did not become healthy: %v (status: %s)", logaas_id, ctx.Err(), status)
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package utilities
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// adminユーザーのパスワードの変更が確定する前に中断した場合も、変更中のパスワードで認証して確定する
+func TestDoCommitsPendingAdminPassword(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if username, password, _ := r.BasicAuth(); username != LogaasAdminUser || password != "new" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"status": "green"}`))
+	}))
+	defer server.Close()
+
+	// LOGaaSのホスト名の代わりにテストサーバーに接続する
+	client := server.Client()
+	transport := client.Transport.(*http.Transport)
+	transport.TLSClientConfig.InsecureSkipVerify = true
+	transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return (&net.Dialer{}).DialContext(ctx, network, server.Listener.Addr().String())
+	}
+
+	ctx := context.Background()
+	clientset := fake.NewSimpleClientset()
+	if err := SaveLogaasCredentials(ctx, clientset, "logs", LogaasCredentials{AdminPassword: "old", PendingAdminPassword: "new", PendingAdminPasswordHash: "new-hash"}); err != nil {
+		t.Fatal(err)
+	}
+	openSearch := &HttpOpenSearchClient{Client: client, Kube: clientset}
+
+	status, err := openSearch.ClusterHealth(ctx, "logs", "example.com")
+	if err != nil || status != OpenSearchHealthGreen {
+		t.Fatalf("ClusterHealth = %q, %v", status, err)
+	}
+	credentials, err := GetLogaasCredentials(ctx, clientset, "logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if credentials.AdminPassword != "new" || credentials.AdminPasswordHash != "new-hash" || credentials.PendingAdminPassword != "" {
+		t.Errorf("credentials after commit = %+v", credentials)
+	}
+
+	// どちらのパスワードでも認証できない場合は401を返す
+	if err := SaveLogaasCredentials(ctx, clientset, "logs", LogaasCredentials{AdminPassword: "old", PendingAdminPassword: "other"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := openSearch.ClusterHealth(ctx, "logs", "example.com"); !IsOpenSearchStatus(err, http.StatusUnauthorized) {
+		t.Errorf("ClusterHealth with wrong passwords = %v, want 401", err)
+	}
+}
@@ -3,75 +3,116 @@ package utilities
 import (
 	"errors"
 	"fmt"
+	"ham3/api"
 	"ham3/config"
-	"os"
-	"strings"
+	"regexp"
+	"time"
 
 	"github.com/gophercloud/gophercloud"
 	"github.com/gophercloud/gophercloud/openstack"
 	"github.com/gophercloud/gophercloud/openstack/blockstorage/v3/volumes"
+	"github.com/gophercloud/gophercloud/openstack/compute/v2/extensions/volumeattach"
 	"github.com/gophercloud/gophercloud/openstack/identity/v3/tokens"
 )
 
-// 戻り値: ProjectID、Adminなのかどうか、errorメッセージ
-func TokenAuth(token string) (string, bool, error) {
-	var IsAdmin bool
-	var ProjectId string
+// Keystone/Cinder/Novaの接続先（起動時にSetKeystoneConfigで設定する）
+var keystoneConfig config.KeystoneConfig
+
+func SetKeystoneConfig(cfg config.KeystoneConfig) {
+	keystoneConfig = cfg
+}
+
+// Keystoneで検証したトークンの情報
+type TokenInfo struct {
+	ProjectId   string
+	ProjectName string
+	UserId      string
+	UserName    string
+	// 設定(keystone.admin-role)の管理者ロールがあるか
+	IsAdmin bool
+	// トークンの有効期限（Keystoneのexpires_at）
+	ExpiresAt time.Time
+}
+
+// rolesに管理者ロール（ロール名の完全一致）が含まれるか
+func hasAdminRole(roles []tokens.Role, adminRole string) bool {
+	for _, role := range roles {
+		if role.Name == adminRole {
+			return true
+		}
+	}
+	return false
+}
+
+// トークンを検証し、トークンのプロジェクトとユーザーを返す
+func TokenAuth(token string) (TokenInfo, error) {
+	var info TokenInfo
 
 	// プロバイダーを作成
-	provider, err := OpenstackProvider()
+	provider, err := GetOpenstackProvider()
 	if err != nil {
-		errMessage := fmt.Errorf("An error occurred during authentication. err: %v", err)
-		return ProjectId, IsAdmin, errMessage
+		return info, fmt.Errorf("An error occurred during authentication. err: %v", err)
 	}
 
 	// KeyStoneサービスクライアントを初期化
-	keystoneClient, err := openstack.NewIdentityv3(provider, gophercloud.EndpointOpts{
-		Region: os.Getenv("OPENSTACK_REGION"),
+	keystoneClient, err := openstack.NewIdentityV3(provider, gophercloud.EndpointOpts{
+		Region: keystoneConfig.Region,
 	})
 	if err != nil {
-		errMessage := fmt.Errorf("An error occurred during creating keystone client. err: %v", err)
-		return ProjectId, IsAdmin, errMessage
+		return info, fmt.Errorf("An error occurred during creating keystone client. err: %v", err)
 	}
 
 	// トークン検証
 	tokenValidationResult, err := tokens.Validate(keystoneClient, token)
 	if err != nil {
-		errMessage := fmt.Errorf("An error occurred during token validation. err: %v", err)
-		return ProjectId, IsAdmin, errMessage
+		return info, fmt.Errorf("An error occurred during token validation. err: %v", err)
 	}
 	if !tokenValidationResult {
-		errMessage := errors.New("Invalid token.")
-		return ProjectId, IsAdmin, errMessage
+		return info, errors.New("Invalid token.")
 	}
 
 	// トークンの詳細情報を取得
 	tokenDetail := tokens.Get(keystoneClient, token)
-	tokeninfo, ok := tokenDetail.Body.(map[string]interface{})["token"].(map[string]interface{})
-	if !ok {
-		errMessage := errors.New("An error occurred while converting to tokenDetail.Body")
-		return ProjectId, IsAdmin, errMessage
+	project, err := tokenDetail.ExtractProject()
+	if err != nil {
+		return info, fmt.Errorf("An error occurred while extracting project from token. err: %v", err)
 	}
-
-	ProjectId = tokeninfo["project"].(map[string]interface{})["id"]
-	roles := tokeninfo["roles"].([]interface{})
-
-	for _, role := range roles {
-		if strings.Contains(role.(map[string]interface{})["name"].(string), "admin") {
-			IsAdmin = true
-		}
+	// プロジェクトスコープでないトークンは受け付けない
+	if project == nil || project.ID == "" {
+		return info, errors.New("Token is not scoped to a project.")
 	}
+	roles, err := tokenDetail.ExtractRoles()
+	if err != nil {
+		return info, fmt.Errorf("An error occurred while extracting roles from token. err: %v", err)
+	}
+	// 監査ログに記録するユーザー
+	user, err := tokenDetail.ExtractUser()
+	if err != nil {
+		return info, fmt.Errorf("An error occurred while extracting user from token. err: %v", err)
+	}
+	// 検証結果のキャッシュはトークンの有効期限を超えない
+	tokenValue, err := tokenDetail.ExtractToken()
+	if err != nil {
+		return info, fmt.Errorf("An error occurred while extracting expiry from token. err: %v", err)
+	}
+
+	info.ProjectId = project.ID
+	info.ProjectName = project.Name
+	info.UserId = user.ID
+	info.UserName = user.Name
+	info.IsAdmin = hasAdminRole(roles, keystoneConfig.AdminRole)
+	info.ExpiresAt = tokenValue.ExpiresAt
 
-	return ProjectId, IsAdmin, nil
+	return info, nil
 }
 
 func GetOpenstackProvider() (*gophercloud.ProviderClient, error) {
 	opts := gophercloud.AuthOptions{
-		IdentityEndpoint: os.Getenv("OPENSTACK_AUTH_ENDPOINT"),
-		Username:         os.Getenv("OPENSTACK_USERNAME"),
-		Password:         os.Getenv("OPENSTACK_PASSWORD"),
-		DomainName:       "Default",
-		TenantName:       os.Getenv("OPENSTACK_PROJECT_NAME"),
+		IdentityEndpoint: keystoneConfig.AuthEndpoint,
+		Username:         keystoneConfig.Username,
+		Password:         keystoneConfig.Password,
+		DomainName:       keystoneConfig.DomainName,
+		TenantName:       keystoneConfig.ProjectName,
 	}
 
 	return openstack.AuthenticatedClient(opts)
@@ -79,7 +120,7 @@ func GetOpenstackProvider() (*gophercloud.ProviderClient, error) {
 
 func GetCinderClient(provider *gophercloud.ProviderClient) (*gophercloud.ServiceClient, error) {
 	client, err := openstack.NewBlockStorageV3(provider, gophercloud.EndpointOpts{
-		Region: os.Getenv("OPENSTACK_REGION"),
+		Region: keystoneConfig.Region,
 	})
 	if err != nil {
 		errMessage := fmt.Errorf("An error occurred during creating cinder client. err: %v", err)
@@ -88,73 +129,190 @@ func GetCinderClient(provider *gophercloud.ProviderClient) (*gophercloud.Service
 	return client, nil
 }
 
-func CreateCinderVolume(logaas_id string, requestData config.LogaasRequestData) error {
+func GetComputeClient(provider *gophercloud.ProviderClient) (*gophercloud.ServiceClient, error) {
+	client, err := openstack.NewComputeV2(provider, gophercloud.EndpointOpts{
+		Region: keystoneConfig.Region,
+	})
+	if err != nil {
+		errMessage := fmt.Errorf("An error occurred during creating compute client. err: %v", err)
+		return client, errMessage
+	}
+	return client, nil
+}
+
+// OpenSearchのノードに割り当てるCinderボリューム
+type OpenSearchVolume struct {
+	Name       string `json:"name"`
+	NodeGroup  string `json:"node_group"`
+	Index      int    `json:"index"`
+	Size       int    `json:"size"`
+	VolumeType string `json:"volume_type"`
+	Zone       string `json:"zone"`
+	ID         string `json:"id"`
+}
+
+// LOGaaSのボリュームの操作（ルーターの設定時に作成してサービスに渡す）
+type VolumeProvider interface {
+	// 作成済みのボリュームはそのまま返す
+	CreateVolumes(logaas_id string, requestData api.LogaasRequestData) ([]OpenSearchVolume, error)
+	WaitVolumesAvailable(volumes []OpenSearchVolume, timeout int) error
+	DeleteVolumes(logaas_id string, requestData api.LogaasRequestData) error
+	FindLeakedVolumes(ocpCluster string, exists func(logaas_id string) (bool, error)) ([]LeakedVolume, error)
+}
+
+// OpenStack Cinderを使ったVolumeProvider（接続先はSetKeystoneConfigで設定する）
+type CinderVolumeProvider struct{}
+
+func (CinderVolumeProvider) CreateVolumes(logaas_id string, requestData api.LogaasRequestData) ([]OpenSearchVolume, error) {
+	return CreateCinderVolume(logaas_id, requestData)
+}
+
+func (CinderVolumeProvider) WaitVolumesAvailable(volumes []OpenSearchVolume, timeout int) error {
+	return WaitCinderVolumesAvailable(volumes, timeout)
+}
+
+func (CinderVolumeProvider) DeleteVolumes(logaas_id string, requestData api.LogaasRequestData) error {
+	return DeleteCinderVolume(logaas_id, requestData)
+}
+
+func (CinderVolumeProvider) FindLeakedVolumes(ocpCluster string, exists func(logaas_id string) (bool, error)) ([]LeakedVolume, error) {
+	return FindLeakedCinderVolumes(ocpCluster, exists)
+}
+
+// Cinderボリュームの命名規則: <OCPクラスタ名>-<LOGaaS名>-<ノードグループ>-opensearch-pv-<連番>
+func OpenSearchVolumeName(ocpCluster string, logaas_id string, nodeGroup string, index int) string {
+	return fmt.Sprintf("%s-%s-%s-opensearch-pv-%v", ocpCluster, logaas_id, nodeGroup, index)
+}
+
+// ノードグループのボリュームサイズとタイプ（ボリュームを持たないノードグループの場合はfalse）
+// scalableのmasterノードは固定サイズ、dataノードとstandardのノードはリクエストのサイズ
+func OpenSearchVolumeSize(requestData api.LogaasRequestData, nodeGroup string) (int, string, bool) {
+	if requestData.ClusterType == "scalable" {
+		switch nodeGroup {
+		case "master":
+			return 2, "economy-medium", true
+		case "data":
+			return requestData.DataDiskSize, requestData.DiskType, true
+		}
+		return 0, "", false
+	}
+	if nodeGroup == "master" {
+		return requestData.DataDiskSize, requestData.DiskType, true
+	}
+	return 0, "", false
+}
+
+// LOGaaSに必要なCinderボリュームの一覧（ノードのレプリカ数分）
+func OpenSearchVolumes(logaas_id string, requestData api.LogaasRequestData) []OpenSearchVolume {
+	replicas := map[string]int{"master": requestData.ScaleSize}
+	if requestData.ClusterType == "scalable" {
+		replicas = map[string]int{"master": 3, "data": requestData.ScaleSize}
+	}
+
+	var opensearchVolumes []OpenSearchVolume
+	for _, nodeGroup := range []string{"master", "data"} {
+		size, volType, ok := OpenSearchVolumeSize(requestData, nodeGroup)
+		if !ok {
+			continue
+		}
+		for i := 0; i < replicas[nodeGroup]; i++ {
+			opensearchVolumes = append(opensearchVolumes, OpenSearchVolume{
+				Name:       OpenSearchVolumeName(requestData.OcpCluster, logaas_id, nodeGroup, i),
+				NodeGroup:  nodeGroup,
+				Index:      i,
+				Size:       size,
+				VolumeType: volType,
+				Zone:       requestData.Zone,
+			})
+		}
+	}
+	return opensearchVolumes
+}
+
+// Cinderボリュームを作成する（同名のボリュームがすでに存在する場合は作成せずに既存のボリュームを使う）
+func CreateCinderVolume(logaas_id string, requestData api.LogaasRequestData) ([]OpenSearchVolume, error) {
 	provider, err := GetOpenstackProvider()
 	if err != nil {
 		errMessage := fmt.Errorf("An error occurred during authentication. err: %v", err)
-		return errMessage
+		return nil, errMessage
 	}
 
 	// Cinderサービスクライアントを初期化
 	cinderClient, err := GetCinderClient(provider)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	var volSize int
-	var volType string
-
-	if requestData.ClusterType == "scalable" {
-		volSize = 2
-		volType = "economy-medium"
-	} else if requestData.ClusterType == "standard" {
-		volSize = requestData.DataDiskSize
-		volType = requestData.DiskType
-	}
+	opensearchVolumes := OpenSearchVolumes(logaas_id, requestData)
+	for i, opensearchVolume := range opensearchVolumes {
+		existing, err := findCinderVolumeByName(cinderClient, opensearchVolume.Name)
+		if err != nil {
+			return nil, err
+		}
+		if existing != nil {
+			fmt.Printf("Volume %s already exists with ID: %s\n", opensearchVolume.Name, existing.ID)
+			opensearchVolumes[i].ID = existing.ID
+			continue
+		}
 
-	// Volumeの設定(masterノード用)
-	for i := 0; i < 3; i++ {
-		masaterCreateOpts := volumes.CreateOpts{
+		createOpts := volumes.CreateOpts{
 			Description:      fmt.Sprintf("%s-%s-opensearch-pv", requestData.OcpCluster, logaas_id),
-			Name:             fmt.Sprintf("%s-%s-master-opensearch-pv-%v", requestData.OcpCluster, logaas_id, i),
-			AvailabilityZone: requestData.Zone,
-			Size:             volSize,
-			VolumeType:       volType,
+			Name:             opensearchVolume.Name,
+			AvailabilityZone: opensearchVolume.Zone,
+			Size:             opensearchVolume.Size,
+			VolumeType:       opensearchVolume.VolumeType,
 		}
 
-		volume, err := volumes.Create(cinderClient, masaterCreateOpts).Extract()
+		volume, err := volumes.Create(cinderClient, createOpts).Extract()
 		if err != nil {
 			errMessage := fmt.Errorf("Failed to create volume: %v", err)
-			return errMessage
+			return nil, errMessage
 		}
-		fmt.Printf("Created volume: %s with ID: %s\n", fmt.Sprintf("%s-%s-master-opensearch-pv-%v", requestData.OcpCluster, logaas_id, i), volume.ID)
+		fmt.Printf("Created volume: %s with ID: %s\n", opensearchVolume.Name, volume.ID)
+		opensearchVolumes[i].ID = volume.ID
 	}
 
-	if requestData.ClusterType == "scalable" {
-		// Volumeの設定(dataノード用)
-		count := requestData.ScaleSize
-		for i := 0; i < count; i++ {
-			dataCreateOpts := volumes.CreateOpts{
-				Description:      fmt.Sprintf("%s-%s-opensearch-pv", requestData.OcpCluster, logaas_id),
-				Name:             fmt.Sprintf("%s-%s-data-opensearch-pv-%v", requestData.OcpCluster, logaas_id, i),
-				AvailabilityZone: requestData.Zone,
-				Size:             requestData.DataDiskSize,
-				VolumeType:       requestData.DiskType,
-			}
+	return opensearchVolumes, nil
+}
+
+// Cinderボリュームがavailableになるまで待つ（errorになった場合はその時点で失敗とする）
+func WaitCinderVolumesAvailable(opensearchVolumes []OpenSearchVolume, timeout int) error {
+	provider, err := GetOpenstackProvider()
+	if err != nil {
+		errMessage := fmt.Errorf("An error occurred during authentication. err: %v", err)
+		return errMessage
+	}
 
-			volume, err := volumes.Create(cinderClient, dataCreateOpts).Extract()
+	// Cinderサービスクライアントを初期化
+	cinderClient, err := GetCinderClient(provider)
+	if err != nil {
+		return err
+	}
+
+	for _, opensearchVolume := range opensearchVolumes {
+		err := gophercloud.WaitFor(timeout, func() (bool, error) {
+			volume, err := volumes.Get(cinderClient, opensearchVolume.ID).Extract()
 			if err != nil {
-				errMessage := fmt.Errorf("Failed to create volume: %v", err)
-				return errMessage
+				return false, err
 			}
-			fmt.Printf("Created volume: %s with ID: %s\n", fmt.Sprintf("%s-%s-data-opensearch-pv-%v", requestData.OcpCluster, logaas_id, i), volume.ID)
+			switch volume.Status {
+			case "available", "in-use":
+				return true, nil
+			case "error":
+				return false, fmt.Errorf("Volume %s is in error status", opensearchVolume.Name)
+			}
+			return false, nil
+		})
+		if err != nil {
+			return fmt.Errorf("Failed to wait for volume %s: %v", opensearchVolume.Name, err)
 		}
 	}
-
 	return nil
 }
 
-func DeleteCinderVolume(logaas_id string, requestData config.LogaasRequestData) error {
+// LOGaaSのCinderボリュームを削除する
+// Podの削除後もアタッチされたままのボリュームはNovaからデタッチしてから削除する
+func DeleteCinderVolume(logaas_id string, requestData api.LogaasRequestData) error {
 	provider, err := GetOpenstackProvider()
 	if err != nil {
 		errMessage := fmt.Errorf("An error occurred during authentication. err: %v", err)
@@ -166,5 +324,135 @@ func DeleteCinderVolume(logaas_id string, requestData config.LogaasRequestData)
 	if err != nil {
 		return err
 	}
+
+	// 命名規則に一致するボリュームをすべて削除する（スケールダウンで使われなくなったボリュームも含む）
+	pattern := regexp.MustCompile(fmt.Sprintf("^%s-%s-(master|data)-opensearch-pv-[0-9]+$", regexp.QuoteMeta(requestData.OcpCluster), regexp.QuoteMeta(logaas_id)))
+	allVolumes, err := listCinderVolumes(cinderClient)
+	if err != nil {
+		return err
+	}
+
+	var computeClient *gophercloud.ServiceClient
+	for _, volume := range allVolumes {
+		if !pattern.MatchString(volume.Name) {
+			continue
+		}
+
+		if volume.Status == "in-use" {
+			// Podの削除後にCSIドライバーがデタッチするのを待つ
+			if err := volumes.WaitForStatus(cinderClient, volume.ID, "available", 60); err != nil {
+				if computeClient == nil {
+					computeClient, err = GetComputeClient(provider)
+					if err != nil {
+						return err
+					}
+				}
+				for _, attachment := range volume.Attachments {
+					fmt.Printf("Detaching volume %s from server %s\n", volume.Name, attachment.ServerID)
+					if err := volumeattach.Delete(computeClient, attachment.ServerID, volume.ID).ExtractErr(); err != nil {
+						return fmt.Errorf("Failed to detach volume %s: %v", volume.Name, err)
+					}
+				}
+				if err := volumes.WaitForStatus(cinderClient, volume.ID, "available", 120); err != nil {
+					return fmt.Errorf("Failed to wait for volume %s to be detached: %v", volume.Name, err)
+				}
+			}
+		}
+
+		if err := volumes.Delete(cinderClient, volume.ID, volumes.DeleteOpts{}).ExtractErr(); err != nil {
+			return fmt.Errorf("Failed to delete volume %s: %v", volume.Name, err)
+		}
+		fmt.Printf("Deleted volume: %s with ID: %s\n", volume.Name, volume.ID)
+	}
 	return nil
 }
+
+// 命名規則からLOGaaSのボリュームと判断できるが、対応するLOGaaSが存在しないボリューム
+type LeakedVolume struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	LogaasId  string `json:"logaas_id"`
+	Status    string `json:"status"`
+	Size      int    `json:"size"`
+	CreatedAt string `json:"created_at"`
+}
+
+// OCPクラスタのLOGaaSボリュームのうち、existsがfalseを返すLOGaaSのボリュームを返す
+func FindLeakedCinderVolumes(ocpCluster string, exists func(logaas_id string) (bool, error)) ([]LeakedVolume, error) {
+	provider, err := GetOpenstackProvider()
+	if err != nil {
+		errMessage := fmt.Errorf("An error occurred during authentication. err: %v", err)
+		return nil, errMessage
+	}
+
+	// Cinderサービスクライアントを初期化
+	cinderClient, err := GetCinderClient(provider)
+	if err != nil {
+		return nil, err
+	}
+
+	allVolumes, err := listCinderVolumes(cinderClient)
+	if err != nil {
+		return nil, err
+	}
+
+	pattern := regexp.MustCompile(fmt.Sprintf("^%s-(.+)-(master|data)-opensearch-pv-[0-9]+$", regexp.QuoteMeta(ocpCluster)))
+	leakedVolumes := []LeakedVolume{}
+	checked := map[string]bool{}
+	for _, volume := range allVolumes {
+		match := pattern.FindStringSubmatch(volume.Name)
+		if match == nil {
+			continue
+		}
+		logaas_id := match[1]
+		found, ok := checked[logaas_id]
+		if !ok {
+			found, err = exists(logaas_id)
+			if err != nil {
+				return nil, err
+			}
+			checked[logaas_id] = found
+		}
+		if found {
+			continue
+		}
+		leakedVolumes = append(leakedVolumes, LeakedVolume{
+			ID:        volume.ID,
+			Name:      volume.Name,
+			LogaasId:  logaas_id,
+			Status:    volume.Status,
+			Size:      volume.Size,
+			CreatedAt: volume.CreatedAt.String(),
+		})
+	}
+	return leakedVolumes, nil
+}
+
+func findCinderVolumeByName(cinderClient *gophercloud.ServiceClient, name string) (*volumes.Volume, error) {
+	page, err := volumes.List(cinderClient, volumes.ListOpts{Name: name}).AllPages()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to list volumes: %v", err)
+	}
+	found, err := volumes.ExtractVolumes(page)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to extract volumes: %v", err)
+	}
+	for _, volume := range found {
+		if volume.Name == name {
+			return &volume, nil
+		}
+	}
+	return nil, nil
+}
+
+func listCinderVolumes(cinderClient *gophercloud.ServiceClient) ([]volumes.Volume, error) {
+	page, err := volumes.List(cinderClient, volumes.ListOpts{}).AllPages()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to list volumes: %v", err)
+	}
+	allVolumes, err := volumes.ExtractVolumes(page)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to extract volumes: %v", err)
+	}
+	return allVolumes, nil
+}
@@ -0,0 +1,31 @@
+package utilities
+
+import (
+	"testing"
+
+	"github.com/gophercloud/gophercloud/openstack/identity/v3/tokens"
+)
+
+// 管理者ロールは名前の完全一致で判定する（adminを含むだけのロールは管理者ではない）
+func TestHasAdminRole(t *testing.T) {
+	cases := []struct {
+		roles     []string
+		adminRole string
+		want      bool
+	}{
+		{roles: []string{"member", "admin"}, adminRole: "admin", want: true},
+		{roles: []string{"project_admin", "nonadmin", "admin-reader"}, adminRole: "admin", want: false},
+		{roles: []string{"admin"}, adminRole: "cloud-admin", want: false},
+		{roles: []string{"member", "cloud-admin"}, adminRole: "cloud-admin", want: true},
+		{roles: nil, adminRole: "admin", want: false},
+	}
+	for _, tc := range cases {
+		var roles []tokens.Role
+		for _, name := range tc.roles {
+			roles = append(roles, tokens.Role{Name: name})
+		}
+		if got := hasAdminRole(roles, tc.adminRole); got != tc.want {
+			t.Errorf("hasAdminRole(%v, %q) = %v, want %v", tc.roles, tc.adminRole, got, tc.want)
+		}
+	}
+}
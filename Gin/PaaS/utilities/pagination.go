@@ -0,0 +1,29 @@
+package utilities
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
+// クエリパラメータ(page, page_size)からページ番号とページサイズを取得する
+// 戻り値: page、pageSize、errorメッセージ
+func GetPagination(c *gin.Context) (int, int, error) {
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		return 0, 0, fmt.Errorf("page must be a positive integer")
+	}
+
+	pageSize, err := strconv.Atoi(c.DefaultQuery("page_size", strconv.Itoa(DefaultPageSize)))
+	if err != nil || pageSize < 1 || pageSize > MaxPageSize {
+		return 0, 0, fmt.Errorf("page_size must be an integer between 1 and %d", MaxPageSize)
+	}
+
+	return page, pageSize, nil
+}
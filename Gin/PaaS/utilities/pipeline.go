@@ -0,0 +1,113 @@
+package utilities
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"ham3/telemetry"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Pipelineを構成する1ステップ
+// Rollbackはステップ成功後に後続ステップが失敗した場合に呼ばれる（nilの場合は補償処理なし）
+type Step struct {
+	Name     string
+	Run      func(ctx context.Context) error
+	Rollback func(ctx context.Context) error
+}
+
+// Pipelineの実行に失敗したステップと補償処理の結果
+type StepError struct {
+	Step         string
+	Err          error
+	Completed    []string
+	RolledBack   []string
+	RollbackErrs []error
+}
+
+func (e *StepError) Error() string {
+	message := fmt.Sprintf("step %q failed: %v", e.Step, e.Err)
+	if len(e.RollbackErrs) > 0 {
+		var errs []string
+		for _, err := range e.RollbackErrs {
+			errs = append(errs, err.Error())
+		}
+		message = fmt.Sprintf("%s (rollback errors: %s)", message, strings.Join(errs, ", "))
+	}
+	return message
+}
+
+func (e *StepError) Unwrap() error {
+	return e.Err
+}
+
+// Pipelineの各ステップ（ロールバックを含む）の開始/終了を受け取る
+type StepObserver interface {
+	StepStarted(name string)
+	StepFinished(name string, err error)
+}
+
+// Stepを順番に実行し、失敗した場合は成功済みのステップを逆順に補償する
+type Pipeline struct {
+	// nilの場合はtelemetry.TracerNameのTracer
+	Tracer     trace.Tracer
+	Attributes []attribute.KeyValue
+	Steps      []Step
+	Observer   StepObserver
+
+	// 成功したステップ名（実行順）
+	Completed []string
+}
+
+func (p *Pipeline) Run(ctx context.Context) error {
+	p.Completed = nil
+	var done []Step
+
+	for _, step := range p.Steps {
+		if err := p.runStep(ctx, step.Name, step.Run); err != nil {
+			stepErr := &StepError{
+				Step:      step.Name,
+				Err:       err,
+				Completed: append([]string(nil), p.Completed...),
+			}
+
+			// 成功済みのステップを逆順にロールバック
+			for i := len(done) - 1; i >= 0; i-- {
+				if done[i].Rollback == nil {
+					continue
+				}
+				name := fmt.Sprintf("Rollback %s", done[i].Name)
+				if err := p.runStep(ctx, name, done[i].Rollback); err != nil {
+					stepErr.RollbackErrs = append(stepErr.RollbackErrs, fmt.Errorf("%s: %w", name, err))
+					continue
+				}
+				stepErr.RolledBack = append(stepErr.RolledBack, done[i].Name)
+			}
+			return stepErr
+		}
+		done = append(done, step)
+		p.Completed = append(p.Completed, step.Name)
+	}
+
+	return nil
+}
+
+func (p *Pipeline) runStep(ctx context.Context, name string, fn func(ctx context.Context) error) (err error) {
+	if p.Observer != nil {
+		p.Observer.StepStarted(name)
+		defer func() { p.Observer.StepFinished(name, err) }()
+	}
+	tracer := p.Tracer
+	if tracer == nil {
+		tracer = otel.Tracer(telemetry.TracerName)
+	}
+
+	ctx, span := tracer.Start(ctx, name, trace.WithAttributes(p.Attributes...))
+	defer func() { telemetry.End(span, err) }()
+
+	return fn(ctx)
+}
@@ -1,16 +1,214 @@
 package utilities
 
 import (
+	"fmt"
+	"ham3/api"
 	"ham3/config"
+	"regexp"
+	"sort"
+	"strings"
 )
 
-func CheckLogaasCreateParameters(requestData config.LogaasRequestData) (bool, string) {
-	var errExist bool
-	var errMessage string
-	if requestData.ClusterType != "scalable" || requestData.ClusterType != "standard" {
-		errExist = true
-		errMessage = "cluster-type must be either 'scalable' or 'standard'."
+// LOGaaS名はHelmのリリース名とk8sのリソース名に使うため、DNSラベルの形式のみ許可する
+// "<LOGaaS名>-dashboards"がHelmのリリース名の上限(53文字)を超えないように長さを制限する
+var logaasIdPattern = regexp.MustCompile(`^[a-z]([-a-z0-9]*[a-z0-9])?$`)
+
+const logaasIdMaxLength = 40
+
+// LOGaaSのパラメータをすべて検証し、エラーをまとめて返す（エラーがない場合は空）
+func CheckLogaasCreateParameters(logaas_id string, requestData api.LogaasRequestData, cfg config.LogaasConfig) []api.FieldError {
+	fieldErrors := []api.FieldError{}
+	addError := func(field string, format string, args ...interface{}) {
+		fieldErrors = append(fieldErrors, api.FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if len(logaas_id) > logaasIdMaxLength || !logaasIdPattern.MatchString(logaas_id) {
+		addError("logaas_id", "must be lowercase alphanumeric or '-', start with a letter and be at most %d characters", logaasIdMaxLength)
+	}
+
+	if requestData.ClusterType != "scalable" && requestData.ClusterType != "standard" {
+		addError("cluster-type", "must be either 'scalable' or 'standard'")
+	}
+
+	// OpenSearchのバージョンはPrometheus Exporterが存在するバージョンのみ
+	openSearchVersions := append(append([]string{}, config.Exporter["aparo_ver"].([]string)...), config.Exporter["aiven_ver"].([]string)...)
+	if !Contains(openSearchVersions, requestData.OpenSearchVersion) {
+		addError("opensearch-version", "must be one of %s", strings.Join(openSearchVersions, ", "))
+	}
+	dashboardsVersions := mapKeys(config.HelmChartVersions["dashboards"].(map[string]string))
+	if !Contains(dashboardsVersions, requestData.OpenSearchDashboardsVersion) {
+		addError("opensearch-dashboards-version", "must be one of %s", strings.Join(dashboardsVersions, ", "))
+	}
+
+	if requestData.ScaleSize < 1 || requestData.ScaleSize > config.LogaasMaxScaleSize {
+		addError("scale-size", "must be between 1 and %d", config.LogaasMaxScaleSize)
+	}
+
+	flavors := map[string]string{
+		"master-flavor": requestData.MasterFlavor,
+		"client-flavor": requestData.ClientFlavor,
+		"data-flavor":   requestData.DataFlavor,
+		"gui-flavor":    requestData.GuiFlavor,
+	}
+	for _, field := range []string{"master-flavor", "client-flavor", "data-flavor", "gui-flavor"} {
+		if _, ok := config.GetFlavor(flavors[field]); !ok {
+			addError(field, "unknown flavor %q (see GET /api/v1/flavors)", flavors[field])
+		}
+	}
+
+	if requestData.DataDiskSize < 1 || requestData.DataDiskSize > config.LogaasMaxDataDiskSize {
+		addError("data-disk-size", "must be between 1 and %d", config.LogaasMaxDataDiskSize)
+	}
+	if !Contains(cfg.DiskTypes, requestData.DiskType) {
+		addError("disk-type-ham3", "must be one of %s", strings.Join(cfg.DiskTypes, ", "))
+	}
+	if !Contains(cfg.Zones, requestData.Zone) {
+		addError("zone", "must be one of %s", strings.Join(cfg.Zones, ", "))
+	}
+
+	// 設定(defaults)のデフォルト値がない場合はリクエストで指定する必要がある
+	required := map[string]string{
+		"base-domain": requestData.BaseDomain,
+		"k8s-name":    requestData.K8sName,
+		"site":        requestData.Site,
+		"ocp-cluster": requestData.OcpCluster,
+	}
+	for _, field := range []string{"base-domain", "k8s-name", "site", "ocp-cluster"} {
+		if required[field] == "" {
+			addError(field, "is required")
+		}
+	}
+
+	return fieldErrors
+}
+
+func mapKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// AAPaaSのパラメータをすべて検証し、エラーをまとめて返す（エラーがない場合は空）
+// AAPaaS名はNamespace名(aapaas-<AAPaaS名>)とHelmのリリース名に使う
+func CheckAapaasCreateParameters(aapaas_id string, requestData api.AapaasRequestData) []api.FieldError {
+	fieldErrors := []api.FieldError{}
+	if len(aapaas_id) > logaasIdMaxLength || !logaasIdPattern.MatchString(aapaas_id) {
+		fieldErrors = append(fieldErrors, api.FieldError{Field: "aapaas_id", Message: fmt.Sprintf("must be lowercase alphanumeric or '-', start with a letter and be at most %d characters", logaasIdMaxLength)})
+	}
+	if requestData.AdminUser == "" {
+		fieldErrors = append(fieldErrors, api.FieldError{Field: "admin-user", Message: "is required"})
+	}
+	if requestData.BaseDomain == "" {
+		fieldErrors = append(fieldErrors, api.FieldError{Field: "base-domain", Message: "is required"})
+	}
+	return fieldErrors
+}
+
+// スナップショット名とISMポリシーIDはOpenSearchのURLのパスに使うため、小文字英数字と'-'、'_'、'.'のみ許可する
+var snapshotNamePattern = regexp.MustCompile(`^[a-z0-9][-_.a-z0-9]*$`)
+
+const snapshotNameMaxLength = 128
+
+// OpenSearchの時間の単位を含む期間（例: 30d、12h）
+var openSearchDurationPattern = regexp.MustCompile(`^[1-9][0-9]*(d|h|m)$`)
+
+func CheckSnapshotName(field string, name string) []api.FieldError {
+	if len(name) > snapshotNameMaxLength || !snapshotNamePattern.MatchString(name) {
+		return []api.FieldError{{Field: field, Message: fmt.Sprintf("must be lowercase alphanumeric, '-', '_' or '.' and be at most %d characters", snapshotNameMaxLength)}}
+	}
+	return nil
+}
+
+// インデックス名のパターンはOpenSearchのURLのパスとカンマ区切りのリストに使うため、小文字英数字と'-'、'_'、'*'のみ許可する
+// '.'で始まるシステムインデックスと'-'で始まる除外の指定は受け付けない
+var indexPatternPattern = regexp.MustCompile(`^[a-z0-9_*][-_a-z0-9*]*$`)
+
+const indexPatternMaxLength = 255
+
+func CheckIndexPatterns(field string, patterns []string) []api.FieldError {
+	fieldErrors := []api.FieldError{}
+	for _, pattern := range patterns {
+		if len(pattern) > indexPatternMaxLength || !indexPatternPattern.MatchString(pattern) {
+			fieldErrors = append(fieldErrors, api.FieldError{Field: field, Message: fmt.Sprintf("invalid index pattern %q: must be lowercase alphanumeric, '-', '_' or '*' and be at most %d characters", pattern, indexPatternMaxLength)})
+		}
+	}
+	return fieldErrors
+}
+
+// スナップショットのリポジトリ(S3)のパラメータを検証する
+// 認証情報を省略した場合はOpenSearchのキーストアの認証情報を使う
+func CheckSnapshotRepositoryParameters(requestData api.SnapshotRepositoryRequestData) []api.FieldError {
+	fieldErrors := []api.FieldError{}
+	if requestData.Bucket == "" {
+		fieldErrors = append(fieldErrors, api.FieldError{Field: "bucket", Message: "is required"})
+	}
+	if (requestData.AccessKey == "") != (requestData.SecretKey == "") {
+		fieldErrors = append(fieldErrors, api.FieldError{Field: "secret-key", Message: "access-key and secret-key must be specified together"})
 	}
+	if requestData.Endpoint != "" && strings.Contains(requestData.Endpoint, "/") && !strings.HasPrefix(requestData.Endpoint, "http://") && !strings.HasPrefix(requestData.Endpoint, "https://") {
+		fieldErrors = append(fieldErrors, api.FieldError{Field: "endpoint", Message: "must be a host name or an http(s) URL"})
+	}
+	return fieldErrors
+}
 
-	return errExist, errMessage
+// リストアのパラメータを検証する
+func CheckSnapshotRestoreParameters(requestData api.SnapshotRestoreRequestData) []api.FieldError {
+	fieldErrors := []api.FieldError{}
+	if requestData.TargetLogaas != "" && (len(requestData.TargetLogaas) > logaasIdMaxLength || !logaasIdPattern.MatchString(requestData.TargetLogaas)) {
+		fieldErrors = append(fieldErrors, api.FieldError{Field: "target-logaas", Message: "must be a LOGaaS name"})
+	}
+	if requestData.RenamePattern != "" {
+		if _, err := regexp.Compile(requestData.RenamePattern); err != nil {
+			fieldErrors = append(fieldErrors, api.FieldError{Field: "rename-pattern", Message: fmt.Sprintf("must be a valid regular expression: %v", err)})
+		}
+	}
+	fieldErrors = append(fieldErrors, CheckIndexPatterns("indices", requestData.Indices)...)
+	if (requestData.RenamePattern == "") != (requestData.RenameReplacement == "") {
+		fieldErrors = append(fieldErrors, api.FieldError{Field: "rename-replacement", Message: "rename-pattern and rename-replacement must be specified together"})
+	}
+	return fieldErrors
+}
+
+// 定期的なスナップショットのポリシー（取得のスケジュールと保持期間）を検証する
+func CheckSnapshotPolicyParameters(requestData api.SnapshotPolicyRequestData) []api.FieldError {
+	fieldErrors := []api.FieldError{}
+	if len(strings.Fields(requestData.Schedule)) != 5 {
+		fieldErrors = append(fieldErrors, api.FieldError{Field: "schedule", Message: "must be a cron expression with 5 fields (e.g. \"0 1 * * *\")"})
+	}
+	if requestData.MaxAge == "" && requestData.MaxCount == 0 {
+		fieldErrors = append(fieldErrors, api.FieldError{Field: "max-age", Message: "max-age or max-count is required"})
+	}
+	if requestData.MaxAge != "" && !openSearchDurationPattern.MatchString(requestData.MaxAge) {
+		fieldErrors = append(fieldErrors, api.FieldError{Field: "max-age", Message: "must be a duration such as 30d, 12h or 90m"})
+	}
+	if requestData.MaxCount < 0 {
+		fieldErrors = append(fieldErrors, api.FieldError{Field: "max-count", Message: "must not be negative"})
+	}
+	if requestData.MinCount < 0 || (requestData.MaxCount > 0 && requestData.MinCount > requestData.MaxCount) {
+		fieldErrors = append(fieldErrors, api.FieldError{Field: "min-count", Message: "must be between 0 and max-count"})
+	}
+	fieldErrors = append(fieldErrors, CheckIndexPatterns("indices", requestData.Indices)...)
+	return fieldErrors
+}
+
+// インデックスの保持期間(ISM)のポリシーを検証する
+func CheckIsmPolicyParameters(policy_id string, requestData api.IsmPolicyRequestData) []api.FieldError {
+	fieldErrors := CheckSnapshotName("policy_id", policy_id)
+	if len(requestData.IndexPatterns) == 0 {
+		fieldErrors = append(fieldErrors, api.FieldError{Field: "index-patterns", Message: "is required"})
+	}
+	fieldErrors = append(fieldErrors, CheckIndexPatterns("index-patterns", requestData.IndexPatterns)...)
+	for _, pattern := range requestData.IndexPatterns {
+		// すべてのインデックスを削除するポリシーは受け付けない
+		if strings.Trim(pattern, "*") == "" {
+			fieldErrors = append(fieldErrors, api.FieldError{Field: "index-patterns", Message: fmt.Sprintf("invalid index pattern %q: must not match all indices", pattern)})
+		}
+	}
+	if !openSearchDurationPattern.MatchString(requestData.DeleteAfter) {
+		fieldErrors = append(fieldErrors, api.FieldError{Field: "delete-after", Message: "must be a duration such as 30d, 12h or 90m"})
+	}
+	return fieldErrors
 }
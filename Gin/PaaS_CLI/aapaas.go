@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+
+	"ham3/api"
+
+	"github.com/fatih/color"
+	"github.com/urfave/cli/v2"
+)
+
+func CreateAAPaaS(c *cli.Context) error {
+	name := c.String("name")
+	ham3 := NewClient()
+
+	s := Spinner("Creating AAPaaS..")
+	s.Start()
+	accepted, err := ham3.CreateAapaas(c.Context, name, api.AapaasRequestData{})
+	_, err = RunJob(c.Context, ham3, s, accepted, err)
+	s.Stop()
+	if err != nil {
+		fmt.Println("Error:", err)
+		return err
+	}
+
+	fmt.Println(color.New(color.FgGreen).Sprintf("%s AAPaaS created successfully", name))
+	return nil
+}
+
+func GetAAPaaS(c *cli.Context) error {
+	name := c.String("name")
+	ham3 := NewClient()
+
+	s := Spinner("Getting info about AAPaaS..")
+	s.Start()
+	aapaas, err := ham3.GetAapaas(c.Context, name)
+	s.Stop()
+	if err != nil {
+		fmt.Println("Error:", err)
+		return err
+	}
+	if err := PrintJSON(aapaas); err != nil {
+		return err
+	}
+
+	fmt.Println(color.New(color.FgGreen).Sprint("AAPaaS retrieved successfully"))
+	return nil
+}
+
+func DeleteAAPaaS(c *cli.Context) error {
+	name := c.String("name")
+	ham3 := NewClient()
+
+	s := Spinner("Deleting AAPaaS..")
+	s.Start()
+	accepted, err := ham3.DeleteAapaas(c.Context, name)
+	_, err = RunJob(c.Context, ham3, s, accepted, err)
+	s.Stop()
+	if err != nil {
+		fmt.Println("Error:", err)
+		return err
+	}
+
+	fmt.Println(color.New(color.FgGreen).Sprintf("%s AAPaaS deleted successfully", name))
+	return nil
+}
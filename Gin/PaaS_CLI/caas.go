@@ -2,36 +2,26 @@ package main
 
 import (
 	"fmt"
-	"io/ioutil"
-	"net/http"
+
+	"ham3/api"
 
 	"github.com/fatih/color"
 	"github.com/urfave/cli/v2"
 )
 
-const (
-	CaasEndpoint = "http://localhost:8081/api/v1/caas"
-)
-
 func CreateCaaS(c *cli.Context) error {
 	tenant := c.String("tenant-id")
+	ham3 := NewClient()
 
 	s := Spinner("Creating CaaS cluster..")
 	s.Start()
-	resp, err := http.Post(fmt.Sprintf("%s/%s", CaasEndpoint, tenant), "application/json", nil)
+	accepted, err := ham3.CreateCaas(c.Context, tenant, api.CaasRequestData{})
+	_, err = RunJob(c.Context, ham3, s, accepted, err)
+	s.Stop()
 	if err != nil {
 		fmt.Println("Error:", err)
 		return err
 	}
-	defer resp.Body.Close()
-
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		fmt.Println("Error reading response body:", err)
-		return err
-	}
-	s.Stop()
-	fmt.Println("POST Response:", string(body))
 
 	fmt.Println(color.New(color.FgGreen).Sprint("CaaS cluster created successfully"))
 	return nil
@@ -39,23 +29,19 @@ func CreateCaaS(c *cli.Context) error {
 
 func GetCaaS(c *cli.Context) error {
 	tenant := c.String("tenant-id")
+	ham3 := NewClient()
 
 	s := Spinner("Getting info about CaaS cluster..")
 	s.Start()
-	resp, err := http.Get(fmt.Sprintf("%s/%s", CaasEndpoint, tenant))
+	caas, err := ham3.GetCaas(c.Context, tenant)
+	s.Stop()
 	if err != nil {
 		fmt.Println("Error:", err)
 		return err
 	}
-	defer resp.Body.Close()
-
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		fmt.Println("Error reading response body:", err)
+	if err := PrintJSON(caas); err != nil {
 		return err
 	}
-	s.Stop()
-	fmt.Println("GET Response:", string(body))
 
 	fmt.Println(color.New(color.FgGreen).Sprint("CaaS cluster retrieved successfully"))
 	return nil
@@ -63,23 +49,17 @@ func GetCaaS(c *cli.Context) error {
 
 func DeleteCaaS(c *cli.Context) error {
 	tenant := c.String("tenant-id")
+	ham3 := NewClient()
 
 	s := Spinner("Deleting CaaS cluster..")
 	s.Start()
-	req, err := http.NewRequest("DELETE", fmt.Sprintf("%s/%s", CaasEndpoint, tenant), nil)
-	if err != nil {
-		fmt.Println("Error creating DELETE request:", err)
-		return err
-	}
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	accepted, err := ham3.DeleteCaas(c.Context, tenant)
+	_, err = RunJob(c.Context, ham3, s, accepted, err)
+	s.Stop()
 	if err != nil {
 		fmt.Println("Error:", err)
 		return err
 	}
-	defer resp.Body.Close()
-	s.Stop()
-	fmt.Println("DELETE Response:", resp.Status)
 
 	fmt.Println(color.New(color.FgGreen).Sprint("CaaS cluster deleted successfully"))
 	return nil
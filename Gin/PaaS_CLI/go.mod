@@ -2,16 +2,21 @@ module ham3_cli
 
 go 1.22.3
 
-require github.com/urfave/cli/v2 v2.27.2
+require (
+	github.com/briandowns/spinner v1.23.0
+	github.com/fatih/color v1.17.0
+	github.com/urfave/cli/v2 v2.27.2
+	ham3 v0.0.0-00010101000000-000000000000
+)
 
 require (
-	github.com/briandowns/spinner v1.23.0 // indirect
 	github.com/cpuguy83/go-md2man/v2 v2.0.4 // indirect
-	github.com/fatih/color v1.17.0 // indirect
 	github.com/mattn/go-colorable v0.1.13 // indirect
 	github.com/mattn/go-isatty v0.0.20 // indirect
 	github.com/russross/blackfriday/v2 v2.1.0 // indirect
 	github.com/xrash/smetrics v0.0.0-20240312152122-5f08fbb34913 // indirect
-	golang.org/x/sys v0.18.0 // indirect
-	golang.org/x/term v0.1.0 // indirect
+	golang.org/x/sys v0.20.0 // indirect
+	golang.org/x/term v0.20.0 // indirect
 )
+
+replace ham3 => ../PaaS
@@ -94,6 +94,48 @@ func main() {
 					},
 				},
 			},
+			{
+				Name:  "aapaas",
+				Usage: "Ansible Automation Platform as a Service",
+				Subcommands: []*cli.Command{
+					{
+						Name:   "create",
+						Usage:  "Create an AAPaaS",
+						Action: CreateAAPaaS,
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:     "name",
+								Usage:    "Name of the AAPaaS",
+								Required: true,
+							},
+						},
+					},
+					{
+						Name:   "get",
+						Usage:  "Get info about an AAPaaS",
+						Action: GetAAPaaS,
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:     "name",
+								Usage:    "Name of the AAPaaS",
+								Required: true,
+							},
+						},
+					},
+					{
+						Name:   "delete",
+						Usage:  "Delete an AAPaaS",
+						Action: DeleteAAPaaS,
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:     "name",
+								Usage:    "Name of the AAPaaS",
+								Required: true,
+							},
+						},
+					},
+				},
+			},
 		},
 	}
 
@@ -1,12 +1,22 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
 	"time"
 
+	"ham3/api"
+	"ham3/client"
+
 	"github.com/briandowns/spinner"
 	"github.com/fatih/color"
 )
 
+// HAM3のURL（HAM3_URL環境変数で変更可能）
+const DefaultURL = "http://localhost:8081"
+
 func Spinner(message string) *spinner.Spinner {
 	s := spinner.New(spinner.CharSets[11], 200*time.Millisecond)
 	s.Prefix = color.New(color.FgGreen).Sprint(message)
@@ -14,3 +24,34 @@ func Spinner(message string) *spinner.Spinner {
 
 	return s
 }
+
+// HAM3 APIのクライアントを作成する（TokenはOS_TOKEN環境変数から取得）
+func NewClient() *client.Client {
+	url := os.Getenv("HAM3_URL")
+	if url == "" {
+		url = DefaultURL
+	}
+	return client.New(url, os.Getenv("OS_TOKEN"))
+}
+
+// ジョブとして受け付けられた場合はジョブが終了するまで待機し、実行中のステップをスピナーに表示する
+func RunJob(ctx context.Context, ham3 *client.Client, s *spinner.Spinner, accepted *api.AcceptedResponse, err error) (*api.Job, error) {
+	if err != nil {
+		return nil, err
+	}
+	return ham3.WaitJob(ctx, accepted.JobId, func(job *api.Job) {
+		if n := len(job.Steps); n > 0 {
+			s.Suffix = fmt.Sprintf(" %s", job.Steps[n-1].Name)
+		}
+	})
+}
+
+// レスポンスをJSONで表示する
+func PrintJSON(v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(data))
+	return nil
+}
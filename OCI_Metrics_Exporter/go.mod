@@ -2,6 +2,12 @@ module oci_monitoring
 
 go 1.20
 
+require (
+	github.com/oracle/oci-go-sdk/v65 v65.39.0
+	github.com/prometheus/client_golang v1.15.1
+	github.com/urfave/cli/v2 v2.25.7
+)
+
 require (
 	github.com/beorn7/perks v1.0.1 // indirect
 	github.com/cespare/xxhash/v2 v2.2.0 // indirect
@@ -9,14 +15,11 @@ require (
 	github.com/gofrs/flock v0.8.1 // indirect
 	github.com/golang/protobuf v1.5.3 // indirect
 	github.com/matttproud/golang_protobuf_extensions v1.0.4 // indirect
-	github.com/oracle/oci-go-sdk/v65 v65.39.0 // indirect
-	github.com/prometheus/client_golang v1.15.1 // indirect
 	github.com/prometheus/client_model v0.3.0 // indirect
 	github.com/prometheus/common v0.42.0 // indirect
 	github.com/prometheus/procfs v0.9.0 // indirect
 	github.com/russross/blackfriday/v2 v2.1.0 // indirect
 	github.com/sony/gobreaker v0.5.0 // indirect
-	github.com/urfave/cli/v2 v2.25.7 // indirect
 	github.com/urfave/cli/v3 v3.0.0-alpha4 // indirect
 	github.com/xrash/smetrics v0.0.0-20201216005158-039620a65673 // indirect
 	golang.org/x/sys v0.6.0 // indirect